@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	domain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+)
+
+// Registration modes
+const (
+	RegistrationModeOpen   = "open"
+	RegistrationModeInvite = "invite"
+)
+
+// RegistrationConfig controls who may create an account. The first account
+// can always be created so a fresh deployment gets its admin.
+type RegistrationConfig struct {
+	Enabled bool
+	Mode    string // RegistrationModeOpen or RegistrationModeInvite
+	// Invites stores invite codes; required in invite mode
+	Invites InviteRepository
+}
+
+// InviteRepository stores single-use registration codes
+type InviteRepository interface {
+	Create(invite *domain.Invite) error
+	List() ([]domain.Invite, error)
+	// Consume marks an unused code as used by userID, returning
+	// user.ErrInvalidInvite if it is unknown or already used
+	Consume(code, userID string) error
+	// Release makes a consumed code usable again after a failed registration
+	Release(code string) error
+}
+
+// inviteCodeBytes is the random length of an invite code before encoding
+const inviteCodeBytes = 12
+
+func (s *service) RegistrationOpen() bool {
+	return s.registration.Enabled && s.registration.Mode != RegistrationModeInvite
+}
+
+// checkRegistration decides whether a registration may go ahead and reports
+// whether it needs an invite consumed
+func (s *service) checkRegistration(req domain.RegisterRequest) (bool, error) {
+	if s.RegistrationOpen() {
+		return false, nil
+	}
+	if count, err := s.userRepo.Count(); err == nil && count == 0 {
+		return false, nil
+	}
+	if !s.registration.Enabled {
+		return false, user.ErrRegistrationClosed
+	}
+	if req.InviteCode == "" {
+		return false, user.ErrInviteRequired
+	}
+	return true, nil
+}
+
+func (s *service) CreateInvite(createdBy string) (*domain.Invite, error) {
+	b := make([]byte, inviteCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	invite := &domain.Invite{
+		Code:      hex.EncodeToString(b),
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if err := s.registration.Invites.Create(invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+func (s *service) ListInvites() ([]domain.Invite, error) {
+	return s.registration.Invites.List()
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/user"
+)
+
+func TestParseJWT(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now().Unix()
+	valid := jwtClaims{ID: "j1", Subject: "u1", Role: user.RoleUser, IssuedAt: now, ExpiresAt: now + 3600}
+	sign := func(c jwtClaims) string {
+		token, err := signJWT(secret, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	// withPayload swaps the payload of a valid token, keeping its signature
+	withPayload := func(c jwtClaims) string {
+		parts := strings.Split(sign(valid), ".")
+		payload, _ := json.Marshal(c)
+		parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+		return strings.Join(parts, ".")
+	}
+	admin := valid
+	admin.Role = user.RoleAdmin
+	expired := valid
+	expired.ExpiresAt = now - 1
+	anonymous := valid
+	anonymous.Subject = ""
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + strings.Split(sign(valid), ".")[1] + "."
+	otherKey, _ := signJWT([]byte("other"), valid)
+	badSignature := sign(valid)
+	if strings.HasSuffix(badSignature, "A") {
+		badSignature = badSignature[:len(badSignature)-1] + "B"
+	} else {
+		badSignature = badSignature[:len(badSignature)-1] + "A"
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		wantOK bool
+	}{
+		{"valid", sign(valid), true},
+		{"expired", sign(expired), false},
+		{"tampered payload", withPayload(admin), false},
+		{"tampered signature", badSignature, false},
+		{"other secret", otherKey, false},
+		{"alg none", unsigned, false},
+		{"no subject", sign(anonymous), false},
+		{"not a JWT", "abc.def", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parseJWT(secret, tt.token)
+			if (err == nil) != tt.wantOK {
+				t.Fatalf("got %+v, %v; want ok = %v", claims, err, tt.wantOK)
+			}
+			if tt.wantOK && (claims.Subject != "u1" || claims.Role != user.RoleUser) {
+				t.Fatalf("got %+v", claims)
+			}
+		})
+	}
+}
+
+// memRevocations is an in-memory denylist
+type memRevocations map[string]time.Time
+
+func (m memRevocations) Revoke(jti string, expiresAt time.Time) error {
+	m[jti] = expiresAt
+	return nil
+}
+
+func (m memRevocations) IsRevoked(jti string) (bool, error) {
+	_, ok := m[jti]
+	return ok, nil
+}
+
+func TestJWTLogout(t *testing.T) {
+	tests := []struct {
+		name        string
+		revocations RevocationRepository
+		validAfter  bool
+	}{
+		{"with denylist", memRevocations{}, false},
+		{"without denylist", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(nil, nil, time.Hour, nil, TokenConfig{Mode: TokenModeJWT, JWTSecret: "secret", Revocations: tt.revocations}, LockoutConfig{}, RegistrationConfig{})
+			session, err := svc.IssueSession(&user.User{ID: "u1", Role: user.RoleAdmin, Email: "a@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := svc.ValidateToken(session.Token)
+			if err != nil || u.ID != "u1" || u.Role != user.RoleAdmin {
+				t.Fatalf("got %+v, %v before logout", u, err)
+			}
+			if err := svc.Logout(session.Token); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := svc.ValidateToken(session.Token); (err == nil) != tt.validAfter {
+				t.Fatalf("after logout: got %v, want valid = %v", err, tt.validAfter)
+			}
+		})
+	}
+}
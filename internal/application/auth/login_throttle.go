@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gomanager/internal/domain/user"
+)
+
+// LockedError is returned by login while an account is locked out. It
+// matches user.ErrAccountLocked with errors.Is.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%v; retry in %ds", user.ErrAccountLocked, e.Seconds())
+}
+
+func (e *LockedError) Unwrap() error {
+	return user.ErrAccountLocked
+}
+
+// Seconds is the remaining lockout rounded up to whole seconds
+func (e *LockedError) Seconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// throttleSweepThreshold is the number of tracked logins after which stale
+// entries are pruned, so probing many emails can't grow the maps forever
+const throttleSweepThreshold = 10000
+
+// knownClientTTL is how long a client IP that signed in to an account stays
+// exempt from that account's lockout
+const knownClientTTL = 30 * 24 * time.Hour
+
+// LockoutConfig controls per-account lockout after failed logins
+type LockoutConfig struct {
+	MaxFailures int           // Consecutive failures before locking (0 disables)
+	BaseDelay   time.Duration // First lockout; doubles with each further failure
+	MaxDelay    time.Duration // Upper bound for the lockout
+}
+
+// loginThrottle tracks consecutive failed logins per email, wherever they
+// come from, so rotating source IPs doesn't get around the lockout. Client
+// IPs that recently signed in to an account are exempt from its lockout, so
+// guessing from elsewhere can't lock the owner out. Unknown emails are
+// tracked the same way so lockouts don't reveal which accounts exist.
+type loginThrottle struct {
+	cfg LockoutConfig
+
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts // By email
+	known    map[string]time.Time      // Last successful login, by email and IP
+}
+
+type loginAttempts struct {
+	failures    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// newLoginThrottle returns nil when lockout is disabled; a nil throttle is a no-op
+func newLoginThrottle(cfg LockoutConfig) *loginThrottle {
+	if cfg.MaxFailures <= 0 {
+		return nil
+	}
+	return &loginThrottle{cfg: cfg, attempts: make(map[string]*loginAttempts), known: make(map[string]time.Time)}
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func clientKey(email, ip string) string {
+	return accountKey(email) + "\x00" + ip
+}
+
+// exempt reports whether ip signed in to email recently; callers hold mu
+func (t *loginThrottle) exempt(email, ip string, now time.Time) bool {
+	if ip == "" {
+		return false
+	}
+	last, ok := t.known[clientKey(email, ip)]
+	return ok && now.Sub(last) < knownClientTTL
+}
+
+// locked returns how long email remains locked for ip, or zero
+func (t *loginThrottle) locked(email, ip string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.exempt(email, ip, now) {
+		return 0
+	}
+	if a, ok := t.attempts[accountKey(email)]; ok {
+		if remaining := a.lockedUntil.Sub(now); remaining > 0 {
+			return remaining
+		}
+	}
+	return 0
+}
+
+// fail records a failed login for email and returns the lockout it
+// triggered for ip, if any
+func (t *loginThrottle) fail(email, ip string) time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	key := accountKey(email)
+	a, ok := t.attempts[key]
+	if !ok {
+		if len(t.attempts) >= throttleSweepThreshold {
+			t.sweep(now)
+		}
+		a = &loginAttempts{}
+		t.attempts[key] = a
+	}
+	// Old failures are forgotten once a full max-length lockout has passed
+	if now.Sub(a.lastFailure) > t.cfg.MaxDelay {
+		a.failures = 0
+	}
+	a.failures++
+	a.lastFailure = now
+
+	if a.failures < t.cfg.MaxFailures {
+		return 0
+	}
+	delay := t.cfg.BaseDelay << min(a.failures-t.cfg.MaxFailures, 16)
+	if delay > t.cfg.MaxDelay || delay <= 0 {
+		delay = t.cfg.MaxDelay
+	}
+	a.lockedUntil = now.Add(delay)
+	if t.exempt(email, ip, now) {
+		return 0
+	}
+	return delay
+}
+
+// reset clears the failures for email after a successful login from ip,
+// and exempts ip from the account's lockouts from now on
+func (t *loginThrottle) reset(email, ip string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	delete(t.attempts, accountKey(email))
+	if ip == "" {
+		return
+	}
+	if len(t.known) >= throttleSweepThreshold {
+		t.sweep(now)
+	}
+	t.known[clientKey(email, ip)] = now
+}
+
+// sweep drops entries whose failures and lockout have both expired, and
+// clients that haven't signed in for a while
+func (t *loginThrottle) sweep(now time.Time) {
+	for key, a := range t.attempts {
+		if now.Sub(a.lastFailure) > t.cfg.MaxDelay && now.After(a.lockedUntil) {
+			delete(t.attempts, key)
+		}
+	}
+	for key, last := range t.known {
+		if now.Sub(last) >= knownClientTTL {
+			delete(t.known, key)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	steps := []struct {
+		name   string
+		action string // fail, reset or check
+		email  string
+		ip     string
+		locked bool // Whether email is locked for ip afterwards
+	}{
+		{"owner signs in", "reset", "a@example.com", "10.0.0.1", false},
+		{"first failure", "fail", "a@example.com", "10.0.0.2", false},
+		{"second failure from another IP locks", "fail", "A@example.com ", "10.0.0.3", true},
+		{"guesses from a fresh IP are locked", "check", "a@example.com", "10.0.0.4", true},
+		{"the owner's IP isn't locked", "check", "a@example.com", "10.0.0.1", false},
+		{"other account isn't locked", "check", "b@example.com", "10.0.0.2", false},
+		{"unknown emails lock the same way", "fail", "nobody@example.com", "10.0.0.5", false},
+		{"unknown email locked", "fail", "nobody@example.com", "10.0.0.6", true},
+		{"success resets", "reset", "a@example.com", "10.0.0.1", false},
+		{"for every IP", "check", "a@example.com", "10.0.0.4", false},
+		{"counting starts over", "fail", "a@example.com", "10.0.0.2", false},
+	}
+	throttle := newLoginThrottle(LockoutConfig{MaxFailures: 2, BaseDelay: time.Minute, MaxDelay: time.Hour})
+	for _, s := range steps {
+		switch s.action {
+		case "fail":
+			throttle.fail(s.email, s.ip)
+		case "reset":
+			throttle.reset(s.email, s.ip)
+		}
+		if locked := throttle.locked(s.email, s.ip) > 0; locked != s.locked {
+			t.Fatalf("%s: locked = %v, want %v", s.name, locked, s.locked)
+		}
+	}
+}
+
+func TestLoginThrottleRotatingIPs(t *testing.T) {
+	throttle := newLoginThrottle(LockoutConfig{MaxFailures: 3, BaseDelay: time.Minute, MaxDelay: time.Hour})
+	throttle.reset("a@example.com", "192.0.2.1")
+
+	tests := []struct {
+		ip   string
+		want bool // Whether this failure locks its client out
+	}{
+		{"198.51.100.1", false},
+		{"198.51.100.2", false},
+		{"198.51.100.3", true},
+		{"198.51.100.4", true},
+		{"192.0.2.1", false}, // Known to the account, so it can keep trying
+	}
+	for _, tt := range tests {
+		if got := throttle.fail("a@example.com", tt.ip) > 0; got != tt.want {
+			t.Errorf("failure from %s locked = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	for _, ip := range []string{"198.51.100.1", "203.0.113.9", ""} {
+		if throttle.locked("a@example.com", ip) == 0 {
+			t.Errorf("%q isn't locked out", ip)
+		}
+	}
+	if throttle.locked("a@example.com", "192.0.2.1") != 0 {
+		t.Error("known client locked out")
+	}
+}
+
+func TestLoginThrottleKnownClientsExpire(t *testing.T) {
+	throttle := newLoginThrottle(LockoutConfig{MaxFailures: 1, BaseDelay: time.Minute, MaxDelay: time.Hour})
+	throttle.reset("a@example.com", "192.0.2.1")
+	throttle.known[clientKey("a@example.com", "192.0.2.1")] = time.Now().Add(-knownClientTTL)
+	throttle.fail("a@example.com", "198.51.100.1")
+	if throttle.locked("a@example.com", "192.0.2.1") == 0 {
+		t.Fatal("client that signed in long ago is still exempt")
+	}
+
+	throttle.sweep(time.Now())
+	if len(throttle.known) != 0 {
+		t.Fatalf("sweep kept %d stale clients", len(throttle.known))
+	}
+}
+
+func TestLoginThrottleBackoff(t *testing.T) {
+	throttle := newLoginThrottle(LockoutConfig{MaxFailures: 1, BaseDelay: time.Minute, MaxDelay: 3 * time.Minute})
+	for i, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		// A new address each time makes no difference
+		if got := throttle.fail("a@example.com", fmt.Sprintf("10.0.0.%d", i+1)); got != want {
+			t.Fatalf("lockout %v, want %v", got, want)
+		}
+	}
+	if newLoginThrottle(LockoutConfig{}).fail("a@example.com", "10.0.0.1") != 0 {
+		t.Fatal("disabled throttle locked an account")
+	}
+}
+
+// memUsers is an in-memory user repository holding a single user
+type memUsers struct {
+	user.Repository
+	u *user.User
+}
+
+func (m memUsers) GetByEmail(email string) (*user.User, error) {
+	if m.u == nil || m.u.Email != email {
+		return nil, user.ErrUserNotFound
+	}
+	return m.u, nil
+}
+
+func (m memUsers) Update(u *user.User) error { return nil }
+
+func TestLoginLockout(t *testing.T) {
+	hash, err := bcryptHasher{}.Hash("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := memUsers{u: &user.User{ID: "u1", Email: "a@example.com", Password: hash, AuthProvider: user.AuthProviderLocal}}
+	s := NewService(users, nil, time.Hour, bcryptHasher{}, TokenConfig{Mode: TokenModeJWT, JWTSecret: "0123456789abcdef0123456789abcdef"}, LockoutConfig{MaxFailures: 2, BaseDelay: time.Minute, MaxDelay: time.Hour}, RegistrationConfig{})
+
+	login := func(email, password, ip string) error {
+		_, err := s.Login(domain.LoginRequest{Email: email, Password: password, ClientIP: ip})
+		return err
+	}
+	if err := login("a@example.com", "secret1", "192.0.2.1"); err != nil {
+		t.Fatalf("owner login: %v", err)
+	}
+
+	steps := []struct {
+		name     string
+		email    string
+		password string
+		ip       string
+		want     error
+	}{
+		{"first guess", "a@example.com", "wrong", "198.51.100.1", user.ErrInvalidCredentials},
+		{"second guess locks", "a@example.com", "wrong", "198.51.100.2", user.ErrAccountLocked},
+		{"right password from a new IP is refused", "a@example.com", "secret1", "198.51.100.3", user.ErrAccountLocked},
+		{"owner's IP still signs in", "a@example.com", "secret1", "192.0.2.1", nil},
+		{"which resets the counter", "a@example.com", "wrong", "198.51.100.4", user.ErrInvalidCredentials},
+		{"unknown email", "ghost@example.com", "x", "198.51.100.1", user.ErrInvalidCredentials},
+		{"unknown email locks too", "ghost@example.com", "x", "198.51.100.2", user.ErrAccountLocked},
+	}
+	for _, st := range steps {
+		err := login(st.email, st.password, st.ip)
+		if !errors.Is(err, st.want) && !(st.want == nil && err == nil) {
+			t.Fatalf("%s: got %v, want %v", st.name, err, st.want)
+		}
+		var locked *LockedError
+		if errors.Is(err, user.ErrAccountLocked) && (!errors.As(err, &locked) || locked.Seconds() <= 0) {
+			t.Fatalf("%s: %v carries no retry time", st.name, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+)
+
+func TestCheckPasswordAcrossAlgorithms(t *testing.T) {
+	bcryptHash, err := bcryptHasher{}.Hash("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := argon2idHasher{}.Hash("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		algo     string
+		stored   string
+		password string
+		want     bool
+	}{
+		{"bcrypt hash under bcrypt", HashAlgoBcrypt, bcryptHash, "secret1", true},
+		{"bcrypt hash under argon2id", HashAlgoArgon2id, bcryptHash, "secret1", true},
+		{"argon2id hash under argon2id", HashAlgoArgon2id, argonHash, "secret1", true},
+		{"argon2id hash under bcrypt", HashAlgoBcrypt, argonHash, "secret1", true},
+		{"wrong password on bcrypt", HashAlgoArgon2id, bcryptHash, "secret2", false},
+		{"wrong password on argon2id", HashAlgoBcrypt, argonHash, "secret2", false},
+		{"unknown hash format", HashAlgoBcrypt, "plaintext", "plaintext", false},
+		{"truncated argon2id hash", HashAlgoArgon2id, argonHash[:len(argonHash)-44], "secret1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := NewPasswordHasher(tt.algo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewService(memUsers{}, nil, time.Hour, hasher, TokenConfig{}, LockoutConfig{}, RegistrationConfig{})
+			if got := s.CheckPassword(tt.stored, tt.password); got != tt.want {
+				t.Fatalf("CheckPassword = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasher(t *testing.T) {
+	tests := []struct {
+		algo    string
+		wantErr bool
+	}{
+		{"", false},
+		{"bcrypt", false},
+		{"ARGON2ID", false},
+		{"md5", true},
+	}
+	for _, tt := range tests {
+		if _, err := NewPasswordHasher(tt.algo); (err != nil) != tt.wantErr {
+			t.Errorf("NewPasswordHasher(%q) error %v, want error %v", tt.algo, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	bcryptHash, err := bcryptHasher{}.Hash("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := argon2idHasher{}.Hash("secret1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		algo      string
+		stored    string
+		password  string
+		wantOwner PasswordHasher
+		wantSame  bool // Whether the stored hash is left untouched
+	}{
+		{"bcrypt upgraded to argon2id", HashAlgoArgon2id, bcryptHash, "secret1", argon2idHasher{}, false},
+		{"argon2id moved back to bcrypt", HashAlgoBcrypt, argonHash, "secret1", bcryptHasher{}, false},
+		{"already on the configured algorithm", HashAlgoArgon2id, argonHash, "secret1", argon2idHasher{}, true},
+		{"failed login keeps the old hash", HashAlgoArgon2id, bcryptHash, "wrong", bcryptHasher{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := NewPasswordHasher(tt.algo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u := &user.User{ID: "u1", Email: "a@example.com", Password: tt.stored, AuthProvider: user.AuthProviderLocal}
+			s := NewService(memUsers{u: u}, nil, time.Hour, hasher, TokenConfig{Mode: TokenModeJWT, JWTSecret: "0123456789abcdef0123456789abcdef"}, LockoutConfig{}, RegistrationConfig{})
+			s.Login(domain.LoginRequest{Email: u.Email, Password: tt.password})
+
+			if !tt.wantOwner.Owns(u.Password) {
+				t.Fatalf("stored hash %q not produced by %T", u.Password, tt.wantOwner)
+			}
+			if (u.Password == tt.stored) != tt.wantSame {
+				t.Fatalf("hash changed %v, want %v", u.Password != tt.stored, !tt.wantSame)
+			}
+			if !s.CheckPassword(u.Password, "secret1") {
+				t.Fatal("the stored hash no longer verifies")
+			}
+		})
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"regexp"
+	"sync"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 
 	domain "gomanager/internal/domain/auth"
 	"gomanager/internal/domain/user"
@@ -18,17 +19,44 @@ type Service interface {
 	Login(req domain.LoginRequest) (*domain.LoginResponse, error)
 	LoginWithUser(req domain.LoginRequest) (*domain.LoginResponse, *user.User, error)
 	ValidateToken(token string) (*user.User, error)
+	// TokenStatus checks a token's remaining lifetime without loading its
+	// user; an invalid or expired token is a status, not an error
+	TokenStatus(token string) (*domain.TokenStatus, error)
 	Logout(token string) error
 	HashPassword(password string) (string, error)
 	CheckPassword(hashedPassword, password string) bool
-	CreateSession(session *domain.Session) error
-	GenerateToken() (string, error)
+	// IssueSession creates a login token for u according to the token mode,
+	// expiring after the configured token lifetime. Every login flow (local
+	// and OAuth) goes through it so session lifetimes can't drift.
+	IssueSession(u *user.User) (*domain.Session, error)
+	// LoadUser returns the stored record for a user from ValidateToken, which
+	// in JWT mode only carries the fields in the token
+	LoadUser(u *user.User) (*user.User, error)
+
+	// RegistrationOpen reports whether anyone may sign up without an invite
+	RegistrationOpen() bool
+	CreateInvite(createdBy string) (*domain.Invite, error)
+	ListInvites() ([]domain.Invite, error)
 }
 
 type service struct {
 	userRepo    user.Repository
 	sessionRepo SessionRepository
 	tokenExpiry time.Duration
+	hasher      PasswordHasher // Used for new hashes
+
+	// jwtSecret is set in JWT mode; revocations optionally backs logout there
+	jwtSecret   []byte
+	revocations RevocationRepository
+
+	throttle *loginThrottle
+
+	registration RegistrationConfig
+
+	// dummyHash is verified against for unknown emails so they take as long
+	// as real accounts
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 // SessionRepository defines the session storage interface
@@ -40,15 +68,28 @@ type SessionRepository interface {
 }
 
 // NewService creates a new auth service
-func NewService(userRepo user.Repository, sessionRepo SessionRepository, tokenExpiry time.Duration) Service {
-	return &service{
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
-		tokenExpiry: tokenExpiry,
+func NewService(userRepo user.Repository, sessionRepo SessionRepository, tokenExpiry time.Duration, hasher PasswordHasher, tokens TokenConfig, lockout LockoutConfig, registration RegistrationConfig) Service {
+	s := &service{
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		tokenExpiry:  tokenExpiry,
+		hasher:       hasher,
+		throttle:     newLoginThrottle(lockout),
+		registration: registration,
 	}
+	if tokens.Mode == TokenModeJWT {
+		s.jwtSecret = []byte(tokens.JWTSecret)
+		s.revocations = tokens.Revocations
+	}
+	return s
 }
 
 func (s *service) Register(req domain.RegisterRequest) (*user.User, error) {
+	needsInvite, err := s.checkRegistration(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate email
 	if !isValidEmail(req.Email) {
 		return nil, user.ErrInvalidEmail
@@ -88,13 +129,25 @@ func (s *service) Register(req domain.RegisterRequest) (*user.User, error) {
 
 	// Create user
 	newUser := &user.User{
+		ID:       uuid.New().String(),
 		Email:    req.Email,
 		Username: req.Username,
 		Password: hashedPassword,
 		Role:     role,
 	}
 
+	// The invite is claimed first so two sign-ups can't share it, and handed
+	// back if the account can't be created
+	if needsInvite {
+		if err := s.registration.Invites.Consume(req.InviteCode, newUser.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := s.userRepo.Create(newUser); err != nil {
+		if needsInvite {
+			s.registration.Invites.Release(req.InviteCode)
+		}
 		return nil, err
 	}
 
@@ -107,42 +160,97 @@ func (s *service) Login(req domain.LoginRequest) (*domain.LoginResponse, error)
 }
 
 func (s *service) LoginWithUser(req domain.LoginRequest) (*domain.LoginResponse, *user.User, error) {
+	if wait := s.throttle.locked(req.Email, req.ClientIP); wait > 0 {
+		return nil, nil, &LockedError{RetryAfter: wait}
+	}
+
 	// Find user by email
 	u, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return nil, nil, user.ErrInvalidCredentials
+		s.verifyDummyPassword(req.Password)
+		return nil, nil, s.loginFailed(req)
 	}
 
 	// Check password (skip for Google users)
-	if u.AuthProvider == user.AuthProviderLocal && !s.CheckPassword(u.Password, req.Password) {
-		return nil, nil, user.ErrInvalidCredentials
+	if u.AuthProvider == user.AuthProviderLocal {
+		if !s.CheckPassword(u.Password, req.Password) {
+			return nil, nil, s.loginFailed(req)
+		}
+		s.upgradePasswordHash(u, req.Password)
 	}
+	s.throttle.reset(req.Email, req.ClientIP)
 
-	// Generate token
-	token, err := generateToken()
+	session, err := s.IssueSession(u)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Create session
-	expiresAt := time.Now().Add(s.tokenExpiry)
+	return &domain.LoginResponse{
+		Token:     session.Token,
+		ExpiresAt: session.ExpiresAt.Unix(),
+	}, u, nil
+}
+
+// loginFailed records a failed attempt and returns the error to report
+func (s *service) loginFailed(req domain.LoginRequest) error {
+	if delay := s.throttle.fail(req.Email, req.ClientIP); delay > 0 {
+		return &LockedError{RetryAfter: delay}
+	}
+	return user.ErrInvalidCredentials
+}
+
+// verifyDummyPassword spends the same effort as checking a real password
+func (s *service) verifyDummyPassword(password string) {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy-password-for-timing")
+	})
+	s.hasher.Verify(s.dummyHash, password)
+}
+
+func (s *service) IssueSession(u *user.User) (*domain.Session, error) {
+	now := time.Now()
 	session := &domain.Session{
+		ID:        uuid.New().String(),
 		UserID:    u.ID,
-		Token:     token,
-		ExpiresAt: expiresAt,
+		ExpiresAt: now.Add(s.tokenExpiry),
+		CreatedAt: now,
 	}
 
-	if err := s.sessionRepo.Create(session); err != nil {
-		return nil, nil, err
+	// JWTs are self-contained, so nothing is stored
+	if s.jwtSecret != nil {
+		token, err := signJWT(s.jwtSecret, jwtClaims{
+			ID:        session.ID,
+			Subject:   u.ID,
+			Role:      u.Role,
+			Email:     u.Email,
+			Username:  u.Username,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: session.ExpiresAt.Unix(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		session.Token = token
+		return session, nil
 	}
 
-	return &domain.LoginResponse{
-		Token:     token,
-		ExpiresAt: expiresAt.Unix(),
-	}, u, nil
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	session.Token = token
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
 func (s *service) ValidateToken(token string) (*user.User, error) {
+	// Opaque tokens issued before switching to JWT mode keep working
+	if s.jwtSecret != nil && isJWT(token) {
+		return s.validateJWT(token)
+	}
+
 	session, err := s.sessionRepo.GetByToken(token)
 	if err != nil {
 		return nil, user.ErrUnauthorized
@@ -156,26 +264,114 @@ func (s *service) ValidateToken(token string) (*user.User, error) {
 	return s.userRepo.GetByID(session.UserID)
 }
 
-func (s *service) Logout(token string) error {
-	return s.sessionRepo.Delete(token)
+func (s *service) TokenStatus(token string) (*domain.TokenStatus, error) {
+	var expiresAt time.Time
+	if s.jwtSecret != nil && isJWT(token) {
+		claims, err := parseJWT(s.jwtSecret, token)
+		if err != nil {
+			return &domain.TokenStatus{}, nil
+		}
+		if s.revocations != nil {
+			revoked, err := s.revocations.IsRevoked(claims.ID)
+			if err != nil {
+				return nil, err
+			}
+			if revoked {
+				return &domain.TokenStatus{}, nil
+			}
+		}
+		expiresAt = time.Unix(claims.ExpiresAt, 0)
+	} else {
+		session, err := s.sessionRepo.GetByToken(token)
+		if err != nil {
+			return &domain.TokenStatus{}, nil
+		}
+		expiresAt = session.ExpiresAt
+	}
+
+	remaining := time.Until(expiresAt)
+	if remaining <= 0 {
+		return &domain.TokenStatus{}, nil
+	}
+	return &domain.TokenStatus{
+		Valid:            true,
+		ExpiresAt:        expiresAt.Unix(),
+		SecondsRemaining: int64(remaining / time.Second),
+	}, nil
 }
 
-func (s *service) CreateSession(session *domain.Session) error {
-	return s.sessionRepo.Create(session)
+// validateJWT checks a signed token and builds the user from its claims
+func (s *service) validateJWT(token string) (*user.User, error) {
+	claims, err := parseJWT(s.jwtSecret, token)
+	if err != nil {
+		return nil, user.ErrUnauthorized
+	}
+	if s.revocations != nil {
+		revoked, err := s.revocations.IsRevoked(claims.ID)
+		if err != nil || revoked {
+			return nil, user.ErrUnauthorized
+		}
+	}
+
+	return &user.User{
+		ID:       claims.Subject,
+		Email:    claims.Email,
+		Username: claims.Username,
+		Role:     claims.Role,
+	}, nil
+}
+
+func (s *service) LoadUser(u *user.User) (*user.User, error) {
+	if s.jwtSecret == nil {
+		return u, nil
+	}
+	return s.userRepo.GetByID(u.ID)
 }
 
-func (s *service) GenerateToken() (string, error) {
-	return generateToken()
+func (s *service) Logout(token string) error {
+	if s.jwtSecret != nil && isJWT(token) {
+		claims, err := parseJWT(s.jwtSecret, token)
+		if err != nil {
+			return user.ErrUnauthorized
+		}
+		// Without a denylist the token stays valid until it expires
+		if s.revocations == nil {
+			return nil
+		}
+		return s.revocations.Revoke(claims.ID, time.Unix(claims.ExpiresAt, 0))
+	}
+	return s.sessionRepo.Delete(token)
 }
 
 func (s *service) HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	return s.hasher.Hash(password)
 }
 
+// CheckPassword verifies with whichever algorithm produced the stored hash
 func (s *service) CheckPassword(hashedPassword, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+	for _, h := range knownHashers {
+		if h.Owns(hashedPassword) {
+			return h.Verify(hashedPassword, password)
+		}
+	}
+	return false
+}
+
+// upgradePasswordHash re-hashes a verified password with the configured
+// algorithm. Failures are ignored; the old hash keeps working.
+func (s *service) upgradePasswordHash(u *user.User, password string) {
+	if s.hasher.Owns(u.Password) {
+		return
+	}
+	hashed, err := s.hasher.Hash(password)
+	if err != nil {
+		return
+	}
+	previous := u.Password
+	u.Password = hashed
+	if err := s.userRepo.Update(u); err != nil {
+		u.Password = previous
+	}
 }
 
 func generateToken() (string, error) {
@@ -0,0 +1,209 @@
+package file
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// Limits for reading zip files so archives can't be used to exhaust the server
+const (
+	archiveMaxEntries   = 10000   // Entries an archive may hold to be read at all
+	archiveMaxEntrySize = 1 << 30 // Uncompressed bytes of an entry that can be extracted
+)
+
+// ListArchive lists the entries of the zip file at p from its central
+// directory; nothing is decompressed
+func (s *service) ListArchive(p string) (*domain.ArchiveListing, error) {
+	f, zr, err := s.openArchive(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	listing := &domain.ArchiveListing{Path: cleanPath(p), Entries: make([]domain.ArchiveEntry, 0, len(zr.File))}
+	for _, zf := range zr.File {
+		entry := archiveEntry(zf)
+		listing.Entries = append(listing.Entries, entry)
+		listing.TotalSize += entry.Size
+	}
+	return listing, nil
+}
+
+// OpenArchiveEntry opens the file named name inside the zip file at p. Entries
+// declaring more than archiveMaxEntrySize bytes are refused, and zip itself
+// fails the read if an entry inflates past its declared size.
+func (s *service) OpenArchiveEntry(p, name string) (io.ReadCloser, *domain.ArchiveEntry, error) {
+	f, zr, err := s.openArchive(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, zf := range zr.File {
+		if zf.Name != name {
+			continue
+		}
+		if zf.FileInfo().IsDir() {
+			f.Close()
+			return nil, nil, domain.ErrIsDirectory
+		}
+		if zf.UncompressedSize64 > archiveMaxEntrySize {
+			f.Close()
+			return nil, nil, domain.ErrArchiveTooLarge
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		entry := archiveEntry(zf)
+		return &archiveEntryReader{ReadCloser: rc, archive: f}, &entry, nil
+	}
+	f.Close()
+	return nil, nil, domain.ErrEntryNotFound
+}
+
+// openArchive opens the zip file at p and reads its central directory. Only
+// .zip files are accepted.
+func (s *service) openArchive(p string) (io.Closer, *zip.Reader, error) {
+	if !strings.EqualFold(path.Ext(p), ".zip") {
+		return nil, nil, domain.ErrNotArchive
+	}
+	f, info, err := s.OpenFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		ra = &seekReaderAt{r: f}
+	}
+	// zip.NewReader loads the whole central directory, so an oversized one
+	// is refused from the count its end record declares
+	if count, ok := declaredEntries(ra, info.Size); ok && count > archiveMaxEntries {
+		f.Close()
+		return nil, nil, domain.ErrArchiveTooLarge
+	}
+	zr, err := zip.NewReader(ra, info.Size)
+	// Entry names are only listed and matched, never joined onto a path, so
+	// names zip flags as insecure ("../x", "/etc/x") are harmless here
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		f.Close()
+		if errors.Is(err, zip.ErrFormat) {
+			return nil, nil, domain.ErrNotArchive
+		}
+		return nil, nil, err
+	}
+	if len(zr.File) > archiveMaxEntries {
+		f.Close()
+		return nil, nil, domain.ErrArchiveTooLarge
+	}
+	return f, zr, nil
+}
+
+// Zip end records, as laid out in the format's APPNOTE
+const (
+	eocdSignature      = 0x06054b50
+	eocdLen            = 22
+	eocdEntriesAt      = 10     // Offset of the total entry count
+	eocdMaxComment     = 0xffff // The record ends with a comment this long at most
+	eocdZip64Marker    = 0xffff // Entry count meaning "see the zip64 record"
+	zip64LocatorSig    = 0x07064b50
+	zip64LocatorLen    = 20
+	zip64EOCDSignature = 0x06064b50
+	zip64EOCDLen       = 56
+	zip64EOCDEntriesAt = 32
+)
+
+// declaredEntries reads the entry count from the end of central directory
+// record of the zip file in r, following the zip64 record when the count
+// overflows. ok is false when no end record is found; zip.NewReader then
+// reports the file as malformed.
+func declaredEntries(r io.ReaderAt, size int64) (count uint64, ok bool) {
+	tail := min(size, eocdLen+eocdMaxComment)
+	buf := make([]byte, tail)
+	if _, err := r.ReadAt(buf, size-tail); err != nil && !errors.Is(err, io.EOF) {
+		return 0, false
+	}
+	at := -1
+	for i := len(buf) - eocdLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == eocdSignature {
+			at = i
+			break
+		}
+	}
+	if at < 0 {
+		return 0, false
+	}
+	count = uint64(binary.LittleEndian.Uint16(buf[at+eocdEntriesAt:]))
+	if count != eocdZip64Marker {
+		return count, true
+	}
+
+	// The zip64 locator sits just before the end record and points at the
+	// zip64 end record holding the full count
+	locatorAt := size - tail + int64(at) - zip64LocatorLen
+	if locatorAt < 0 {
+		return count, true
+	}
+	locator := make([]byte, zip64LocatorLen)
+	if _, err := r.ReadAt(locator, locatorAt); err != nil || binary.LittleEndian.Uint32(locator) != zip64LocatorSig {
+		return count, true
+	}
+	recordAt := int64(binary.LittleEndian.Uint64(locator[8:]))
+	if recordAt < 0 || recordAt > size-zip64EOCDLen {
+		return count, true
+	}
+	record := make([]byte, zip64EOCDLen)
+	if _, err := r.ReadAt(record, recordAt); err != nil || binary.LittleEndian.Uint32(record) != zip64EOCDSignature {
+		return count, true
+	}
+	return binary.LittleEndian.Uint64(record[zip64EOCDEntriesAt:]), true
+}
+
+func archiveEntry(zf *zip.File) domain.ArchiveEntry {
+	return domain.ArchiveEntry{
+		Name:           zf.Name,
+		Size:           int64(zf.UncompressedSize64),
+		CompressedSize: int64(zf.CompressedSize64),
+		ModTime:        zf.Modified,
+		IsDir:          zf.FileInfo().IsDir(),
+	}
+}
+
+// archiveEntryReader closes the archive along with the entry read from it
+type archiveEntryReader struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+func (r *archiveEntryReader) Close() error {
+	err := r.ReadCloser.Close()
+	if closeErr := r.archive.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// seekReaderAt reads at offsets by seeking, for storage backends whose files
+// aren't an io.ReaderAt. It isn't safe for concurrent use, which zip doesn't
+// need when one entry is read at a time.
+type seekReaderAt struct {
+	r io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,114 @@
+package file
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// testZip returns a zip holding files, in order
+func testZip(t *testing.T, files ...[2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestListArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    []byte
+		want    []string
+		wantErr error
+	}{
+		{"entries in order", "a.zip", testZip(t, [2]string{"docs/", ""}, [2]string{"docs/a.txt", "hello"}, [2]string{"b.txt", "hi"}), []string{"docs/", "docs/a.txt", "b.txt"}, nil},
+		{"empty archive", "empty.zip", testZip(t), []string{}, nil},
+		{"not a zip", "fake.zip", []byte("plain text"), nil, domain.ErrNotArchive},
+		{"wrong extension", "a.txt", testZip(t, [2]string{"a", ""}), nil, domain.ErrNotArchive},
+		{"entry count over the limit", "big.zip", withDeclaredEntries(testZip(t, [2]string{"a", ""}), archiveMaxEntries+1), nil, domain.ErrArchiveTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestService(t, nil)
+			os.WriteFile(filepath.Join(dir, tt.file), tt.data, 0644)
+			listing, err := s.ListArchive(tt.file)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for _, e := range listing.Entries {
+				got = append(got, e.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("entries %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("entries %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenArchiveEntry(t *testing.T) {
+	data := testZip(t, [2]string{"docs/", ""}, [2]string{"docs/a.txt", "hello"})
+	tests := []struct {
+		name    string
+		entry   string
+		want    string
+		wantErr error
+	}{
+		{"file", "docs/a.txt", "hello", nil},
+		{"folder", "docs/", "", domain.ErrIsDirectory},
+		{"missing", "nope.txt", "", domain.ErrEntryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestService(t, nil)
+			os.WriteFile(filepath.Join(dir, "a.zip"), data, 0644)
+			rc, entry, err := s.OpenArchiveEntry("a.zip", tt.entry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+			got, err := io.ReadAll(rc)
+			if err != nil || string(got) != tt.want || entry.Size != int64(len(tt.want)) {
+				t.Fatalf("read %q (size %d), %v; want %q", got, entry.Size, err, tt.want)
+			}
+		})
+	}
+}
+
+// withDeclaredEntries rewrites the entry counts in data's end record
+func withDeclaredEntries(data []byte, n uint16) []byte {
+	data = bytes.Clone(data)
+	end := data[len(data)-eocdLen:]
+	binary.LittleEndian.PutUint16(end[8:], n)
+	binary.LittleEndian.PutUint16(end[eocdEntriesAt:], n)
+	return data
+}
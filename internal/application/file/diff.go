@@ -0,0 +1,171 @@
+package file
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// Limits for folder comparisons so huge trees can't tie up the server
+const (
+	diffMaxEntries       = 100000  // Per side
+	diffMaxChecksumBytes = 1 << 30 // Total bytes hashed when comparing contents
+)
+
+// Diff compares the folders left and right. Files on both sides differ when
+// their size or modification time (to the second) does; with checksums set,
+// same-size files are also compared by content until the hashing budget
+// runs out.
+func (s *service) Diff(left, right string, checksums bool) (*domain.DirDiff, error) {
+	left, right = cleanPath(left), cleanPath(right)
+	leftEntries, err := s.diffSide(left)
+	if err != nil {
+		return nil, err
+	}
+	rightEntries, err := s.diffSide(right)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &domain.DirDiff{
+		Left:      left,
+		Right:     right,
+		OnlyLeft:  onlyIn(leftEntries, rightEntries),
+		OnlyRight: onlyIn(rightEntries, leftEntries),
+		Changed:   []domain.DiffEntry{},
+		Checksums: checksums,
+	}
+
+	budget := int64(diffMaxChecksumBytes)
+	for rel, l := range leftEntries {
+		r, ok := rightEntries[rel]
+		if !ok {
+			continue
+		}
+
+		var reasons []string
+		switch {
+		case l.IsDir != r.IsDir:
+			reasons = append(reasons, domain.DiffType)
+		case l.IsDir:
+			// Folder modtimes change with their contents, which are compared directly
+		default:
+			if l.Size != r.Size {
+				reasons = append(reasons, domain.DiffSize)
+			}
+			if !l.ModTime.Truncate(time.Second).Equal(r.ModTime.Truncate(time.Second)) {
+				reasons = append(reasons, domain.DiffModTime)
+			}
+			if !checksums || l.Size != r.Size {
+				break
+			}
+			if budget < 2*l.Size {
+				diff.Unhashed++
+				break
+			}
+			budget -= 2 * l.Size
+			same, err := s.sameContent(l.Path, r.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !same {
+				reasons = append(reasons, domain.DiffChecksum)
+			}
+		}
+
+		if len(reasons) == 0 {
+			diff.Same++
+			continue
+		}
+		l.Path, r.Path = rel, rel
+		diff.Changed = append(diff.Changed, domain.DiffEntry{Path: rel, Left: l, Right: r, Reasons: reasons})
+	}
+
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Path < diff.Changed[j].Path })
+	return diff, nil
+}
+
+// diffSide lists everything under the folder root, keyed by path relative
+// to it
+func (s *service) diffSide(root string) (map[string]domain.FileInfo, error) {
+	info, err := s.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir {
+		return nil, domain.ErrNotDirectory
+	}
+
+	entries := make(map[string]domain.FileInfo)
+	err = s.repo.Walk(root, s.hidden, func(info domain.FileInfo) error {
+		if info.Path == root {
+			return nil
+		}
+		if len(entries) == diffMaxEntries {
+			return domain.ErrDiffTooLarge
+		}
+		rel := info.Path
+		if root != "" {
+			rel = strings.TrimPrefix(rel, root+"/")
+		}
+		entries[rel] = info
+		return nil
+	})
+	if err != nil && !errors.Is(err, domain.ErrWalkIncomplete) {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// onlyIn returns the entries of a missing from b, relative to their root.
+// Contents of a folder that is itself missing are left out.
+func onlyIn(a, b map[string]domain.FileInfo) []domain.FileInfo {
+	only := []domain.FileInfo{}
+	for rel, info := range a {
+		if _, ok := b[rel]; ok {
+			continue
+		}
+		if parent := path.Dir(rel); parent != "." {
+			if _, inB := b[parent]; !inB {
+				continue
+			}
+		}
+		info.Path = rel
+		only = append(only, info)
+	}
+	sort.Slice(only, func(i, j int) bool { return only[i].Path < only[j].Path })
+	return only
+}
+
+// sameContent reports whether the files at a and b hash the same
+func (s *service) sameContent(a, b string) (bool, error) {
+	hashA, err := s.hashFile(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := s.hashFile(b)
+	if err != nil {
+		return false, err
+	}
+	return string(hashA) == string(hashB), nil
+}
+
+func (s *service) hashFile(p string) ([]byte, error) {
+	f, _, err := s.repo.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
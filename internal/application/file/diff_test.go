@@ -0,0 +1,117 @@
+package file
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestDiff(t *testing.T) {
+	svc, dir := newTestService(t, map[string]string{
+		"left/same.txt":     "same",
+		"left/sub/n.txt":    "nested",
+		"left/size.txt":     "aaaa",
+		"left/content.txt":  "abcd",
+		"left/mtime.txt":    "m",
+		"left/only.txt":     "o",
+		"left/gone/a.txt":   "a",
+		"left/gone/b.txt":   "b",
+		"left/kind":         "file",
+		"right/same.txt":    "same",
+		"right/sub/n.txt":   "nested",
+		"right/size.txt":    "aaaaaaa",
+		"right/content.txt": "wxyz",
+		"right/mtime.txt":   "m",
+		"right/extra.txt":   "e",
+		"right/kind/x.txt":  "x",
+	})
+	// Give every entry the same modification time except the one meant to differ
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	filepath.WalkDir(dir, func(p string, _ fs.DirEntry, err error) error {
+		if err == nil {
+			os.Chtimes(p, at, at)
+		}
+		return err
+	})
+	os.Chtimes(filepath.Join(dir, "right", "mtime.txt"), at, at.Add(time.Hour))
+
+	paths := func(infos []domain.FileInfo) []string {
+		var out []string
+		for _, info := range infos {
+			out = append(out, info.Path)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name        string
+		checksums   bool
+		wantChanged map[string][]string
+		wantSame    int64
+	}{
+		{"metadata only", false, map[string][]string{
+			"kind":      {domain.DiffType},
+			"mtime.txt": {domain.DiffModTime},
+			"size.txt":  {domain.DiffSize},
+		}, 4},
+		{"with checksums", true, map[string][]string{
+			"content.txt": {domain.DiffChecksum},
+			"kind":        {domain.DiffType},
+			"mtime.txt":   {domain.DiffModTime},
+			"size.txt":    {domain.DiffSize},
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := svc.Diff("/left/", "right", tt.checksums)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff.Left != "left" || diff.Right != "right" {
+				t.Errorf("compared %q with %q", diff.Left, diff.Right)
+			}
+			if got := paths(diff.OnlyLeft); !slices.Equal(got, []string{"gone", "only.txt"}) {
+				t.Errorf("only in left %v", got)
+			}
+			if got := paths(diff.OnlyRight); !slices.Equal(got, []string{"extra.txt", "kind/x.txt"}) {
+				t.Errorf("only in right %v", got)
+			}
+			if len(diff.Changed) != len(tt.wantChanged) {
+				t.Fatalf("changed %+v, want %v", diff.Changed, tt.wantChanged)
+			}
+			for _, entry := range diff.Changed {
+				if !slices.Equal(entry.Reasons, tt.wantChanged[entry.Path]) {
+					t.Errorf("%s differs by %v, want %v", entry.Path, entry.Reasons, tt.wantChanged[entry.Path])
+				}
+			}
+			if diff.Same != tt.wantSame || diff.Unhashed != 0 {
+				t.Errorf("same %d, unhashed %d; want %d, 0", diff.Same, diff.Unhashed, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestDiffRejectsNonFolders(t *testing.T) {
+	svc, _ := newTestService(t, map[string]string{"left/a.txt": "a", "file.txt": "f"})
+	tests := []struct {
+		name        string
+		left, right string
+		want        error
+	}{
+		{"missing side", "left", "nope", domain.ErrNotFound},
+		{"file side", "file.txt", "left", domain.ErrNotDirectory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Diff(tt.left, tt.right, false); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
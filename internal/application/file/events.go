@@ -0,0 +1,71 @@
+package file
+
+import (
+	"sync"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// eventBufferSize is how many events a slow subscriber may fall behind by
+// before further events are dropped for it
+const eventBufferSize = 64
+
+// EventBroker fans file change events out to live subscribers, such as
+// server-sent event streams
+type EventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan domain.Event]struct{}
+}
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subscribers: make(map[chan domain.Event]struct{})}
+}
+
+// Publish delivers event to every subscriber with room for it
+func (b *EventBroker) Publish(event domain.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of future events and a function that ends
+// the subscription
+func (b *EventBroker) Subscribe() (<-chan domain.Event, func()) {
+	ch := make(chan domain.Event, eventBufferSize)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publishers hands each event to several publishers in order
+type Publishers []domain.EventPublisher
+
+func (p Publishers) Publish(event domain.Event) {
+	for _, publisher := range p {
+		publisher.Publish(event)
+	}
+}
+
+// publish reports a change to the configured publisher, leaving out hidden
+// paths
+func (s *service) publish(eventType, path, from string) {
+	if s.events == nil || s.isHidden(path) {
+		return
+	}
+	s.events.Publish(domain.Event{Type: eventType, Path: path, From: from, Time: time.Now()})
+}
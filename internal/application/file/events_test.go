@@ -0,0 +1,62 @@
+package file
+
+import (
+	"testing"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestServicePublishesChanges(t *testing.T) {
+	svc, _ := newTestService(t, map[string]string{"doc.txt": "d"})
+	broker := NewEventBroker()
+	svc.events = broker
+	events, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+
+	steps := []struct {
+		name string
+		run  func() error
+		want domain.Event // Zero when nothing should be published
+	}{
+		{"create folder", func() error { return svc.CreateFolder("/archive/") }, domain.Event{Type: domain.EventCreated, Path: "archive"}},
+		{"copy", func() error { _, err := svc.Copy("doc.txt", "archive/copy.txt"); return err }, domain.Event{Type: domain.EventCreated, Path: "archive/copy.txt"}},
+		{"move", func() error { _, err := svc.Move("doc.txt", "archive/doc.txt"); return err }, domain.Event{Type: domain.EventMoved, Path: "archive/doc.txt", From: "doc.txt"}},
+		{"delete", func() error { return svc.Delete("archive/copy.txt") }, domain.Event{Type: domain.EventDeleted, Path: "archive/copy.txt"}},
+		{"rejected delete", func() error { svc.Delete(".avatars"); return nil }, domain.Event{}},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		select {
+		case got := <-events:
+			if got.Type != step.want.Type || got.Path != step.want.Path || got.From != step.want.From || got.Time.IsZero() {
+				t.Fatalf("%s: published %+v, want %+v", step.name, got, step.want)
+			}
+		default:
+			if step.want.Type != "" {
+				t.Fatalf("%s: nothing published", step.name)
+			}
+		}
+	}
+}
+
+func TestEventBroker(t *testing.T) {
+	broker := NewEventBroker()
+	slow, stopSlow := broker.Subscribe()
+	defer stopSlow()
+	gone, stopGone := broker.Subscribe()
+	stopGone()
+	stopGone() // Ending a subscription twice is harmless
+
+	// A subscriber that stops reading only loses what overflows its buffer
+	for i := 0; i < eventBufferSize+10; i++ {
+		broker.Publish(domain.Event{Type: domain.EventCreated})
+	}
+	if len(slow) != eventBufferSize {
+		t.Fatalf("slow subscriber holds %d events, want %d", len(slow), eventBufferSize)
+	}
+	if len(gone) != 0 {
+		t.Fatalf("ended subscription received %d events", len(gone))
+	}
+}
@@ -0,0 +1,120 @@
+package file
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// ListingCacheConfig bounds the directory listing cache. A zero Size
+// disables caching.
+type ListingCacheConfig struct {
+	Size int           // Maximum number of cached directories
+	TTL  time.Duration // Maximum age of a cached listing
+}
+
+// listingCache is an LRU of directory listings keyed by cleaned path.
+// Writes made through the service invalidate affected entries; the TTL
+// bounds staleness from changes made to storage behind the service's back.
+type listingCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+}
+
+type listingCacheEntry struct {
+	path     string
+	files    []domain.FileInfo
+	cachedAt time.Time
+}
+
+// newListingCache returns nil when caching is disabled; a nil cache is a no-op
+func newListingCache(cfg ListingCacheConfig) *listingCache {
+	if cfg.Size <= 0 {
+		return nil
+	}
+	return &listingCache{
+		size:    cfg.Size,
+		ttl:     cfg.TTL,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the cached listing for path, if still fresh
+func (c *listingCache) get(path string) ([]domain.FileInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*listingCacheEntry)
+	if c.ttl > 0 && time.Since(entry.cachedAt) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, path)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return append([]domain.FileInfo(nil), entry.files...), true
+}
+
+// put stores a copy of files so callers may modify their slice freely
+func (c *listingCache) put(path string, files []domain.FileInfo) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &listingCacheEntry{
+		path:     path,
+		files:    append([]domain.FileInfo(nil), files...),
+		cachedAt: time.Now(),
+	}
+	if elem, ok := c.entries[path]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[path] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*listingCacheEntry).path)
+	}
+}
+
+// invalidate drops path, its ancestors (whose entries carry its size and
+// modtime) and everything below it
+func (c *listingCache) invalidate(paths ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range paths {
+		for key, elem := range c.entries {
+			if isWithin(p, key) || isWithin(key, p) {
+				c.order.Remove(elem)
+				delete(c.entries, key)
+			}
+		}
+	}
+}
+
+// isWithin reports whether p is dir or lies below it; "" is the root
+func isWithin(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
@@ -0,0 +1,131 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/repository"
+)
+
+func TestListingCache(t *testing.T) {
+	listing := func(names ...string) []domain.FileInfo {
+		files := make([]domain.FileInfo, len(names))
+		for i, n := range names {
+			files[i] = domain.FileInfo{Name: n}
+		}
+		return files
+	}
+	tests := []struct {
+		name    string
+		cfg     ListingCacheConfig
+		setup   func(c *listingCache)
+		cached  []string
+		missing []string
+	}{
+		{"disabled", ListingCacheConfig{}, func(c *listingCache) { c.put("a", listing("x")) }, nil, []string{"a"}},
+		{"least recently used goes first", ListingCacheConfig{Size: 2}, func(c *listingCache) {
+			c.put("a", listing("x"))
+			c.put("b", listing("x"))
+			c.get("a")
+			c.put("c", listing("x"))
+		}, []string{"a", "c"}, []string{"b"}},
+		{"expired", ListingCacheConfig{Size: 2, TTL: time.Nanosecond}, func(c *listingCache) {
+			c.put("a", listing("x"))
+			time.Sleep(time.Millisecond)
+		}, nil, []string{"a"}},
+		{"invalidate drops ancestors and descendants", ListingCacheConfig{Size: 10}, func(c *listingCache) {
+			for _, p := range []string{"", "a", "a/b", "a/b/c", "a/bc", "d"} {
+				c.put(p, listing("x"))
+			}
+			c.invalidate("a/b")
+		}, []string{"a/bc", "d"}, []string{"", "a", "a/b", "a/b/c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newListingCache(tt.cfg)
+			tt.setup(c)
+			for _, p := range tt.cached {
+				if _, ok := c.get(p); !ok {
+					t.Errorf("%q is not cached", p)
+				}
+			}
+			for _, p := range tt.missing {
+				if _, ok := c.get(p); ok {
+					t.Errorf("%q is still cached", p)
+				}
+			}
+		})
+	}
+}
+
+func TestListFilesCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func(s *service) error
+		dir  string
+		want []string
+	}{
+		{"delete", func(s *service) error { return s.Delete("docs/a.txt") }, "docs", []string{"b.txt"}},
+		{"delete folder", func(s *service) error { return s.Delete("docs") }, "", []string{"other"}},
+		{"create folder", func(s *service) error { return s.CreateFolder("docs/new") }, "docs", []string{"a.txt", "b.txt", "new"}},
+		{"move out", func(s *service) error { _, err := s.Move("docs/a.txt", "other"); return err }, "docs", []string{"b.txt"}},
+		{"move in", func(s *service) error { _, err := s.Move("docs/a.txt", "other"); return err }, "other", []string{"a.txt"}},
+		{"upload", func(s *service) error {
+			_, err := s.UploadStream("docs", "c.txt", strings.NewReader("c"), domain.ConflictReject)
+			return err
+		}, "docs", []string{"a.txt", "b.txt", "c.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"docs/a.txt": "a", "docs/b.txt": "b", "other/.keep": ""})
+			repo := repository.NewFilesystemRepository(dir, nil, false)
+			s := NewService(repo, newMemIndex(), nil, repository.NewUploadStore(dir, repo, nil, 0), ListingCacheConfig{Size: 10, TTL: time.Hour}, 0, nil, domain.NameSanitizer{}, nil, nil).(*service)
+
+			for _, p := range []string{"", "docs", "other"} {
+				if _, err := s.ListFiles(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.op(s); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := s.ListFiles(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range files {
+				if f.Name != ".keep" {
+					got = append(got, f.Name)
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("listed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFilesServesFromCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"docs/a.txt": "a"})
+	repo := repository.NewFilesystemRepository(dir, nil, false)
+	s := NewService(repo, newMemIndex(), nil, repository.NewUploadStore(dir, repo, nil, 0), ListingCacheConfig{Size: 10, TTL: time.Hour}, 0, nil, domain.NameSanitizer{}, nil, nil)
+
+	if _, err := s.ListFiles("docs"); err != nil {
+		t.Fatal(err)
+	}
+	// Changes made behind the service's back wait for the TTL
+	os.WriteFile(filepath.Join(dir, "docs", "b.txt"), nil, 0644)
+	if files, _ := s.ListFiles("docs"); len(files) != 1 {
+		t.Fatalf("listed %d files, want the cached 1", len(files))
+	}
+}
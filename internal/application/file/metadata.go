@@ -0,0 +1,116 @@
+package file
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// Limits on what can be attached to a single file
+const (
+	maxTags           = 64
+	maxTagLength      = 64
+	maxMetadataValues = 64
+	maxMetadataKey    = 64
+	maxMetadataValue  = 1024
+)
+
+func (s *service) GetMetadata(path string) (*domain.Metadata, error) {
+	cleaned, err := s.metadataTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.meta.Get(cleaned)
+}
+
+func (s *service) SetMetadata(path string, meta domain.Metadata) (*domain.Metadata, error) {
+	cleaned, err := s.metadataTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := normalizeMetadata(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.index.GetOrCreate(cleaned)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.meta.Set(id, *normalized); err != nil {
+		return nil, err
+	}
+	// Listings carrying tags are cached by version
+	s.changed(parentPath(cleaned))
+	return normalized, nil
+}
+
+func (s *service) AttachMetadata(dir string, files []domain.FileInfo) error {
+	children, err := s.meta.Children(cleanPath(dir))
+	if err != nil {
+		return err
+	}
+	for i := range files {
+		if meta, ok := children[files[i].Path]; ok {
+			files[i].Meta = meta
+		} else {
+			files[i].Meta = &domain.Metadata{Tags: []string{}, Values: map[string]string{}}
+		}
+	}
+	return nil
+}
+
+// metadataTarget cleans path and checks it names an existing, visible entry
+// other than the storage root
+func (s *service) metadataTarget(path string) (string, error) {
+	cleaned := cleanPath(path)
+	if cleaned == "" {
+		return "", domain.ErrInvalidPath
+	}
+	if s.isHidden(cleaned) {
+		return "", domain.ErrNotFound
+	}
+	exists, err := s.repo.Exists(cleaned)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", domain.ErrNotFound
+	}
+	return cleaned, nil
+}
+
+// normalizeMetadata trims tags and keys, drops empty and duplicate tags
+// (ignoring case) and enforces the size limits
+func normalizeMetadata(meta domain.Metadata) (*domain.Metadata, error) {
+	normalized := &domain.Metadata{Tags: []string{}, Values: map[string]string{}}
+
+	for _, tag := range meta.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || normalized.HasTag(tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, domain.ErrInvalidMetadata
+		}
+		normalized.Tags = append(normalized.Tags, tag)
+	}
+	if len(normalized.Tags) > maxTags {
+		return nil, domain.ErrInvalidMetadata
+	}
+	slices.Sort(normalized.Tags)
+
+	if len(meta.Values) > maxMetadataValues {
+		return nil, domain.ErrInvalidMetadata
+	}
+	for key, value := range meta.Values {
+		key = strings.TrimSpace(key)
+		if key == "" || key == domain.MetadataTagKey || utf8.RuneCountInString(key) > maxMetadataKey || utf8.RuneCountInString(value) > maxMetadataValue {
+			return nil, domain.ErrInvalidMetadata
+		}
+		normalized.Values[key] = value
+	}
+	return normalized, nil
+}
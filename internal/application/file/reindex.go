@@ -0,0 +1,102 @@
+package file
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// reindexer runs at most one storage reindex at a time and keeps the status
+// of the latest run for polling
+type reindexer struct {
+	mu     sync.Mutex
+	status domain.ReindexStatus
+}
+
+func (s *service) StartReindex(onDone func()) (domain.ReindexStatus, error) {
+	s.reindex.mu.Lock()
+	defer s.reindex.mu.Unlock()
+
+	if s.reindex.status.Running {
+		return s.reindex.status, domain.ErrReindexRunning
+	}
+	now := time.Now()
+	s.reindex.status = domain.ReindexStatus{Running: true, StartedAt: &now}
+
+	go func() {
+		s.runReindex()
+		if onDone != nil {
+			onDone()
+		}
+	}()
+	return s.reindex.status, nil
+}
+
+func (s *service) ReindexStatus() domain.ReindexStatus {
+	s.reindex.mu.Lock()
+	defer s.reindex.mu.Unlock()
+	return s.reindex.status
+}
+
+// runReindex walks the whole tree, drops every cache built from earlier
+// reads and removes file-ID entries for paths that no longer exist. IDs are
+// only pruned after a walk that read everything, and only those that predate
+// it, so files added or moved meanwhile keep theirs.
+func (s *service) runReindex() {
+	started := time.Now()
+	var files, folders int64
+	seen := make(map[string]bool)
+
+	err := s.repo.Walk("", s.hidden, func(info domain.FileInfo) error {
+		if info.IsDir {
+			folders++
+		} else {
+			files++
+		}
+		seen[info.Path] = true
+
+		// Publish progress every so often for long walks
+		if (files+folders)%1000 == 0 {
+			s.reindex.mu.Lock()
+			s.reindex.status.Files, s.reindex.status.Folders = files, folders
+			s.reindex.mu.Unlock()
+		}
+		return nil
+	})
+
+	pruned := 0
+	incomplete := errors.Is(err, domain.ErrWalkIncomplete)
+	if err == nil || incomplete {
+		// Caches are cleared only after the walk so a failed run leaves the
+		// service as it was
+		s.changed("")
+	}
+	if err == nil {
+		pruned, err = s.index.Prune(started, func(path string) bool {
+			if seen[path] {
+				return true
+			}
+			exists, err := s.repo.Exists(path)
+			return exists || err != nil
+		})
+	}
+
+	now := time.Now()
+	s.reindex.mu.Lock()
+	defer s.reindex.mu.Unlock()
+	s.reindex.status.Running = false
+	s.reindex.status.FinishedAt = &now
+	s.reindex.status.Files, s.reindex.status.Folders = files, folders
+	s.reindex.status.IndexPruned = pruned
+	switch {
+	case incomplete:
+		log.Printf("reindex: %v; the file index was not pruned", err)
+		s.reindex.status.Error = "Some entries could not be read; the file index was not pruned"
+	case err != nil:
+		log.Printf("reindex: %v", err)
+		s.reindex.status.Error = "Reindex failed"
+	}
+}
@@ -0,0 +1,76 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// walkHook is a repository whose Walk calls after once the tree has been
+// read, then reports err in place of the walk's own result
+type walkHook struct {
+	domain.Repository
+	after func()
+	err   error
+}
+
+func (w walkHook) Walk(root string, excludePaths []string, fn func(info domain.FileInfo) error) error {
+	if err := w.Repository.Walk(root, excludePaths, fn); err != nil {
+		return err
+	}
+	if w.after != nil {
+		w.after()
+	}
+	return w.err
+}
+
+func TestReindexPrune(t *testing.T) {
+	tests := []struct {
+		name       string
+		walkErr    error
+		addLate    bool // Create late.txt once the walk is past it
+		wantPruned int
+		wantKept   []string
+		wantError  bool
+	}{
+		{"complete walk prunes missing paths", nil, false, 1, []string{"a.txt"}, false},
+		{"incomplete walk prunes nothing", domain.ErrWalkIncomplete, false, 0, []string{"a.txt", "gone.txt"}, true},
+		{"file added during the walk is kept", nil, true, 1, []string{"a.txt", "late.txt"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestService(t, map[string]string{"a.txt": "a"})
+			for _, p := range []string{"a.txt", "gone.txt", "late.txt"} {
+				s.index.GetOrCreate(p)
+			}
+			hook := walkHook{Repository: s.repo, err: tt.walkErr}
+			if tt.addLate {
+				hook.after = func() { os.WriteFile(filepath.Join(dir, "late.txt"), nil, 0644) }
+			} else {
+				s.index.Remove("late.txt")
+			}
+			s.repo = hook
+
+			s.runReindex()
+
+			status := s.ReindexStatus()
+			if status.IndexPruned != tt.wantPruned {
+				t.Errorf("pruned %d, want %d", status.IndexPruned, tt.wantPruned)
+			}
+			if (status.Error != "") != tt.wantError {
+				t.Errorf("status error %q, want error %v", status.Error, tt.wantError)
+			}
+			index := s.index.(*memIndex)
+			if len(index.paths) != len(tt.wantKept) {
+				t.Errorf("index holds %v, want %v", index.paths, tt.wantKept)
+			}
+			for _, p := range tt.wantKept {
+				if _, err := index.GetOrCreate(p); err != nil || len(index.paths) != len(tt.wantKept) {
+					t.Errorf("%s was pruned", p)
+				}
+			}
+		})
+	}
+}
@@ -1,104 +1,972 @@
 package file
 
 import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"mime/multipart"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	domain "gomanager/internal/domain/file"
 )
 
-// Hidden folders/files that should not be shown in listings
-var hiddenPaths = []string{".avatars"}
+// builtinHiddenPaths are the server's internal folders; they are hidden
+// whatever else is configured
+var builtinHiddenPaths = []string{".avatars", ".uploads", ".quarantine", ".thumbnails"}
+
+// maxRenameAttempts bounds the search for a free "name (n)" when pasting
+const maxRenameAttempts = 1000
+
+// Limits for directory size walks so huge trees can't stall a listing, and
+// for how many computed sizes are kept
+const (
+	dirSizeMaxDepth   = 32
+	dirSizeTimeout    = 2 * time.Second
+	dirSizeCacheLimit = 10000
+)
 
 // Service defines the business logic for file operations
 type Service interface {
 	ListFiles(path string) ([]domain.FileInfo, error)
-	GetFileForDownload(path string) (string, error)
-	UploadFiles(path string, files []*multipart.FileHeader) ([]string, error)
+	// AttachDirSizes fills in the recursive size of each folder in files, a
+	// listing, for as long as the size walk's time budget allows
+	AttachDirSizes(files []domain.FileInfo)
+	OpenFile(path string) (io.ReadSeekCloser, *domain.FileInfo, error)
+	Stat(path string) (*domain.FileInfo, error)
+	Exists(path string) (exists bool, isDir bool, err error)
+	// Breadcrumbs lists each segment of path from the top down; paths with
+	// ".." segments are rejected rather than cleaned
+	Breadcrumbs(path string) ([]domain.Breadcrumb, error)
+	UploadFiles(path string, files []*multipart.FileHeader, policy domain.ConflictPolicy) (*domain.UploadResult, error)
+	// UploadStream stores data as filename inside path, creating missing
+	// folders, and returns the stored file
+	UploadStream(path, filename string, data io.Reader, policy domain.ConflictPolicy) (*domain.FileInfo, error)
+	// SanitizeName returns the name an upload called name is stored under
+	SanitizeName(name string) string
 	CreateFolder(path string) error
 	Delete(path string) error
+	// PreviewDelete lists what Delete would remove, up to limit entries
+	PreviewDelete(path string, limit int) (*domain.DeletePreview, error)
+	Move(source, destination string) (string, error)
+	Copy(source, destination string) (string, error)
+	Paste(operation string, sources []string, destination string, policy domain.ConflictPolicy) ([]domain.PasteResult, error)
 	GetStats() (*domain.StorageStats, error)
+	// FilesByType lists the files of type ext (see domain.TypeKey), largest
+	// first, skipping offset and returning at most limit
+	FilesByType(ext string, offset, limit int) (*domain.TypeFiles, error)
+	// Version changes whenever a file operation goes through this service, for
+	// use in cache validators; changes made directly on disk aren't counted
+	Version() string
+	Touch(path string, modTime time.Time, recursive bool) error
+	WriteTar(path string, w io.Writer, compress bool) error
+	// Walk calls fn for path and everything under it, skipping hidden folders
+	Walk(path string, fn func(info domain.FileInfo) error) error
+	// Diff compares two folders, optionally by content as well
+	Diff(left, right string, checksums bool) (*domain.DirDiff, error)
+	// ListArchive lists the entries of the zip file at path without
+	// extracting anything
+	ListArchive(path string) (*domain.ArchiveListing, error)
+	// OpenArchiveEntry opens one file inside the zip file at path for
+	// reading; the caller must close it
+	OpenArchiveEntry(path, entry string) (io.ReadCloser, *domain.ArchiveEntry, error)
+
+	// StartReindex rescans storage in the background, rebuilding caches and
+	// pruning stale file IDs, then calls onDone (if set); ReindexStatus
+	// reports the latest run. onDone isn't called when a run is already going.
+	StartReindex(onDone func()) (domain.ReindexStatus, error)
+	ReindexStatus() domain.ReindexStatus
+
+	// GetMetadata returns the tags and key/value pairs set on path
+	GetMetadata(path string) (*domain.Metadata, error)
+	// SetMetadata replaces the metadata on path and returns it as stored
+	SetMetadata(path string, meta domain.Metadata) (*domain.Metadata, error)
+	// AttachMetadata fills in Meta on files, a listing of dir
+	AttachMetadata(dir string, files []domain.FileInfo) error
+
+	// Resumable uploads
+	CreateUpload(userID, path, filename string, length int64) (*domain.PendingUpload, error)
+	GetUpload(id string) (*domain.PendingUpload, error)
+	AppendUpload(id string, offset int64, data io.Reader) (*domain.PendingUpload, string, error)
 }
 
 type service struct {
-	repo domain.Repository
+	repo    domain.Repository
+	index   domain.IDIndex
+	meta    domain.MetadataStore
+	uploads domain.UploadStore
+
+	// listings caches raw directory listings; nil when disabled
+	listings *listingCache
+
+	// version counts changes made through the service; bootID keeps
+	// versions from different runs apart
+	version atomic.Uint64
+	bootID  string
+
+	// maxDepth caps the number of segments in created directories (0 = no limit)
+	maxDepth int
+
+	// hidden lists storage paths kept out of listings, stats and lookups
+	hidden []string
+
+	// names cleans upload file names before they reach storage
+	names domain.NameSanitizer
+
+	// nameCheck vets the names entries are moved or copied to, as uploads
+	// are vetted; nil allows any name
+	nameCheck domain.FileScanner
+
+	// events is told about changes made through the service; nil when unused
+	events domain.EventPublisher
+
+	// dirSizes caches computed directory sizes keyed by path, up to
+	// dirSizeCacheLimit entries
+	dirSizesMu sync.Mutex
+	dirSizes   map[string]dirSizeEntry
+
+	reindex reindexer
+}
+
+// dirSizeEntry is a cached directory size, valid while the directory's modtime is unchanged
+type dirSizeEntry struct {
+	modTime time.Time
+	size    int64
 }
 
 // NewService creates a new file service
-func NewService(repo domain.Repository) Service {
-	return &service{repo: repo}
+func NewService(repo domain.Repository, index domain.IDIndex, meta domain.MetadataStore, uploads domain.UploadStore, cache ListingCacheConfig, maxDepth int, hiddenPaths []string, names domain.NameSanitizer, nameCheck domain.FileScanner, events domain.EventPublisher) Service {
+	s := &service{
+		repo:      repo,
+		index:     index,
+		meta:      meta,
+		uploads:   uploads,
+		listings:  newListingCache(cache),
+		bootID:    strconv.FormatInt(time.Now().UnixNano(), 36),
+		maxDepth:  maxDepth,
+		hidden:    mergeHiddenPaths(hiddenPaths),
+		names:     names,
+		nameCheck: nameCheck,
+		events:    events,
+		dirSizes:  make(map[string]dirSizeEntry),
+	}
+	// Uploads scanned in the background show up once placed
+	if notifier, ok := repo.(domain.PlacementNotifier); ok {
+		notifier.OnPlaced(func(p string) {
+			s.changed(p)
+			s.publish(domain.EventCreated, p, "")
+		})
+	}
+	return s
 }
 
 func (s *service) ListFiles(path string) ([]domain.FileInfo, error) {
-	files, err := s.repo.List(path)
+	cleaned := cleanPath(path)
+	if s.isHidden(cleaned) {
+		return nil, domain.ErrNotFound
+	}
+	files, err := s.listDir(path)
 	if err != nil {
 		return nil, err
 	}
 
-	// Filter out hidden files/folders at root level
-	if path == "" || path == "/" {
-		filtered := make([]domain.FileInfo, 0, len(files))
-		for _, f := range files {
-			if !isHidden(f.Name) {
-				filtered = append(filtered, f)
+	filtered := make([]domain.FileInfo, 0, len(files))
+	for _, f := range files {
+		if !s.isHidden(joinPath(cleaned, f.Name)) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered, nil
+}
+
+// AttachDirSizes fills in the recursive size of each subdirectory in a listing.
+// Sizes are cached by directory modtime and dropped when the service changes
+// anything inside; modtime only catches direct children being added or
+// removed, so nested edits made behind the service's back may take a while
+// to show up.
+func (s *service) AttachDirSizes(files []domain.FileInfo) {
+	deadline := time.Now().Add(dirSizeTimeout)
+	for i := range files {
+		if !files[i].IsDir {
+			continue
+		}
+
+		s.dirSizesMu.Lock()
+		entry, ok := s.dirSizes[files[i].Path]
+		s.dirSizesMu.Unlock()
+		if ok && entry.modTime.Equal(files[i].ModTime) {
+			files[i].Size = entry.size
+			continue
+		}
+
+		size, complete, err := s.repo.DirSize(files[i].Path, dirSizeMaxDepth, deadline)
+		if err != nil {
+			continue
+		}
+		files[i].Size = size
+		files[i].SizePartial = !complete
+
+		// Only cache sizes from walks that finished
+		if complete {
+			s.dirSizesMu.Lock()
+			if len(s.dirSizes) >= dirSizeCacheLimit {
+				for p := range s.dirSizes {
+					delete(s.dirSizes, p)
+					break
+				}
+			}
+			s.dirSizes[files[i].Path] = dirSizeEntry{modTime: files[i].ModTime, size: size}
+			s.dirSizesMu.Unlock()
+		}
+	}
+}
+
+// changed records a modification of paths: it bumps the version and drops
+// any cached listings and directory sizes they affect
+func (s *service) changed(paths ...string) {
+	s.version.Add(1)
+	s.listings.invalidate(paths...)
+
+	// A directory's size covers everything below it, so ancestors go too
+	s.dirSizesMu.Lock()
+	for _, p := range paths {
+		for dir := range s.dirSizes {
+			if isWithin(p, dir) || isWithin(dir, p) {
+				delete(s.dirSizes, dir)
 			}
 		}
-		return filtered, nil
 	}
+	s.dirSizesMu.Unlock()
+}
 
+func (s *service) Version() string {
+	return s.bootID + "." + strconv.FormatUint(s.version.Load(), 36)
+}
+
+// listDir reads a directory through the listing cache
+func (s *service) listDir(path string) ([]domain.FileInfo, error) {
+	key := cleanPath(path)
+	if files, ok := s.listings.get(key); ok {
+		return files, nil
+	}
+
+	files, err := s.repo.List(path)
+	if err != nil {
+		return nil, err
+	}
+	s.listings.put(key, files)
 	return files, nil
 }
 
-// isHidden checks if a file/folder name should be hidden
-func isHidden(name string) bool {
-	for _, hidden := range hiddenPaths {
-		if strings.EqualFold(name, hidden) {
+func (s *service) Breadcrumbs(p string) ([]domain.Breadcrumb, error) {
+	for _, segment := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return nil, domain.ErrInvalidPath
+		}
+	}
+
+	cleaned := cleanPath(p)
+	crumbs := []domain.Breadcrumb{}
+	if cleaned == "" {
+		return crumbs, nil
+	}
+
+	// Everything below a missing entry or a file is missing too, so only
+	// stat while the chain holds
+	segments := strings.Split(cleaned, "/")
+	chain := true
+	for i, name := range segments {
+		crumb := domain.Breadcrumb{Name: name, Path: strings.Join(segments[:i+1], "/")}
+		if chain && s.isHidden(crumb.Path) {
+			chain = false
+		}
+		if chain {
+			info, err := s.repo.Stat(crumb.Path)
+			switch {
+			case err == nil:
+				crumb.Exists, crumb.IsDir = true, info.IsDir
+			case errors.Is(err, domain.ErrNotFound):
+			default:
+				return nil, err
+			}
+			chain = crumb.IsDir
+		}
+		crumbs = append(crumbs, crumb)
+	}
+	return crumbs, nil
+}
+
+// mergeHiddenPaths cleans the configured paths and adds the built-in ones,
+// so internal folders can't be un-hidden
+func mergeHiddenPaths(configured []string) []string {
+	merged := append([]string(nil), builtinHiddenPaths...)
+	for _, p := range configured {
+		p = cleanPath(p)
+		if p == "" || slices.ContainsFunc(merged, func(h string) bool { return strings.EqualFold(h, p) }) {
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// isHidden reports whether a cleaned storage path is, or is inside, a hidden path
+func (s *service) isHidden(p string) bool {
+	for _, hidden := range s.hidden {
+		if strings.EqualFold(p, hidden) {
+			return true
+		}
+		if len(p) > len(hidden) && p[len(hidden)] == '/' && strings.EqualFold(p[:len(hidden)], hidden) {
 			return true
 		}
 	}
 	return false
 }
 
-func (s *service) GetFileForDownload(path string) (string, error) {
-	isDir, err := s.repo.IsDirectory(path)
+// OpenFile opens a file for download; the caller must close it
+func (s *service) OpenFile(path string) (io.ReadSeekCloser, *domain.FileInfo, error) {
+	if s.isHidden(cleanPath(path)) {
+		return nil, nil, domain.ErrNotFound
+	}
+	return s.repo.Open(path)
+}
+
+func (s *service) Stat(path string) (*domain.FileInfo, error) {
+	// Hidden entries are as invisible here as they are in listings
+	if s.isHidden(cleanPath(path)) {
+		return nil, domain.ErrNotFound
+	}
+
+	info, err := s.repo.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Path == "" {
+		info.Name = ""
+	}
+	if !info.IsDir {
+		info.ContentType = domain.ContentType(info.Name)
+	}
+	return info, nil
+}
+
+func (s *service) Exists(path string) (bool, bool, error) {
+	cleaned := cleanPath(path)
+	if s.isHidden(cleaned) {
+		return false, false, nil
+	}
+
+	exists, err := s.repo.Exists(cleaned)
+	if err != nil || !exists {
+		return false, false, err
+	}
+	isDir, err := s.repo.IsDirectory(cleaned)
 	if err != nil {
-		return "", domain.ErrNotFound
+		return false, false, err
+	}
+	return true, isDir, nil
+}
+
+// checkDepth rejects directory paths with more segments than maxDepth
+func (s *service) checkDepth(path string) error {
+	cleaned := cleanPath(path)
+	if s.maxDepth > 0 && cleaned != "" && strings.Count(cleaned, "/")+1 > s.maxDepth {
+		return domain.ErrPathTooDeep
+	}
+	return nil
+}
+
+// checkName rejects moving or copying a file to a name uploads may not
+// use, so a rename can't slip a blocked extension past the upload scanner
+func (s *service) checkName(source, destination string) error {
+	if s.nameCheck == nil || strings.EqualFold(path.Ext(source), path.Ext(destination)) {
+		return nil
 	}
+	if isDir, err := s.repo.IsDirectory(source); err == nil && isDir {
+		return nil
+	}
+	if err := s.nameCheck.Scan(destination); err != nil {
+		return domain.ErrScanRejected
+	}
+	return nil
+}
 
-	if isDir {
-		return "", domain.ErrIsDirectory
+// checkTreeDepth rejects putting source at target when that would leave a
+// folder, the source itself or one inside it, deeper than maxDepth
+func (s *service) checkTreeDepth(source, target string) error {
+	if s.maxDepth <= 0 {
+		return nil
+	}
+	if isDir, err := s.repo.IsDirectory(source); err != nil || !isDir {
+		return s.checkDepth(parentPath(target))
 	}
 
-	return s.repo.GetFilePath(path)
+	// Segments below source of its deepest subfolder
+	deepest := 0
+	err := s.repo.Walk(source, nil, func(info domain.FileInfo) error {
+		if info.IsDir {
+			deepest = max(deepest, strings.Count(strings.TrimPrefix(info.Path, source), "/"))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, domain.ErrWalkIncomplete) {
+		return err
+	}
+	if strings.Count(target, "/")+1+deepest > s.maxDepth {
+		return domain.ErrPathTooDeep
+	}
+	return nil
 }
 
-func (s *service) UploadFiles(path string, files []*multipart.FileHeader) ([]string, error) {
+// checkTargetDir fails with ErrNotDirectory when path, or the nearest of its
+// ancestors that exists, is a file, so it can't be used as a folder
+func (s *service) checkTargetDir(path string) error {
+	for p := cleanPath(path); p != ""; p = parentPath(p) {
+		exists, err := s.repo.Exists(p)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if isDir, err := s.repo.IsDirectory(p); err != nil {
+			return err
+		} else if !isDir {
+			return domain.ErrNotDirectory
+		}
+		return nil
+	}
+	return nil
+}
+
+// parentPath returns the parent of a cleaned path, "" for top-level entries
+func parentPath(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+func (s *service) UploadFiles(path string, files []*multipart.FileHeader, policy domain.ConflictPolicy) (*domain.UploadResult, error) {
+	// Names that sanitize to the same result are settled by policy in Save
+	for _, fileHeader := range files {
+		fileHeader.Filename = s.names.Sanitize(fileHeader.Filename)
+		if s.isHidden(joinPath(cleanPath(path), fileHeader.Filename)) {
+			return nil, domain.ErrInvalidPath
+		}
+	}
+	if err := s.checkDepth(path); err != nil {
+		return nil, err
+	}
+	if err := s.checkTargetDir(path); err != nil {
+		return nil, err
+	}
 	if err := s.repo.CreateDirectory(path); err != nil {
 		return nil, domain.ErrCreateFailed
 	}
 
-	uploaded, err := s.repo.Save(path, files)
+	result, err := s.repo.Save(path, files, policy)
+	s.changed(cleanPath(path))
 	if err != nil {
 		return nil, domain.ErrUploadFailed
 	}
+	for _, name := range result.Uploaded {
+		s.publish(domain.EventCreated, joinPath(cleanPath(path), name), "")
+	}
+
+	return result, nil
+}
+
+func (s *service) SanitizeName(name string) string {
+	return s.names.Sanitize(name)
+}
+
+func (s *service) UploadStream(path, filename string, data io.Reader, policy domain.ConflictPolicy) (*domain.FileInfo, error) {
+	path = cleanPath(path)
+	filename = cleanPath(filename)
+	if filename == "" || strings.Contains(filename, "/") {
+		return nil, domain.ErrInvalidPath
+	}
+	filename = s.names.Sanitize(filename)
+	if s.isHidden(joinPath(path, filename)) {
+		return nil, domain.ErrInvalidPath
+	}
+	if err := s.checkDepth(path); err != nil {
+		return nil, err
+	}
+	if err := s.checkTargetDir(path); err != nil {
+		return nil, err
+	}
 
-	return uploaded, nil
+	// Fail before reading the body when the name is taken
+	if policy == domain.ConflictReject {
+		if exists, err := s.repo.Exists(joinPath(path, filename)); err == nil && exists {
+			return nil, domain.ErrExists
+		}
+	}
+	if err := s.repo.CreateDirectory(path); err != nil {
+		return nil, domain.ErrCreateFailed
+	}
+
+	finalPath, err := s.uploads.Receive(path, filename, data, policy)
+	s.changed(path)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(domain.EventCreated, finalPath, "")
+	return s.repo.Stat(finalPath)
 }
 
 func (s *service) CreateFolder(path string) error {
-	if path == "" {
+	if path == "" || s.isHidden(cleanPath(path)) {
 		return domain.ErrInvalidPath
 	}
-	return s.repo.CreateDirectory(path)
+	if err := s.checkDepth(path); err != nil {
+		return err
+	}
+	if err := s.checkTargetDir(path); err != nil {
+		return err
+	}
+	defer s.changed(cleanPath(path))
+	if err := s.repo.CreateDirectory(path); err != nil {
+		return err
+	}
+	s.publish(domain.EventCreated, cleanPath(path), "")
+	return nil
 }
 
 func (s *service) Delete(path string) error {
 	if path == "" {
 		return domain.ErrRootDeletion
 	}
-	return s.repo.Delete(path)
+	if s.isHidden(cleanPath(path)) {
+		return domain.ErrNotFound
+	}
+	err := s.repo.Delete(path)
+	// RemoveAll may fail part way, so evict even on error
+	s.changed(cleanPath(path))
+	if err != nil {
+		return err
+	}
+	s.index.Remove(path)
+	s.publish(domain.EventDeleted, cleanPath(path), "")
+	return nil
+}
+
+func (s *service) PreviewDelete(path string, limit int) (*domain.DeletePreview, error) {
+	cleaned := cleanPath(path)
+	if cleaned == "" {
+		return nil, domain.ErrRootDeletion
+	}
+	if s.isHidden(cleaned) {
+		return nil, domain.ErrNotFound
+	}
+
+	preview := &domain.DeletePreview{Path: cleaned, Entries: []domain.FileInfo{}}
+	err := s.repo.Walk(cleaned, nil, func(info domain.FileInfo) error {
+		if info.IsDir {
+			preview.Folders++
+		} else {
+			preview.Files++
+			preview.TotalSize += info.Size
+		}
+		if len(preview.Entries) < limit {
+			preview.Entries = append(preview.Entries, info)
+		} else {
+			preview.Truncated = true
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, domain.ErrWalkIncomplete) {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// Move moves source to destination and returns the resulting path. An
+// existing directory destination receives the source under its base name.
+func (s *service) Move(source, destination string) (string, error) {
+	source = cleanPath(source)
+	destination = cleanPath(destination)
+	if source == "" || destination == "" {
+		return "", domain.ErrInvalidPath
+	}
+
+	if isDir, err := s.repo.IsDirectory(destination); err == nil && isDir {
+		destination = path.Join(destination, path.Base(source))
+	}
+	// Hidden folders hold internal data that must neither leave nor receive entries
+	if s.isHidden(source) || s.isHidden(destination) {
+		return "", domain.ErrNotFound
+	}
+
+	if destination == source {
+		return "", domain.ErrExists
+	}
+	if strings.HasPrefix(destination, source+"/") {
+		return "", domain.ErrMoveIntoSelf
+	}
+	if err := s.checkTreeDepth(source, destination); err != nil {
+		return "", err
+	}
+	if err := s.checkName(source, destination); err != nil {
+		return "", err
+	}
+
+	if err := s.repo.Move(source, destination); err != nil {
+		return "", err
+	}
+	s.changed(source, destination)
+
+	// The move itself succeeded; a stale index only affects share lookups
+	s.index.Remove(destination)
+	s.index.Rename(source, destination)
+	s.publish(domain.EventMoved, destination, source)
+
+	return destination, nil
+}
+
+// Copy copies source to destination and returns the resulting path. Like
+// Move, an existing directory destination receives the source under its base name.
+func (s *service) Copy(source, destination string) (string, error) {
+	source = cleanPath(source)
+	destination = cleanPath(destination)
+	if source == "" || destination == "" {
+		return "", domain.ErrInvalidPath
+	}
+
+	if isDir, err := s.repo.IsDirectory(destination); err == nil && isDir {
+		destination = path.Join(destination, path.Base(source))
+	}
+	// Hidden folders hold internal data that must neither leave nor receive entries
+	if s.isHidden(source) || s.isHidden(destination) {
+		return "", domain.ErrNotFound
+	}
+
+	if destination == source {
+		return "", domain.ErrExists
+	}
+	if strings.HasPrefix(destination, source+"/") {
+		return "", domain.ErrMoveIntoSelf
+	}
+	if err := s.checkTreeDepth(source, destination); err != nil {
+		return "", err
+	}
+	if err := s.checkName(source, destination); err != nil {
+		return "", err
+	}
+
+	if err := s.repo.Copy(source, destination); err != nil {
+		return "", err
+	}
+	s.changed(destination)
+	s.publish(domain.EventCreated, destination, "")
+
+	return destination, nil
+}
+
+// Paste moves or copies each source into the destination folder, resolving
+// name collisions with policy. Failures are reported per item; only an
+// unusable destination fails the whole call.
+func (s *service) Paste(operation string, sources []string, destination string, policy domain.ConflictPolicy) ([]domain.PasteResult, error) {
+	if operation != domain.PasteMove && operation != domain.PasteCopy {
+		return nil, fmt.Errorf("unknown paste operation %q", operation)
+	}
+	destination = cleanPath(destination)
+	if s.isHidden(destination) {
+		return nil, domain.ErrNotFound
+	}
+	isDir, err := s.repo.IsDirectory(destination)
+	if err != nil {
+		return nil, domain.ErrNotFound
+	}
+	if !isDir {
+		return nil, domain.ErrNotDirectory
+	}
+
+	results := make([]domain.PasteResult, 0, len(sources))
+	for _, source := range sources {
+		results = append(results, s.pasteOne(operation, cleanPath(source), destination, policy))
+	}
+	return results, nil
+}
+
+func (s *service) pasteOne(operation, source, destination string, policy domain.ConflictPolicy) domain.PasteResult {
+	result := domain.PasteResult{Source: source, Status: domain.PasteStatusFailed}
+	if source == "" || s.isHidden(source) {
+		result.Error = domain.ErrInvalidPath.Error()
+		return result
+	}
+	if exists, err := s.repo.Exists(source); err != nil || !exists {
+		result.Error = domain.ErrNotFound.Error()
+		return result
+	}
+
+	target := joinPath(destination, path.Base(source))
+
+	// Moving an item onto itself is a no-op; copying it onto itself needs a new name
+	if target == source && (operation == domain.PasteMove || policy != domain.ConflictRename) {
+		result.Status = domain.PasteStatusSkipped
+		result.Path = target
+		return result
+	}
+	if strings.HasPrefix(target, source+"/") {
+		result.Error = domain.ErrMoveIntoSelf.Error()
+		return result
+	}
+
+	// The existing entry is only set aside, so a failed paste can put it back
+	var aside string
+	if exists, _ := s.repo.Exists(target); exists {
+		switch policy {
+		case domain.ConflictOverwrite:
+			// Replacing a folder that contains the source would delete the source too
+			if strings.HasPrefix(source, target+"/") {
+				result.Error = domain.ErrExists.Error()
+				return result
+			}
+			// Fail before anything is set aside when the paste can't succeed
+			if err := s.checkTreeDepth(source, target); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			aside = joinPath(destination, fmt.Sprintf(".%s.replaced-%s", path.Base(target), strconv.FormatInt(time.Now().UnixNano(), 36)))
+			if err := s.repo.Move(target, aside); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			s.changed(target, aside)
+			s.publish(domain.EventDeleted, target, "")
+		case domain.ConflictRename:
+			renamed, err := s.freeName(destination, path.Base(source))
+			if err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			target = renamed
+		default:
+			result.Status = domain.PasteStatusSkipped
+			result.Error = domain.ErrExists.Error()
+			return result
+		}
+	}
+
+	var placed string
+	var err error
+	if operation == domain.PasteMove {
+		placed, err = s.Move(source, target)
+	} else {
+		placed, err = s.Copy(source, target)
+	}
+	if err != nil {
+		if aside != "" {
+			s.restoreAside(aside, target)
+		}
+		result.Error = err.Error()
+		return result
+	}
+	if aside != "" {
+		if operation == domain.PasteCopy {
+			// IDs of the replaced entry must not resolve to the copy
+			s.index.Remove(placed)
+		}
+		s.repo.Delete(aside)
+		s.changed(aside)
+	}
+	result.Status = domain.PasteStatusDone
+	result.Path = placed
+	return result
+}
+
+// restoreAside puts an entry set aside by an overwriting paste back at
+// target after the paste failed
+func (s *service) restoreAside(aside, target string) {
+	if err := s.repo.Move(aside, target); err != nil {
+		log.Printf("paste: could not restore %s from %s: %v", target, aside, err)
+		return
+	}
+	s.changed(aside, target)
+	s.publish(domain.EventCreated, target, "")
+}
+
+// freeName finds an unused "name (n).ext" in dir, matching how uploads are renamed
+func (s *service) freeName(dir, name string) (string, error) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := joinPath(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+		if exists, err := s.repo.Exists(candidate); err == nil && !exists {
+			return candidate, nil
+		}
+	}
+	return "", domain.ErrExists
+}
+
+// cleanPath normalizes a client path to slash-separated form without a
+// leading slash; paths escaping the root collapse to ""
+func cleanPath(p string) string {
+	cleaned := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if cleaned == "." {
+		return ""
+	}
+	return cleaned
 }
 
 func (s *service) GetStats() (*domain.StorageStats, error) {
-	return s.repo.GetStats(hiddenPaths)
+	return s.repo.GetStats(s.hidden)
+}
+
+func (s *service) FilesByType(ext string, offset, limit int) (*domain.TypeFiles, error) {
+	result := &domain.TypeFiles{Ext: ext, Files: []domain.FileInfo{}}
+	var matches []domain.FileInfo
+	err := s.repo.Walk("", s.hidden, func(info domain.FileInfo) error {
+		if info.IsDir || domain.TypeKey(info.Name) != ext {
+			return nil
+		}
+		result.TotalSize += info.Size
+		matches = append(matches, info)
+		return nil
+	})
+	if err != nil && !errors.Is(err, domain.ErrWalkIncomplete) {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Size != matches[j].Size {
+			return matches[i].Size > matches[j].Size
+		}
+		return matches[i].Path < matches[j].Path
+	})
+	result.Total = len(matches)
+	if offset >= 0 && offset < len(matches) {
+		result.Files = matches[offset : offset+min(limit, len(matches)-offset)]
+	}
+	return result, nil
+}
+
+// WriteTar streams a directory as a tar archive, gzipped when compress is
+// set. Entries sit under the directory's own name and hidden paths are left out.
+func (s *service) WriteTar(dir string, w io.Writer, compress bool) error {
+	cleaned := cleanPath(dir)
+	if s.isHidden(cleaned) {
+		return domain.ErrNotFound
+	}
+
+	prefix := ""
+	if cleaned != "" {
+		prefix = path.Base(cleaned)
+	}
+
+	if !compress {
+		return s.repo.WriteTar(cleaned, prefix, w, s.hidden)
+	}
+	gz := gzip.NewWriter(w)
+	if err := s.repo.WriteTar(cleaned, prefix, gz, s.hidden); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (s *service) Walk(path string, fn func(info domain.FileInfo) error) error {
+	cleaned := cleanPath(path)
+	if s.isHidden(cleaned) {
+		return domain.ErrNotFound
+	}
+	return s.repo.Walk(cleaned, s.hidden, fn)
+}
+
+func (s *service) Touch(path string, modTime time.Time, recursive bool) error {
+	// "/" and "." clean to the storage root, which a recursive touch would
+	// rewrite in full, internal folders included
+	cleaned := cleanPath(path)
+	if cleaned == "" {
+		return domain.ErrInvalidPath
+	}
+	if s.isHidden(cleaned) {
+		return domain.ErrNotFound
+	}
+	defer s.changed(cleaned)
+	return s.repo.SetModTime(cleaned, modTime, recursive)
+}
+
+func (s *service) CreateUpload(userID, path, filename string, length int64) (*domain.PendingUpload, error) {
+	path = cleanPath(path)
+	filename = cleanPath(filename)
+	if filename == "" || strings.Contains(filename, "/") {
+		return nil, domain.ErrInvalidPath
+	}
+	filename = s.names.Sanitize(filename)
+	if s.isHidden(joinPath(path, filename)) {
+		return nil, domain.ErrInvalidPath
+	}
+	if err := s.checkDepth(path); err != nil {
+		return nil, err
+	}
+	if err := s.checkTargetDir(path); err != nil {
+		return nil, err
+	}
+
+	// Fail early rather than after the whole file has been sent
+	if exists, err := s.repo.Exists(joinPath(path, filename)); err == nil && exists {
+		return nil, domain.ErrExists
+	}
+
+	upload := &domain.PendingUpload{
+		UserID:    userID,
+		Path:      path,
+		Filename:  filename,
+		Length:    length,
+		CreatedAt: time.Now(),
+	}
+	if err := s.uploads.Create(upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (s *service) GetUpload(id string) (*domain.PendingUpload, error) {
+	return s.uploads.Get(id)
+}
+
+// AppendUpload writes data at offset. Once the last byte arrives the file is
+// moved into storage and its path returned; a name taken in the meantime is
+// resolved by renaming so the transferred data is never dropped.
+func (s *service) AppendUpload(id string, offset int64, data io.Reader) (*domain.PendingUpload, string, error) {
+	newOffset, err := s.uploads.Append(id, offset, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	upload, err := s.uploads.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	upload.Offset = newOffset
+	if !upload.IsComplete() {
+		return upload, "", nil
+	}
+
+	finalPath, err := s.uploads.Complete(id, domain.ConflictRename)
+	if err != nil {
+		return nil, "", err
+	}
+	s.changed(finalPath)
+	s.publish(domain.EventCreated, finalPath, "")
+	return upload, finalPath, nil
+}
+
+// joinPath joins client paths, treating "" as the storage root
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
 }
@@ -0,0 +1,650 @@
+package file
+
+import (
+	"errors"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// memIndex is an in-memory IDIndex
+type memIndex struct {
+	mu    sync.Mutex
+	paths map[string]string // id -> path
+	next  int
+}
+
+func newMemIndex() *memIndex {
+	return &memIndex{paths: map[string]string{}}
+}
+
+func (m *memIndex) GetOrCreate(p string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, existing := range m.paths {
+		if existing == p {
+			return id, nil
+		}
+	}
+	m.next++
+	id := strings.Repeat("i", m.next)
+	m.paths[id] = p
+	return id, nil
+}
+
+func (m *memIndex) Resolve(id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.paths[id]; ok {
+		return p, nil
+	}
+	return "", domain.ErrNotFound
+}
+
+func (m *memIndex) Rename(oldPath, newPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, p := range m.paths {
+		if p == oldPath {
+			m.paths[id] = newPath
+		} else if strings.HasPrefix(p, oldPath+"/") {
+			m.paths[id] = newPath + p[len(oldPath):]
+		}
+	}
+	return nil
+}
+
+func (m *memIndex) Remove(p string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, existing := range m.paths {
+		if existing == p || strings.HasPrefix(existing, p+"/") {
+			delete(m.paths, id)
+		}
+	}
+	return nil
+}
+
+func (m *memIndex) Prune(_ time.Time, keep func(path string) bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pruned := 0
+	for id, p := range m.paths {
+		if !keep(p) {
+			delete(m.paths, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// newTestService returns a service over a temporary storage folder holding
+// files, a map of slash-separated paths to contents
+func newTestService(t *testing.T, files map[string]string) (*service, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	repo := repository.NewFilesystemRepository(dir, nil, false)
+	svc := NewService(repo, newMemIndex(), nil, repository.NewUploadStore(dir, repo, nil, 0), ListingCacheConfig{}, 0, nil, domain.NameSanitizer{}, nil, nil)
+	return svc.(*service), dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func exists(dir, p string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(p)))
+	return err == nil
+}
+
+func TestHiddenPathsCannotBeChanged(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func(s *service) error
+	}{
+		{"delete hidden folder", func(s *service) error { return s.Delete(".quarantine") }},
+		{"delete inside hidden folder", func(s *service) error { return s.Delete(".avatars/a.png") }},
+		{"preview delete of hidden folder", func(s *service) error { _, err := s.PreviewDelete(".avatars", 10); return err }},
+		{"move out of hidden folder", func(s *service) error { _, err := s.Move(".uploads/part", "visible"); return err }},
+		{"move into hidden folder", func(s *service) error { _, err := s.Move("doc.txt", ".avatars"); return err }},
+		{"move onto hidden path", func(s *service) error { _, err := s.Move("doc.txt", ".quarantine/doc.txt"); return err }},
+		{"copy out of hidden folder", func(s *service) error { _, err := s.Copy(".avatars/a.png", "a.png"); return err }},
+		{"copy into hidden folder", func(s *service) error { _, err := s.Copy("doc.txt", ".avatars"); return err }},
+		{"hidden case-insensitively", func(s *service) error { return s.Delete(".AVATARS") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestService(t, map[string]string{
+				"doc.txt":        "doc",
+				".avatars/a.png": "png",
+				".uploads/part":  "partial",
+			})
+			if err := tt.op(s); !errors.Is(err, domain.ErrNotFound) {
+				t.Fatalf("got %v, want ErrNotFound", err)
+			}
+			for _, p := range []string{"doc.txt", ".avatars/a.png", ".uploads/part"} {
+				if !exists(dir, p) {
+					t.Errorf("%s was changed", p)
+				}
+			}
+			for _, p := range []string{"visible", "a.png", ".avatars/doc.txt", ".quarantine/doc.txt"} {
+				if exists(dir, p) {
+					t.Errorf("%s was created", p)
+				}
+			}
+		})
+	}
+}
+
+func TestHiddenPathsRejectNewEntries(t *testing.T) {
+	upload := func(dir, name string) func(s *service) error {
+		return func(s *service) error {
+			// Rejected before the file is opened, so no content is needed
+			_, err := s.UploadFiles(dir, []*multipart.FileHeader{{Filename: name}}, domain.ConflictOverwrite)
+			return err
+		}
+	}
+	tests := []struct {
+		name string
+		op   func(s *service) error
+	}{
+		{"upload into hidden folder", upload(".avatars", "a.png")},
+		{"upload into nested hidden folder", upload(".uploads/sub", "part")},
+		{"upload into hidden folder case-insensitively", upload(".Thumbnails", "x.jpg")},
+		{"mkdir hidden folder", func(s *service) error { return s.CreateFolder(".thumbnails") }},
+		{"mkdir inside hidden folder", func(s *service) error { return s.CreateFolder(".avatars/new") }},
+		{"mkdir hidden folder with slashes", func(s *service) error { return s.CreateFolder("/.thumbnails/") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestService(t, map[string]string{".avatars/a.png": "png"})
+			if err := tt.op(s); !errors.Is(err, domain.ErrInvalidPath) {
+				t.Fatalf("got %v, want ErrInvalidPath", err)
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, ".avatars", "a.png")); string(data) != "png" {
+				t.Errorf("avatar holds %q", data)
+			}
+			// .uploads and .quarantine are made by the upload store up front
+			for _, p := range []string{".avatars/new", ".uploads/sub", ".thumbnails", ".Thumbnails", ".quarantine/.keep"} {
+				if exists(dir, p) {
+					t.Errorf("%s was created", p)
+				}
+			}
+		})
+	}
+}
+
+func TestConfiguredHiddenPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"doc.txt":                 "doc",
+		".git/config":             "[core]",
+		".avatars/a.png":          "png",
+		"projects/app.go":         "package app",
+		"projects/.cache/obj":     "cached",
+		"projects/.cacheless/obj": "kept",
+	})
+	repo := repository.NewFilesystemRepository(dir, nil, false)
+	// Listing a built-in path again mustn't be read as un-hiding it
+	s := NewService(repo, newMemIndex(), nil, nil, ListingCacheConfig{}, 0, []string{".GIT", "/projects/.cache/", ".avatars", ""}, domain.NameSanitizer{}, nil, nil)
+
+	listings := []struct {
+		dir  string
+		want []string
+	}{
+		{"", []string{"projects", "doc.txt"}},
+		{"projects", []string{".cacheless", "app.go"}},
+	}
+	for _, tt := range listings {
+		files, err := s.ListFiles(tt.dir)
+		if err != nil {
+			t.Fatalf("list %q: %v", tt.dir, err)
+		}
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("list %q: got %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+
+	for _, p := range []string{".git", ".git/config", ".Avatars/a.png", "projects/.cache/obj", ".quarantine", ".thumbnails"} {
+		if _, err := s.Stat(p); !errors.Is(err, domain.ErrNotFound) {
+			t.Errorf("stat %s: got %v, want ErrNotFound", p, err)
+		}
+		if _, err := s.ListFiles(p); err == nil {
+			t.Errorf("list %s succeeded", p)
+		}
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalFiles != 3 || stats.TotalSize != int64(len("doc")+len("package app")+len("kept")) {
+		t.Errorf("stats counted %d files, %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+}
+
+func TestMergeHiddenPaths(t *testing.T) {
+	tests := []struct {
+		configured []string
+		want       []string
+	}{
+		{nil, builtinHiddenPaths},
+		{[]string{".git"}, append(append([]string(nil), builtinHiddenPaths...), ".git")},
+		{[]string{".AVATARS", "/.uploads/", ""}, builtinHiddenPaths},
+		{[]string{"a/../.cache/"}, append(append([]string(nil), builtinHiddenPaths...), ".cache")},
+	}
+	for _, tt := range tests {
+		if got := mergeHiddenPaths(tt.configured); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: got %v, want %v", tt.configured, got, tt.want)
+		}
+	}
+}
+
+func TestVisiblePathsCanBeChanged(t *testing.T) {
+	s, dir := newTestService(t, map[string]string{"a/doc.txt": "doc", "b/keep": ""})
+	if _, err := s.Copy("a/doc.txt", "b"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if _, err := s.Move("a", "c"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if err := s.Delete("b/doc.txt"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if !exists(dir, "c/doc.txt") || exists(dir, "a") || exists(dir, "b/doc.txt") {
+		t.Fatal("operations were not applied")
+	}
+}
+
+func TestMoveAndCopyRespectDepthLimit(t *testing.T) {
+	files := map[string]string{
+		"a/b/c/file":  "",
+		"tree/x/file": "", // tree holds one level of folders
+		"top.txt":     "",
+	}
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		wantErr     error
+	}{
+		{"file into deepest allowed folder", "top.txt", "a/b/c", nil},
+		{"folder whose child lands at the limit", "tree", "a", nil},
+		{"folder whose child lands past the limit", "tree", "a/b", domain.ErrPathTooDeep},
+		{"folder landing past the limit", "tree", "a/b/c/tree2", domain.ErrPathTooDeep},
+		{"file to a new path at the limit", "top.txt", "a/b/c/top2.txt", nil},
+	}
+	for _, tt := range tests {
+		for _, op := range []string{"move", "copy"} {
+			t.Run(op+" "+tt.name, func(t *testing.T) {
+				s, dir := newTestService(t, files)
+				s.maxDepth = 3
+				var err error
+				if op == "move" {
+					_, err = s.Move(tt.source, tt.destination)
+				} else {
+					_, err = s.Copy(tt.source, tt.destination)
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil && !exists(dir, tt.source) {
+					t.Fatal("source was moved despite the error")
+				}
+			})
+		}
+	}
+}
+
+// failingCopies is a repository whose copies always fail
+type failingCopies struct {
+	domain.Repository
+}
+
+func (failingCopies) Copy(source, destination string) error {
+	return errors.New("disk full")
+}
+
+func TestPasteOverwrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		operation  string
+		source     string
+		failCopy   bool
+		maxDepth   int
+		wantStatus string
+		want       map[string]string // Path -> content expected afterwards
+	}{
+		{"move replaces file", domain.PasteMove, "src/a.txt", false, 0, domain.PasteStatusDone, map[string]string{"dst/a.txt": "new"}},
+		{"copy replaces file", domain.PasteCopy, "src/a.txt", false, 0, domain.PasteStatusDone, map[string]string{"dst/a.txt": "new", "src/a.txt": "new"}},
+		{"move replaces folder", domain.PasteMove, "src/dir", false, 0, domain.PasteStatusDone, map[string]string{"dst/dir/new.txt": "new"}},
+		{"failed copy keeps the original", domain.PasteCopy, "src/a.txt", true, 0, domain.PasteStatusFailed, map[string]string{"dst/a.txt": "old"}},
+		{"failed folder copy keeps the original", domain.PasteCopy, "src/dir", true, 0, domain.PasteStatusFailed, map[string]string{"dst/dir/old.txt": "old"}},
+		{"too deep keeps the original", domain.PasteMove, "src/dir", false, 2, domain.PasteStatusFailed, map[string]string{"dst/dir/old.txt": "old", "src/dir/sub/new.txt": "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestService(t, map[string]string{
+				"src/a.txt":           "new",
+				"src/dir/new.txt":     "new",
+				"src/dir/sub/new.txt": "new",
+				"dst/a.txt":           "old",
+				"dst/dir/old.txt":     "old",
+			})
+			s.maxDepth = tt.maxDepth
+			if tt.failCopy {
+				s.repo = failingCopies{s.repo}
+			}
+			results, err := s.Paste(tt.operation, []string{tt.source}, "dst", domain.ConflictOverwrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[0].Status != tt.wantStatus {
+				t.Fatalf("status %q (%s), want %q", results[0].Status, results[0].Error, tt.wantStatus)
+			}
+			for p, content := range tt.want {
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+				if err != nil || string(data) != content {
+					t.Errorf("%s holds %q, %v; want %q", p, data, err, content)
+				}
+			}
+			entries, _ := os.ReadDir(filepath.Join(dir, "dst"))
+			if len(entries) != 2 {
+				t.Errorf("dst holds %d entries, want 2", len(entries))
+			}
+		})
+	}
+}
+
+// extensionCheck rejects names ending in ext
+type extensionCheck string
+
+func (c extensionCheck) Scan(p string) error {
+	if strings.HasSuffix(p, string(c)) {
+		return errors.New("blocked")
+	}
+	return nil
+}
+
+func TestMoveAndCopyCheckNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		wantErr     error
+	}{
+		{"rename to a blocked extension", "a.txt", "a.exe", domain.ErrScanRejected},
+		{"rename keeping the extension", "a.txt", "b.txt", nil},
+		{"into a folder", "a.txt", "dir", nil},
+		{"folder with a blocked-looking name", "dir", "dir.exe", nil},
+	}
+	for _, tt := range tests {
+		for _, op := range []string{"move", "copy"} {
+			t.Run(op+" "+tt.name, func(t *testing.T) {
+				s, dir := newTestService(t, map[string]string{"a.txt": "a", "dir/keep": ""})
+				s.nameCheck = extensionCheck(".exe")
+				var err error
+				if op == "move" {
+					_, err = s.Move(tt.source, tt.destination)
+				} else {
+					_, err = s.Copy(tt.source, tt.destination)
+				}
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr != nil && exists(dir, tt.destination) {
+					t.Fatal("blocked name was created")
+				}
+			})
+		}
+	}
+}
+
+func TestFilesByTypeOffsets(t *testing.T) {
+	s, _ := newTestService(t, map[string]string{"a.jpg": "aa", "b.jpg": "b"})
+	for _, tt := range []struct {
+		offset, limit, want int
+	}{
+		{0, 10, 2},
+		{1, 10, 1},
+		{2, 10, 0},
+		{-5, 10, 0},
+		{1, int(^uint(0) >> 1), 1},
+	} {
+		result, err := s.FilesByType(".jpg", tt.offset, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Files) != tt.want || result.Total != 2 {
+			t.Errorf("offset %d, limit %d: %d of %d files, want %d of 2", tt.offset, tt.limit, len(result.Files), result.Total, tt.want)
+		}
+	}
+}
+
+func TestTouchRefusesRootAndHiddenPaths(t *testing.T) {
+	old := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		path    string
+		wantErr error
+	}{
+		{"", domain.ErrInvalidPath},
+		{"/", domain.ErrInvalidPath},
+		{".", domain.ErrInvalidPath},
+		{"./", domain.ErrInvalidPath},
+		{".avatars", domain.ErrNotFound},
+		{"/.avatars/a.png", domain.ErrNotFound},
+		{"docs", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			s, dir := newTestService(t, map[string]string{"docs/a.txt": "a", ".avatars/a.png": "png"})
+			if err := s.Touch(tt.path, old, true); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			info, err := os.Stat(filepath.Join(dir, ".avatars", "a.png"))
+			if err != nil || info.ModTime().Equal(old) {
+				t.Fatal("hidden file was touched")
+			}
+			if tt.wantErr == nil {
+				if info, _ := os.Stat(filepath.Join(dir, "docs", "a.txt")); !info.ModTime().Equal(old) {
+					t.Fatal("docs/a.txt was not touched")
+				}
+			}
+		})
+	}
+}
+
+func TestAttachDirSizes(t *testing.T) {
+	s, _ := newTestService(t, map[string]string{
+		"top.txt":         "12",
+		"a/one.txt":       "123",
+		"a/b/two.txt":     "1234",
+		"a/b/c/three.txt": "12345",
+		"empty/.keep":     "",
+	})
+	sizes := func() map[string]int64 {
+		t.Helper()
+		files, err := s.ListFiles("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AttachDirSizes(files)
+		got := make(map[string]int64)
+		for _, f := range files {
+			got[f.Name] = f.Size
+		}
+		return got
+	}
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   map[string]int64
+	}{
+		{"nested folders add up", nil, map[string]int64{"top.txt": 2, "a": 12, "empty": 0}},
+		{"cached", nil, map[string]int64{"top.txt": 2, "a": 12, "empty": 0}},
+		// Leaves a's own modtime alone, so only eviction catches it
+		{"deep delete", func() error { return s.Delete("a/b/c/three.txt") }, map[string]int64{"top.txt": 2, "a": 7, "empty": 0}},
+		{"move between folders", func() error { _, err := s.Move("a/b/two.txt", "empty/two.txt"); return err }, map[string]int64{"top.txt": 2, "a": 3, "empty": 4}},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			if err := step.change(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		got := sizes()
+		for name, want := range step.want {
+			if got[name] != want {
+				t.Fatalf("%s: %s has size %d, want %d", step.name, name, got[name], want)
+			}
+		}
+	}
+}
+
+// storedTree lists every entry below dir by slash-separated path
+func storedTree(t *testing.T, dir string) map[string]os.FileInfo {
+	t.Helper()
+	tree := make(map[string]os.FileInfo)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		tree[filepath.ToSlash(rel)] = info
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestPreviewDeleteMatchesDelete(t *testing.T) {
+	files := map[string]string{
+		"docs/a.txt":        "aaaa",
+		"docs/sub/b.txt":    "bb",
+		"docs/sub/deep/c":   "c",
+		"docs-old/keep.txt": "keep",
+		"top.txt":           "top",
+	}
+	tests := []struct {
+		name          string
+		path          string
+		limit         int
+		wantTruncated bool
+	}{
+		{"folder", "docs", 100, false},
+		{"nested folder", "/docs/sub/", 100, false},
+		{"single file", "top.txt", 100, false},
+		{"capped list", "docs", 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, dir := newTestService(t, files)
+			before := storedTree(t, dir)
+
+			preview, err := svc.PreviewDelete(tt.path, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after := storedTree(t, dir); len(after) != len(before) {
+				t.Fatalf("dry run removed %d entries", len(before)-len(after))
+			}
+
+			if err := svc.Delete(tt.path); err != nil {
+				t.Fatal(err)
+			}
+			after := storedTree(t, dir)
+			var files, folders, size int64
+			removed := make(map[string]bool)
+			for p, info := range before {
+				if _, ok := after[p]; ok {
+					continue
+				}
+				removed[p] = true
+				if info.IsDir() {
+					folders++
+				} else {
+					files++
+					size += info.Size()
+				}
+			}
+
+			if preview.Files != files || preview.Folders != folders || preview.TotalSize != size {
+				t.Errorf("preview %d files, %d folders, %d bytes; delete removed %d, %d, %d", preview.Files, preview.Folders, preview.TotalSize, files, folders, size)
+			}
+			if preview.Truncated != tt.wantTruncated || len(preview.Entries) != min(len(removed), tt.limit) {
+				t.Errorf("%d entries listed, truncated %v", len(preview.Entries), preview.Truncated)
+			}
+			for _, e := range preview.Entries {
+				if !removed[e.Path] {
+					t.Errorf("listed %s, which wasn't deleted", e.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizedNameCollisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		upload    string
+		policy    domain.ConflictPolicy
+		wantErr   error
+		wantPath  string
+		wantNames []string
+	}{
+		{"control characters", "new\x07.txt", domain.ConflictReject, nil, "docs/new.txt", []string{"docs/a_b.txt", "docs/new.txt"}},
+		{"reserved name", "NUL.txt", domain.ConflictReject, nil, "docs/_NUL.txt", []string{"docs/_NUL.txt", "docs/a_b.txt"}},
+		{"collision rejected", "a:b.txt", domain.ConflictReject, domain.ErrExists, "", []string{"docs/a_b.txt"}},
+		{"collision renamed", "a:b.txt", domain.ConflictRename, nil, "docs/a_b (1).txt", []string{"docs/a_b (1).txt", "docs/a_b.txt"}},
+		{"collision overwritten", "a?b.txt", domain.ConflictOverwrite, nil, "docs/a_b.txt", []string{"docs/a_b.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, dir := newTestService(t, map[string]string{"docs/a_b.txt": "old"})
+			svc.names = domain.NameSanitizer{Mode: domain.NameSanitizePortable}
+
+			info, err := svc.UploadStream("docs", tt.upload, strings.NewReader("new"), tt.policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && info.Path != tt.wantPath {
+				t.Fatalf("stored at %q, want %q", info.Path, tt.wantPath)
+			}
+			var names []string
+			for p := range storedTree(t, dir) {
+				if strings.HasPrefix(p, "docs/") {
+					names = append(names, p)
+				}
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.wantNames) {
+				t.Fatalf("stored %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package file
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestDownloadSigner(t *testing.T) {
+	signer := NewDownloadSigner("secret")
+	expires := time.Now().Add(time.Hour)
+	sig := signer.Sign("docs/a.txt", expires)
+	past := time.Now().Add(-time.Second)
+	tampered := "A" + sig[1:]
+	if sig[0] == 'A' {
+		tampered = "B" + sig[1:]
+	}
+
+	tests := []struct {
+		name    string
+		signer  *DownloadSigner
+		path    string
+		expires int64
+		sig     string
+		want    error
+	}{
+		{"valid", signer, "docs/a.txt", expires.Unix(), sig, nil},
+		{"equivalent path", signer, "/docs//a.txt", expires.Unix(), sig, nil},
+		{"expired", signer, "docs/a.txt", past.Unix(), signer.Sign("docs/a.txt", past), domain.ErrLinkExpired},
+		{"extended expiry", signer, "docs/a.txt", expires.Unix() + 3600, sig, domain.ErrLinkInvalid},
+		{"other path", signer, "docs/b.txt", expires.Unix(), sig, domain.ErrLinkInvalid},
+		{"tampered signature", signer, "docs/a.txt", expires.Unix(), tampered, domain.ErrLinkInvalid},
+		{"other secret", NewDownloadSigner("other"), "docs/a.txt", expires.Unix(), sig, domain.ErrLinkInvalid},
+		{"random secret", NewDownloadSigner(""), "docs/a.txt", expires.Unix(), sig, domain.ErrLinkInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.signer.Verify(tt.path, tt.expires, tt.sig); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
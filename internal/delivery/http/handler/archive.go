@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// sendArchiveError writes the response for an error opening a zip file or
+// one of its entries
+func sendArchiveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		SendError(w, "File not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrEntryNotFound):
+		SendError(w, "Entry not found in the archive", http.StatusNotFound)
+	case errors.Is(err, domain.ErrIsDirectory):
+		SendError(w, "Cannot extract a folder", http.StatusBadRequest)
+	case errors.Is(err, domain.ErrNotArchive):
+		SendError(w, "Not a zip archive", http.StatusUnsupportedMediaType)
+	case errors.Is(err, domain.ErrArchiveTooLarge):
+		SendError(w, "Archive or entry exceeds the size limits", http.StatusUnprocessableEntity)
+	default:
+		SendError(w, "Failed to read archive", http.StatusInternalServerError)
+	}
+}
+
+// ArchiveList handles GET /api/files/archive/list?path= and returns the
+// entries of a zip file without extracting them
+func (h *FileHandler) ArchiveList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		SendError(w, "Path is required", http.StatusBadRequest)
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	listing, err := h.service.ListArchive(filePath)
+	release()
+	if err != nil {
+		sendArchiveError(w, err)
+		return
+	}
+
+	SendSuccess(w, "", listing)
+}
+
+// ArchiveExtractEntry handles GET /api/files/archive/extract-entry?path=&entry=
+// and streams one file out of a zip file as a download
+func (h *FileHandler) ArchiveExtractEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filePath, entryName := query.Get("path"), query.Get("entry")
+	if filePath == "" || entryName == "" {
+		SendError(w, "path and entry are required", http.StatusBadRequest)
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	rc, entry, err := h.service.OpenArchiveEntry(filePath, entryName)
+	if err != nil {
+		sendArchiveError(w, err)
+		return
+	}
+	defer rc.Close()
+
+	// The entry is inflated as it's sent, so a corrupt one can only cut the
+	// stream short
+	name := path.Base(entry.Name)
+	w.Header().Set("Content-Type", domain.ContentType(name))
+	w.Header().Set("Content-Disposition", ContentDisposition("attachment", name))
+	w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+	if _, err := io.Copy(w, rc); err != nil {
+		log.Printf("extracting %s from %s failed: %v", entry.Name, filePath, err)
+	}
+}
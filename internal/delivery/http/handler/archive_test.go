@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gomanager/internal/domain/user"
+)
+
+// zipOf returns a zip holding files, in order
+func zipOf(t *testing.T, files ...[2]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(f[1]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func newTestArchiveHandler(t *testing.T) *FileHandler {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"a.zip":    zipOf(t, [2]string{"docs/", ""}, [2]string{"docs/a.txt", "hello"}, [2]string{"b.txt", "hi"}),
+		"fake.zip": "plain text",
+		"docs/x":   "",
+	})
+	return h
+}
+
+func TestArchiveList(t *testing.T) {
+	h := newTestArchiveHandler(t)
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{"entries", "path=a.zip", http.StatusOK, []string{"docs/", "docs/a.txt", "b.txt"}},
+		{"missing path", "", http.StatusBadRequest, nil},
+		{"missing file", "path=nope.zip", http.StatusNotFound, nil},
+		{"not a zip", "path=fake.zip", http.StatusUnsupportedMediaType, nil},
+		{"folder", "path=docs", http.StatusUnsupportedMediaType, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(http.MethodGet, "/api/files/archive/list?"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.ArchiveList(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp struct {
+				Data struct {
+					Entries []struct{ Name string }
+				}
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Data.Entries) != len(tt.want) {
+				t.Fatalf("entries %+v, want %v", resp.Data.Entries, tt.want)
+			}
+			for i, e := range resp.Data.Entries {
+				if e.Name != tt.want[i] {
+					t.Fatalf("entries %+v, want %v", resp.Data.Entries, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveExtractEntry(t *testing.T) {
+	h := newTestArchiveHandler(t)
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"nested entry", "path=a.zip&entry=docs/a.txt", http.StatusOK, "hello"},
+		{"top-level entry", "path=a.zip&entry=b.txt", http.StatusOK, "hi"},
+		{"missing entry", "path=a.zip&entry=c.txt", http.StatusNotFound, ""},
+		{"folder entry", "path=a.zip&entry=docs/", http.StatusBadRequest, ""},
+		{"missing file", "path=nope.zip&entry=b.txt", http.StatusNotFound, ""},
+		{"not a zip", "path=fake.zip&entry=b.txt", http.StatusUnsupportedMediaType, ""},
+		{"no entry", "path=a.zip", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(http.MethodGet, "/api/files/archive/extract-entry?"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.ArchiveExtractEntry(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body %q, want %q", w.Body, tt.body)
+			}
+			if got := w.Header().Get("Content-Disposition"); got == "" {
+				t.Error("no Content-Disposition")
+			}
+		})
+	}
+}
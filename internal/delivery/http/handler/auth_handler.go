@@ -3,8 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"gomanager/internal/application/auth"
 	domain "gomanager/internal/domain/auth"
@@ -13,11 +15,19 @@ import (
 
 type AuthHandler struct {
 	service auth.Service
+	cookies SessionCookieConfig
+	captcha domain.CaptchaVerifier
 }
 
-func NewAuthHandler(service auth.Service) *AuthHandler {
+// NewAuthHandler creates an auth handler; a nil captcha skips CAPTCHA checks
+func NewAuthHandler(service auth.Service, cookies SessionCookieConfig, captcha domain.CaptchaVerifier) *AuthHandler {
+	if captcha == nil {
+		captcha = domain.NoCaptcha{}
+	}
 	return &AuthHandler{
 		service: service,
+		cookies: cookies,
+		captcha: captcha,
 	}
 }
 
@@ -39,6 +49,17 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Bots are turned away before any database work
+	if err := h.captcha.Verify(req.CaptchaToken, ClientIP(r)); err != nil {
+		if errors.Is(err, domain.ErrCaptchaFailed) {
+			SendError(w, "CAPTCHA verification failed", http.StatusBadRequest)
+			return
+		}
+		log.Printf("captcha verification error: %v", err)
+		SendError(w, "CAPTCHA verification is unavailable, try again shortly", http.StatusServiceUnavailable)
+		return
+	}
+
 	newUser, err := h.service.Register(req)
 	if err != nil {
 		switch {
@@ -50,16 +71,22 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 			SendError(w, "Username must be at least 3 characters", http.StatusBadRequest)
 		case errors.Is(err, user.ErrInvalidPassword):
 			SendError(w, "Password must be at least 6 characters", http.StatusBadRequest)
+		case errors.Is(err, user.ErrRegistrationClosed):
+			SendError(w, "Registration is disabled", http.StatusForbidden)
+		case errors.Is(err, user.ErrInviteRequired):
+			SendError(w, "An invite code is required to register", http.StatusForbidden)
+		case errors.Is(err, user.ErrInvalidInvite):
+			SendError(w, "Invite code is invalid or already used", http.StatusForbidden)
 		default:
 			SendError(w, "Failed to register user", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	SendSuccess(w, "User registered successfully", newUser.ToResponse())
+	SendSuccess(w, "User registered successfully", userResponse(r, newUser))
 }
 
-// Login handles POST /api/auth/login
+// Login handles POST /api/auth/login and returns the token along with the user
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -77,8 +104,19 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.service.Login(req)
+	req.ClientIP = ClientIP(r)
+	resp, u, err := h.service.LoginWithUser(req)
 	if err != nil {
+		var locked *auth.LockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(locked.Seconds()))
+			SendJSON(w, http.StatusTooManyRequests, Response{
+				Success: false,
+				Message: "Account temporarily locked after repeated failed logins",
+				Data:    map[string]int{"retryAfter": locked.Seconds()},
+			})
+			return
+		}
 		if errors.Is(err, user.ErrInvalidCredentials) {
 			SendError(w, "Invalid email or password", http.StatusUnauthorized)
 			return
@@ -87,7 +125,23 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	SendSuccess(w, "Login successful", resp)
+	if err := SetSessionCookies(w, h.cookies, resp.Token, time.Unix(resp.ExpiresAt, 0)); err != nil {
+		SendError(w, "Failed to login", http.StatusInternalServerError)
+		return
+	}
+	// An HttpOnly cookie is pointless if scripts can read the token anyway
+	if h.cookies.Enabled {
+		resp = &domain.LoginResponse{ExpiresAt: resp.ExpiresAt}
+	}
+
+	SendSuccess(w, "Login successful", loginResponse{LoginResponse: resp, User: userResponse(r, u)})
+}
+
+// loginResponse adds the signed-in user to the token so clients don't need
+// a follow-up call to /api/auth/me
+type loginResponse struct {
+	*domain.LoginResponse
+	User user.UserResponse `json:"user"`
 }
 
 // Logout handles POST /api/auth/logout
@@ -97,7 +151,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
+	token, _ := ExtractToken(r)
 	if token == "" {
 		SendError(w, "Authorization required", http.StatusUnauthorized)
 		return
@@ -107,6 +161,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		SendError(w, "Failed to logout", http.StatusInternalServerError)
 		return
 	}
+	ClearSessionCookies(w, h.cookies)
 
 	SendSuccess(w, "Logged out successfully", nil)
 }
@@ -118,30 +173,75 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := extractToken(r)
+	token, _ := ExtractToken(r)
 	if token == "" {
 		SendError(w, "Authorization required", http.StatusUnauthorized)
 		return
 	}
 
 	u, err := h.service.ValidateToken(token)
+	if err == nil {
+		u, err = h.service.LoadUser(u)
+	}
 	if err != nil {
 		SendError(w, "Invalid or expired token", http.StatusUnauthorized)
 		return
 	}
 
-	SendSuccess(w, "", u.ToResponse())
+	SendSuccess(w, "", userResponse(r, u))
 }
 
-func extractToken(r *http.Request) string {
-	authHeader := r.Header.Get("Authorization")
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		return strings.TrimPrefix(authHeader, "Bearer ")
+// TokenStatus handles GET /api/auth/token/status. Invalid and expired tokens
+// get a 200 with valid=false so clients can branch without error handling.
+func (h *AuthHandler) TokenStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	if token := r.URL.Query().Get("token"); token != "" {
-		return token
+	token, _ := ExtractToken(r)
+	if token == "" {
+		SendSuccess(w, "", domain.TokenStatus{})
+		return
 	}
 
-	return ""
+	status, err := h.service.TokenStatus(token)
+	if err != nil {
+		SendError(w, "Failed to check token", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", status)
+}
+
+// Invites handles GET and POST /api/admin/invites: list all invite codes or
+// create a new single-use one
+func (h *AuthHandler) Invites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		invites, err := h.service.ListInvites()
+		if err != nil {
+			SendError(w, "Failed to retrieve invites", http.StatusInternalServerError)
+			return
+		}
+		SendSuccess(w, "", invites)
+	case http.MethodPost:
+		u := GetUserFromContext(r.Context())
+		if u == nil {
+			SendError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		invite, err := h.service.CreateInvite(u.ID)
+		if err != nil {
+			SendError(w, "Failed to create invite", http.StatusInternalServerError)
+			return
+		}
+		SendJSON(w, http.StatusCreated, Response{
+			Success: true,
+			Message: "Invite created",
+			Data:    invite,
+		})
+	default:
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
 }
@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/application/auth"
+	domain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// stubCaptcha answers every verification with err
+type stubCaptcha struct{ err error }
+
+func (s stubCaptcha) Verify(token, ip string) error { return s.err }
+
+// registerRecorder is an auth service that only records registrations
+type registerRecorder struct {
+	auth.Service
+	calls int
+}
+
+func (s *registerRecorder) Register(req domain.RegisterRequest) (*user.User, error) {
+	s.calls++
+	return &user.User{ID: "u1", Email: req.Email, Username: req.Username, Role: user.RoleUser}, nil
+}
+
+func TestRegisterCaptcha(t *testing.T) {
+	tests := []struct {
+		name       string
+		captcha    domain.CaptchaVerifier
+		body       string
+		status     int
+		registered bool
+	}{
+		{"no provider", nil, `{"email":"a@example.com","username":"alice","password":"secret1"}`, http.StatusOK, true},
+		{"accepted", stubCaptcha{}, `{"email":"a@example.com","username":"alice","password":"secret1","captchaToken":"tok"}`, http.StatusOK, true},
+		{"rejected", stubCaptcha{domain.ErrCaptchaFailed}, `{"email":"a@example.com","username":"alice","password":"secret1","captchaToken":"tok"}`, http.StatusBadRequest, false},
+		{"provider unreachable", stubCaptcha{errors.New("dial tcp: timeout")}, `{"email":"a@example.com","username":"alice","password":"secret1","captchaToken":"tok"}`, http.StatusServiceUnavailable, false},
+		{"missing fields checked first", stubCaptcha{domain.ErrCaptchaFailed}, `{"email":"a@example.com"}`, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &registerRecorder{}
+			h := NewAuthHandler(svc, SessionCookieConfig{}, tt.captcha)
+			w := httptest.NewRecorder()
+			h.Register(w, httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if registered := svc.calls > 0; registered != tt.registered {
+				t.Fatalf("registered = %v, want %v", registered, tt.registered)
+			}
+		})
+	}
+}
+
+// newTestLogin returns an auth handler over a fresh database holding the
+// user a@example.com with password secret1, and the service behind it
+func newTestLogin(t *testing.T, cookies SessionCookieConfig, expiry time.Duration) (*AuthHandler, auth.Service) {
+	t.Helper()
+	svc := newTestAuthService(t, newTestDB(t), expiry)
+	if _, err := svc.Register(domain.RegisterRequest{Email: "a@example.com", Username: "alice", Password: "secret1"}); err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthHandler(svc, cookies, nil), svc
+}
+
+// login posts the test user's credentials
+func login(h *AuthHandler, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(`{"email":"a@example.com","password":"`+password+`"}`)))
+	return w
+}
+
+func TestLoginSessionCookie(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookies SessionCookieConfig
+	}{
+		{"bearer mode", SessionCookieConfig{}},
+		{"cookie mode", SessionCookieConfig{Enabled: true, Secure: true, SameSite: http.SameSiteLaxMode}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, svc := newTestLogin(t, tt.cookies, time.Hour)
+			w := login(h, "secret1")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp struct {
+				Data map[string]json.RawMessage
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := resp.Data["expiresAt"]; !ok {
+				t.Error("response lacks expiresAt")
+			}
+
+			var token string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == SessionCookieName {
+					token = c.Value
+				}
+			}
+			rawToken, inBody := resp.Data["token"]
+			if tt.cookies.Enabled {
+				// Scripts mustn't be able to read what the HttpOnly cookie hides
+				if inBody {
+					t.Errorf("token in the body: %s", rawToken)
+				}
+			} else {
+				if token != "" {
+					t.Error("session cookie set in bearer mode")
+				}
+				json.Unmarshal(rawToken, &token)
+			}
+			if _, err := svc.ValidateToken(token); err != nil {
+				t.Errorf("issued token %q: %v", token, err)
+			}
+		})
+	}
+}
+
+func TestLoginIncludesUser(t *testing.T) {
+	for _, cookies := range []SessionCookieConfig{{}, {Enabled: true, SameSite: http.SameSiteLaxMode}} {
+		h, _ := newTestLogin(t, cookies, time.Hour)
+		w := login(h, "secret1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var resp struct {
+			Data struct {
+				ExpiresAt int64
+				User      *user.UserResponse
+			}
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		// The first user to register becomes the admin
+		if u := resp.Data.User; u == nil || u.ID == "" || u.Email != "a@example.com" || u.Username != "alice" || u.Role != user.RoleAdmin {
+			t.Errorf("cookies %v: user %+v", cookies.Enabled, u)
+		}
+		if resp.Data.ExpiresAt == 0 {
+			t.Errorf("cookies %v: the user replaced expiresAt: %s", cookies.Enabled, w.Body)
+		}
+		if strings.Contains(w.Body.String(), "password") {
+			t.Errorf("cookies %v: response leaks the password: %s", cookies.Enabled, w.Body)
+		}
+	}
+}
+
+func TestTokenStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiry    time.Duration
+		token     string // "" uses the token from logging in
+		wantValid bool
+	}{
+		{"valid token", time.Hour, "", true},
+		{"expired token", -time.Minute, "", false},
+		{"unknown token", time.Hour, "not-a-session", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestLogin(t, SessionCookieConfig{}, tt.expiry)
+			token := tt.token
+			if token == "" {
+				var resp struct{ Data struct{ Token string } }
+				w := login(h, "secret1")
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.Token == "" {
+					t.Fatalf("login: status %d: %s", w.Code, w.Body)
+				}
+				token = resp.Data.Token
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/auth/token/status", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			h.TokenStatus(w, r)
+			// Invalid tokens are a status, not an error
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp struct{ Data domain.TokenStatus }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			status := resp.Data
+			if status.Valid != tt.wantValid {
+				t.Fatalf("valid %v, want %v", status.Valid, tt.wantValid)
+			}
+			if !tt.wantValid {
+				if status.ExpiresAt != 0 || status.SecondsRemaining != 0 {
+					t.Errorf("invalid token reports a lifetime: %+v", status)
+				}
+				return
+			}
+			if remaining := time.Duration(status.SecondsRemaining) * time.Second; remaining <= 0 || remaining > tt.expiry {
+				t.Errorf("%v remaining, want at most %v", remaining, tt.expiry)
+			}
+			if until := time.Until(time.Unix(status.ExpiresAt, 0)); until < tt.expiry-time.Minute || until > tt.expiry {
+				t.Errorf("expires in %v, want %v", until, tt.expiry)
+			}
+		})
+	}
+}
+
+func TestRegistrationModes(t *testing.T) {
+	type step struct {
+		email  string
+		invite string // "new" to use a freshly created invite, "last" to reuse it
+		status int
+	}
+	tests := []struct {
+		name  string
+		reg   auth.RegistrationConfig
+		steps []step
+	}{
+		{"open", auth.RegistrationConfig{Enabled: true}, []step{
+			{"a@example.com", "", http.StatusOK},
+			{"b@example.com", "", http.StatusOK},
+		}},
+		{"disabled", auth.RegistrationConfig{Enabled: false}, []step{
+			{"a@example.com", "", http.StatusOK}, // The first account is the admin
+			{"b@example.com", "", http.StatusForbidden},
+			{"c@example.com", "new", http.StatusForbidden},
+		}},
+		{"invite only", auth.RegistrationConfig{Enabled: true, Mode: auth.RegistrationModeInvite}, []step{
+			{"a@example.com", "", http.StatusOK},
+			{"b@example.com", "", http.StatusForbidden},
+			{"b@example.com", "0123456789abcdef01234567", http.StatusForbidden},
+			{"b@example.com", "new", http.StatusOK},
+			{"c@example.com", "last", http.StatusForbidden},
+			{"a@example.com", "new", http.StatusConflict}, // A failed registration hands the code back
+			{"c@example.com", "last", http.StatusOK},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			hasher, _ := auth.NewPasswordHasher("")
+			tt.reg.Invites = repository.NewInviteRepository(db)
+			svc := auth.NewService(repository.NewUserRepository(db), repository.NewSessionRepository(db), time.Hour, hasher, auth.TokenConfig{}, auth.LockoutConfig{}, tt.reg)
+			h := NewAuthHandler(svc, SessionCookieConfig{}, nil)
+			admin := &user.User{ID: "admin", Role: user.RoleAdmin}
+
+			var code string
+			for i, st := range tt.steps {
+				switch st.invite {
+				case "new":
+					w := httptest.NewRecorder()
+					h.Invites(w, withUser(httptest.NewRequest(http.MethodPost, "/api/admin/invites", nil), admin))
+					var created struct{ Data domain.Invite }
+					if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusCreated {
+						t.Fatalf("create invite: status %d: %s", w.Code, w.Body)
+					}
+					code = created.Data.Code
+				case "last":
+				default:
+					code = st.invite
+				}
+
+				body, _ := json.Marshal(domain.RegisterRequest{Email: st.email, Username: strings.Split(st.email, "@")[0] + "user", Password: "secret1", InviteCode: code})
+				w := httptest.NewRecorder()
+				h.Register(w, httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(string(body))))
+				if w.Code != st.status {
+					t.Fatalf("step %d (%s): status %d, want %d: %s", i, st.email, w.Code, st.status, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestListInvites(t *testing.T) {
+	db := newTestDB(t)
+	hasher, _ := auth.NewPasswordHasher("")
+	invites := repository.NewInviteRepository(db)
+	svc := auth.NewService(repository.NewUserRepository(db), repository.NewSessionRepository(db), time.Hour, hasher, auth.TokenConfig{}, auth.LockoutConfig{}, auth.RegistrationConfig{Enabled: true, Mode: auth.RegistrationModeInvite, Invites: invites})
+	if _, err := svc.Register(domain.RegisterRequest{Email: "a@example.com", Username: "alice", Password: "secret1"}); err != nil {
+		t.Fatal(err)
+	}
+	used, err := svc.CreateInvite("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateInvite("admin"); err != nil {
+		t.Fatal(err)
+	}
+	newUser, err := svc.Register(domain.RegisterRequest{Email: "b@example.com", Username: "bob", Password: "secret1", InviteCode: used.Code})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	NewAuthHandler(svc, SessionCookieConfig{}, nil).Invites(w, withUser(httptest.NewRequest(http.MethodGet, "/api/admin/invites", nil), &user.User{ID: "admin", Role: user.RoleAdmin}))
+	var resp struct{ Data []domain.Invite }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("%d invites, want 2", len(resp.Data))
+	}
+	for _, invite := range resp.Data {
+		if wantUsed := invite.Code == used.Code; (invite.UsedAt != nil) != wantUsed || (invite.UsedBy == newUser.ID) != wantUsed {
+			t.Errorf("invite %s used by %q at %v", invite.Code, invite.UsedBy, invite.UsedAt)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxRemoteAvatarBytes caps how much of a remote picture is downloaded
+	maxRemoteAvatarBytes = 5 << 20
+	// remoteAvatarTimeout bounds a picture download during login
+	remoteAvatarTimeout = 10 * time.Second
+)
+
+var errAvatarHostNotAllowed = errors.New("avatar host not allowed")
+
+// remoteAvatarCache copies remote profile pictures into the avatar folder so
+// they are served like uploaded avatars instead of hot-linked
+type remoteAvatarCache struct {
+	dir    string
+	maxDim int
+	hosts  []string // Allowed hosts; subdomains match too
+	client *http.Client
+}
+
+// newRemoteAvatarCache stores pictures in dir, downloading only over HTTPS
+// from hosts (and their subdomains)
+func newRemoteAvatarCache(dir string, maxDim int, hosts []string) *remoteAvatarCache {
+	c := &remoteAvatarCache{dir: dir, maxDim: maxDim, hosts: hosts}
+	c.client = &http.Client{
+		Timeout: remoteAvatarTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !c.allowed(req.URL) {
+				return errAvatarHostNotAllowed
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+func (c *remoteAvatarCache) allowed(u *url.URL) bool {
+	if u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range c.hosts {
+		allowed = strings.ToLower(strings.TrimPrefix(allowed, "."))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// avatarURL returns the local URL of a cached copy of remoteURL, or
+// remoteURL itself when caching is off or the download fails
+func (c *remoteAvatarCache) avatarURL(remoteURL string) string {
+	if c == nil || remoteURL == "" {
+		return remoteURL
+	}
+	filename, err := c.fetch(remoteURL)
+	if err != nil {
+		log.Printf("avatar cache: keeping remote URL: %v", err)
+		return remoteURL
+	}
+	return "/api/user/avatar/" + filename
+}
+
+// fetch downloads, checks and re-encodes a remote picture as PNG, returning
+// the stored file name
+func (c *remoteAvatarCache) fetch(remoteURL string) (string, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return "", err
+	}
+	if !c.allowed(u) {
+		return "", fmt.Errorf("%w: %s", errAvatarHostNotAllowed, u.Host)
+	}
+
+	resp, err := c.client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: %s", u.Host, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteAvatarBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxRemoteAvatarBytes {
+		return "", errors.New("picture too large")
+	}
+
+	// Go through the same checks as uploads, taking the format from the content
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", errAvatarUnsupported
+	}
+	img, err := decodeAvatar(bytes.NewReader(data), "."+format)
+	if err != nil {
+		return "", err
+	}
+	img = fitAvatar(img, c.maxDim)
+
+	filename := uuid.New().String() + ".png"
+	filePath := filepath.Join(c.dir, filename)
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+	encodeErr := png.Encode(dst, img)
+	if closeErr := dst.Close(); encodeErr == nil {
+		encodeErr = closeErr
+	}
+	if encodeErr != nil {
+		os.Remove(filePath)
+		return "", encodeErr
+	}
+	return filename, nil
+}
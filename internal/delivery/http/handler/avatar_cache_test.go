@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"bytes"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// newTestAvatarHost returns a TLS server handing out pictures, and an avatar
+// cache over a temporary folder that trusts it
+func newTestAvatarHost(t *testing.T) (*httptest.Server, *remoteAvatarCache) {
+	t.Helper()
+	picture := encodedImage(t, "jpeg", 400, 200)
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.jpg":
+			w.Write(picture)
+		case "/text":
+			w.Write([]byte("not a picture"))
+		case "/huge":
+			w.Write(bytes.Repeat([]byte{0}, maxRemoteAvatarBytes+1))
+		case "/away":
+			http.Redirect(w, r, "https://evil.example/photo.jpg", http.StatusFound)
+		case "/back":
+			http.Redirect(w, r, srv.URL+"/photo.jpg", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newRemoteAvatarCache(t.TempDir(), 100, []string{"127.0.0.1"})
+	c.client.Transport = srv.Client().Transport
+	return srv, c
+}
+
+func TestRemoteAvatarCache(t *testing.T) {
+	srv, c := newTestAvatarHost(t)
+	tests := []struct {
+		name      string
+		url       string
+		wantLocal bool
+	}{
+		{"picture", srv.URL + "/photo.jpg", true},
+		{"allowed redirect", srv.URL + "/back", true},
+		{"not found", srv.URL + "/missing", false},
+		{"not a picture", srv.URL + "/text", false},
+		{"too large", srv.URL + "/huge", false},
+		{"redirect to another host", srv.URL + "/away", false},
+		{"plain http", strings.Replace(srv.URL, "https://", "http://", 1) + "/photo.jpg", false},
+		{"host not allowed", "https://evil.example/photo.jpg", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := os.ReadDir(c.dir)
+			got := c.avatarURL(tt.url)
+			after, _ := os.ReadDir(c.dir)
+			if !tt.wantLocal {
+				if got != tt.url || len(after) != len(before) {
+					t.Fatalf("got %q with %d new files; want the remote URL kept", got, len(after)-len(before))
+				}
+				return
+			}
+
+			filename, ok := strings.CutPrefix(got, "/api/user/avatar/")
+			if !ok || !strings.HasSuffix(filename, ".png") {
+				t.Fatalf("got %q, want a local avatar URL", got)
+			}
+			f, err := os.Open(filepath.Join(c.dir, filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			cfg, format, err := image.DecodeConfig(f)
+			if err != nil || format != "png" || cfg.Width != 100 || cfg.Height != 50 {
+				t.Fatalf("stored %s %dx%d (%v), want a 100x50 png", format, cfg.Width, cfg.Height, err)
+			}
+		})
+	}
+}
+
+func TestGoogleLoginCachesAvatar(t *testing.T) {
+	srv, c := newTestAvatarHost(t)
+	profile := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"g1","email":"g@example.com","verified_email":true,"picture":"` + srv.URL + `/photo.jpg"}`))
+	}))
+	defer profile.Close()
+
+	h, _ := newTestGoogleLogin(t, &config.Config{}, time.Hour)
+	h.userInfoURL = profile.URL
+	h.avatars = c
+	if w := googleCallback(h); w.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	u, err := h.userRepo.GetByEmail("g@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename, ok := strings.CutPrefix(u.AvatarURL, "/api/user/avatar/")
+	if !ok {
+		t.Fatalf("avatar URL %q, want a local one", u.AvatarURL)
+	}
+	if _, err := os.Stat(filepath.Join(c.dir, filename)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewOAuthHandlerAvatarCache(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		storage := t.TempDir()
+		h := NewOAuthHandler(&config.Config{StoragePath: storage, CacheRemoteAvatars: enabled}, nil, repository.NewUserRepository(newTestDB(t)))
+		if (h.avatars != nil) != enabled {
+			t.Fatalf("CACHE_REMOTE_AVATARS=%v: cache set %v", enabled, h.avatars != nil)
+		}
+		// Without the cache the picture URL is stored as given
+		if !enabled && h.avatars.avatarURL("https://lh3.googleusercontent.com/a") != "https://lh3.googleusercontent.com/a" {
+			t.Fatal("remote URL changed with caching off")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+
+	// Registered decoders for avatar uploads
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// maxAvatarSourcePixels bounds the decoded size of an upload so a small
+// compressed file can't expand into gigabytes of pixels
+const maxAvatarSourcePixels = 40_000_000
+
+var (
+	errAvatarFormatMismatch = errors.New("file content does not match its extension")
+	errAvatarUnsupported    = errors.New("unsupported or corrupt image")
+	errAvatarTooLarge       = errors.New("image dimensions are too large")
+)
+
+// avatarFormats maps allowed extensions to the decoder name image.Decode reports
+var avatarFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+}
+
+// decodeAvatar decodes an uploaded avatar, checking the content matches ext.
+// Animated GIFs decode to their first frame.
+func decodeAvatar(r io.ReadSeeker, ext string) (image.Image, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, errAvatarUnsupported
+	}
+	if avatarFormats[ext] != format {
+		return nil, errAvatarFormatMismatch
+	}
+	if cfg.Width*cfg.Height > maxAvatarSourcePixels {
+		return nil, errAvatarTooLarge
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, errAvatarUnsupported
+	}
+	return img, nil
+}
+
+// fitAvatar downscales img to fit within maxDim x maxDim, averaging the
+// source pixels covered by each output pixel. Smaller images are returned as-is.
+func fitAvatar(img image.Image, maxDim int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if maxDim <= 0 || (srcW <= maxDim && srcH <= maxDim) {
+		return img
+	}
+
+	dstW, dstH := maxDim, maxDim
+	if srcW > srcH {
+		dstH = max(1, srcH*maxDim/srcW)
+	} else {
+		dstW = max(1, srcW*maxDim/srcH)
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := y*srcH/dstH, max((y+1)*srcH/dstH, y*srcH/dstH+1)
+		for x := 0; x < dstW; x++ {
+			x0, x1 := x*srcW/dstW, max((x+1)*srcW/dstW, x*srcW/dstW+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.NRGBAAt(sx, sy)
+					r += int(c.R)
+					g += int(c.G)
+					b += int(c.B)
+					a += int(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
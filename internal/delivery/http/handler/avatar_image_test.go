@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// encodedImage returns a w x h image of one colour in format
+func encodedImage(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{200, 10, 10, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		// Two frames; only the first may survive
+		frame := func(c color.Color) *image.Paletted {
+			p := image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, c})
+			for i := range p.Pix {
+				p.Pix[i] = 1
+			}
+			return p
+		}
+		err = gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame(color.White), frame(color.Black)}, Delay: []int{10, 10}})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeAvatar(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"></svg>`)
+	tests := []struct {
+		name    string
+		data    []byte
+		ext     string
+		wantErr error
+	}{
+		{"png", encodedImage(t, "png", 8, 8), ".png", nil},
+		{"jpeg", encodedImage(t, "jpeg", 8, 8), ".jpeg", nil},
+		{"gif", encodedImage(t, "gif", 8, 8), ".gif", nil},
+		{"png named .jpg", encodedImage(t, "png", 8, 8), ".jpg", errAvatarFormatMismatch},
+		{"gif named .png", encodedImage(t, "gif", 8, 8), ".png", errAvatarFormatMismatch},
+		{"svg named .png", svg, ".png", errAvatarUnsupported},
+		{"truncated png", encodedImage(t, "png", 8, 8)[:20], ".png", errAvatarUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := decodeAvatar(bytes.NewReader(tt.data), tt.ext)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && img.Bounds().Dx() != 8 {
+				t.Fatalf("decoded %v", img.Bounds())
+			}
+		})
+	}
+
+	// Animated GIFs keep their first frame
+	img, err := decodeAvatar(bytes.NewReader(encodedImage(t, "gif", 4, 4)), ".gif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Fatalf("first pixel %v, want the first frame's white", img.At(0, 0))
+	}
+}
+
+func TestFitAvatar(t *testing.T) {
+	tests := []struct {
+		w, h, maxDim int
+		wantW, wantH int
+	}{
+		{64, 64, 128, 64, 64},
+		{512, 512, 128, 128, 128},
+		{400, 200, 128, 128, 64},
+		{200, 400, 128, 64, 128},
+		{1000, 1, 100, 100, 1},
+		{300, 300, 0, 300, 300}, // No limit
+	}
+	for _, tt := range tests {
+		img := fitAvatar(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.maxDim)
+		if b := img.Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("%dx%d within %d: got %dx%d, want %dx%d", tt.w, tt.h, tt.maxDim, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestUploadAvatarNormalizes(t *testing.T) {
+	tests := []struct {
+		name         string
+		file         string
+		data         []byte
+		status       int
+		wantW, wantH int
+	}{
+		{"oversized png downscaled", "me.png", encodedImage(t, "png", 600, 300), http.StatusOK, 128, 64},
+		{"jpeg stored as png", "me.jpg", encodedImage(t, "jpeg", 32, 32), http.StatusOK, 32, 32},
+		{"animated gif flattened", "me.gif", encodedImage(t, "gif", 16, 16), http.StatusOK, 16, 16},
+		{"mismatched type", "me.jpg", encodedImage(t, "png", 32, 32), http.StatusBadRequest, 0, 0},
+		{"svg", "me.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			u := newTestUser(t, db, "u1", user.RoleUser)
+			h := NewUserHandler(nil, repository.NewUserRepository(db), nil, nil, t.TempDir(), 128, "", NewHeavyOpLimiter(0, 0))
+			w := uploadAvatar(h, u, tt.file, tt.data)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			files := avatarFiles(t, h)
+			if tt.status != http.StatusOK {
+				if len(files) != 0 {
+					t.Fatalf("rejected upload stored %v", files)
+				}
+				return
+			}
+			if len(files) != 1 || filepath.Ext(files[0]) != ".png" {
+				t.Fatalf("stored %v, want one PNG", files)
+			}
+			f, err := os.Open(filepath.Join(h.avatarPath, files[0]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			cfg, format, err := image.DecodeConfig(f)
+			if err != nil || format != "png" || cfg.Width != tt.wantW || cfg.Height != tt.wantH {
+				t.Fatalf("stored %s %dx%d (%v), want png %dx%d", format, cfg.Width, cfg.Height, err, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package handler
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"strings"
 
 	"gomanager/internal/domain/user"
 )
@@ -20,3 +23,47 @@ func GetUserFromContext(ctx context.Context) *user.User {
 	}
 	return u
 }
+
+// ClientIPContextKey is the key used to store the real client IP in context
+const ClientIPContextKey contextKey = "clientIP"
+
+// ClientIP returns the client IP resolved by the RealIP middleware, falling
+// back to the connection's remote address
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPContextKey).(string); ok && ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// BasePathContextKey is the key used to store the prefix the API is mounted under
+const BasePathContextKey contextKey = "basePath"
+
+// BasePath returns the prefix stripped by the StripBasePath middleware, or ""
+// when the API is served from the root
+func BasePath(r *http.Request) string {
+	base, _ := r.Context().Value(BasePathContextKey).(string)
+	return base
+}
+
+// publicPath prefixes a root-relative API path with the mount prefix so
+// clients can request it as-is; absolute URLs are returned unchanged
+func publicPath(r *http.Request, p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") {
+		return p
+	}
+	return BasePath(r) + p
+}
+
+// userResponse is u's public view, with a locally stored avatar's URL
+// reachable under the base path. Stored avatar URLs stay root-relative so
+// they survive a change of base path.
+func userResponse(r *http.Request, u *user.User) user.UserResponse {
+	resp := u.ToResponse()
+	resp.AvatarURL = publicPath(r, resp.AvatarURL)
+	return resp
+}
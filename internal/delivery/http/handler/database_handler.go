@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gomanager/internal/infrastructure/database"
+)
+
+// DatabaseHandler exposes database maintenance to admins
+type DatabaseHandler struct {
+	db    *database.DB
+	heavy *HeavyOpLimiter
+}
+
+func NewDatabaseHandler(db *database.DB, heavy *HeavyOpLimiter) *DatabaseHandler {
+	return &DatabaseHandler{db: db, heavy: heavy}
+}
+
+// Vacuum handles POST /api/admin/vacuum and compacts a SQLite database,
+// reporting its size before and after. Other backends are skipped.
+func (h *DatabaseHandler) Vacuum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.db.Vacuum()
+	release()
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrVacuumUnsupported):
+			SendSuccess(w, "Vacuum skipped: only SQLite databases need it", map[string]bool{"skipped": true})
+		case errors.Is(err, database.ErrDatabaseBusy):
+			w.Header().Set("Retry-After", strconv.Itoa(heavyOpRetryAfter))
+			SendError(w, "Database is busy, try again shortly", http.StatusServiceUnavailable)
+		default:
+			SendError(w, "Failed to vacuum database", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	SendSuccess(w, "Database vacuumed", result)
+}
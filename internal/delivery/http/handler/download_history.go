@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// downloadQueueSize is how many downloads may wait to be written to the
+// history; beyond that they go unrecorded rather than slow the download
+const downloadQueueSize = 256
+
+const defaultRecentDownloads = 20
+
+type downloadEntry struct {
+	userID string
+	path   string
+	at     time.Time
+}
+
+// downloadRecorder writes download history in the background
+type downloadRecorder struct {
+	history domain.DownloadHistory
+	queue   chan downloadEntry
+}
+
+func newDownloadRecorder(history domain.DownloadHistory) *downloadRecorder {
+	rec := &downloadRecorder{history: history, queue: make(chan downloadEntry, downloadQueueSize)}
+	go func() {
+		for entry := range rec.queue {
+			if err := history.Record(entry.userID, entry.path, entry.at); err != nil {
+				log.Printf("failed to record download of %s: %v", entry.path, err)
+			}
+		}
+	}()
+	return rec
+}
+
+// recordDownload queues a download by the signed-in user for their history.
+// Inline previews and ranges past the start of the file (a player seeking)
+// aren't counted as downloads.
+func (h *FileHandler) recordDownload(r *http.Request, filePath string) {
+	if h.downloads == nil || r.URL.Query().Get("preview") == "true" {
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" && !strings.HasPrefix(rng, "bytes=0-") {
+		return
+	}
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		return
+	}
+
+	select {
+	case h.downloads.queue <- downloadEntry{userID: u.ID, path: cleanSharePath(filePath), at: time.Now()}:
+	default:
+	}
+}
+
+// RecentDownloads handles GET /api/files/recent-downloads?limit= and returns
+// the caller's latest downloads, newest first
+func (h *FileHandler) RecentDownloads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.downloads == nil {
+		SendSuccess(w, "", []domain.Download{})
+		return
+	}
+
+	limit := defaultRecentDownloads
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			SendError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, domain.MaxDownloadHistory)
+	}
+
+	downloads, err := h.downloads.history.Recent(u.ID, limit)
+	if err != nil {
+		SendError(w, "Failed to load download history", http.StatusInternalServerError)
+		return
+	}
+	SendSuccess(w, "", downloads)
+}
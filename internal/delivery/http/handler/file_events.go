@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// eventHeartbeat keeps idle event streams from being closed by proxies
+const eventHeartbeat = 30 * time.Second
+
+// Events handles GET /api/files/events?path=... and streams file changes as
+// server-sent events while the connection stays open. With path set, only
+// changes to it or anything under it are sent. Events that arrive while the
+// client is disconnected are not replayed; reconnecting clients should
+// re-list what they show.
+func (h *FileHandler) Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.events == nil {
+		SendError(w, "File events are disabled", http.StatusNotFound)
+		return
+	}
+
+	filter := strings.Trim(path.Clean("/"+r.URL.Query().Get("path")), "/")
+
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep nginx from holding events back
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-events:
+			if !eventMatches(event, filter) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// eventMatches reports whether event touches dir or anything under it; a
+// move counts for both its old and new location
+func eventMatches(event domain.Event, dir string) bool {
+	if dir == "" {
+		return true
+	}
+	for _, p := range []string{event.Path, event.From} {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
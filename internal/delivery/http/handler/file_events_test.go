@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	fileService "gomanager/internal/application/file"
+	fileDomain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// readEvent reads the next server-sent event from the stream
+func readEvent(t *testing.T, stream *bufio.Reader) (string, fileDomain.Event) {
+	t.Helper()
+	var name string
+	var event fileDomain.Event
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, event
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestEventsStreamUploads(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantPath string // First event the client receives
+	}{
+		{"everything", "", "docs/report.txt"},
+		{"viewed folder", "docs", "docs/report.txt"},
+		{"other folder", "/other/", "other/notes.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, map[string]string{"docs/old.txt": "o", "other/old.txt": "o"})
+			repo := repository.NewFilesystemRepository(dir, nil, false)
+			broker := fileService.NewEventBroker()
+			svc := fileService.NewService(repo, repository.NewFileIndexRepository(newTestDB(t)), nil, repository.NewUploadStore(dir, repo, nil, 0), fileService.ListingCacheConfig{}, 0, nil, fileDomain.NameSanitizer{}, nil, broker)
+			h := NewFileHandler(svc, UploadPolicy{DefaultMaxFileSize: 1 << 20, MemoryLimit: 1 << 20}, nil, "", 0, NewHeavyOpLimiter(0, 0), broker, nil, nil)
+
+			srv := httptest.NewServer(http.HandlerFunc(h.Events))
+			defer srv.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/files/events?path="+tt.filter, nil)
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+			}
+
+			// The client is subscribed once the headers arrive
+			for _, target := range []struct{ path, name string }{{"docs", "report.txt"}, {"other", "notes.txt"}} {
+				if w := multipartUpload(h, target.path, target.name); w.Code != http.StatusOK {
+					t.Fatalf("upload: status %d: %s", w.Code, w.Body)
+				}
+			}
+
+			name, event := readEvent(t, bufio.NewReader(resp.Body))
+			if name != fileDomain.EventCreated || event.Type != fileDomain.EventCreated || event.Path != tt.wantPath {
+				t.Fatalf("got %s event %+v, want created %s", name, event, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestEventsDisabled(t *testing.T) {
+	h, _ := newTestFileHandler(t, nil)
+	w := httptest.NewRecorder()
+	h.Events(w, httptest.NewRequest(http.MethodGet, "/api/files/events", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestEventMatches(t *testing.T) {
+	tests := []struct {
+		event fileDomain.Event
+		dir   string
+		want  bool
+	}{
+		{fileDomain.Event{Path: "docs/a.txt"}, "", true},
+		{fileDomain.Event{Path: "docs/a.txt"}, "docs", true},
+		{fileDomain.Event{Path: "docs"}, "docs", true},
+		{fileDomain.Event{Path: "docs2/a.txt"}, "docs", false},
+		{fileDomain.Event{Path: "archive/a.txt", From: "docs/a.txt"}, "docs", true}, // Moved out
+		{fileDomain.Event{Path: "docs/a.txt", From: "inbox/a.txt"}, "docs", true},   // Moved in
+		{fileDomain.Event{Path: "archive/a.txt", From: "inbox/a.txt"}, "docs", false},
+	}
+	for _, tt := range tests {
+		if got := eventMatches(tt.event, tt.dir); got != tt.want {
+			t.Errorf("%+v under %q: got %v, want %v", tt.event, tt.dir, got, tt.want)
+		}
+	}
+}
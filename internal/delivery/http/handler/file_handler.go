@@ -4,27 +4,86 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/webdav"
 
 	fileService "gomanager/internal/application/file"
 	domain "gomanager/internal/domain/file"
+	"gomanager/internal/domain/user"
 )
 
 type FileHandler struct {
-	service     fileService.Service
-	maxFileSize int64
+	service      fileService.Service
+	uploadPolicy UploadPolicy
+
+	// Signed download links
+	signer       *fileService.DownloadSigner
+	baseURL      string
+	maxSignedTTL time.Duration
+
+	heavy *HeavyOpLimiter
+
+	// events feeds the change stream; nil disables it
+	events *fileService.EventBroker
+
+	// davLocks holds WebDAV locks for the life of the process
+	davLocks webdav.LockSystem
+
+	thumbnails *ThumbnailCache
+
+	// downloads records each user's download history; nil disables it
+	downloads *downloadRecorder
 }
 
-func NewFileHandler(service fileService.Service, maxFileSize int64) *FileHandler {
-	return &FileHandler{
-		service:     service,
-		maxFileSize: maxFileSize,
+// UploadPolicy resolves the upload size limit for a user's role
+type UploadPolicy struct {
+	DefaultMaxFileSize int64
+	MaxFileSizeByRole  map[user.Role]int64
+
+	// MemoryLimit is how much of a multipart upload is buffered in RAM; the
+	// rest is spooled to temp files (0 spools every file)
+	MemoryLimit int64
+}
+
+// MaxFileSize returns the role's override, or the default if none is set
+func (p UploadPolicy) MaxFileSize(role user.Role) int64 {
+	if size, ok := p.MaxFileSizeByRole[role]; ok && size > 0 {
+		return size
+	}
+	return p.DefaultMaxFileSize
+}
+
+func NewFileHandler(service fileService.Service, uploadPolicy UploadPolicy, signer *fileService.DownloadSigner, baseURL string, maxSignedTTL time.Duration, heavy *HeavyOpLimiter, events *fileService.EventBroker, thumbnails *ThumbnailCache, downloads domain.DownloadHistory) *FileHandler {
+	h := &FileHandler{
+		service:      service,
+		uploadPolicy: uploadPolicy,
+		signer:       signer,
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		maxSignedTTL: maxSignedTTL,
+		heavy:        heavy,
+		events:       events,
+		davLocks:     webdav.NewMemLS(),
+		thumbnails:   thumbnails,
+	}
+	if downloads != nil {
+		h.downloads = newDownloadRecorder(downloads)
 	}
+	return h
 }
 
-// List handles GET /api/files?path=...
+// List handles GET /api/files?path=...&withSizes=...&withMeta=...&tag=...
+// &type=...&prefix=...&glob=.... prefix and glob match entry names ignoring
+// case, so a large folder can be filtered as the user types.
 func (h *FileHandler) List(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -32,6 +91,26 @@ func (h *FileHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	path := r.URL.Query().Get("path")
+
+	// The directory's modtime catches entries added or removed outside the API
+	if info, err := h.service.Stat(path); err == nil {
+		tag := h.service.Version() + "." + strconv.FormatInt(info.ModTime.UnixNano(), 36)
+		if NotModified(w, r, `W/"`+tag+`"`) {
+			return
+		}
+	}
+
+	names := domain.NameFilter{Prefix: r.URL.Query().Get("prefix"), Glob: r.URL.Query().Get("glob")}
+	if !names.Valid() {
+		SendError(w, "Invalid glob pattern", http.StatusBadRequest)
+		return
+	}
+	category := domain.Category(r.URL.Query().Get("type"))
+	if category != "" && !category.Valid() {
+		SendError(w, "type must be one of image, video, audio, document", http.StatusBadRequest)
+		return
+	}
+
 	files, err := h.service.ListFiles(path)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
@@ -42,17 +121,96 @@ func (h *FileHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Narrow the listing first so folder sizes and metadata are only looked
+	// up for entries that are returned
+	if names != (domain.NameFilter{}) {
+		files = filterByName(files, names)
+	}
+	if category != "" {
+		files = filterByCategory(files, category)
+	}
+	if r.URL.Query().Get("withSizes") == "true" {
+		release, ok := h.heavy.acquire(w, r)
+		if !ok {
+			return
+		}
+		h.service.AttachDirSizes(files)
+		release()
+	}
+
+	// Filtering by tag needs the metadata too
+	tag := strings.TrimSpace(r.URL.Query().Get("tag"))
+	if tag != "" || r.URL.Query().Get("withMeta") == "true" {
+		if err := h.service.AttachMetadata(path, files); err != nil {
+			SendError(w, "Failed to read file metadata", http.StatusInternalServerError)
+			return
+		}
+	}
+	if tag != "" {
+		files = filterByTag(files, tag)
+	}
+
 	SendSuccess(w, "", files)
 }
 
-// Upload handles POST /api/upload?path=...
+// filterByName keeps entries, files or folders, whose name passes filter
+func filterByName(files []domain.FileInfo, filter domain.NameFilter) []domain.FileInfo {
+	filtered := make([]domain.FileInfo, 0, len(files))
+	for _, f := range files {
+		if filter.Match(f.Name) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// filterByTag keeps entries, files or folders, carrying tag
+func filterByTag(files []domain.FileInfo, tag string) []domain.FileInfo {
+	filtered := make([]domain.FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.Meta.HasTag(tag) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// filterByCategory keeps files of the given category; directories always
+// pass so the client can still navigate
+func filterByCategory(files []domain.FileInfo, category domain.Category) []domain.FileInfo {
+	filtered := make([]domain.FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.IsDir || domain.CategoryOf(f.Name) == category {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// Upload handles POST /api/upload?path=.... Each file may be paired with a
+// "checksums" form value holding its expected SHA-256; files that don't match
+// aren't stored and are reported with a 422 when nothing else was accepted.
 func (h *FileHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	if err := r.ParseMultipartForm(h.maxFileSize); err != nil {
+	// Resolve the limit from the caller's role
+	maxFileSize := h.uploadPolicy.DefaultMaxFileSize
+	if u := GetUserFromContext(r.Context()); u != nil {
+		maxFileSize = h.uploadPolicy.MaxFileSize(u.Role)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileSize)
+
+	// Buffer only MemoryLimit bytes in RAM so concurrent large uploads don't
+	// each hold up to maxFileSize in memory
+	if err := r.ParseMultipartForm(h.uploadPolicy.MemoryLimit); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			SendError(w, fmt.Sprintf("Upload exceeds the %d byte limit", maxFileSize), http.StatusRequestEntityTooLarge)
+			return
+		}
 		SendError(w, "Failed to parse form", http.StatusBadRequest)
 		return
 	}
@@ -65,13 +223,205 @@ func (h *FileHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	uploaded, err := h.service.UploadFiles(targetPath, files)
+	policy, ok := uploadConflictPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	files, mismatched, err := verifyUploadChecksums(files, r.MultipartForm.Value["checksums"])
+	if errors.Is(err, domain.ErrInvalidChecksum) {
+		SendError(w, "checksums must be hex SHA-256 values, at most one per file", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		SendError(w, "Failed to upload files", http.StatusInternalServerError)
 		return
 	}
 
-	SendSuccess(w, fmt.Sprintf("Uploaded %d file(s)", len(uploaded)), uploaded)
+	result := &domain.UploadResult{Uploaded: []string{}}
+	if len(files) > 0 {
+		result, err = h.service.UploadFiles(targetPath, files, policy)
+	}
+	if errors.Is(err, domain.ErrInvalidPath) {
+		SendError(w, "Invalid upload path", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, domain.ErrPathTooDeep) {
+		SendError(w, "Path exceeds the maximum directory depth", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, domain.ErrNotDirectory) {
+		SendError(w, "Target is not a directory", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		SendError(w, "Failed to upload files", http.StatusInternalServerError)
+		return
+	}
+
+	result.ChecksumMismatch = mismatched
+
+	if len(result.Conflicts) > 0 || len(result.Rejected) > 0 || len(result.Pending) > 0 || len(result.ChecksumMismatch) > 0 {
+		accepted := len(result.Uploaded) + len(result.Pending)
+		status := http.StatusOK
+		switch {
+		case accepted > 0:
+		case len(result.Rejected) > 0 || len(result.ChecksumMismatch) > 0:
+			status = http.StatusUnprocessableEntity
+		default:
+			status = http.StatusConflict
+		}
+		message := fmt.Sprintf("Uploaded %d file(s)", len(result.Uploaded))
+		if n := len(result.Pending); n > 0 {
+			message += fmt.Sprintf(", %d pending scan", n)
+		}
+		if n := len(result.Conflicts); n > 0 {
+			message += fmt.Sprintf(", %d already exist", n)
+		}
+		if n := len(result.Rejected); n > 0 {
+			message += fmt.Sprintf(", %d rejected", n)
+		}
+		if n := len(result.ChecksumMismatch); n > 0 {
+			message += fmt.Sprintf(", %d failed checksum verification", n)
+		}
+		SendJSON(w, status, Response{
+			Success: accepted > 0,
+			Message: message,
+			Data:    result,
+		})
+		return
+	}
+
+	SendSuccess(w, fmt.Sprintf("Uploaded %d file(s)", len(result.Uploaded)), result.Uploaded)
+}
+
+// verifyUploadChecksums checks multipart files against the SHA-256 values in
+// checksums, where the n-th value belongs to the n-th file and blank values
+// skip the check. It returns the files to store and the names of those whose
+// content didn't match.
+func verifyUploadChecksums(files []*multipart.FileHeader, checksums []string) ([]*multipart.FileHeader, []string, error) {
+	if len(checksums) == 0 {
+		return files, nil, nil
+	}
+	if len(checksums) > len(files) {
+		return nil, nil, domain.ErrInvalidChecksum
+	}
+
+	var passed []*multipart.FileHeader
+	var mismatched []string
+	for i, fileHeader := range files {
+		if i >= len(checksums) || strings.TrimSpace(checksums[i]) == "" {
+			passed = append(passed, fileHeader)
+			continue
+		}
+		expected, err := domain.ParseChecksum(checksums[i])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		f, err := fileHeader.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		actual, err := domain.Checksum(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if actual != expected {
+			mismatched = append(mismatched, fileHeader.Filename)
+			continue
+		}
+		passed = append(passed, fileHeader)
+	}
+	return passed, mismatched, nil
+}
+
+// ChecksumHeader carries the expected SHA-256 of a raw upload's body
+const ChecksumHeader = "X-Checksum"
+
+// UploadRaw handles PUT /api/files/raw?path=...&name=..., storing the request
+// body as a single file without multipart parsing
+func (h *FileHandler) UploadRaw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		SendError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	policy, ok := uploadConflictPolicy(w, r)
+	if !ok {
+		return
+	}
+
+	maxFileSize := h.uploadPolicy.DefaultMaxFileSize
+	if u := GetUserFromContext(r.Context()); u != nil {
+		maxFileSize = h.uploadPolicy.MaxFileSize(u.Role)
+	}
+	if r.ContentLength > maxFileSize {
+		SendError(w, fmt.Sprintf("Upload exceeds the %d byte limit", maxFileSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileSize)
+
+	// With X-Checksum set, a body that doesn't match is dropped before it is stored
+	var body io.Reader = r.Body
+	if v := r.Header.Get(ChecksumHeader); v != "" {
+		expected, err := domain.ParseChecksum(v)
+		if err != nil {
+			SendError(w, ChecksumHeader+" must be a hex SHA-256", http.StatusBadRequest)
+			return
+		}
+		body = domain.NewChecksumReader(body, expected)
+	}
+
+	info, err := h.service.UploadStream(r.URL.Query().Get("path"), name, body, policy)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			SendError(w, fmt.Sprintf("Upload exceeds the %d byte limit", maxFileSize), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, domain.ErrInvalidPath):
+			SendError(w, "Invalid file name", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrPathTooDeep):
+			SendError(w, "Path exceeds the maximum directory depth", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrNotDirectory):
+			SendError(w, "Target is not a directory", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrExists):
+			SendError(w, "A file with that name already exists", http.StatusConflict)
+		case errors.Is(err, domain.ErrScanRejected):
+			SendError(w, "File was rejected by the upload scanner", http.StatusUnprocessableEntity)
+		case errors.Is(err, domain.ErrChecksumMismatch):
+			SendError(w, "File content does not match "+ChecksumHeader, http.StatusUnprocessableEntity)
+		default:
+			SendError(w, "Failed to upload file", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	SendSuccess(w, "File uploaded", info)
+}
+
+// uploadConflictPolicy reads the overwrite/rename query flags, writing the
+// error response when they conflict. Existing files are kept by default.
+func uploadConflictPolicy(w http.ResponseWriter, r *http.Request) (domain.ConflictPolicy, bool) {
+	query := r.URL.Query()
+	overwrite := query.Get("overwrite") == "true"
+	rename := query.Get("rename") == "true"
+	switch {
+	case overwrite && rename:
+		SendError(w, "overwrite and rename cannot be combined", http.StatusBadRequest)
+		return "", false
+	case overwrite:
+		return domain.ConflictOverwrite, true
+	case rename:
+		return domain.ConflictRename, true
+	}
+	return domain.ConflictReject, true
 }
 
 // Download handles GET /api/download/{path}
@@ -81,8 +431,12 @@ func (h *FileHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filePath := strings.TrimPrefix(r.URL.Path, "/api/download/")
-	fullPath, err := h.service.GetFileForDownload(filePath)
+	h.serveFile(w, r, strings.TrimPrefix(r.URL.Path, "/api/download/"))
+}
+
+// serveFile sends a stored file, inline when ?preview=true
+func (h *FileHandler) serveFile(w http.ResponseWriter, r *http.Request, filePath string) {
+	f, info, err := h.service.OpenFile(filePath)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
 			SendError(w, "File not found", http.StatusNotFound)
@@ -95,84 +449,196 @@ func (h *FileHandler) Download(w http.ResponseWriter, r *http.Request) {
 		SendError(w, "Failed to access file", http.StatusInternalServerError)
 		return
 	}
+	defer f.Close()
+	h.recordDownload(r, filePath)
 
 	// Check if this is a preview request (inline display)
 	isPreview := r.URL.Query().Get("preview") == "true"
 
-	filename := filepath.Base(fullPath)
+	filename := info.Name
 
 	// Set appropriate Content-Type based on file extension
-	contentType := getContentType(filename)
+	contentType := domain.ContentType(filename)
 	w.Header().Set("Content-Type", contentType)
 
 	if isPreview {
 		// For preview, use inline disposition so browser displays the file
-		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
+		w.Header().Set("Content-Disposition", ContentDisposition("inline", filename))
 	} else {
 		// For download, use attachment disposition
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	}
-
-	http.ServeFile(w, r, fullPath)
-}
-
-// getContentType returns the MIME type based on file extension
-func getContentType(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	// Images
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".png":
-		return "image/png"
-	case ".gif":
-		return "image/gif"
-	case ".webp":
-		return "image/webp"
-	case ".svg":
-		return "image/svg+xml"
-	case ".bmp":
-		return "image/bmp"
-	case ".ico":
-		return "image/x-icon"
-	// Videos
-	case ".mp4":
-		return "video/mp4"
-	case ".webm":
-		return "video/webm"
-	case ".ogg":
-		return "video/ogg"
-	case ".mov":
-		return "video/quicktime"
-	// Audio
-	case ".mp3":
-		return "audio/mpeg"
-	case ".wav":
-		return "audio/wav"
-	case ".flac":
-		return "audio/flac"
-	case ".aac":
-		return "audio/aac"
-	case ".m4a":
-		return "audio/mp4"
-	// Documents
-	case ".pdf":
-		return "application/pdf"
-	case ".txt":
-		return "text/plain"
-	case ".html", ".htm":
-		return "text/html"
-	case ".css":
-		return "text/css"
-	case ".js":
-		return "application/javascript"
-	case ".json":
-		return "application/json"
-	case ".xml":
-		return "application/xml"
-	default:
-		return "application/octet-stream"
+		w.Header().Set("Content-Disposition", ContentDisposition("attachment", filename))
+	}
+
+	http.ServeContent(w, r, filename, info.ModTime, f)
+}
+
+// defaultSignedTTL is the lifetime of a signed link when none is requested
+const defaultSignedTTL = time.Hour
+
+// SignedURL handles POST /api/files/signed-url?path=&expiresIn= and returns a
+// download link that works without authentication until it expires
+func (h *FileHandler) SignedURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		SendError(w, "Path is required", http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultSignedTTL
+	if v := r.URL.Query().Get("expiresIn"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 1 {
+			SendError(w, "expiresIn must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if h.maxSignedTTL > 0 && ttl > h.maxSignedTTL {
+		SendError(w, fmt.Sprintf("expiresIn may be at most %d seconds", int(h.maxSignedTTL/time.Second)), http.StatusBadRequest)
+		return
+	}
+
+	info, err := h.service.Stat(filePath)
+	if err != nil {
+		SendError(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if info.IsDir {
+		SendError(w, "Cannot sign a directory", http.StatusBadRequest)
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	query := url.Values{
+		"path":    {info.Path},
+		"expires": {strconv.FormatInt(expires.Unix(), 10)},
+		"sig":     {h.signer.Sign(info.Path, expires)},
+	}
+	SendSuccess(w, "", map[string]interface{}{
+		"url":       h.baseURL + "/api/download/signed?" + query.Encode(),
+		"expiresAt": expires.UTC().Truncate(time.Second),
+	})
+}
+
+// SignedDownload handles GET /api/download/signed?path=&expires=&sig=. It
+// needs no token; the signature grants access to that one file.
+func (h *FileHandler) SignedDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filePath := query.Get("path")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if filePath == "" || err != nil || query.Get("sig") == "" {
+		SendError(w, "Invalid download link", http.StatusBadRequest)
+		return
 	}
+
+	if err := h.signer.Verify(filePath, expires, query.Get("sig")); err != nil {
+		if errors.Is(err, domain.ErrLinkExpired) {
+			SendError(w, "Download link has expired", http.StatusGone)
+			return
+		}
+		SendError(w, "Invalid download link", http.StatusForbidden)
+		return
+	}
+
+	h.serveFile(w, r, filePath)
+}
+
+// Bounds for text excerpts returned by Preview
+const (
+	defaultPreviewBytes = 64 << 10
+	maxPreviewBytes     = 1 << 20
+)
+
+// Preview handles GET /api/files/preview?path=&bytes= and returns the start
+// of a text file without downloading all of it
+func (h *FileHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		SendError(w, "Path is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultPreviewBytes
+	if v := r.URL.Query().Get("bytes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			SendError(w, "bytes must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxPreviewBytes)
+	}
+
+	f, info, err := h.service.OpenFile(filePath)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			SendError(w, "File not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrIsDirectory) {
+			SendError(w, "Cannot preview a directory", http.StatusBadRequest)
+			return
+		}
+		SendError(w, "Failed to access file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	buf := make([]byte, limit)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		SendError(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	buf = buf[:n]
+
+	detected := http.DetectContentType(buf)
+	if !strings.HasPrefix(detected, "text/") {
+		SendError(w, "Preview is only available for text files", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Don't split a multi-byte character at the cut-off
+	if int64(n) < info.Size {
+		for i := 1; i < utf8.UTFMax && i <= len(buf); i++ {
+			if utf8.RuneStart(buf[len(buf)-i]) {
+				if !utf8.FullRune(buf[len(buf)-i:]) {
+					buf = buf[:len(buf)-i]
+				}
+				break
+			}
+		}
+	}
+	n = len(buf)
+
+	// Prefer the extension's type (e.g. application/json), which sniffing reports as text/plain
+	contentType := detected
+	if byExt := domain.ContentType(info.Name); byExt != "application/octet-stream" {
+		contentType = byExt
+	}
+
+	SendSuccess(w, "", map[string]interface{}{
+		"path":        filePath,
+		"contentType": contentType,
+		"content":     string(buf),
+		"bytes":       n,
+		"size":        info.Size,
+		"truncated":   int64(n) < info.Size,
+	})
 }
 
 // CreateFolder handles POST /api/mkdir
@@ -194,6 +660,18 @@ func (h *FileHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.service.CreateFolder(req.Path); err != nil {
+		if errors.Is(err, domain.ErrInvalidPath) {
+			SendError(w, "Invalid folder path", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, domain.ErrPathTooDeep) {
+			SendError(w, "Path exceeds the maximum directory depth", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, domain.ErrNotDirectory) {
+			SendError(w, "Target is not a directory", http.StatusBadRequest)
+			return
+		}
 		SendError(w, "Failed to create directory", http.StatusInternalServerError)
 		return
 	}
@@ -201,7 +679,11 @@ func (h *FileHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 	SendSuccess(w, "Directory created", nil)
 }
 
-// Delete handles POST /api/delete
+// maxDeletePreviewEntries caps the entries listed by a delete dry run
+const maxDeletePreviewEntries = 1000
+
+// Delete handles POST /api/delete; with ?dryRun=true it only reports what
+// would be removed
 func (h *FileHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -219,11 +701,32 @@ func (h *FileHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("dryRun") == "true" {
+		preview, err := h.service.PreviewDelete(req.Path, maxDeletePreviewEntries)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrRootDeletion):
+				SendError(w, "Cannot delete root directory", http.StatusForbidden)
+			case errors.Is(err, domain.ErrNotFound):
+				SendError(w, "File or directory not found", http.StatusNotFound)
+			default:
+				SendError(w, "Failed to list files to delete", http.StatusInternalServerError)
+			}
+			return
+		}
+		SendSuccess(w, "Dry run, nothing was deleted", preview)
+		return
+	}
+
 	if err := h.service.Delete(req.Path); err != nil {
 		if errors.Is(err, domain.ErrRootDeletion) {
 			SendError(w, "Cannot delete root directory", http.StatusForbidden)
 			return
 		}
+		if errors.Is(err, domain.ErrNotFound) {
+			SendError(w, "File or directory not found", http.StatusNotFound)
+			return
+		}
 		SendError(w, "Failed to delete", http.StatusInternalServerError)
 		return
 	}
@@ -231,6 +734,112 @@ func (h *FileHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	SendSuccess(w, "Deleted successfully", nil)
 }
 
+// Move handles POST /api/move
+func (h *FileHandler) Move(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req domain.MoveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Source == "" || req.Destination == "" {
+		SendError(w, "Source and destination are required", http.StatusBadRequest)
+		return
+	}
+
+	newPath, err := h.service.Move(req.Source, req.Destination)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			SendError(w, "Source not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrExists):
+			SendError(w, "Destination already exists", http.StatusConflict)
+		case errors.Is(err, domain.ErrMoveIntoSelf):
+			SendError(w, "Cannot move a folder into itself", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrPathTooDeep):
+			SendError(w, "Path exceeds the maximum directory depth", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrScanRejected):
+			SendError(w, "That file type is not allowed", http.StatusUnprocessableEntity)
+		case errors.Is(err, domain.ErrInvalidPath):
+			SendError(w, "Invalid path", http.StatusBadRequest)
+		default:
+			SendError(w, "Failed to move", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	SendSuccess(w, "Moved successfully", map[string]string{"path": newPath})
+}
+
+// Paste handles POST /api/files/paste, moving or copying several items into
+// one folder and reporting the outcome for each
+func (h *FileHandler) Paste(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req domain.PasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Operation != domain.PasteMove && req.Operation != domain.PasteCopy {
+		SendError(w, "operation must be move or copy", http.StatusBadRequest)
+		return
+	}
+	if len(req.Sources) == 0 || req.Destination == "" {
+		SendError(w, "Sources and destination are required", http.StatusBadRequest)
+		return
+	}
+
+	var policy domain.ConflictPolicy
+	switch req.Conflict {
+	case "", "skip":
+		policy = domain.ConflictReject
+	case "overwrite":
+		policy = domain.ConflictOverwrite
+	case "rename":
+		policy = domain.ConflictRename
+	default:
+		SendError(w, "conflict must be skip, overwrite or rename", http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.service.Paste(req.Operation, req.Sources, req.Destination, policy)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			SendError(w, "Destination not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrNotDirectory):
+			SendError(w, "Destination is not a folder", http.StatusBadRequest)
+		default:
+			SendError(w, "Failed to paste", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	done := 0
+	for _, res := range results {
+		if res.Status == domain.PasteStatusDone {
+			done++
+		}
+	}
+	SendSuccess(w, fmt.Sprintf("Pasted %d of %d items", done, len(results)), map[string]interface{}{
+		"results": results,
+	})
+}
+
+// statsRevalidateInterval bounds how long /api/stats keeps answering 304
+// when nothing changed through the API
+const statsRevalidateInterval = time.Minute
+
 // Stats handles GET /api/stats
 func (h *FileHandler) Stats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -238,7 +847,19 @@ func (h *FileHandler) Stats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Stats walk the whole tree, so changes made outside the API are picked
+	// up by letting the validator expire every statsRevalidateInterval
+	epoch := time.Now().Unix() / int64(statsRevalidateInterval/time.Second)
+	if NotModified(w, r, `W/"`+h.service.Version()+"."+strconv.FormatInt(epoch, 36)+`"`) {
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
 	stats, err := h.service.GetStats()
+	release()
 	if err != nil {
 		SendError(w, "Failed to get stats", http.StatusInternalServerError)
 		return
@@ -246,3 +867,397 @@ func (h *FileHandler) Stats(w http.ResponseWriter, r *http.Request) {
 
 	SendSuccess(w, "", stats)
 }
+
+const (
+	defaultTypeFilesPageSize = 50
+	maxTypeFilesPageSize     = 500
+)
+
+// StatsByType handles GET /api/stats/by-type?ext=.jpg&page=&pageSize= and
+// lists the files counted under ext in the stats, largest first, to show
+// what is taking up space. ext may omit the dot; "no extension" selects
+// files without one.
+func (h *FileHandler) StatsByType(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	ext := strings.ToLower(strings.TrimSpace(query.Get("ext")))
+	if ext == "" {
+		SendError(w, "ext is required", http.StatusBadRequest)
+		return
+	}
+	if ext != domain.NoExtension && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	page, pageSize := 1, defaultTypeFilesPageSize
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		// Bounded so the offset computed from it can't overflow
+		if err != nil || n < 1 || n > math.MaxInt32 {
+			SendError(w, "page must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	if v := query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			SendError(w, "pageSize must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		pageSize = min(n, maxTypeFilesPageSize)
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.service.FilesByType(ext, (page-1)*pageSize, pageSize)
+	release()
+	if err != nil {
+		SendError(w, "Failed to list files", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", map[string]interface{}{
+		"ext":       result.Ext,
+		"files":     result.Files,
+		"total":     result.Total,
+		"totalSize": result.TotalSize,
+		"page":      page,
+		"pageSize":  pageSize,
+	})
+}
+
+// Diff handles GET /api/files/diff?left=...&right=...[&checksum=true] and
+// reports what differs between two folders
+func (h *FileHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if !query.Has("left") || !query.Has("right") {
+		SendError(w, "left and right are required", http.StatusBadRequest)
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	diff, err := h.service.Diff(query.Get("left"), query.Get("right"), query.Get("checksum") == "true")
+	release()
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			SendError(w, "Folder not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrNotDirectory):
+			SendError(w, "Both paths must be folders", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrDiffTooLarge):
+			SendError(w, "Folders are too large to compare", http.StatusUnprocessableEntity)
+		default:
+			SendError(w, "Failed to compare folders", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	SendSuccess(w, "", diff)
+}
+
+// UserCapabilities is what the current user may do, and within what limits
+type UserCapabilities struct {
+	Role user.Role `json:"role"`
+	user.Capabilities
+	Limits UserLimits `json:"limits"`
+}
+
+// UserLimits are the size and time limits that apply to the current user
+type UserLimits struct {
+	MaxFileSize     int64 `json:"maxFileSize"`     // Bytes per upload
+	MaxSignedURLTTL int64 `json:"maxSignedUrlTtl"` // Seconds; 0 means no limit
+}
+
+// Capabilities handles GET /api/user/capabilities. It lives with the file
+// handler because the limits it reports are the upload policy's.
+func (h *FileHandler) Capabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	SendSuccess(w, "", UserCapabilities{
+		Role:         u.Role,
+		Capabilities: u.Role.Capabilities(),
+		Limits: UserLimits{
+			MaxFileSize:     h.uploadPolicy.MaxFileSize(u.Role),
+			MaxSignedURLTTL: int64(h.maxSignedTTL / time.Second),
+		},
+	})
+}
+
+// Info handles GET /api/files/info?path=... and returns a single entry
+func (h *FileHandler) Info(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info, err := h.service.Stat(r.URL.Query().Get("path"))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			SendError(w, "File or directory not found", http.StatusNotFound)
+			return
+		}
+		SendError(w, "Failed to read file info", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", info)
+}
+
+// maxStatBatch caps the paths looked up by one stat-batch request
+const maxStatBatch = 1000
+
+// StatBatch handles POST /api/files/stat-batch with {"paths": [...]} and
+// returns the info of every path in order, so a selection's size and count
+// take one request. Missing or invalid paths get an error in their slot
+// instead of failing the batch. totalSize adds up the files found; folders
+// are counted but not sized.
+func (h *FileHandler) StatBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req domain.StatBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Paths) == 0 {
+		SendError(w, "paths is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Paths) > maxStatBatch {
+		SendError(w, fmt.Sprintf("At most %d paths can be looked up at once", maxStatBatch), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]domain.StatResult, len(req.Paths))
+	var files, folders, totalSize int64
+	for i, p := range req.Paths {
+		results[i].Path = p
+		// Paths climbing out with ".." are refused rather than cleaned into
+		// something the client didn't ask for
+		if !utf8.ValidString(p) || slices.Contains(strings.Split(p, "/"), "..") {
+			results[i].Error = "invalid path"
+			continue
+		}
+
+		info, err := h.service.Stat(p)
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			results[i].Error = "not found"
+		case err != nil:
+			results[i].Error = "failed to read file info"
+		default:
+			results[i].Info = info
+			if info.IsDir {
+				folders++
+			} else {
+				files++
+				totalSize += info.Size
+			}
+		}
+	}
+
+	SendSuccess(w, "", map[string]interface{}{
+		"results":   results,
+		"files":     files,
+		"folders":   folders,
+		"totalSize": totalSize,
+	})
+}
+
+// Metadata handles GET and PUT /api/files/metadata?path=..., reading or
+// replacing the tags and key/value pairs on a file or folder
+func (h *FileHandler) Metadata(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+
+	var meta *domain.Metadata
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		meta, err = h.service.GetMetadata(path)
+	case http.MethodPut:
+		// Only users who can change files may tag them
+		if u := GetUserFromContext(r.Context()); u == nil || !u.Role.CanUpload() {
+			SendError(w, "Insufficient permissions", http.StatusForbidden)
+			return
+		}
+		var req domain.Metadata
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			SendError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		meta, err = h.service.SetMetadata(path, req)
+	default:
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			SendError(w, "File or directory not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrInvalidPath):
+			SendError(w, "Invalid path", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidMetadata):
+			SendError(w, "Invalid tags or metadata", http.StatusBadRequest)
+		default:
+			SendError(w, "Failed to access file metadata", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	SendSuccess(w, "", meta)
+}
+
+// Exists handles GET /api/files/exists?path=... without a 404 for missing paths
+func (h *FileHandler) Exists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	exists, isDir, err := h.service.Exists(r.URL.Query().Get("path"))
+	if err != nil {
+		SendError(w, "Failed to check path", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", map[string]bool{
+		"exists": exists,
+		"isDir":  isDir,
+	})
+}
+
+// Breadcrumbs handles GET /api/files/breadcrumbs?path=... and returns each
+// segment of the path with whether it exists
+func (h *FileHandler) Breadcrumbs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	crumbs, err := h.service.Breadcrumbs(r.URL.Query().Get("path"))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPath) {
+			SendError(w, "Invalid path", http.StatusBadRequest)
+			return
+		}
+		SendError(w, "Failed to resolve path", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", crumbs)
+}
+
+// Touch handles POST /api/files/touch?path=...&time=...&recursive=...
+func (h *FileHandler) Touch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		SendError(w, "Path is required", http.StatusBadRequest)
+		return
+	}
+
+	// Default to now, or use the provided RFC3339 time
+	modTime := time.Now()
+	if t := r.URL.Query().Get("time"); t != "" {
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			SendError(w, "Invalid time, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		modTime = parsed
+	}
+
+	recursive := r.URL.Query().Get("recursive") == "true"
+
+	if err := h.service.Touch(path, modTime, recursive); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			SendError(w, "File not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrRecursiveRequired):
+			SendError(w, "Path is a directory, set recursive=true to touch its contents", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrInvalidPath):
+			SendError(w, "Invalid path", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrNotSupported):
+			SendError(w, "Modification times cannot be changed on this storage backend", http.StatusNotImplemented)
+		default:
+			SendError(w, "Failed to update modification time", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	SendSuccess(w, "Modification time updated", map[string]interface{}{
+		"path":    path,
+		"modTime": modTime,
+	})
+}
+
+// Reindex handles POST /api/admin/reindex and starts a background rescan of storage
+func (h *FileHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The slot is held until the background run finishes
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	status, err := h.service.StartReindex(release)
+	if errors.Is(err, domain.ErrReindexRunning) {
+		release()
+		SendJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Message: "A reindex is already running",
+			Data:    status,
+		})
+		return
+	}
+
+	SendJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Message: "Reindex started",
+		Data:    status,
+	})
+}
+
+// ReindexStatus handles GET /api/admin/reindex/status
+func (h *FileHandler) ReindexStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	SendSuccess(w, "", h.service.ReindexStatus())
+}
@@ -0,0 +1,1255 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	fileService "gomanager/internal/application/file"
+	fileDomain "gomanager/internal/domain/file"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/repository"
+)
+
+func TestStatsByTypePaging(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{"a.jpg": "aaa", "b.jpg": "bb", "c.jpg": "c", "d.txt": ""})
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{"first page", "ext=jpg&pageSize=2", http.StatusOK, []string{"a.jpg", "b.jpg"}},
+		{"last page", "ext=.jpg&page=2&pageSize=2", http.StatusOK, []string{"c.jpg"}},
+		{"past the end", "ext=jpg&page=50", http.StatusOK, []string{}},
+		{"page that would overflow the offset", "ext=jpg&page=9223372036854775807&pageSize=1000", http.StatusBadRequest, nil},
+		{"zero page", "ext=jpg&page=0", http.StatusBadRequest, nil},
+		{"missing ext", "page=1", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(http.MethodGet, "/api/stats/by-type?"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.StatsByType(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp struct {
+				Data struct {
+					Files []struct{ Name string }
+				}
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Data.Files) != len(tt.want) {
+				t.Fatalf("files %+v, want %v", resp.Data.Files, tt.want)
+			}
+			for i, f := range resp.Data.Files {
+				if f.Name != tt.want[i] {
+					t.Fatalf("files %+v, want %v", resp.Data.Files, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		destination string
+		status      int
+		want        string // Path holding the source afterwards
+	}{
+		{"into a folder", "a.txt", "docs", http.StatusOK, "docs/a.txt"},
+		{"to a new path", "a.txt", "docs/renamed.txt", http.StatusOK, "docs/renamed.txt"},
+		{"name taken in the folder", "a.txt", "taken", http.StatusConflict, "a.txt"},
+		{"into a hidden folder", "a.txt", ".avatars", http.StatusNotFound, "a.txt"},
+		{"onto a hidden path", "a.txt", ".quarantine/a.txt", http.StatusNotFound, "a.txt"},
+		{"missing source", "nope.txt", "docs", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, dir := newTestFileHandler(t, map[string]string{
+				"a.txt":           "a",
+				"docs/keep":       "",
+				"taken/a.txt":     "other",
+				".avatars/u1.png": "png",
+				".quarantine/x":   "x",
+			})
+			body, _ := json.Marshal(map[string]string{"source": tt.source, "destination": tt.destination})
+			r := withUser(httptest.NewRequest(http.MethodPost, "/api/move", strings.NewReader(string(body))), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.Move(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.want == "" {
+				return
+			}
+			if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(tt.want))); err != nil || string(data) != "a" {
+				t.Fatalf("%s holds %q, %v; want the source", tt.want, data, err)
+			}
+			if data, _ := os.ReadFile(filepath.Join(dir, "taken", "a.txt")); string(data) != "other" {
+				t.Fatal("the existing file was replaced")
+			}
+		})
+	}
+}
+
+func TestUploadConflictPolicy(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   fileDomain.ConflictPolicy
+		wantOK bool
+	}{
+		{"", fileDomain.ConflictReject, true},
+		{"overwrite=true", fileDomain.ConflictOverwrite, true},
+		{"rename=true", fileDomain.ConflictRename, true},
+		{"overwrite=false&rename=false", fileDomain.ConflictReject, true},
+		{"overwrite=true&rename=true", "", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		got, ok := uploadConflictPolicy(w, httptest.NewRequest(http.MethodPost, "/api/upload?"+tt.query, nil))
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%q: got %q, %v; want %q, %v", tt.query, got, ok, tt.want, tt.wantOK)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", tt.query, w.Code)
+		}
+	}
+}
+
+func TestSignedDownload(t *testing.T) {
+	svc, _ := newTestFileService(t, newTestDB(t), map[string]string{"docs/a.txt": "hello"})
+	h := NewFileHandler(svc, UploadPolicy{}, fileService.NewDownloadSigner("secret"), "http://example.com", time.Hour, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+	signer := fileService.NewDownloadSigner("secret")
+
+	r := withUser(httptest.NewRequest(http.MethodPost, "/api/files/signed-url?path=docs/a.txt&expiresIn=60", nil), &user.User{ID: "u1", Role: user.RoleUser})
+	w := httptest.NewRecorder()
+	h.SignedURL(w, r)
+	var resp struct{ Data struct{ URL string } }
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	signed, err := url.Parse(resp.Data.URL)
+	if w.Code != http.StatusOK || err != nil || signed.Path != "/api/download/signed" {
+		t.Fatalf("signing: %d %s", w.Code, w.Body)
+	}
+
+	link := func(p string, expires time.Time) string {
+		return "/api/download/signed?" + url.Values{
+			"path":    {p},
+			"expires": {strconv.FormatInt(expires.Unix(), 10)},
+			"sig":     {signer.Sign(p, expires)},
+		}.Encode()
+	}
+	valid := signed.Query()
+	tampered := url.Values{"path": {"docs/b.txt"}, "expires": valid["expires"], "sig": valid["sig"]}
+	extended := url.Values{"path": valid["path"], "expires": {strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)}, "sig": valid["sig"]}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"issued link", signed.RequestURI(), http.StatusOK},
+		{"expired", link("docs/a.txt", time.Now().Add(-time.Minute)), http.StatusGone},
+		{"other path", "/api/download/signed?" + tampered.Encode(), http.StatusForbidden},
+		{"extended expiry", "/api/download/signed?" + extended.Encode(), http.StatusForbidden},
+		{"missing signature", "/api/download/signed?path=docs/a.txt&expires=1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No user: the signature alone grants access
+			w := httptest.NewRecorder()
+			h.SignedDownload(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK && w.Body.String() != "hello" {
+				t.Fatalf("served %q", w.Body)
+			}
+		})
+	}
+}
+
+func TestSignedURLRequest(t *testing.T) {
+	svc, _ := newTestFileService(t, newTestDB(t), map[string]string{"docs/a.txt": "hello"})
+	h := NewFileHandler(svc, UploadPolicy{}, fileService.NewDownloadSigner("secret"), "", time.Hour, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"path=docs/a.txt", http.StatusOK},
+		{"path=docs/a.txt&expiresIn=3600", http.StatusOK},
+		{"path=docs/a.txt&expiresIn=3601", http.StatusBadRequest},
+		{"path=docs/a.txt&expiresIn=0", http.StatusBadRequest},
+		{"path=docs", http.StatusBadRequest},
+		{"path=nope.txt", http.StatusNotFound},
+		{"", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := withUser(httptest.NewRequest(http.MethodPost, "/api/files/signed-url?"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleUser})
+		w := httptest.NewRecorder()
+		h.SignedURL(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%q: status %d, want %d: %s", tt.query, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+// helloSum is the SHA-256 of "hello"
+const helloSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+// storedFiles lists every file under dir, hidden staging folders included
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files
+}
+
+func TestUploadRawChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string
+		status   int
+		stored   bool
+	}{
+		{"no checksum", "", http.StatusOK, true},
+		{"matching", "sha256:" + helloSum, http.StatusOK, true},
+		{"mismatched", strings.Repeat("0", 64), http.StatusUnprocessableEntity, false},
+		{"malformed", "abc", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, dir := newTestFileService(t, newTestDB(t), map[string]string{"docs/.keep": ""})
+			h := NewFileHandler(svc, UploadPolicy{DefaultMaxFileSize: 1 << 20}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+
+			r := httptest.NewRequest(http.MethodPut, "/api/files/raw?path=docs&name=a.txt", strings.NewReader("hello"))
+			if tt.checksum != "" {
+				r.Header.Set(ChecksumHeader, tt.checksum)
+			}
+			w := httptest.NewRecorder()
+			h.UploadRaw(w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			want := []string{"docs/.keep"}
+			if tt.stored {
+				want = append(want, "docs/a.txt")
+			}
+			if got := storedFiles(t, dir); !slices.Equal(got, want) {
+				t.Fatalf("stored %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestUploadChecksums(t *testing.T) {
+	tests := []struct {
+		name      string
+		checksums []string // One per file, in order: a.txt then b.txt
+		status    int
+		stored    []string
+	}{
+		{"no checksums", nil, http.StatusOK, []string{"a.txt", "b.txt"}},
+		{"matching", []string{helloSum, ""}, http.StatusOK, []string{"a.txt", "b.txt"}},
+		{"one mismatched", []string{strings.Repeat("0", 64), ""}, http.StatusOK, []string{"b.txt"}},
+		{"all mismatched", []string{strings.Repeat("0", 64), strings.Repeat("0", 64)}, http.StatusUnprocessableEntity, nil},
+		{"malformed", []string{"abc"}, http.StatusBadRequest, nil},
+		{"more checksums than files", []string{helloSum, "", ""}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, dir := newTestFileService(t, newTestDB(t), nil)
+			h := NewFileHandler(svc, UploadPolicy{DefaultMaxFileSize: 1 << 20, MemoryLimit: 1 << 20}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+
+			var body strings.Builder
+			mw := multipart.NewWriter(&body)
+			for _, f := range []struct{ name, content string }{{"a.txt", "hello"}, {"b.txt", "world"}} {
+				fw, _ := mw.CreateFormFile("files", f.name)
+				io.WriteString(fw, f.content)
+			}
+			for _, c := range tt.checksums {
+				mw.WriteField("checksums", c)
+			}
+			mw.Close()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/upload", strings.NewReader(body.String()))
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			w := httptest.NewRecorder()
+			h.Upload(w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := storedFiles(t, dir); !slices.Equal(got, tt.stored) {
+				t.Fatalf("stored %q, want %q", got, tt.stored)
+			}
+		})
+	}
+}
+
+func TestRecentDownloads(t *testing.T) {
+	db := newTestDB(t)
+	svc, _ := newTestFileService(t, db, map[string]string{"docs/a.txt": "aaaa", "docs/b.txt": "b", "docs/c.mp4": "cccc"})
+	h := NewFileHandler(svc, UploadPolicy{}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, repository.NewDownloadHistoryRepository(db))
+	newTestUser(t, db, "u1", user.RoleUser)
+	newTestUser(t, db, "u2", user.RoleUser)
+	u1, u2 := &user.User{ID: "u1", Role: user.RoleUser}, &user.User{ID: "u2", Role: user.RoleUser}
+
+	for _, d := range []struct {
+		u      *user.User
+		target string
+		rng    string
+	}{
+		{u1, "/api/download/docs/a.txt", ""},
+		{u1, "/api/download/docs/b.txt?preview=true", ""}, // Inline previews don't count
+		{u1, "/api/download/docs/c.mp4", "bytes=2-"},      // Nor does a player seeking
+		{u2, "/api/download/docs/b.txt", ""},
+		{u1, "/api/download/docs/c.mp4", "bytes=0-"},
+	} {
+		r := withUser(httptest.NewRequest(http.MethodGet, d.target, nil), d.u)
+		if d.rng != "" {
+			r.Header.Set("Range", d.rng)
+		}
+		w := httptest.NewRecorder()
+		h.Download(w, r)
+		if w.Code != http.StatusOK && w.Code != http.StatusPartialContent {
+			t.Fatalf("%s: status %d", d.target, w.Code)
+		}
+	}
+
+	// Downloads are recorded in the background
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w := httptest.NewRecorder()
+		h.RecentDownloads(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/recent-downloads", nil), u1))
+		var resp struct{ Data []fileDomain.Download }
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		got = got[:0]
+		for _, d := range resp.Data {
+			got = append(got, d.Path)
+		}
+		if len(got) >= 2 {
+			break
+		}
+	}
+	if want := []string{"docs/c.mp4", "docs/a.txt"}; !slices.Equal(got, want) {
+		t.Fatalf("recent downloads %q, want %q", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	h.RecentDownloads(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/recent-downloads?limit=0", nil), u1))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status %d, want 400", w.Code)
+	}
+}
+
+func TestStatBatch(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"docs/a.txt":      "aaaa",
+		"docs/sub/b.txt":  "bb",
+		".avatars/u1.png": "png",
+	})
+	send := func(body string) *httptest.ResponseRecorder {
+		r := withUser(httptest.NewRequest(http.MethodPost, "/api/files/stat-batch", strings.NewReader(body)), &user.User{ID: "u1", Role: user.RoleUser})
+		w := httptest.NewRecorder()
+		h.StatBatch(w, r)
+		return w
+	}
+
+	w := send(`{"paths":["docs/a.txt","missing.txt","docs/sub","../etc/passwd",".avatars/u1.png","docs/sub/b.txt"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data struct {
+			Results []struct {
+				Path  string
+				Info  *struct{ Size int64 }
+				Error string
+			}
+			Files, Folders, TotalSize int64
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		path  string
+		size  int64
+		error string
+	}{
+		{"docs/a.txt", 4, ""},
+		{"missing.txt", 0, "not found"},
+		{"docs/sub", 0, ""},
+		{"../etc/passwd", 0, "invalid path"},
+		{".avatars/u1.png", 0, "not found"},
+		{"docs/sub/b.txt", 2, ""},
+	}
+	if len(resp.Data.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(resp.Data.Results), len(want))
+	}
+	for i, res := range resp.Data.Results {
+		if res.Path != want[i].path || res.Error != want[i].error || (res.Info == nil) != (want[i].error != "") {
+			t.Errorf("result %d = %+v, want %+v", i, res, want[i])
+		}
+		if res.Info != nil && want[i].size > 0 && res.Info.Size != want[i].size {
+			t.Errorf("%s: size %d, want %d", res.Path, res.Info.Size, want[i].size)
+		}
+	}
+	if resp.Data.Files != 2 || resp.Data.Folders != 1 || resp.Data.TotalSize != 6 {
+		t.Errorf("totals %d files, %d folders, %d bytes; want 2, 1, 6", resp.Data.Files, resp.Data.Folders, resp.Data.TotalSize)
+	}
+
+	for _, body := range []string{`{"paths":[]}`, `{"paths":`, fmt.Sprintf(`{"paths":[%s"x"]}`, strings.Repeat(`"x",`, maxStatBatch))} {
+		if w := send(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%.40s: status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestListNameFilter(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"Report-2024.pdf":    "aaaa",
+		"report-2025.txt":    "bb",
+		"summary.pdf":        "c",
+		"reports/q1.pdf":     "ddd",
+		"photos/holiday.jpg": "e",
+	})
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string
+	}{
+		{"no filter", "", http.StatusOK, []string{"Report-2024.pdf", "photos", "report-2025.txt", "reports", "summary.pdf"}},
+		{"prefix ignores case", "prefix=REPORT", http.StatusOK, []string{"Report-2024.pdf", "report-2025.txt", "reports"}},
+		{"glob ignores case", "glob=*.PDF", http.StatusOK, []string{"Report-2024.pdf", "summary.pdf"}},
+		{"prefix and glob", "prefix=rep&glob=*.pdf", http.StatusOK, []string{"Report-2024.pdf"}},
+		{"glob and type", "glob=*2*&type=document", http.StatusOK, []string{"Report-2024.pdf", "report-2025.txt"}},
+		{"prefix with sizes", "prefix=reports&withSizes=true", http.StatusOK, []string{"reports"}},
+		{"no match", "glob=*.zip", http.StatusOK, []string{}},
+		{"invalid glob", "glob=[", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(http.MethodGet, "/api/files?path=&"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.List(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp struct {
+				Data []struct {
+					Name string
+					Size int64
+				}
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, f := range resp.Data {
+				got = append(got, f.Name)
+				if f.Name == "reports" && strings.Contains(tt.query, "withSizes") && f.Size != 3 {
+					t.Errorf("reports size %d, want 3", f.Size)
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadIntoHiddenPaths(t *testing.T) {
+	existing := map[string]string{
+		".avatars/u2.png":   "avatar",
+		".uploads/abc.json": "{}",
+		".thumbnails/x.jpg": "thumb",
+		".quarantine/bad":   "bad",
+	}
+	tests := []struct {
+		name string
+		send func(h *FileHandler) *httptest.ResponseRecorder
+	}{
+		{"multipart into avatars", func(h *FileHandler) *httptest.ResponseRecorder {
+			return multipartUpload(h, ".avatars", "u2.png")
+		}},
+		{"multipart into tus metadata", func(h *FileHandler) *httptest.ResponseRecorder {
+			return multipartUpload(h, ".uploads", "abc.json")
+		}},
+		{"multipart into a hidden folder's subfolder", func(h *FileHandler) *httptest.ResponseRecorder {
+			return multipartUpload(h, ".Thumbnails/nested", "x.jpg")
+		}},
+		{"raw into thumbnails", func(h *FileHandler) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodPut, "/api/files/raw?path=.thumbnails&name=x.jpg&overwrite=true", strings.NewReader("evil"))
+			w := httptest.NewRecorder()
+			h.UploadRaw(w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+			return w
+		}},
+		{"mkdir inside quarantine", func(h *FileHandler) *httptest.ResponseRecorder {
+			return createFolder(h, ".quarantine/new")
+		}},
+		{"mkdir of a hidden folder", func(h *FileHandler) *httptest.ResponseRecorder {
+			return createFolder(h, "/.avatars/")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, dir := newTestFileService(t, newTestDB(t), existing)
+			h := NewFileHandler(svc, UploadPolicy{DefaultMaxFileSize: 1 << 20, MemoryLimit: 1 << 20}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+			if w := tt.send(h); w.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", w.Code, w.Body)
+			}
+			for p, content := range existing {
+				if data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p))); string(data) != content {
+					t.Errorf("%s holds %q, want %q", p, data, content)
+				}
+			}
+			if got := storedFiles(t, dir); len(got) != len(existing) {
+				t.Errorf("stored %q", got)
+			}
+			if _, err := os.Stat(filepath.Join(dir, ".quarantine", "new")); err == nil {
+				t.Error("folder was created")
+			}
+		})
+	}
+
+	// Visible targets are unaffected
+	h, dir := newTestFileHandler(t, existing)
+	h.uploadPolicy = UploadPolicy{DefaultMaxFileSize: 1 << 20, MemoryLimit: 1 << 20}
+	if w := multipartUpload(h, "docs", "avatars.png"); w.Code != http.StatusOK {
+		t.Fatalf("visible upload: status %d: %s", w.Code, w.Body)
+	}
+	if w := createFolder(h, "docs/.avatars-old"); w.Code != http.StatusOK {
+		t.Fatalf("visible mkdir: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs", "avatars.png")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// multipartUpload posts one file called name into path, replacing what's there
+func multipartUpload(h *FileHandler, path, name string) *httptest.ResponseRecorder {
+	var body strings.Builder
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("files", name)
+	io.WriteString(fw, "evil")
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/upload?overwrite=true&path="+url.QueryEscape(path), strings.NewReader(body.String()))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.Upload(w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+	return w
+}
+
+// createFolder posts a mkdir request for path
+func createFolder(h *FileHandler, path string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(fileDomain.CreateFolderRequest{Path: path})
+	r := httptest.NewRequest(http.MethodPost, "/api/folder", strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	h.CreateFolder(w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+	return w
+}
+
+func TestCapabilities(t *testing.T) {
+	h, _ := newTestFileHandler(t, nil)
+	tests := []struct {
+		role user.Role
+		want user.Capabilities
+	}{
+		{user.RoleViewer, user.Capabilities{CanRead: true}},
+		{user.RoleUser, user.Capabilities{CanRead: true, CanUpload: true, CanDelete: true, CanShare: true}},
+		{user.RoleAdmin, user.Capabilities{CanRead: true, CanUpload: true, CanDelete: true, CanShare: true, CanManageUsers: true}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Capabilities(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/capabilities", nil), &user.User{ID: "u1", Role: tt.role}))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp struct{ Data UserCapabilities }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Role != tt.role || resp.Data.Capabilities != tt.want {
+				t.Errorf("got %s %+v, want %+v", resp.Data.Role, resp.Data.Capabilities, tt.want)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.Capabilities(w, httptest.NewRequest(http.MethodGet, "/api/user/capabilities", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without a user: status %d, want 401", w.Code)
+	}
+}
+
+func TestUploadPolicyMaxFileSize(t *testing.T) {
+	policy := UploadPolicy{DefaultMaxFileSize: 100, MaxFileSizeByRole: map[user.Role]int64{user.RoleAdmin: 1000, user.RoleViewer: 0}}
+	for _, tt := range []struct {
+		role user.Role
+		want int64
+	}{
+		{user.RoleAdmin, 1000},
+		{user.RoleUser, 100},
+		{user.RoleViewer, 100}, // A zero override falls back to the default
+	} {
+		if got := policy.MaxFileSize(tt.role); got != tt.want {
+			t.Errorf("%s: %d bytes, want %d", tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestUploadSizeByRole(t *testing.T) {
+	tests := []struct {
+		role   user.Role
+		status int
+	}{
+		{user.RoleAdmin, http.StatusOK},
+		{user.RoleUser, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			svc, dir := newTestFileService(t, newTestDB(t), nil)
+			policy := UploadPolicy{DefaultMaxFileSize: 4 << 10, MaxFileSizeByRole: map[user.Role]int64{user.RoleAdmin: 1 << 20}}
+			h := NewFileHandler(svc, policy, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+
+			var body strings.Builder
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("files", "big.bin")
+			io.WriteString(fw, strings.Repeat("x", 64<<10))
+			mw.Close()
+			r := httptest.NewRequest(http.MethodPost, "/api/upload?path=", strings.NewReader(body.String()))
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+			w := httptest.NewRecorder()
+			h.Upload(w, withUser(r, &user.User{ID: "u1", Role: tt.role}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if stored := slices.Contains(storedFiles(t, dir), "big.bin"); stored != (tt.status == http.StatusOK) {
+				t.Errorf("stored = %v", stored)
+			}
+		})
+	}
+}
+
+func TestListTypeFilter(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"beach.jpg":      "a",
+		"logo.png":       "b",
+		"clip.mp4":       "c",
+		"song.mp3":       "d",
+		"report.pdf":     "e",
+		"archive.zip":    "f",
+		"photos/sea.jpg": "g",
+	})
+	tests := []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		{"type=image", http.StatusOK, []string{"beach.jpg", "logo.png", "photos"}},
+		{"type=video", http.StatusOK, []string{"clip.mp4", "photos"}},
+		{"type=audio", http.StatusOK, []string{"photos", "song.mp3"}},
+		{"type=document", http.StatusOK, []string{"photos", "report.pdf"}},
+		{"type=other", http.StatusBadRequest, nil},
+		{"type=pictures", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.List(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files?path=&"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp struct{ Data []struct{ Name string } }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, f := range resp.Data {
+				got = append(got, f.Name)
+			}
+			// Folders stay so the listing can still be navigated
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	large := strings.Repeat("0123456789abcdef log line\n", 100_000) // About 2.6MB
+	h, _ := newTestFileHandler(t, map[string]string{
+		"large.log":    large,
+		"small.json":   `{"a":1}`,
+		"accents.txt":  strings.Repeat("é", 10),
+		"image.png":    "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"folder/x.txt": "x",
+	})
+	tests := []struct {
+		name          string
+		query         string
+		status        int
+		wantContent   string
+		wantType      string
+		wantTruncated bool
+	}{
+		{"first 1KB", "path=large.log&bytes=1024", http.StatusOK, large[:1024], "text/plain; charset=utf-8", true},
+		{"default size", "path=large.log", http.StatusOK, large[:defaultPreviewBytes], "text/plain; charset=utf-8", true},
+		{"capped size", "path=large.log&bytes=99999999", http.StatusOK, large[:maxPreviewBytes], "text/plain; charset=utf-8", true},
+		{"whole small file", "path=small.json", http.StatusOK, `{"a":1}`, "application/json", false},
+		{"cut before a split character", "path=accents.txt&bytes=3", http.StatusOK, "é", "text/plain; charset=utf-8", true},
+		{"binary file", "path=image.png", http.StatusUnsupportedMediaType, "", "", false},
+		{"directory", "path=folder", http.StatusBadRequest, "", "", false},
+		{"missing file", "path=nope.txt", http.StatusNotFound, "", "", false},
+		{"zero bytes", "path=large.log&bytes=0", http.StatusBadRequest, "", "", false},
+		{"no path", "", http.StatusBadRequest, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Preview(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/preview?"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleViewer}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Data struct {
+					Content, ContentType string
+					Bytes                int
+					Truncated            bool
+				}
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := resp.Data
+			if got.Content != tt.wantContent || got.Bytes != len(tt.wantContent) || got.Truncated != tt.wantTruncated {
+				t.Errorf("got %d bytes, truncated %v; want %d, %v", got.Bytes, got.Truncated, len(tt.wantContent), tt.wantTruncated)
+			}
+			if !strings.HasPrefix(got.ContentType, strings.Split(tt.wantType, ";")[0]) {
+				t.Errorf("content type %q, want %q", got.ContentType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestInfo(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"docs/report.pdf": "%PDF-1.4",
+		"docs/notes.txt":  "hello",
+	})
+	tests := []struct {
+		name            string
+		path            string
+		status          int
+		wantName        string
+		wantSize        int64
+		wantDir         bool
+		wantContentType string
+	}{
+		{"file", "docs/notes.txt", http.StatusOK, "notes.txt", 5, false, "text/plain"},
+		{"directory", "docs", http.StatusOK, "docs", -1, true, ""},
+		{"missing", "docs/nope.txt", http.StatusNotFound, "", 0, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Info(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/info?path="+tt.path, nil), &user.User{ID: "u1", Role: user.RoleViewer}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct{ Data fileDomain.FileInfo }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			got := resp.Data
+			if got.Name != tt.wantName || got.IsDir != tt.wantDir || got.Path != tt.path || got.ModTime.IsZero() {
+				t.Errorf("got %+v", got)
+			}
+			if tt.wantSize >= 0 && got.Size != tt.wantSize {
+				t.Errorf("size %d, want %d", got.Size, tt.wantSize)
+			}
+			if !strings.HasPrefix(got.ContentType, tt.wantContentType) || (tt.wantContentType == "") != (got.ContentType == "") {
+				t.Errorf("content type %q, want %q", got.ContentType, tt.wantContentType)
+			}
+		})
+	}
+}
+
+func TestExists(t *testing.T) {
+	h, dir := newTestFileHandler(t, map[string]string{
+		"docs/notes.txt": "hello",
+	})
+	tests := []struct {
+		name       string
+		path       string
+		wantExists bool
+		wantDir    bool
+	}{
+		{"existing file", "docs/notes.txt", true, false},
+		{"existing folder", "docs", true, true},
+		{"trailing slash", "docs/", true, true},
+		{"missing path", "docs/nope", false, false},
+		{"escaping the root", "../" + filepath.Base(dir), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Exists(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/exists?path="+url.QueryEscape(tt.path), nil), &user.User{ID: "u1", Role: user.RoleViewer}))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if strings.Contains(w.Body.String(), dir) {
+				t.Fatalf("response leaks the storage path: %s", w.Body)
+			}
+			var resp struct{ Data struct{ Exists, IsDir bool } }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Exists != tt.wantExists || resp.Data.IsDir != tt.wantDir {
+				t.Fatalf("exists %v, isDir %v; want %v, %v", resp.Data.Exists, resp.Data.IsDir, tt.wantExists, tt.wantDir)
+			}
+		})
+	}
+}
+
+func TestListAndStatsRevalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		serve  func(h *FileHandler, w http.ResponseWriter, r *http.Request)
+	}{
+		{"listing", "/api/files?path=docs", (*FileHandler).List},
+		{"stats", "/api/stats", (*FileHandler).Stats},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestFileHandler(t, map[string]string{"docs/a.txt": "a"})
+			h.uploadPolicy = UploadPolicy{DefaultMaxFileSize: 1 << 20, MemoryLimit: 1 << 20}
+			get := func(etag string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+				if etag != "" {
+					r.Header.Set("If-None-Match", etag)
+				}
+				w := httptest.NewRecorder()
+				tt.serve(h, w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+				return w
+			}
+
+			first := get("")
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" || first.Header().Get("Cache-Control") != "no-cache" {
+				t.Fatalf("status %d, ETag %q, Cache-Control %q", first.Code, etag, first.Header().Get("Cache-Control"))
+			}
+
+			if w := get(etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Fatalf("unchanged: status %d with %d bytes, want an empty 304", w.Code, w.Body.Len())
+			}
+
+			if w := multipartUpload(h, "docs", "b.txt"); w.Code != http.StatusOK {
+				t.Fatalf("upload: status %d: %s", w.Code, w.Body)
+			}
+			w := get(etag)
+			if w.Code != http.StatusOK || w.Header().Get("ETag") == etag || !strings.Contains(w.Body.String(), `"success":true`) {
+				t.Fatalf("after upload: status %d, ETag %q; want a fresh 200", w.Code, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"no validator", "", false},
+		{"same tag", `W/"v1"`, true},
+		{"strong form of the tag", `"v1"`, true},
+		{"one of several", `W/"v0", W/"v1"`, true},
+		{"any", "*", true},
+		{"other tag", `W/"v2"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+			got := NotModified(w, r, `W/"v1"`)
+			if got != tt.want || (w.Code == http.StatusNotModified) != tt.want {
+				t.Fatalf("NotModified = %v with status %d, want %v", got, w.Code, tt.want)
+			}
+			if w.Header().Get("ETag") != `W/"v1"` {
+				t.Fatalf("ETag %q not set", w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestTargetIsAFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		send   func(h *FileHandler) *httptest.ResponseRecorder
+		status int
+	}{
+		{"upload into a file", func(h *FileHandler) *httptest.ResponseRecorder {
+			return multipartUpload(h, "notes.txt", "a.txt")
+		}, http.StatusBadRequest},
+		{"upload below a file", func(h *FileHandler) *httptest.ResponseRecorder {
+			return multipartUpload(h, "notes.txt/sub", "a.txt")
+		}, http.StatusBadRequest},
+		{"mkdir over a file", func(h *FileHandler) *httptest.ResponseRecorder {
+			return createFolder(h, "notes.txt")
+		}, http.StatusBadRequest},
+		{"mkdir below a file", func(h *FileHandler) *httptest.ResponseRecorder {
+			return createFolder(h, "docs/notes.txt/new")
+		}, http.StatusBadRequest},
+		{"upload into a new folder", func(h *FileHandler) *httptest.ResponseRecorder {
+			return multipartUpload(h, "docs/new", "a.txt")
+		}, http.StatusOK},
+		{"mkdir next to a file", func(h *FileHandler) *httptest.ResponseRecorder {
+			return createFolder(h, "docs/notes")
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := map[string]string{"notes.txt": "n", "docs/notes.txt": "n"}
+			h, dir := newTestFileHandler(t, existing)
+			h.uploadPolicy = UploadPolicy{DefaultMaxFileSize: 1 << 20, MemoryLimit: 1 << 20}
+			w := tt.send(h)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusBadRequest && !strings.Contains(w.Body.String(), "Target is not a directory") {
+				t.Fatalf("message %s", w.Body)
+			}
+			for p, content := range existing {
+				if data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(p))); string(data) != content {
+					t.Errorf("%s holds %q, want %q", p, data, content)
+				}
+			}
+		})
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"a/b/notes.txt":  "n",
+		".avatars/u.png": "png",
+	})
+	type crumb = fileDomain.Breadcrumb
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   []crumb
+	}{
+		{"root", "", http.StatusOK, []crumb{}},
+		{"slash only", "/", http.StatusOK, []crumb{}},
+		{"nested folders", "a/b", http.StatusOK, []crumb{
+			{Name: "a", Path: "a", Exists: true, IsDir: true},
+			{Name: "b", Path: "a/b", Exists: true, IsDir: true},
+		}},
+		{"file at the end", "/a/b/notes.txt/", http.StatusOK, []crumb{
+			{Name: "a", Path: "a", Exists: true, IsDir: true},
+			{Name: "b", Path: "a/b", Exists: true, IsDir: true},
+			{Name: "notes.txt", Path: "a/b/notes.txt", Exists: true},
+		}},
+		{"missing tail", "a/x/y", http.StatusOK, []crumb{
+			{Name: "a", Path: "a", Exists: true, IsDir: true},
+			{Name: "x", Path: "a/x"},
+			{Name: "y", Path: "a/x/y"},
+		}},
+		{"below a file", "a/b/notes.txt/c", http.StatusOK, []crumb{
+			{Name: "a", Path: "a", Exists: true, IsDir: true},
+			{Name: "b", Path: "a/b", Exists: true, IsDir: true},
+			{Name: "notes.txt", Path: "a/b/notes.txt", Exists: true},
+			{Name: "c", Path: "a/b/notes.txt/c"},
+		}},
+		{"hidden folder", ".avatars", http.StatusOK, []crumb{{Name: ".avatars", Path: ".avatars"}}},
+		{"traversal", "a/../../etc", http.StatusBadRequest, nil},
+		{"backslash traversal", `a\..\b`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Breadcrumbs(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/breadcrumbs?path="+url.QueryEscape(tt.path), nil), &user.User{ID: "u1", Role: user.RoleViewer}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct{ Data []crumb }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data == nil || !slices.Equal(resp.Data, tt.want) {
+				t.Fatalf("got %+v, want %+v", resp.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	existing := map[string]string{"docs/a.txt": "aaaa", "docs/sub/b.txt": "bb", ".avatars/u.png": "png"}
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		wantFiles int64
+		wantSize  int64
+	}{
+		{"folder", "docs", http.StatusOK, 2, 6},
+		{"root", "/", http.StatusForbidden, 0, 0},
+		{"missing", "nope", http.StatusNotFound, 0, 0},
+		{"hidden folder", ".avatars", http.StatusNotFound, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, dir := newTestFileHandler(t, existing)
+			body, _ := json.Marshal(fileDomain.DeleteRequest{Path: tt.path})
+			w := httptest.NewRecorder()
+			h.Delete(w, withUser(httptest.NewRequest(http.MethodPost, "/api/delete?dryRun=true", strings.NewReader(string(body))), &user.User{ID: "u1", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := storedFiles(t, dir); len(got) != len(existing) {
+				t.Fatalf("dry run left %q", got)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct{ Data fileDomain.DeletePreview }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Files != tt.wantFiles || resp.Data.TotalSize != tt.wantSize {
+				t.Fatalf("preview %d files, %d bytes; want %d, %d", resp.Data.Files, resp.Data.TotalSize, tt.wantFiles, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestUploadRaw(t *testing.T) {
+	data := make([]byte, 200<<10)
+	for i := range data {
+		data[i] = byte(i*7 + i/256)
+	}
+	tests := []struct {
+		name       string
+		query      string
+		body       []byte
+		unsized    bool // Sent without a Content-Length
+		status     int
+		wantPath   string // Where data should end up
+		wantStored []string
+	}{
+		{"new nested folders", "path=a/b/c&name=blob.bin", data, false, http.StatusOK, "a/b/c/blob.bin", []string{"a/b/c/blob.bin", "docs/old.bin"}},
+		{"existing name kept", "path=docs&name=old.bin", data, false, http.StatusConflict, "", []string{"docs/old.bin"}},
+		{"overwrite", "path=docs&name=old.bin&overwrite=true", data, false, http.StatusOK, "docs/old.bin", []string{"docs/old.bin"}},
+		{"rename", "path=docs&name=old.bin&rename=true", data, false, http.StatusOK, "docs/old (1).bin", []string{"docs/old (1).bin", "docs/old.bin"}},
+		{"over the limit", "path=docs&name=big.bin", make([]byte, 300<<10), false, http.StatusRequestEntityTooLarge, "", []string{"docs/old.bin"}},
+		{"over the limit unsized", "path=docs&name=big.bin", make([]byte, 300<<10), true, http.StatusRequestEntityTooLarge, "", []string{"docs/old.bin"}},
+		{"name with a path stays in the folder", "path=docs&name=../escape.bin", data, false, http.StatusOK, "docs/escape.bin", []string{"docs/escape.bin", "docs/old.bin"}},
+		{"dot-dot name", "path=docs&name=..", data, false, http.StatusBadRequest, "", []string{"docs/old.bin"}},
+		{"path escaping the root", "path=../..&name=x.bin", data, false, http.StatusOK, "x.bin", []string{"docs/old.bin", "x.bin"}},
+		{"no name", "path=docs", data, false, http.StatusBadRequest, "", []string{"docs/old.bin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, dir := newTestFileHandler(t, map[string]string{"docs/old.bin": "old"})
+			h.uploadPolicy = UploadPolicy{DefaultMaxFileSize: 256 << 10}
+
+			r := httptest.NewRequest(http.MethodPut, "/api/files/raw?"+tt.query, bytes.NewReader(tt.body))
+			if tt.unsized {
+				r.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			h.UploadRaw(w, withUser(r, &user.User{ID: "u1", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := storedFiles(t, dir); !slices.Equal(got, tt.wantStored) {
+				t.Fatalf("stored %q, want %q", got, tt.wantStored)
+			}
+			if tt.wantPath == "" {
+				if got, _ := os.ReadFile(filepath.Join(dir, "docs", "old.bin")); string(got) != "old" {
+					t.Fatal("existing file changed")
+				}
+				return
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(tt.wantPath)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("stored %d bytes that differ from the %d sent", len(got), len(data))
+			}
+			var resp struct{ Data fileDomain.FileInfo }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Path != tt.wantPath || resp.Data.Size != int64(len(data)) || resp.Data.IsDir {
+				t.Fatalf("returned %+v", resp.Data)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{
+		"backup/a.txt":  "a",
+		"current/a.txt": "a",
+		"current/b.txt": "b",
+		"notes.txt":     "n",
+	})
+	tests := []struct {
+		name      string
+		query     string
+		status    int
+		wantRight []string
+	}{
+		{"folders", "left=backup&right=current", http.StatusOK, []string{"b.txt"}},
+		{"missing side", "left=backup", http.StatusBadRequest, nil},
+		{"unknown folder", "left=backup&right=nope", http.StatusNotFound, nil},
+		{"file instead of folder", "left=backup&right=notes.txt", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.Diff(w, withUser(httptest.NewRequest(http.MethodGet, "/api/files/diff?"+tt.query, nil), &user.User{ID: "u1", Role: user.RoleViewer}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct{ Data fileDomain.DirDiff }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var right []string
+			for _, info := range resp.Data.OnlyRight {
+				right = append(right, info.Path)
+			}
+			if !slices.Equal(right, tt.wantRight) || len(resp.Data.OnlyLeft) != 0 {
+				t.Fatalf("only left %+v, only right %v; want none, %v", resp.Data.OnlyLeft, right, tt.wantRight)
+			}
+		})
+	}
+}
+
+func TestListRawResponse(t *testing.T) {
+	h, _ := newTestFileHandler(t, map[string]string{"docs/a.txt": "a", "docs/b.txt": "b"})
+	tests := []struct {
+		name   string
+		target string
+		header string
+		status int
+		raw    bool // Whether the body is the bare listing
+	}{
+		{"enveloped", "/api/files?path=docs", "", http.StatusOK, false},
+		{"raw header", "/api/files?path=docs", "raw", http.StatusOK, true},
+		{"raw header any case", "/api/files?path=docs", "RAW", http.StatusOK, true},
+		{"raw query", "/api/files?path=docs&raw=true", "", http.StatusOK, true},
+		{"other format", "/api/files?path=docs", "envelope", http.StatusOK, false},
+		{"errors keep their body", "/api/files?path=missing", "raw", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(http.MethodGet, tt.target, nil), &user.User{ID: "u1", Role: user.RoleUser})
+			if tt.header != "" {
+				r.Header.Set(ResponseFormatHeader, tt.header)
+			}
+			// What the router's RawResponses middleware does
+			rec := httptest.NewRecorder()
+			var w http.ResponseWriter = rec
+			if WantsRaw(r) {
+				w = RawWriter(w)
+			}
+			h.List(w, r)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+
+			var names []string
+			if tt.raw {
+				var files []fileDomain.FileInfo
+				if err := json.Unmarshal(rec.Body.Bytes(), &files); err != nil {
+					t.Fatalf("not a bare listing: %s", rec.Body)
+				}
+				for _, f := range files {
+					names = append(names, f.Name)
+				}
+			} else {
+				var resp Response
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Success != (tt.status == http.StatusOK) || (!resp.Success && resp.Message == "") {
+					t.Fatalf("envelope %+v", resp)
+				}
+				if files, ok := resp.Data.([]any); ok {
+					for _, f := range files {
+						names = append(names, f.(map[string]any)["name"].(string))
+					}
+				}
+			}
+			if tt.status == http.StatusOK {
+				slices.Sort(names)
+				if !slices.Equal(names, []string{"a.txt", "b.txt"}) {
+					t.Fatalf("listed %v", names)
+				}
+			}
+		})
+	}
+}
@@ -1,17 +1,16 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"gomanager/internal/domain/user"
 	"gomanager/internal/infrastructure/config"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 // GoogleAdsHandler handles Google Ads API calls
@@ -19,26 +18,18 @@ type GoogleAdsHandler struct {
 	config      *config.Config
 	userRepo    user.Repository
 	oauthConfig *oauth2.Config
+	timeout     time.Duration // Per call to Google
 }
 
 // NewGoogleAdsHandler creates a new Google Ads handler
 func NewGoogleAdsHandler(cfg *config.Config, userRepo user.Repository) *GoogleAdsHandler {
-	oauthConfig := &oauth2.Config{
-		ClientID:     cfg.GoogleClientID,
-		ClientSecret: cfg.GoogleClientSecret,
-		RedirectURL:  cfg.BaseURL + "/api/auth/google/callback",
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-			"https://www.googleapis.com/auth/adwords",
-		},
-		Endpoint: google.Endpoint,
-	}
+	oauthConfig := newGoogleOAuthConfig(cfg)
 
 	return &GoogleAdsHandler{
 		config:      cfg,
 		userRepo:    userRepo,
 		oauthConfig: oauthConfig,
+		timeout:     time.Duration(cfg.GoogleHTTPTimeout) * time.Second,
 	}
 }
 
@@ -89,19 +80,10 @@ type PerformanceReport struct {
 	Date         string  `json:"date"`
 }
 
-// getOAuthClient creates an OAuth2 client for the user
-func (h *GoogleAdsHandler) getOAuthClient(u *user.User) (*http.Client, error) {
-	if u.GoogleToken == "" {
-		return nil, ErrNoGoogleToken
-	}
-
-	token := &oauth2.Token{
-		RefreshToken: u.GoogleToken,
-		TokenType:    "Bearer",
-	}
-
-	tokenSource := h.oauthConfig.TokenSource(context.Background(), token)
-	return oauth2.NewClient(context.Background(), tokenSource), nil
+// getOAuthClient creates an OAuth2 client for the user whose calls end with
+// the inbound request
+func (h *GoogleAdsHandler) getOAuthClient(r *http.Request, u *user.User, acceptedScopes ...string) (*http.Client, error) {
+	return newGoogleClient(r.Context(), h.oauthConfig, h.userRepo, u, h.timeout, acceptedScopes...)
 }
 
 // ListCampaigns handles GET /api/google/ads/campaigns
@@ -117,9 +99,9 @@ func (h *GoogleAdsHandler) ListCampaigns(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, adsScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -137,7 +119,7 @@ func (h *GoogleAdsHandler) ListCampaigns(w http.ResponseWriter, r *http.Request)
 
 	resp, err := client.Get(apiURL)
 	if err != nil {
-		SendError(w, "Failed to fetch campaigns", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch campaigns")
 		return
 	}
 	defer resp.Body.Close()
@@ -180,9 +162,9 @@ func (h *GoogleAdsHandler) GetCampaignPerformance(w http.ResponseWriter, r *http
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, adsScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -218,7 +200,7 @@ func (h *GoogleAdsHandler) GetCampaignPerformance(w http.ResponseWriter, r *http
 
 	resp, err := client.Get(apiURL)
 	if err != nil {
-		SendError(w, "Failed to fetch performance data", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch performance data")
 		return
 	}
 	defer resp.Body.Close()
@@ -263,9 +245,9 @@ func (h *GoogleAdsHandler) CreateCampaign(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	_, err := h.getOAuthClient(u)
+	_, err := h.getOAuthClient(r, u, adsScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
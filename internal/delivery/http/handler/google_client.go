@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// ErrGoogleReconnectRequired is returned when the stored refresh token was rejected by Google
+var ErrGoogleReconnectRequired = &googleError{"Google authorization expired, please reconnect your Google account"}
+
+// ErrGoogleScopeNotGranted is returned when the user's grant lacks the scope an endpoint needs
+var ErrGoogleScopeNotGranted = &googleError{"Google scope not granted, enable it and reconnect your Google account"}
+
+// Scopes accepted by each group of Google endpoints; any one of them is enough
+var (
+	calendarReadScopes = []string{
+		"https://www.googleapis.com/auth/calendar",
+		"https://www.googleapis.com/auth/calendar.readonly",
+		"https://www.googleapis.com/auth/calendar.events",
+		"https://www.googleapis.com/auth/calendar.events.readonly",
+	}
+	calendarWriteScopes = []string{
+		"https://www.googleapis.com/auth/calendar",
+		"https://www.googleapis.com/auth/calendar.events",
+	}
+	calendarFreeBusyScopes = append([]string{
+		"https://www.googleapis.com/auth/calendar.freebusy",
+	}, calendarReadScopes...)
+	tasksReadScopes = []string{
+		"https://www.googleapis.com/auth/tasks",
+		"https://www.googleapis.com/auth/tasks.readonly",
+	}
+	tasksWriteScopes = []string{
+		"https://www.googleapis.com/auth/tasks",
+	}
+	driveReadScopes = []string{
+		"https://www.googleapis.com/auth/drive",
+		"https://www.googleapis.com/auth/drive.file",
+		"https://www.googleapis.com/auth/drive.readonly",
+	}
+	driveWriteScopes = []string{
+		"https://www.googleapis.com/auth/drive",
+		"https://www.googleapis.com/auth/drive.file",
+	}
+	adsScopes = []string{
+		"https://www.googleapis.com/auth/adwords",
+	}
+)
+
+// newGoogleOAuthConfig builds the OAuth2 config shared by the Google handlers
+func newGoogleOAuthConfig(cfg *config.Config) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.GoogleClientID,
+		ClientSecret: cfg.GoogleClientSecret,
+		RedirectURL:  cfg.PublicURL() + "/api/auth/google/callback",
+		Scopes:       cfg.GoogleScopes,
+		Endpoint:     google.Endpoint,
+	}
+}
+
+// hasAnyScope returns true if granted contains at least one of accepted
+func hasAnyScope(granted, accepted []string) bool {
+	for _, g := range granted {
+		for _, a := range accepted {
+			if g == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newGoogleClient creates an OAuth2 client from the user's stored refresh token.
+// The token is refreshed up front so a revoked or expired grant is detected here:
+// the stored token is cleared (marking the user disconnected) and
+// ErrGoogleReconnectRequired is returned. If acceptedScopes is non-empty, the
+// refreshed token must carry at least one of them.
+//
+// Every call made with the client, including token refreshes, is bounded by
+// timeout (0 = none) and cancelled along with ctx, normally the inbound
+// request's context.
+func newGoogleClient(ctx context.Context, oauthConfig *oauth2.Config, userRepo user.Repository, u *user.User, timeout time.Duration, acceptedScopes ...string) (*http.Client, error) {
+	ctx, accessToken, tokenSource, err := refreshGoogleToken(ctx, oauthConfig, userRepo, u, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Google reports the granted scopes on refresh; skip the check if it doesn't
+	if granted, _ := accessToken.Extra("scope").(string); granted != "" && len(acceptedScopes) > 0 {
+		if !hasAnyScope(strings.Fields(granted), acceptedScopes) {
+			return nil, ErrGoogleScopeNotGranted
+		}
+	}
+
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(accessToken, tokenSource)), nil
+}
+
+// refreshGoogleToken exchanges the user's stored refresh token for an access
+// token, clearing the stored token when Google reports the grant invalid
+// (see newGoogleClient). The returned context carries the bounded base client for
+// further oauth2 calls.
+func refreshGoogleToken(ctx context.Context, oauthConfig *oauth2.Config, userRepo user.Repository, u *user.User, timeout time.Duration) (context.Context, *oauth2.Token, oauth2.TokenSource, error) {
+	if u.GoogleToken == "" {
+		return nil, nil, nil, ErrNoGoogleToken
+	}
+
+	token := &oauth2.Token{
+		RefreshToken: u.GoogleToken,
+		TokenType:    "Bearer",
+	}
+
+	// oauth2 takes its base client from the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, newGoogleHTTPClient(ctx, timeout))
+
+	tokenSource := oauthConfig.TokenSource(ctx, token)
+	accessToken, err := tokenSource.Token()
+	if err != nil {
+		// Only invalid_grant means the grant is gone; rate limits and other
+		// client errors leave the stored token for the next attempt
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			u.GoogleToken = ""
+			if err := userRepo.Update(u); err != nil {
+				log.Printf("failed to clear revoked Google token for user %s: %v", u.ID, err)
+				return nil, nil, nil, fmt.Errorf("%w: %v", ErrGoogleReconnectRequired, err)
+			}
+			return nil, nil, nil, ErrGoogleReconnectRequired
+		}
+		return nil, nil, nil, err
+	}
+	return ctx, accessToken, tokenSource, nil
+}
+
+// newGoogleHTTPClient returns a plain client for Google calls that don't
+// need the user's authorization, bounded like newGoogleClient's
+func newGoogleHTTPClient(ctx context.Context, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &contextTransport{ctx: ctx, timeout: timeout, base: http.DefaultTransport},
+	}
+}
+
+// contextTransport ties requests to ctx as well as their own context, so
+// callers using client.Get and friends are still cancelled with ctx. The
+// timeout is applied here rather than as http.Client.Timeout, which would
+// go through oauth2.Transport's deprecated CancelRequest.
+type contextTransport struct {
+	ctx     context.Context
+	timeout time.Duration
+	base    http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	stop := context.AfterFunc(t.ctx, func() { cancel(context.Cause(t.ctx)) })
+	cancelTimeout := context.CancelFunc(func() {})
+	if t.timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, t.timeout)
+	}
+	release := func() {
+		stop()
+		cancelTimeout()
+		cancel(nil)
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The body is still read under ctx, so release it only once closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// isTimeout reports whether err comes from a Google call that ran out of time
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// sendGoogleRequestError writes the response for a failed call to a Google
+// API: 504 when it timed out, otherwise a 500 with message
+func sendGoogleRequestError(w http.ResponseWriter, err error, message string) {
+	if isTimeout(err) {
+		SendError(w, "Google did not respond in time", http.StatusGatewayTimeout)
+		return
+	}
+	SendError(w, message, http.StatusInternalServerError)
+}
+
+// sendGoogleClientError writes the response for a failed newGoogleClient call
+func sendGoogleClientError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrGoogleReconnectRequired):
+		SendError(w, ErrGoogleReconnectRequired.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrGoogleScopeNotGranted):
+		SendError(w, ErrGoogleScopeNotGranted.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrNoGoogleToken):
+		SendError(w, "Google account not connected", http.StatusBadRequest)
+	case isTimeout(err):
+		SendError(w, "Google did not respond in time", http.StatusGatewayTimeout)
+	default:
+		SendError(w, "Failed to authenticate with Google", http.StatusBadGateway)
+	}
+}
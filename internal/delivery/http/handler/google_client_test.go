@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// failingUpdates is a user repository whose updates always fail
+type failingUpdates struct {
+	user.Repository
+}
+
+func (failingUpdates) Update(*user.User) error {
+	return errors.New("database is read-only")
+}
+
+func TestRefreshGoogleToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		failUpdate  bool
+		wantErr     error // nil: any error, unless wantOK
+		wantOK      bool
+		wantCleared bool
+	}{
+		{"valid grant", http.StatusOK, `{"access_token":"at","token_type":"Bearer","expires_in":3600}`, false, nil, true, false},
+		{"revoked grant", http.StatusBadRequest, `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`, false, ErrGoogleReconnectRequired, false, true},
+		{"revoked grant, update fails", http.StatusBadRequest, `{"error":"invalid_grant"}`, true, ErrGoogleReconnectRequired, false, false},
+		{"rate limited", http.StatusTooManyRequests, `{"error":"rate_limit_exceeded"}`, false, nil, false, false},
+		{"other client error", http.StatusBadRequest, `{"error":"invalid_request"}`, false, nil, false, false},
+		{"unauthorized client", http.StatusUnauthorized, `{"error":"invalid_client"}`, false, nil, false, false},
+		{"server error", http.StatusServiceUnavailable, `{"error":"backend_error"}`, false, nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			db := newTestDB(t)
+			u := newTestUser(t, db, "u1", user.RoleUser)
+			u.GoogleToken = "refresh-token"
+			users := repository.NewUserRepository(db)
+			if err := users.Update(u); err != nil {
+				t.Fatal(err)
+			}
+			repo := users
+			if tt.failUpdate {
+				repo = failingUpdates{users}
+			}
+
+			cfg := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: srv.URL, AuthStyle: oauth2.AuthStyleInParams}}
+			_, token, _, err := refreshGoogleToken(context.Background(), cfg, repo, u, 0)
+
+			switch {
+			case tt.wantOK:
+				if err != nil || token.AccessToken != "at" {
+					t.Fatalf("got %v, %v; want the access token", token, err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err == nil || errors.Is(err, ErrGoogleReconnectRequired) {
+					t.Fatalf("got %v, want a retryable error", err)
+				}
+			}
+
+			stored, err := users.GetByID("u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cleared := stored.GoogleToken == ""; cleared != tt.wantCleared {
+				t.Fatalf("stored token cleared = %v, want %v", cleared, tt.wantCleared)
+			}
+		})
+	}
+}
+
+func TestGoogleTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	tests := []struct {
+		name   string
+		slow   string // Path of the stub endpoint that hangs
+		call   func(h *GoogleServicesHandler) http.HandlerFunc
+		status int
+	}{
+		{"grants, slow token refresh", "/token", func(h *GoogleServicesHandler) http.HandlerFunc { return h.GoogleGrants }, http.StatusGatewayTimeout},
+		{"grants, slow tokeninfo", "/tokeninfo", func(h *GoogleServicesHandler) http.HandlerFunc { return h.GoogleGrants }, http.StatusGatewayTimeout},
+		{"calendars, slow token refresh", "/token", func(h *GoogleServicesHandler) http.HandlerFunc { return h.ListCalendars }, http.StatusGatewayTimeout},
+		{"grants, prompt", "", func(h *GoogleServicesHandler) http.HandlerFunc { return h.GoogleGrants }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _, u := newTestGoogleHandler(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == tt.slow {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(10 * timeout):
+					}
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/token":
+					w.Write([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":3600}`))
+				case "/tokeninfo":
+					w.Write([]byte(`{"scope":"openid","exp":"1900000000"}`))
+				}
+			}, "refresh-token")
+			h.timeout = timeout
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			tt.call(h)(w, withUser(httptest.NewRequest(http.MethodGet, "/api/google", nil), u))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if elapsed := time.Since(start); elapsed > 5*timeout {
+				t.Errorf("took %v with a %v timeout", elapsed, timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Google endpoints for inspecting and revoking tokens
+const (
+	googleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+	googleRevokeURL    = "https://oauth2.googleapis.com/revoke"
+)
+
+// GoogleGrants describes what the user's Google connection allows
+type GoogleGrants struct {
+	Scopes    []string  `json:"scopes"`
+	Email     string    `json:"email,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"` // Of the current access token; the grant itself lasts until revoked
+}
+
+// GoogleGrants handles GET /api/google/grants and reports the scopes the
+// user granted, as Google's tokeninfo endpoint sees them
+func (h *GoogleServicesHandler) GoogleGrants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// A revoked grant fails here and is cleared like on any other Google call
+	ctx, accessToken, _, err := refreshGoogleToken(r.Context(), h.oauthConfig, h.userRepo, u, h.timeout)
+	if err != nil {
+		sendGoogleClientError(w, err)
+		return
+	}
+
+	resp, err := newGoogleHTTPClient(ctx, h.timeout).Get(h.tokenInfoURL + "?access_token=" + url.QueryEscape(accessToken.AccessToken))
+	if err != nil {
+		sendGoogleRequestError(w, err, "Failed to fetch Google grants")
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		SendError(w, "Failed to fetch Google grants", http.StatusBadGateway)
+		return
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+		Email string `json:"email"`
+		Exp   string `json:"exp"` // Unix seconds, sent as a string
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		SendError(w, "Failed to parse Google grants", http.StatusInternalServerError)
+		return
+	}
+
+	grants := GoogleGrants{
+		Scopes:    strings.Fields(info.Scope),
+		Email:     info.Email,
+		ExpiresAt: accessToken.Expiry,
+	}
+	if exp, err := strconv.ParseInt(info.Exp, 10, 64); err == nil {
+		grants.ExpiresAt = time.Unix(exp, 0)
+	}
+
+	SendSuccess(w, "", grants)
+}
+
+// RevokeGoogle handles POST /api/google/revoke. It revokes the grant at
+// Google, not just in this app, and clears the stored token. A token Google
+// no longer recognizes counts as already revoked.
+func (h *GoogleServicesHandler) RevokeGoogle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if u.GoogleToken == "" {
+		SendError(w, "Google account not connected", http.StatusBadRequest)
+		return
+	}
+
+	// Revoking the refresh token also revokes its access tokens
+	form := url.Values{"token": {u.GoogleToken}}
+	resp, err := newGoogleHTTPClient(r.Context(), h.timeout).PostForm(h.revokeURL, form)
+	if err != nil {
+		sendGoogleRequestError(w, err, "Failed to revoke Google access")
+		return
+	}
+	resp.Body.Close()
+
+	// Google answers 400 invalid_token for tokens that are already revoked
+	// or expired; anything else means the grant may still be live
+	alreadyRevoked := resp.StatusCode == http.StatusBadRequest
+	if resp.StatusCode != http.StatusOK && !alreadyRevoked {
+		SendError(w, "Failed to revoke Google access", http.StatusBadGateway)
+		return
+	}
+
+	u.GoogleToken = ""
+	if err := h.userRepo.Update(u); err != nil {
+		SendError(w, "Failed to clear Google connection", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Google access revoked"
+	if alreadyRevoked {
+		message = "Google access was already revoked"
+	}
+	SendSuccess(w, message, nil)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// newTestGoogleHandler returns a handler whose Google endpoints are served by
+// google, with user "u1" connected through refreshToken ("" for none)
+func newTestGoogleHandler(t *testing.T, google http.HandlerFunc, refreshToken string) (*GoogleServicesHandler, user.Repository, *user.User) {
+	t.Helper()
+	srv := httptest.NewServer(google)
+	t.Cleanup(srv.Close)
+
+	db := newTestDB(t)
+	u := newTestUser(t, db, "u1", user.RoleUser)
+	u.GoogleToken = refreshToken
+	users := repository.NewUserRepository(db)
+	if err := users.Update(u); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewGoogleServicesHandler(&config.Config{GoogleClientID: "id", GoogleClientSecret: "secret"}, users)
+	h.oauthConfig.Endpoint = oauth2.Endpoint{TokenURL: srv.URL + "/token", AuthStyle: oauth2.AuthStyleInParams}
+	h.tokenInfoURL = srv.URL + "/tokeninfo"
+	h.revokeURL = srv.URL + "/revoke"
+	return h, users, u
+}
+
+func TestGoogleGrants(t *testing.T) {
+	tests := []struct {
+		name       string
+		connected  bool
+		tokenInfo  int
+		status     int
+		wantScopes []string
+	}{
+		{"granted scopes", true, http.StatusOK, http.StatusOK, []string{"openid", "https://www.googleapis.com/auth/tasks"}},
+		{"tokeninfo fails", true, http.StatusBadRequest, http.StatusBadGateway, nil},
+		{"not connected", false, http.StatusOK, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresh := ""
+			if tt.connected {
+				refresh = "refresh-token"
+			}
+			h, _, u := newTestGoogleHandler(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/token":
+					w.Write([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":3600}`))
+				case "/tokeninfo":
+					if r.URL.Query().Get("access_token") != "at" {
+						t.Errorf("tokeninfo got %q", r.URL.RawQuery)
+					}
+					w.WriteHeader(tt.tokenInfo)
+					w.Write([]byte(`{"scope":"openid https://www.googleapis.com/auth/tasks","email":"u1@example.com","exp":"1900000000"}`))
+				}
+			}, refresh)
+
+			w := httptest.NewRecorder()
+			h.GoogleGrants(w, withUser(httptest.NewRequest(http.MethodGet, "/api/google/grants", nil), u))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var resp struct{ Data GoogleGrants }
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if !slices.Equal(resp.Data.Scopes, tt.wantScopes) || resp.Data.ExpiresAt.Unix() != 1900000000 || resp.Data.Email != "u1@example.com" {
+				t.Fatalf("got %+v", resp.Data)
+			}
+		})
+	}
+}
+
+func TestRevokeGoogle(t *testing.T) {
+	tests := []struct {
+		name        string
+		connected   bool
+		google      int
+		status      int
+		message     string
+		wantCleared bool
+	}{
+		{"revoked", true, http.StatusOK, http.StatusOK, "Google access revoked", true},
+		{"already revoked", true, http.StatusBadRequest, http.StatusOK, "already revoked", true},
+		{"Google unavailable", true, http.StatusServiceUnavailable, http.StatusBadGateway, "Failed to revoke", false},
+		{"not connected", false, http.StatusOK, http.StatusBadRequest, "not connected", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refresh := ""
+			if tt.connected {
+				refresh = "refresh-token"
+			}
+			called := false
+			h, users, u := newTestGoogleHandler(t, func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if r.URL.Path != "/revoke" || r.PostFormValue("token") != "refresh-token" {
+					t.Errorf("unexpected call %s %v", r.URL.Path, r.PostForm)
+				}
+				w.WriteHeader(tt.google)
+			}, refresh)
+
+			w := httptest.NewRecorder()
+			h.RevokeGoogle(w, withUser(httptest.NewRequest(http.MethodPost, "/api/google/revoke", nil), u))
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.message) {
+				t.Fatalf("got %d %s, want %d %q", w.Code, w.Body, tt.status, tt.message)
+			}
+			if called != tt.connected {
+				t.Fatalf("Google called = %v", called)
+			}
+
+			stored, err := users.GetByID("u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cleared := stored.GoogleToken == ""; tt.connected && cleared != tt.wantCleared {
+				t.Fatalf("stored token cleared = %v, want %v", cleared, tt.wantCleared)
+			}
+		})
+	}
+}
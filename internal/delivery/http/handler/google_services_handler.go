@@ -2,49 +2,45 @@ package handler
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"gomanager/internal/domain/googledrive"
 	"gomanager/internal/domain/user"
 	"gomanager/internal/infrastructure/config"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 // GoogleServicesHandler handles Google Calendar and Tasks API calls
 type GoogleServicesHandler struct {
 	oauthConfig *oauth2.Config
 	userRepo    user.Repository
+	timeout     time.Duration // Per call to Google
+
+	// Token inspection and revocation endpoints, replaceable in tests
+	tokenInfoURL string
+	revokeURL    string
 }
 
 // NewGoogleServicesHandler creates a new Google services handler
 func NewGoogleServicesHandler(cfg *config.Config, userRepo user.Repository) *GoogleServicesHandler {
-	oauthConfig := &oauth2.Config{
-		ClientID:     cfg.GoogleClientID,
-		ClientSecret: cfg.GoogleClientSecret,
-		RedirectURL:  cfg.BaseURL + "/api/auth/google/callback",
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-			"https://www.googleapis.com/auth/calendar.readonly",
-			"https://www.googleapis.com/auth/calendar.events",
-			"https://www.googleapis.com/auth/tasks.readonly",
-			"https://www.googleapis.com/auth/tasks",
-			"https://www.googleapis.com/auth/drive",
-			"https://www.googleapis.com/auth/drive.file",
-			"https://www.googleapis.com/auth/adwords",
-		},
-		Endpoint: google.Endpoint,
-	}
+	oauthConfig := newGoogleOAuthConfig(cfg)
 
 	return &GoogleServicesHandler{
 		oauthConfig: oauthConfig,
 		userRepo:    userRepo,
+		timeout:     time.Duration(cfg.GoogleHTTPTimeout) * time.Second,
+
+		tokenInfoURL: googleTokenInfoURL,
+		revokeURL:    googleRevokeURL,
 	}
 }
 
@@ -87,19 +83,10 @@ type TaskList struct {
 	Title string `json:"title"`
 }
 
-// getOAuthClient creates an OAuth2 client for the user
-func (h *GoogleServicesHandler) getOAuthClient(u *user.User) (*http.Client, error) {
-	if u.GoogleToken == "" {
-		return nil, ErrNoGoogleToken
-	}
-
-	token := &oauth2.Token{
-		RefreshToken: u.GoogleToken,
-		TokenType:    "Bearer",
-	}
-
-	tokenSource := h.oauthConfig.TokenSource(context.Background(), token)
-	return oauth2.NewClient(context.Background(), tokenSource), nil
+// getOAuthClient creates an OAuth2 client for the user whose calls end with
+// the inbound request
+func (h *GoogleServicesHandler) getOAuthClient(r *http.Request, u *user.User, acceptedScopes ...string) (*http.Client, error) {
+	return newGoogleClient(r.Context(), h.oauthConfig, h.userRepo, u, h.timeout, acceptedScopes...)
 }
 
 // ListCalendars handles GET /api/google/calendars
@@ -115,15 +102,15 @@ func (h *GoogleServicesHandler) ListCalendars(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, calendarReadScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
 	resp, err := client.Get("https://www.googleapis.com/calendar/v3/users/me/calendarList")
 	if err != nil {
-		SendError(w, "Failed to fetch calendars", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch calendars")
 		return
 	}
 	defer resp.Body.Close()
@@ -161,9 +148,9 @@ func (h *GoogleServicesHandler) ListEvents(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, calendarReadScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -198,7 +185,7 @@ func (h *GoogleServicesHandler) ListEvents(w http.ResponseWriter, r *http.Reques
 
 	resp, err := client.Get(apiURL)
 	if err != nil {
-		SendError(w, "Failed to fetch events", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch events")
 		return
 	}
 	defer resp.Body.Close()
@@ -230,9 +217,27 @@ func (h *GoogleServicesHandler) CreateEvent(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	// Read the event from request body
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the event, but forward the original body so fields we don't model reach Google
+	var event CalendarEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if (event.Start.DateTime == "" && event.Start.Date == "") || (event.End.DateTime == "" && event.End.Date == "") {
+		SendError(w, "Event start and end are required", http.StatusBadRequest)
+		return
+	}
+
+	client, err := h.getOAuthClient(r, u, calendarWriteScopes...)
+	if err != nil {
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -241,37 +246,137 @@ func (h *GoogleServicesHandler) CreateEvent(w http.ResponseWriter, r *http.Reque
 		calendarID = "primary"
 	}
 
-	// Read the event from request body
-	body, err := io.ReadAll(r.Body)
+	apiURL := "https://www.googleapis.com/calendar/v3/calendars/" + url.PathEscape(calendarID) + "/events"
+
+	resp, err := client.Post(apiURL, "application/json", bytes.NewReader(body))
 	if err != nil {
-		SendError(w, "Invalid request body", http.StatusBadRequest)
+		sendGoogleRequestError(w, err, "Failed to create event")
 		return
 	}
+	defer resp.Body.Close()
 
-	apiURL := "https://www.googleapis.com/calendar/v3/calendars/" + url.PathEscape(calendarID) + "/events"
+	respBody, _ := io.ReadAll(resp.Body)
 
-	req, _ := http.NewRequest("POST", apiURL, io.NopCloser(io.Reader(nil)))
-	req.Header.Set("Content-Type", "application/json")
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		SendError(w, "Failed to create event: "+string(respBody), resp.StatusCode)
+		return
+	}
 
-	// Create a new request with the body
-	resp, err := client.Post(apiURL, "application/json", io.NopCloser(jsonReader(body)))
+	var created CalendarEvent
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		SendError(w, "Failed to parse created event", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "Event created", created)
+}
+
+// BusyInterval represents a busy period in a calendar
+type BusyInterval struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// CalendarFreeBusy holds the busy intervals for one calendar, or the errors
+// Google reported for it (e.g. the calendar isn't accessible)
+type CalendarFreeBusy struct {
+	Busy   []BusyInterval `json:"busy"`
+	Errors []struct {
+		Domain string `json:"domain"`
+		Reason string `json:"reason"`
+	} `json:"errors,omitempty"`
+}
+
+// FreeBusy handles GET /api/google/calendar/freebusy?timeMin=...&timeMax=...&calendarIds=a,b
+func (h *GoogleServicesHandler) FreeBusy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Default to next 7 days
+	timeMin := time.Now()
+	timeMax := timeMin.AddDate(0, 0, 7)
+
+	if tm := r.URL.Query().Get("timeMin"); tm != "" {
+		parsed, err := time.Parse(time.RFC3339, tm)
+		if err != nil {
+			SendError(w, "Invalid timeMin, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		timeMin = parsed
+	}
+	if tm := r.URL.Query().Get("timeMax"); tm != "" {
+		parsed, err := time.Parse(time.RFC3339, tm)
+		if err != nil {
+			SendError(w, "Invalid timeMax, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		timeMax = parsed
+	}
+	if !timeMax.After(timeMin) {
+		SendError(w, "timeMax must be after timeMin", http.StatusBadRequest)
+		return
+	}
+
+	calendarIDs := []string{"primary"}
+	if ids := r.URL.Query().Get("calendarIds"); ids != "" {
+		calendarIDs = strings.Split(ids, ",")
+	}
+
+	client, err := h.getOAuthClient(r, u, calendarFreeBusyScopes...)
+	if err != nil {
+		sendGoogleClientError(w, err)
+		return
+	}
+
+	items := make([]map[string]string, len(calendarIDs))
+	for i, id := range calendarIDs {
+		items[i] = map[string]string{"id": strings.TrimSpace(id)}
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"timeMin": timeMin.Format(time.RFC3339),
+		"timeMax": timeMax.Format(time.RFC3339),
+		"items":   items,
+	})
+
+	resp, err := client.Post("https://www.googleapis.com/calendar/v3/freeBusy", "application/json", jsonReader(body))
 	if err != nil {
-		SendError(w, "Failed to create event", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch free/busy")
 		return
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		SendError(w, "Failed to create event: "+string(respBody), resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		SendError(w, "Failed to fetch free/busy", resp.StatusCode)
 		return
 	}
 
-	var event CalendarEvent
-	json.Unmarshal(respBody, &event)
+	// Per-calendar errors are returned alongside the busy intervals
+	// rather than failing the whole request
+	var result struct {
+		Calendars map[string]CalendarFreeBusy `json:"calendars"`
+	}
 
-	SendSuccess(w, "Event created", event)
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		SendError(w, "Failed to parse free/busy", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", map[string]interface{}{
+		"timeMin":   timeMin.Format(time.RFC3339),
+		"timeMax":   timeMax.Format(time.RFC3339),
+		"calendars": result.Calendars,
+	})
 }
 
 // ListTaskLists handles GET /api/google/tasks/lists
@@ -287,15 +392,15 @@ func (h *GoogleServicesHandler) ListTaskLists(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, tasksReadScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
 	resp, err := client.Get("https://www.googleapis.com/tasks/v1/users/@me/lists")
 	if err != nil {
-		SendError(w, "Failed to fetch task lists", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch task lists")
 		return
 	}
 	defer resp.Body.Close()
@@ -327,9 +432,9 @@ func (h *GoogleServicesHandler) ListTasks(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, tasksReadScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -349,7 +454,7 @@ func (h *GoogleServicesHandler) ListTasks(w http.ResponseWriter, r *http.Request
 
 	resp, err := client.Get(apiURL)
 	if err != nil {
-		SendError(w, "Failed to fetch tasks", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch tasks")
 		return
 	}
 	defer resp.Body.Close()
@@ -381,9 +486,9 @@ func (h *GoogleServicesHandler) CreateTask(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, tasksWriteScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -402,7 +507,7 @@ func (h *GoogleServicesHandler) CreateTask(w http.ResponseWriter, r *http.Reques
 
 	resp, err := client.Post(apiURL, "application/json", jsonReader(body))
 	if err != nil {
-		SendError(w, "Failed to create task", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to create task")
 		return
 	}
 	defer resp.Body.Close()
@@ -433,9 +538,9 @@ func (h *GoogleServicesHandler) UpdateTask(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, tasksWriteScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -463,7 +568,7 @@ func (h *GoogleServicesHandler) UpdateTask(w http.ResponseWriter, r *http.Reques
 
 	resp, err := client.Do(req)
 	if err != nil {
-		SendError(w, "Failed to update task", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to update task")
 		return
 	}
 	defer resp.Body.Close()
@@ -494,9 +599,9 @@ func (h *GoogleServicesHandler) CompleteTask(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, tasksWriteScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -511,6 +616,22 @@ func (h *GoogleServicesHandler) CompleteTask(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	status, err := completeTask(client, taskListID, taskID)
+	if err != nil {
+		sendGoogleRequestError(w, err, "Failed to complete task")
+		return
+	}
+
+	if status != http.StatusOK {
+		SendError(w, "Failed to complete task", status)
+		return
+	}
+
+	SendSuccess(w, "Task completed", nil)
+}
+
+// completeTask patches a task's status to completed and returns Google's status code
+func completeTask(client *http.Client, taskListID, taskID string) (int, error) {
 	// Update task status to completed
 	updateBody := `{"status": "completed"}`
 
@@ -521,17 +642,103 @@ func (h *GoogleServicesHandler) CompleteTask(w http.ResponseWriter, r *http.Requ
 
 	resp, err := client.Do(req)
 	if err != nil {
-		SendError(w, "Failed to complete task", http.StatusInternalServerError)
-		return
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		SendError(w, "Failed to complete task", resp.StatusCode)
+	return resp.StatusCode, nil
+}
+
+// batchCompleteConcurrency bounds the concurrent requests made by CompleteTasksBatch
+const batchCompleteConcurrency = 5
+
+// BatchTaskResult is the outcome of completing one task in a batch
+type BatchTaskResult struct {
+	TaskID  string `json:"taskId"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CompleteTasksBatch handles POST /api/google/tasks/complete-batch
+func (h *GoogleServicesHandler) CompleteTasksBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	SendSuccess(w, "Task completed", nil)
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		TaskListID string   `json:"taskListId"`
+		TaskIDs    []string `json:"taskIds"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(request.TaskIDs) == 0 {
+		SendError(w, "Task IDs required", http.StatusBadRequest)
+		return
+	}
+
+	if request.TaskListID == "" {
+		request.TaskListID = "@default"
+	}
+
+	client, err := h.getOAuthClient(r, u, tasksWriteScopes...)
+	if err != nil {
+		sendGoogleClientError(w, err)
+		return
+	}
+
+	results := completeTasks(client, request.TaskListID, request.TaskIDs)
+
+	completed := 0
+	for _, result := range results {
+		if result.Success {
+			completed++
+		}
+	}
+
+	SendSuccess(w, fmt.Sprintf("Completed %d of %d task(s)", completed, len(results)), results)
+}
+
+// completeTasks completes taskIDs concurrently; each result is reported
+// independently, so one failure doesn't stop the others
+func completeTasks(client *http.Client, taskListID string, taskIDs []string) []BatchTaskResult {
+	results := make([]BatchTaskResult, len(taskIDs))
+	sem := make(chan struct{}, batchCompleteConcurrency)
+	var wg sync.WaitGroup
+
+	for i, taskID := range taskIDs {
+		wg.Add(1)
+		go func(i int, taskID string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = BatchTaskResult{TaskID: taskID}
+			status, err := completeTask(client, taskListID, taskID)
+			switch {
+			case isTimeout(err):
+				results[i].Error = "timed out"
+			case err != nil:
+				results[i].Error = "request failed"
+			case status != http.StatusOK:
+				results[i].Error = http.StatusText(status)
+			default:
+				results[i].Success = true
+			}
+		}(i, taskID)
+	}
+	wg.Wait()
+	return results
 }
 
 // GoogleConnectionStatus handles GET /api/google/status
@@ -572,9 +779,9 @@ func (h *GoogleServicesHandler) ListDriveFiles(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, driveReadScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -602,7 +809,7 @@ func (h *GoogleServicesHandler) ListDriveFiles(w http.ResponseWriter, r *http.Re
 
 	resp, err := client.Get(apiURL)
 	if err != nil {
-		SendError(w, "Failed to fetch files", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to fetch files")
 		return
 	}
 	defer resp.Body.Close()
@@ -622,6 +829,137 @@ func (h *GoogleServicesHandler) ListDriveFiles(w http.ResponseWriter, r *http.Re
 	SendSuccess(w, "", result)
 }
 
+// driveFileFields is the expanded field set fetched for a single Drive file
+const driveFileFields = "id,name,mimeType,size,parents,createdTime,modifiedTime,webViewLink,properties," +
+	"owners(displayName,emailAddress),permissions(id,type,role,emailAddress,domain),capabilities"
+
+// GetDriveFile handles GET /api/google/drive/file?fileId=... and returns the
+// file's full metadata, including owners, permissions and capabilities
+func (h *GoogleServicesHandler) GetDriveFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	fileID := r.URL.Query().Get("fileId")
+	if fileID == "" {
+		SendError(w, "File ID required", http.StatusBadRequest)
+		return
+	}
+
+	client, err := h.getOAuthClient(r, u, driveReadScopes...)
+	if err != nil {
+		sendGoogleClientError(w, err)
+		return
+	}
+
+	apiURL := "https://www.googleapis.com/drive/v3/files/" + url.PathEscape(fileID)
+	apiURL += "?supportsAllDrives=true&fields=" + url.QueryEscape(driveFileFields)
+
+	resp, err := client.Get(apiURL)
+	if err != nil {
+		sendGoogleRequestError(w, err, "Failed to fetch file")
+		return
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		status := driveFileErrorStatus(resp.StatusCode)
+		message := "Failed to fetch file"
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if status != http.StatusBadGateway && json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		SendError(w, message, status)
+		return
+	}
+
+	var file driveFileDetails
+	if err := json.Unmarshal(body, &file); err != nil {
+		SendError(w, "Failed to parse file", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "", file.toDomain())
+}
+
+// driveFileErrorStatus maps a failed Drive file lookup to the status sent to
+// the client. A missing file (404) and one the user can't see (403) pass
+// through; anything else, a 401 in particular, is Google's failure and not
+// the client's, so it becomes 502.
+func driveFileErrorStatus(googleStatus int) int {
+	switch googleStatus {
+	case http.StatusForbidden, http.StatusNotFound:
+		return googleStatus
+	}
+	return http.StatusBadGateway
+}
+
+// driveFileDetails is a Drive file as returned with driveFileFields
+type driveFileDetails struct {
+	DriveFile
+	Properties map[string]string `json:"properties"`
+	Owners     []struct {
+		DisplayName  string `json:"displayName"`
+		EmailAddress string `json:"emailAddress"`
+	} `json:"owners"`
+	Permissions []struct {
+		ID           string `json:"id"`
+		Type         string `json:"type"`
+		Role         string `json:"role"`
+		EmailAddress string `json:"emailAddress"`
+		Domain       string `json:"domain"`
+	} `json:"permissions"`
+	Capabilities map[string]bool `json:"capabilities"`
+}
+
+func (f *driveFileDetails) toDomain() *googledrive.DriveFile {
+	// Drive sends sizes as strings and omits them for folders and Docs files
+	size, _ := strconv.ParseInt(f.Size, 10, 64)
+	file := &googledrive.DriveFile{
+		ID:           f.ID,
+		Name:         f.Name,
+		MimeType:     f.MimeType,
+		Size:         size,
+		Parents:      f.Parents,
+		CreatedTime:  f.CreatedTime,
+		ModifiedTime: f.ModifiedTime,
+		WebViewLink:  f.WebViewLink,
+		Permissions:  make([]googledrive.FilePermission, 0, len(f.Permissions)),
+		Properties:   f.Properties,
+		Owners:       make([]googledrive.FileOwner, 0, len(f.Owners)),
+		Capabilities: f.Capabilities,
+	}
+	for _, p := range f.Permissions {
+		file.Permissions = append(file.Permissions, googledrive.FilePermission{
+			ID:           p.ID,
+			Type:         p.Type,
+			Role:         p.Role,
+			EmailAddress: p.EmailAddress,
+			Domain:       p.Domain,
+		})
+	}
+	for _, o := range f.Owners {
+		file.Owners = append(file.Owners, googledrive.FileOwner{
+			DisplayName:  o.DisplayName,
+			EmailAddress: o.EmailAddress,
+		})
+	}
+	return file
+}
+
 // CreateDriveFolder handles POST /api/google/drive/folders
 func (h *GoogleServicesHandler) CreateDriveFolder(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -635,9 +973,9 @@ func (h *GoogleServicesHandler) CreateDriveFolder(w http.ResponseWriter, r *http
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, driveWriteScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -665,7 +1003,7 @@ func (h *GoogleServicesHandler) CreateDriveFolder(w http.ResponseWriter, r *http
 
 	resp, err := client.Post("https://www.googleapis.com/drive/v3/files", "application/json", jsonReader(body))
 	if err != nil {
-		SendError(w, "Failed to create folder", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to create folder")
 		return
 	}
 	defer resp.Body.Close()
@@ -696,9 +1034,9 @@ func (h *GoogleServicesHandler) UploadDriveFile(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, driveWriteScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -763,7 +1101,7 @@ func (h *GoogleServicesHandler) UploadDriveFile(w http.ResponseWriter, r *http.R
 
 	resp, err := client.Do(req)
 	if err != nil {
-		SendError(w, "Failed to upload file", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to upload file")
 		return
 	}
 	defer resp.Body.Close()
@@ -794,9 +1132,9 @@ func (h *GoogleServicesHandler) DeleteDriveFile(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	client, err := h.getOAuthClient(u)
+	client, err := h.getOAuthClient(r, u, driveWriteScopes...)
 	if err != nil {
-		SendError(w, "Google account not connected", http.StatusBadRequest)
+		sendGoogleClientError(w, err)
 		return
 	}
 
@@ -814,7 +1152,7 @@ func (h *GoogleServicesHandler) DeleteDriveFile(w http.ResponseWriter, r *http.R
 
 	resp, err := client.Do(req)
 	if err != nil {
-		SendError(w, "Failed to delete file", http.StatusInternalServerError)
+		sendGoogleRequestError(w, err, "Failed to delete file")
 		return
 	}
 	defer resp.Body.Close()
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+)
+
+func TestDriveFileErrorStatus(t *testing.T) {
+	for _, tt := range []struct {
+		google, want int
+	}{
+		{http.StatusNotFound, http.StatusNotFound},
+		{http.StatusForbidden, http.StatusForbidden},
+		{http.StatusUnauthorized, http.StatusBadGateway},
+		{http.StatusBadRequest, http.StatusBadGateway},
+		{http.StatusTooManyRequests, http.StatusBadGateway},
+		{http.StatusInternalServerError, http.StatusBadGateway},
+	} {
+		if got := driveFileErrorStatus(tt.google); got != tt.want {
+			t.Errorf("Google %d: sent %d, want %d", tt.google, got, tt.want)
+		}
+	}
+}
+
+func TestCreateEventValidatesBody(t *testing.T) {
+	h := NewGoogleServicesHandler(&config.Config{GoogleClientID: "id"}, nil)
+	tests := []struct {
+		name    string
+		body    string
+		message string // The body is checked before Google is contacted
+	}{
+		{"malformed JSON", `{"summary":`, "Invalid request body"},
+		{"wrong field type", `{"summary":5}`, "Invalid request body"},
+		{"missing start", `{"summary":"x","end":{"date":"2026-01-02"}}`, "Event start and end are required"},
+		{"null", `null`, "Event start and end are required"},
+		{"valid event", `{"summary":"x","start":{"date":"2026-01-01"},"end":{"date":"2026-01-02"}}`, "Google account not connected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(http.MethodPost, "/api/google/calendar/events/create", strings.NewReader(tt.body)), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.CreateEvent(w, r)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.message) {
+				t.Fatalf("got %d %s, want 400 %q", w.Code, w.Body, tt.message)
+			}
+		})
+	}
+}
+
+// redirectTransport sends every request to the test server at target
+type redirectTransport struct{ target *url.URL }
+
+func (rt redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestCompleteTasks(t *testing.T) {
+	var (
+		mu                sync.Mutex
+		patched           = map[string]string{} // Escaped path -> body
+		active, maxActive int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		active--
+		if r.Method == http.MethodPatch {
+			patched[r.URL.EscapedPath()] = string(body)
+		}
+		mu.Unlock()
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/missing"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/throttled"):
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+	target, _ := url.Parse(srv.URL)
+	client := &http.Client{Transport: redirectTransport{target}}
+
+	ids := []string{"missing", "a/b", "throttled"}
+	for i := range 9 {
+		ids = append(ids, fmt.Sprintf("t%d", i))
+	}
+	results := completeTasks(client, "@default", ids)
+
+	if len(results) != len(ids) {
+		t.Fatalf("got %d results, want %d", len(results), len(ids))
+	}
+	for i, res := range results {
+		wantErr := map[string]string{"missing": "Not Found", "throttled": "Too Many Requests"}[ids[i]]
+		if res.TaskID != ids[i] || res.Success != (wantErr == "") || res.Error != wantErr {
+			t.Errorf("result %d = %+v, want task %s with error %q", i, res, ids[i], wantErr)
+		}
+		p := "/tasks/v1/lists/@default/tasks/" + url.PathEscape(ids[i])
+		if body, ok := patched[p]; !ok || !strings.Contains(body, `"completed"`) {
+			t.Errorf("%s: patched %v with %q", ids[i], ok, body)
+		}
+	}
+	if maxActive > batchCompleteConcurrency {
+		t.Errorf("%d concurrent requests, want at most %d", maxActive, batchCompleteConcurrency)
+	}
+}
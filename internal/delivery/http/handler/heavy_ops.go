@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HeavyOpLimiter caps how many expensive operations (stats walks, directory
+// sizes, archive streaming, reindexing, share thumbnails) run at once.
+// Requests beyond the limit wait up to the queue timeout for a slot, then
+// get a 503. A nil limiter never blocks.
+type HeavyOpLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// NewHeavyOpLimiter allows max concurrent heavy operations, queueing extra
+// requests for up to wait (0 rejects them at once). max <= 0 disables the limit.
+func NewHeavyOpLimiter(max int, wait time.Duration) *HeavyOpLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &HeavyOpLimiter{slots: make(chan struct{}, max), wait: wait}
+}
+
+// acquire takes a slot, waiting up to the queue timeout or until r is
+// cancelled. On failure it writes the 503 and returns false; otherwise the
+// caller must call release once the work is done.
+func (l *HeavyOpLimiter) acquire(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+
+	release = func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	if l.wait > 0 {
+		timer := time.NewTimer(l.wait)
+		defer timer.Stop()
+		select {
+		case l.slots <- struct{}{}:
+			return release, true
+		case <-timer.C:
+		case <-r.Context().Done():
+			return nil, false
+		}
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(heavyOpRetryAfter))
+	SendError(w, "Server is busy, try again shortly", http.StatusServiceUnavailable)
+	return nil, false
+}
+
+// tryAcquire takes a slot only if one is free right away, for optional work
+// that is skipped rather than queued when the server is busy
+func (l *HeavyOpLimiter) tryAcquire() (release func(), ok bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, true
+	default:
+		return nil, false
+	}
+}
+
+// heavyOpRetryAfter is the Retry-After (seconds) sent with a busy 503
+const heavyOpRetryAfter = 5
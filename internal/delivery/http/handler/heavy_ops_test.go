@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/user"
+)
+
+func TestHeavyOpLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		wait      time.Duration
+		freeAfter time.Duration // When a held slot is released; 0 keeps it
+		cancel    bool
+		wantOK    bool
+		wantCode  int
+	}{
+		{"rejected at once", 2, 0, 0, false, false, http.StatusServiceUnavailable},
+		{"queued until a slot frees", 2, time.Second, 50 * time.Millisecond, false, true, http.StatusOK},
+		{"queue times out", 2, 50 * time.Millisecond, 0, false, false, http.StatusServiceUnavailable},
+		{"client gives up while queued", 2, time.Second, 0, true, false, http.StatusOK},
+		{"no limit", 0, 0, 0, false, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewHeavyOpLimiter(tt.max, tt.wait)
+			var held []func()
+			for i := 0; i < tt.max; i++ {
+				release, ok := l.acquire(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+				if !ok {
+					t.Fatalf("op %d of %d rejected", i+1, tt.max)
+				}
+				held = append(held, release)
+			}
+			if tt.freeAfter > 0 {
+				time.AfterFunc(tt.freeAfter, held[0])
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+			w := httptest.NewRecorder()
+			release, ok := l.acquire(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			if ok != tt.wantOK || w.Code != tt.wantCode {
+				t.Fatalf("extra op ok %v with status %d, want %v, %d", ok, w.Code, tt.wantOK, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+				t.Error("busy response has no Retry-After")
+			}
+			if ok {
+				release()
+			}
+		})
+	}
+}
+
+func TestHeavyOpLimiterTryAcquire(t *testing.T) {
+	l := NewHeavyOpLimiter(1, time.Second)
+	release, ok := l.tryAcquire()
+	if !ok {
+		t.Fatal("first op skipped")
+	}
+	if _, ok := l.tryAcquire(); ok {
+		t.Fatal("second op ran past the limit")
+	}
+	release()
+	if release, ok := l.tryAcquire(); !ok {
+		t.Fatal("freed slot not reused")
+	} else {
+		release()
+	}
+}
+
+func TestStatsNeedsHeavyOpSlot(t *testing.T) {
+	svc, _ := newTestFileService(t, newTestDB(t), map[string]string{"a.txt": "a"})
+	h := NewFileHandler(svc, UploadPolicy{}, nil, "", 0, NewHeavyOpLimiter(1, 0), nil, nil, nil)
+	stats := func() int {
+		w := httptest.NewRecorder()
+		h.Stats(w, withUser(httptest.NewRequest(http.MethodGet, "/api/stats", nil), &user.User{ID: "u1", Role: user.RoleUser}))
+		return w.Code
+	}
+
+	release, _ := h.heavy.acquire(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if code := stats(); code != http.StatusServiceUnavailable {
+		t.Fatalf("stats while busy: status %d, want 503", code)
+	}
+	release()
+	if code := stats(); code != http.StatusOK {
+		t.Fatalf("stats after release: status %d, want 200", code)
+	}
+}
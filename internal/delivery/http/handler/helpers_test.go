@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gomanager/internal/application/auth"
+	fileService "gomanager/internal/application/file"
+	fileDomain "gomanager/internal/domain/file"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/database"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// newTestDB returns a migrated SQLite database in a temporary folder
+func newTestDB(t testing.TB) *database.DB {
+	t.Helper()
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestUser stores a user with role in db
+func newTestUser(t *testing.T, db *database.DB, id string, role user.Role) *user.User {
+	t.Helper()
+	u := &user.User{ID: id, Email: id + "@example.com", Username: id, Role: role, AuthProvider: user.AuthProviderLocal, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := repository.NewUserRepository(db).Create(u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// newTestFileService returns a file service over a temporary storage folder
+// holding files, a map of slash-separated paths to contents
+func newTestFileService(t testing.TB, db *database.DB, files map[string]string) (fileService.Service, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFiles(t, dir, files)
+	repo := repository.NewFilesystemRepository(dir, nil, false)
+	return fileService.NewService(repo, repository.NewFileIndexRepository(db), nil, repository.NewUploadStore(dir, repo, nil, 0), fileService.ListingCacheConfig{}, 0, nil, fileDomain.NameSanitizer{}, nil, nil), dir
+}
+
+// newTestFileHandler returns a file handler over a temporary storage folder
+func newTestFileHandler(t *testing.T, files map[string]string) (*FileHandler, string) {
+	t.Helper()
+	svc, dir := newTestFileService(t, newTestDB(t), files)
+	return NewFileHandler(svc, UploadPolicy{}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil), dir
+}
+
+func writeTestFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// withUser returns r as sent by the signed-in u
+func withUser(r *http.Request, u *user.User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), UserContextKey, u))
+}
+
+// newTestShareHandler returns a share handler over a temporary storage folder
+// holding files, with users "owner" and "other" already stored
+func newTestShareHandler(t *testing.T, cfg *config.Config, files map[string]string) (*ShareHandler, *database.DB) {
+	t.Helper()
+	db := newTestDB(t)
+	newTestUser(t, db, "owner", user.RoleUser)
+	newTestUser(t, db, "other", user.RoleUser)
+	svc, dir := newTestFileService(t, db, files)
+	thumbnails := NewThumbnailCache(repository.NewFilesystemRepository(dir, nil, false), dir)
+	return NewShareHandler(repository.NewShareRepository(db), repository.NewUserRepository(db), svc, repository.NewFileIndexRepository(db), cfg, nil, NewHeavyOpLimiter(0, 0), thumbnails), db
+}
+
+// newTestAuthService returns an auth service over db issuing sessions that
+// last expiry
+func newTestAuthService(t testing.TB, db *database.DB, expiry time.Duration) auth.Service {
+	t.Helper()
+	hasher, err := auth.NewPasswordHasher("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.NewService(repository.NewUserRepository(db), repository.NewSessionRepository(db), expiry, hasher, auth.TokenConfig{}, auth.LockoutConfig{}, auth.RegistrationConfig{Enabled: true})
+}
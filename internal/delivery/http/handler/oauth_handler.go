@@ -2,11 +2,19 @@ package handler
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,7 +25,6 @@ import (
 
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 )
 
 // GoogleUserInfo represents the user info returned by Google
@@ -37,29 +44,43 @@ type OAuthHandler struct {
 	authService auth.Service
 	userRepo    user.Repository
 	frontendURL string
+	stateSecret []byte
+	cookies     SessionCookieConfig
+	userInfoURL string
+
+	// avatars caches Google pictures locally; nil keeps the remote URL
+	avatars *remoteAvatarCache
 }
 
+// googleUserInfoURL returns the profile of the account behind an access token
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
+// linkStatePrefix marks OAuth states that link Google to a logged-in user
+const linkStatePrefix = "link"
+
+// oauthStateTTL bounds how long a consent started here can be completed;
+// link states carry their issue time and the state cookie expires with it
+const oauthStateTTL = 10 * time.Minute
+
 // NewOAuthHandler creates a new OAuth handler
 func NewOAuthHandler(cfg *config.Config, authService auth.Service, userRepo user.Repository) *OAuthHandler {
-	oauthConfig := &oauth2.Config{
-		ClientID:     cfg.GoogleClientID,
-		ClientSecret: cfg.GoogleClientSecret,
-		RedirectURL:  cfg.BaseURL + "/api/auth/google/callback",
-		Scopes: []string{
-			"https://www.googleapis.com/auth/userinfo.email",
-			"https://www.googleapis.com/auth/userinfo.profile",
-			"https://www.googleapis.com/auth/calendar.readonly",
-			"https://www.googleapis.com/auth/calendar.events",
-		},
-		Endpoint: google.Endpoint,
-	}
-
-	return &OAuthHandler{
+	oauthConfig := newGoogleOAuthConfig(cfg)
+
+	h := &OAuthHandler{
 		oauthConfig: oauthConfig,
 		authService: authService,
 		userRepo:    userRepo,
 		frontendURL: cfg.FrontendURL,
+		stateSecret: []byte(cfg.OAuthStateSecret),
+		cookies:     NewSessionCookieConfig(cfg),
+		userInfoURL: googleUserInfoURL,
 	}
+	if cfg.CacheRemoteAvatars {
+		avatarPath := filepath.Join(cfg.StoragePath, ".avatars")
+		os.MkdirAll(avatarPath, 0755)
+		h.avatars = newRemoteAvatarCache(avatarPath, cfg.AvatarMaxDimension, cfg.AvatarRemoteHosts)
+	}
+	return h
 }
 
 // GoogleLogin redirects to Google OAuth login page
@@ -69,23 +90,90 @@ func (h *OAuthHandler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate state token
 	state := uuid.New().String()
+	h.setStateCookie(w, state)
+	http.Redirect(w, r, h.authCodeURL(state), http.StatusTemporaryRedirect)
+}
+
+// GoogleLink handles POST /api/auth/google/link and returns the Google
+// consent URL that connects Google to the logged-in user. It is a POST
+// answered with JSON, not a redirect, so another site can't start a link
+// for a signed-in user by sending them here.
+func (h *OAuthHandler) GoogleLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.oauthConfig.ClientID == "" {
+		SendError(w, "Google OAuth not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	// Store state in cookie for verification
+	if len(h.stateSecret) == 0 {
+		SendError(w, "Google account linking not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	state := h.signLinkState(uuid.New().String(), u.ID, time.Now())
+	h.setStateCookie(w, state)
+	SendSuccess(w, "", map[string]string{"url": h.authCodeURL(state)})
+}
+
+// setStateCookie stores state so the callback can check it came from here
+func (h *OAuthHandler) setStateCookie(w http.ResponseWriter, state string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "oauth_state",
 		Value:    state,
 		Path:     "/",
 		HttpOnly: true,
 		Secure:   strings.HasPrefix(h.frontendURL, "https"),
-		MaxAge:   600, // 10 minutes
+		MaxAge:   int(oauthStateTTL / time.Second),
 		SameSite: http.SameSiteLaxMode,
 	})
+}
+
+// authCodeURL is the Google consent page for state, asking for offline
+// access to get a refresh token
+func (h *OAuthHandler) authCodeURL(state string) string {
+	return h.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+}
 
-	// Request offline access to get refresh token
-	url := h.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+// signLinkState builds a link state of the form
+// link.{nonce}.{userID}.{issued unix time}.{signature}
+func (h *OAuthHandler) signLinkState(nonce, userID string, issued time.Time) string {
+	payload := linkStatePrefix + "." + nonce + "." + userID + "." + strconv.FormatInt(issued.Unix(), 10)
+	mac := hmac.New(sha256.New, h.stateSecret)
+	mac.Write([]byte(payload))
+	return payload + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseLinkState returns the user ID from a validly signed link state issued
+// within oauthStateTTL
+func (h *OAuthHandler) parseLinkState(state string) (string, bool) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 5 || parts[0] != linkStatePrefix || len(h.stateSecret) == 0 {
+		return "", false
+	}
+	issuedUnix, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	issued := time.Unix(issuedUnix, 0)
+
+	expected := h.signLinkState(parts[1], parts[2], issued)
+	if !hmac.Equal([]byte(expected), []byte(state)) {
+		return "", false
+	}
+	if age := time.Since(issued); age < -time.Minute || age > oauthStateTTL {
+		return "", false
+	}
+	return parts[2], true
 }
 
 // GoogleCallback handles the OAuth callback from Google
@@ -133,41 +221,67 @@ func (h *OAuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find or create user
-	u, err := h.findOrCreateGoogleUser(googleUser, token)
-	if err != nil {
-		h.redirectWithError(w, r, "Failed to create user")
+	// Link to the logged-in user instead of matching by email
+	if strings.HasPrefix(state, linkStatePrefix+".") {
+		userID, ok := h.parseLinkState(state)
+		if !ok {
+			h.redirectWithError(w, r, "Invalid link state")
+			return
+		}
+		if err := h.linkGoogleUser(userID, googleUser, token); err != nil {
+			if errors.Is(err, user.ErrUserAlreadyExists) {
+				h.redirectWithError(w, r, "Google account belongs to another user")
+				return
+			}
+			h.redirectWithError(w, r, "Failed to link Google account")
+			return
+		}
+		http.Redirect(w, r, h.frontendURL+"/auth/callback?linked=google", http.StatusTemporaryRedirect)
 		return
 	}
 
-	// Create session token
-	sessionToken, err := h.authService.GenerateToken()
+	// Find or create user
+	u, created, err := h.findOrCreateGoogleUser(googleUser, token)
+	if errors.Is(err, user.ErrRegistrationClosed) {
+		h.redirectWithErrorCode(w, r, oauthErrRegistrationClosed, "No account exists for this Google user and registration is closed")
+		return
+	}
 	if err != nil {
-		h.redirectWithError(w, r, "Failed to generate token")
+		h.redirectWithErrorCode(w, r, oauthErrUserCreate, "Failed to create user")
 		return
 	}
 
-	session := &authDomain.Session{
-		ID:        uuid.New().String(),
-		UserID:    u.ID,
-		Token:     sessionToken,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
-		CreatedAt: time.Now(),
+	session, err := h.createOAuthSession(u)
+	if err != nil {
+		// Don't leave behind an account the user never managed to sign in to;
+		// they can retry the whole flow from scratch
+		if created {
+			if delErr := h.userRepo.Delete(u.ID); delErr != nil {
+				log.Printf("oauth: failed to roll back user %s: %v", u.ID, delErr)
+			}
+		}
+		h.redirectWithErrorCode(w, r, oauthErrSessionCreate, "Failed to create session")
+		return
 	}
+	sessionToken := session.Token
 
-	if err := h.authService.CreateSession(session); err != nil {
+	if err := SetSessionCookies(w, h.cookies, sessionToken, session.ExpiresAt); err != nil {
 		h.redirectWithError(w, r, "Failed to create session")
 		return
 	}
 
-	// Redirect to frontend with token
+	// Redirect to frontend with token, unless it went out as a cookie: a URL
+	// would leave it in browser history and referrers
 	redirectURL := fmt.Sprintf("%s/auth/callback?token=%s", h.frontendURL, sessionToken)
+	if h.cookies.Enabled {
+		redirectURL = h.frontendURL + "/auth/callback"
+	}
 	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
 
 // getGoogleUserInfo fetches user info from Google API
 func (h *OAuthHandler) getGoogleUserInfo(accessToken string) (*GoogleUserInfo, error) {
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + accessToken)
+	resp, err := http.Get(h.userInfoURL + "?access_token=" + url.QueryEscape(accessToken))
 	if err != nil {
 		return nil, err
 	}
@@ -186,18 +300,19 @@ func (h *OAuthHandler) getGoogleUserInfo(accessToken string) (*GoogleUserInfo, e
 	return &userInfo, nil
 }
 
-// findOrCreateGoogleUser finds an existing user or creates a new one
-func (h *OAuthHandler) findOrCreateGoogleUser(googleUser *GoogleUserInfo, token *oauth2.Token) (*user.User, error) {
+// findOrCreateGoogleUser resolves the account for a Google login. created
+// reports whether a new user was inserted so callers can roll it back.
+func (h *OAuthHandler) findOrCreateGoogleUser(googleUser *GoogleUserInfo, token *oauth2.Token) (*user.User, bool, error) {
 	// First, try to find by Google ID
 	u, err := h.userRepo.GetByGoogleID(googleUser.ID)
 	if err == nil {
 		// Update Google token if we have a refresh token
 		if token.RefreshToken != "" {
 			u.GoogleToken = token.RefreshToken
-			u.AvatarURL = googleUser.Picture
+			u.AvatarURL = h.avatars.avatarURL(googleUser.Picture)
 			h.userRepo.Update(u)
 		}
-		return u, nil
+		return u, false, nil
 	}
 
 	// Try to find by email
@@ -209,15 +324,21 @@ func (h *OAuthHandler) findOrCreateGoogleUser(googleUser *GoogleUserInfo, token
 		if token.RefreshToken != "" {
 			u.GoogleToken = token.RefreshToken
 		}
-		u.AvatarURL = googleUser.Picture
+		u.AvatarURL = h.avatars.avatarURL(googleUser.Picture)
 		if err := h.userRepo.Update(u); err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		return u, nil
+		return u, false, nil
 	}
 
 	if !errors.Is(err, user.ErrUserNotFound) {
-		return nil, err
+		return nil, false, err
+	}
+	// Google login can't stand in for an invite, but can create the first account
+	if !h.authService.RegistrationOpen() {
+		if count, err := h.userRepo.Count(); err != nil || count > 0 {
+			return nil, false, user.ErrRegistrationClosed
+		}
 	}
 
 	// Create new user
@@ -245,21 +366,79 @@ func (h *OAuthHandler) findOrCreateGoogleUser(googleUser *GoogleUserInfo, token
 		AuthProvider: user.AuthProviderGoogle,
 		GoogleID:     googleUser.ID,
 		GoogleToken:  token.RefreshToken,
-		AvatarURL:    googleUser.Picture,
+		AvatarURL:    h.avatars.avatarURL(googleUser.Picture),
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
 	if err := h.userRepo.Create(newUser); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return newUser, nil
+	return newUser, true, nil
+}
+
+// linkGoogleUser attaches a Google account to an existing user.
+// Returns ErrUserAlreadyExists if the Google account or its email belongs to someone else.
+func (h *OAuthHandler) linkGoogleUser(userID string, googleUser *GoogleUserInfo, token *oauth2.Token) error {
+	if other, err := h.userRepo.GetByGoogleID(googleUser.ID); err == nil && other.ID != userID {
+		return user.ErrUserAlreadyExists
+	}
+	if other, err := h.userRepo.GetByEmail(googleUser.Email); err == nil && other.ID != userID {
+		return user.ErrUserAlreadyExists
+	}
+
+	u, err := h.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	// Keep the existing auth provider so local password login still works
+	u.GoogleID = googleUser.ID
+	if token.RefreshToken != "" {
+		u.GoogleToken = token.RefreshToken
+	}
+	if u.AvatarURL == "" {
+		u.AvatarURL = h.avatars.avatarURL(googleUser.Picture)
+	}
+	return h.userRepo.Update(u)
+}
+
+// sessionCreateAttempts is how many times the callback tries to store a
+// session before giving up
+const sessionCreateAttempts = 3
+
+// createOAuthSession issues a login session for u, retrying transient
+// failures
+func (h *OAuthHandler) createOAuthSession(u *user.User) (*authDomain.Session, error) {
+	var err error
+	for attempt := 1; attempt <= sessionCreateAttempts; attempt++ {
+		var session *authDomain.Session
+		if session, err = h.authService.IssueSession(u); err == nil {
+			return session, nil
+		}
+		log.Printf("oauth: session create attempt %d for user %s failed: %v", attempt, u.ID, err)
+	}
+	return nil, err
+}
+
+// Error codes passed to the frontend callback alongside the message
+const (
+	oauthErrUserCreate         = "user_create_failed"
+	oauthErrSessionCreate      = "session_create_failed"
+	oauthErrRegistrationClosed = "registration_closed"
+)
+
+// redirectWithErrorCode is redirectWithError with a machine-readable code
+// the frontend can switch on
+func (h *OAuthHandler) redirectWithErrorCode(w http.ResponseWriter, r *http.Request, code, errMsg string) {
+	redirectURL := fmt.Sprintf("%s/auth/callback?error=%s&code=%s", h.frontendURL, url.QueryEscape(errMsg), url.QueryEscape(code))
+	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
 
 // redirectWithError redirects to frontend with error message
 func (h *OAuthHandler) redirectWithError(w http.ResponseWriter, r *http.Request, errMsg string) {
-	redirectURL := fmt.Sprintf("%s/auth/callback?error=%s", h.frontendURL, errMsg)
+	redirectURL := fmt.Sprintf("%s/auth/callback?error=%s", h.frontendURL, url.QueryEscape(errMsg))
 	http.Redirect(w, r, redirectURL, http.StatusTemporaryRedirect)
 }
 
@@ -267,6 +446,7 @@ func (h *OAuthHandler) redirectWithError(w http.ResponseWriter, r *http.Request,
 func (h *OAuthHandler) GoogleStatus(w http.ResponseWriter, r *http.Request) {
 	SendSuccess(w, "", map[string]interface{}{
 		"enabled":  h.oauthConfig.ClientID != "",
-		"calendar": h.oauthConfig.ClientID != "",
+		"calendar": h.oauthConfig.ClientID != "" && hasAnyScope(h.oauthConfig.Scopes, calendarReadScopes),
+		"scopes":   h.oauthConfig.Scopes,
 	})
 }
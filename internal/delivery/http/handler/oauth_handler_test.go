@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"gomanager/internal/application/auth"
+	authDomain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/repository"
+)
+
+func newTestOAuthHandler() *OAuthHandler {
+	return NewOAuthHandler(&config.Config{GoogleClientID: "client", OAuthStateSecret: "state-secret"}, nil, nil)
+}
+
+func TestGoogleLink(t *testing.T) {
+	h := newTestOAuthHandler()
+	for _, tt := range []struct {
+		method string
+		status int
+	}{
+		{http.MethodGet, http.StatusMethodNotAllowed},
+		{http.MethodPost, http.StatusOK},
+	} {
+		t.Run(tt.method, func(t *testing.T) {
+			r := withUser(httptest.NewRequest(tt.method, "/api/auth/google/link", nil), &user.User{ID: "u1", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.GoogleLink(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct{ Data struct{ URL string } }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			consent, err := url.Parse(resp.Data.URL)
+			if err != nil || !strings.HasPrefix(resp.Data.URL, "https://accounts.google.com/") {
+				t.Fatalf("url %q is not Google's consent page", resp.Data.URL)
+			}
+			state := consent.Query().Get("state")
+			if userID, ok := h.parseLinkState(state); !ok || userID != "u1" {
+				t.Fatalf("state %q links %q, %v", state, userID, ok)
+			}
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != "oauth_state" || cookies[0].Value != state {
+				t.Fatalf("cookies %v, want the state", cookies)
+			}
+		})
+	}
+}
+
+func TestParseLinkState(t *testing.T) {
+	h := newTestOAuthHandler()
+	now := time.Now()
+	valid := h.signLinkState("nonce", "u1", now)
+	tests := []struct {
+		name  string
+		state string
+		ok    bool
+	}{
+		{"fresh", valid, true},
+		{"expired", h.signLinkState("nonce", "u1", now.Add(-oauthStateTTL-time.Minute)), false},
+		{"issued in the future", h.signLinkState("nonce", "u1", now.Add(time.Hour)), false},
+		{"other user", strings.Replace(valid, ".u1.", ".u2.", 1), false},
+		{"old format", "link.nonce.u1." + valid[strings.LastIndex(valid, ".")+1:], false},
+		{"login state", "9b2f0f1c-5a4e-4d43-9c1e-1f1a2b3c4d5e", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := h.parseLinkState(tt.state); ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+}
+
+// newTestGoogleLogin returns an OAuth handler whose token exchange and
+// profile lookup go to a stub Google signing in g@example.com, with the
+// auth service it issues sessions through
+func newTestGoogleLogin(t *testing.T, cfg *config.Config, expiry time.Duration) (*OAuthHandler, auth.Service) {
+	t.Helper()
+	google := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			w.Write([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":3600}`))
+		case "/userinfo":
+			w.Write([]byte(`{"id":"g1","email":"g@example.com","verified_email":true,"given_name":"Gina"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(google.Close)
+
+	db := newTestDB(t)
+	svc := newTestAuthService(t, db, expiry)
+	cfg.GoogleClientID, cfg.FrontendURL = "client", "http://front.example"
+	h := NewOAuthHandler(cfg, svc, repository.NewUserRepository(db))
+	h.oauthConfig.Endpoint = oauth2.Endpoint{AuthURL: google.URL + "/auth", TokenURL: google.URL + "/token"}
+	h.userInfoURL = google.URL + "/userinfo"
+	return h, svc
+}
+
+// googleCallback completes a login consent as the browser would
+func googleCallback(h *OAuthHandler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/google/callback?state=s1&code=c1", nil)
+	r.AddCookie(&http.Cookie{Name: "oauth_state", Value: "s1"})
+	w := httptest.NewRecorder()
+	h.GoogleCallback(w, r)
+	return w
+}
+
+func TestGoogleCallbackSessionCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie bool
+	}{
+		{"bearer mode", false},
+		{"cookie mode", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, svc := newTestGoogleLogin(t, &config.Config{SessionCookie: tt.cookie}, time.Hour)
+			w := googleCallback(h)
+			if w.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil || location.Host != "front.example" || location.Path != "/auth/callback" {
+				t.Fatalf("redirected to %q", w.Header().Get("Location"))
+			}
+			if msg := location.Query().Get("error"); msg != "" {
+				t.Fatalf("callback failed: %s", msg)
+			}
+
+			var cookie string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == SessionCookieName {
+					cookie = c.Value
+				}
+			}
+			token := location.Query().Get("token")
+			if tt.cookie {
+				// A token in the URL would end up in history and referrers
+				if location.RawQuery != "" {
+					t.Errorf("redirect carries %q", location.RawQuery)
+				}
+				token = cookie
+			} else if cookie != "" {
+				t.Error("session cookie set in bearer mode")
+			}
+			if u, err := svc.ValidateToken(token); err != nil || u.Email != "g@example.com" {
+				t.Errorf("issued token %q: %v, %v", token, u, err)
+			}
+		})
+	}
+}
+
+func TestLoginsUseTokenExpiry(t *testing.T) {
+	const expiry = 90 * time.Minute
+	tests := []struct {
+		name  string
+		login func(t *testing.T) (token string, svc auth.Service)
+	}{
+		{"local", func(t *testing.T) (string, auth.Service) {
+			h, svc := newTestLogin(t, SessionCookieConfig{}, expiry)
+			var resp struct{ Data struct{ Token string } }
+			w := login(h, "secret1")
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("login: status %d: %s", w.Code, w.Body)
+			}
+			return resp.Data.Token, svc
+		}},
+		{"google", func(t *testing.T) (string, auth.Service) {
+			h, svc := newTestGoogleLogin(t, &config.Config{}, expiry)
+			location, err := url.Parse(googleCallback(h).Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return location.Query().Get("token"), svc
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, svc := tt.login(t)
+			status, err := svc.TokenStatus(token)
+			if err != nil || !status.Valid {
+				t.Fatalf("token %q: %+v, %v", token, status, err)
+			}
+			if until := time.Until(time.Unix(status.ExpiresAt, 0)); until < expiry-time.Minute || until > expiry {
+				t.Errorf("session expires in %v, want %v", until, expiry)
+			}
+		})
+	}
+}
+
+// flakySessions is an auth service whose first failures session issues fail
+type flakySessions struct {
+	auth.Service
+	failures, calls int
+}
+
+func (f *flakySessions) IssueSession(u *user.User) (*authDomain.Session, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errors.New("database is locked")
+	}
+	return f.Service.IssueSession(u)
+}
+
+func TestGoogleCallbackSessionFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		existingUser bool
+		failures     int
+		wantCode     string // "" when the login succeeds
+		wantUser     bool
+	}{
+		{"retried until it works", false, sessionCreateAttempts - 1, "", true},
+		{"new user rolled back", false, sessionCreateAttempts, oauthErrSessionCreate, false},
+		{"existing user kept", true, sessionCreateAttempts, oauthErrSessionCreate, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, svc := newTestGoogleLogin(t, &config.Config{}, time.Hour)
+			if tt.existingUser {
+				if w := googleCallback(h); w.Code != http.StatusTemporaryRedirect {
+					t.Fatalf("first login: status %d", w.Code)
+				}
+			}
+			flaky := &flakySessions{Service: svc, failures: tt.failures}
+			h.authService = flaky
+
+			location, err := url.Parse(googleCallback(h).Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			query := location.Query()
+			if query.Get("code") != tt.wantCode {
+				t.Fatalf("redirected with %q, want code %q", location.RawQuery, tt.wantCode)
+			}
+			if tt.wantCode == "" {
+				if _, err := svc.ValidateToken(query.Get("token")); err != nil {
+					t.Fatalf("token %q: %v", query.Get("token"), err)
+				}
+			} else if query.Get("token") != "" || query.Get("error") == "" {
+				t.Fatalf("redirected with %q", location.RawQuery)
+			}
+			if flaky.calls != min(tt.failures+1, sessionCreateAttempts) {
+				t.Errorf("%d session attempts", flaky.calls)
+			}
+
+			_, err = h.userRepo.GetByEmail("g@example.com")
+			if exists := err == nil; exists != tt.wantUser {
+				t.Errorf("user exists = %v, want %v", exists, tt.wantUser)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"unicode"
 )
 
 // Response represents a standard API response
@@ -19,8 +22,13 @@ func SendJSON(w http.ResponseWriter, statusCode int, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// SendSuccess sends a successful JSON response
+// SendSuccess sends a successful JSON response. Requests that asked for a
+// raw response (see RawWriter) get data alone, without the envelope.
 func SendSuccess(w http.ResponseWriter, message string, data any) {
+	if isRaw(w) {
+		SendJSON(w, http.StatusOK, data)
+		return
+	}
 	SendJSON(w, http.StatusOK, Response{
 		Success: true,
 		Message: message,
@@ -35,3 +43,106 @@ func SendError(w http.ResponseWriter, message string, statusCode int) {
 		Message: message,
 	})
 }
+
+// ResponseFormatHeader set to "raw" asks for success payloads without the
+// Response envelope; ?raw=true does the same
+const ResponseFormatHeader = "X-Response-Format"
+
+// WantsRaw reports whether r asked for a raw response. Only reads qualify,
+// so writes always report their outcome in the envelope.
+func WantsRaw(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return strings.EqualFold(r.Header.Get(ResponseFormatHeader), "raw") || r.URL.Query().Get("raw") == "true"
+}
+
+// RawWriter marks w so SendSuccess writes bare payloads to it. Errors keep
+// their structured body.
+func RawWriter(w http.ResponseWriter) http.ResponseWriter {
+	return &rawWriter{ResponseWriter: w}
+}
+
+type rawWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *rawWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// isRaw reports whether w, or a writer it wraps, came from RawWriter
+func isRaw(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(*rawWriter); ok {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// NotModified sets etag with Cache-Control: no-cache, so clients revalidate
+// every time, and answers 304 when the client already holds that version.
+// It returns true when the response has been sent.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimSpace(candidate)
+		// Weak comparison, as RFC 9110 requires for If-None-Match
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ContentDisposition builds a Content-Disposition value (disposition is
+// "attachment" or "inline") that is safe for any filename. It carries an
+// ASCII-only filename for old clients and the exact name as RFC 5987
+// filename*; control characters are dropped from both.
+func ContentDisposition(disposition, filename string) string {
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filename)
+
+	fallback := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, filename)
+	if strings.Trim(fallback, "_ .") == "" {
+		fallback = "download"
+	}
+
+	var encoded strings.Builder
+	for _, b := range []byte(filename) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback, encoded.String())
+}
+
+// isAttrChar reports whether b may appear unencoded in an RFC 5987 value
+func isAttrChar(b byte) bool {
+	switch {
+	case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
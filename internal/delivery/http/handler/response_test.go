@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gomanager/internal/domain/user"
+)
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name         string
+		filename     string
+		wantHeader   string
+		wantFilename string // As decoded by a client honouring filename*
+	}{
+		{"plain", "report.pdf", `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`, "report.pdf"},
+		{"spaces", "my report.pdf", `attachment; filename="my report.pdf"; filename*=UTF-8''my%20report.pdf`, "my report.pdf"},
+		{"quotes and backslash", `say "hi"\.txt`, `attachment; filename="say _hi__.txt"; filename*=UTF-8''say%20%22hi%22%5C.txt`, `say "hi"\.txt`},
+		{"unicode", "résumé 日本.pdf", `attachment; filename="r_sum_ __.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%20%E6%97%A5%E6%9C%AC.pdf`, "résumé 日本.pdf"},
+		{"header injection", "a\r\nSet-Cookie: x=1.txt", `attachment; filename="aSet-Cookie: x=1.txt"; filename*=UTF-8''aSet-Cookie%3A%20x%3D1.txt`, "aSet-Cookie: x=1.txt"},
+		{"nothing ASCII left", "日本", `attachment; filename="download"; filename*=UTF-8''%E6%97%A5%E6%9C%AC`, "日本"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ContentDisposition("attachment", tt.filename)
+			if got != tt.wantHeader {
+				t.Fatalf("got  %s\nwant %s", got, tt.wantHeader)
+			}
+			if strings.ContainsAny(got, "\r\n") {
+				t.Fatalf("header holds a line break: %q", got)
+			}
+			disposition, params, err := mime.ParseMediaType(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if disposition != "attachment" || params["filename"] != tt.wantFilename {
+				t.Fatalf("parsed %s with filename %q, want %q", disposition, params["filename"], tt.wantFilename)
+			}
+		})
+	}
+}
+
+func TestDownloadContentDisposition(t *testing.T) {
+	name := `my "résumé" 2024.pdf`
+	h, _ := newTestFileHandler(t, map[string]string{"docs/" + name: "%PDF"})
+
+	tests := []struct {
+		query       string
+		disposition string
+	}{
+		{"", "attachment"},
+		{"?preview=true", "inline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.disposition, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = "/api/download/docs/" + name
+			r.URL.RawQuery = strings.TrimPrefix(tt.query, "?")
+			w := httptest.NewRecorder()
+			h.Download(w, withUser(r, &user.User{ID: "u1", Role: user.RoleViewer}))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			want := tt.disposition + `; filename="my _r_sum__ 2024.pdf"; filename*=UTF-8''my%20%22r%C3%A9sum%C3%A9%22%202024.pdf`
+			if got := w.Header().Get("Content-Disposition"); got != want {
+				t.Fatalf("Content-Disposition %s, want %s", got, want)
+			}
+		})
+	}
+}
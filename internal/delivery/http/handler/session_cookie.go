@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"gomanager/internal/infrastructure/config"
+)
+
+const (
+	// SessionCookieName holds the HttpOnly session token
+	SessionCookieName = "gomanager_session"
+	// CSRFCookieName holds the double-submit token readable by the frontend
+	CSRFCookieName = "gomanager_csrf"
+	// CSRFHeaderName must echo the CSRF cookie on cookie-authenticated writes
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// SessionCookieConfig controls issuing the session token as a cookie
+type SessionCookieConfig struct {
+	Enabled  bool
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// NewSessionCookieConfig builds the cookie settings from the app config
+func NewSessionCookieConfig(cfg *config.Config) SessionCookieConfig {
+	sameSite := http.SameSiteLaxMode
+	switch strings.ToLower(cfg.SessionCookieSameSite) {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+
+	return SessionCookieConfig{
+		Enabled:  cfg.SessionCookie,
+		Secure:   cfg.SessionCookieSecure,
+		SameSite: sameSite,
+	}
+}
+
+// SetSessionCookies issues the session and CSRF cookies when enabled
+func SetSessionCookies(w http.ResponseWriter, c SessionCookieConfig, token string, expiresAt time.Time) error {
+	if !c.Enabled {
+		return nil
+	}
+
+	csrfBytes := make([]byte, 32)
+	if _, err := rand.Read(csrfBytes); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: c.SameSite,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    hex.EncodeToString(csrfBytes),
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   c.Secure,
+		SameSite: c.SameSite,
+	})
+	return nil
+}
+
+// ClearSessionCookies expires the session and CSRF cookies
+func ClearSessionCookies(w http.ResponseWriter, c SessionCookieConfig) {
+	if !c.Enabled {
+		return
+	}
+
+	for _, name := range []string{SessionCookieName, CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == SessionCookieName,
+			Secure:   c.Secure,
+			SameSite: c.SameSite,
+		})
+	}
+}
+
+// ExtractToken returns the session token from the Authorization header, the
+// token query parameter or the session cookie. fromCookie reports whether it
+// came from the cookie, in which case writes must pass ValidCSRF.
+func ExtractToken(r *http.Request) (token string, fromCookie bool) {
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer "), false
+	}
+
+	// Query parameter (for downloads)
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, false
+	}
+
+	if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, true
+	}
+
+	return "", false
+}
+
+// ValidCSRF checks the double-submit token for cookie-authenticated requests.
+// Safe methods don't need one.
+func ValidCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeaderName)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) == 1
+}
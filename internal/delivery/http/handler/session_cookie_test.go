@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSetSessionCookies(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	w := httptest.NewRecorder()
+	if err := SetSessionCookies(w, SessionCookieConfig{}, "tok", expires); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Result().Cookies(); len(got) != 0 {
+		t.Fatalf("disabled config set %d cookies", len(got))
+	}
+
+	cfg := SessionCookieConfig{Enabled: true, Secure: true, SameSite: http.SameSiteStrictMode}
+	w = httptest.NewRecorder()
+	if err := SetSessionCookies(w, cfg, "tok", expires); err != nil {
+		t.Fatal(err)
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	session, csrf := cookies[SessionCookieName], cookies[CSRFCookieName]
+	if session == nil || csrf == nil {
+		t.Fatalf("cookies %v", cookies)
+	}
+	if session.Value != "tok" || !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteStrictMode || !session.Expires.Equal(expires) {
+		t.Errorf("session cookie %+v", session)
+	}
+	// The frontend has to read the CSRF token to echo it
+	if len(csrf.Value) != 64 || csrf.HttpOnly || !csrf.Secure {
+		t.Errorf("csrf cookie %+v", csrf)
+	}
+
+	w = httptest.NewRecorder()
+	ClearSessionCookies(w, cfg)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 || c.Value != "" {
+			t.Errorf("%s not cleared: %+v", c.Name, c)
+		}
+	}
+	if n := len(w.Result().Cookies()); n != 2 {
+		t.Errorf("cleared %d cookies, want 2", n)
+	}
+}
+
+func TestExtractToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		query      string
+		cookie     string
+		want       string
+		fromCookie bool
+	}{
+		{"bearer", "Bearer head", "", "", "head", false},
+		{"query", "", "query", "", "query", false},
+		{"cookie", "", "", "jar", "jar", true},
+		{"bearer wins over query and cookie", "Bearer head", "query", "jar", "head", false},
+		{"query wins over cookie", "", "query", "jar", "query", false},
+		{"basic auth is ignored", "Basic dTpw", "", "jar", "jar", true},
+		{"empty cookie", "", "", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/files?token="+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: tt.cookie})
+			token, fromCookie := ExtractToken(r)
+			if token != tt.want || fromCookie != tt.fromCookie {
+				t.Errorf("got %q, %v; want %q, %v", token, fromCookie, tt.want, tt.fromCookie)
+			}
+		})
+	}
+}
+
+func TestValidCSRF(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		want   bool
+	}{
+		{"safe method needs nothing", http.MethodGet, "", "", true},
+		{"head", http.MethodHead, "", "", true},
+		{"matching", http.MethodPost, "abc", "abc", true},
+		{"missing header", http.MethodPost, "abc", "", false},
+		{"mismatched", http.MethodDelete, "abc", "abd", false},
+		{"missing cookie", http.MethodPut, "", "abc", false},
+		{"both empty", http.MethodPost, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/delete", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFHeaderName, tt.header)
+			}
+			if got := ValidCSRF(r); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,27 +3,94 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	fileService "gomanager/internal/application/file"
+	fileDomain "gomanager/internal/domain/file"
 	domain "gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
 )
 
 type ShareHandler struct {
 	shareRepo   domain.Repository
+	userRepo    user.Repository
 	fileService fileService.Service
+	fileIndex   fileDomain.IDIndex
 	baseURL     string
+
+	maxLifetime     time.Duration
+	defaultLifetime time.Duration
+	clampLifetime   bool
+
+	// Applied to shares created without a type or permission
+	defaultType       domain.ShareType
+	defaultPermission domain.Permission
+
+	// maxShares caps a user's active shares (0 = unlimited)
+	maxShares int
+
+	viewerCanDownload bool
+
+	// Referrer checks: whether requests without one pass, and the host of
+	// the app's own frontend, which always passes
+	allowNoReferrer bool
+	frontendHost    string
+
+	// Download notifications; nil notifier disables them
+	notifier domain.AccessNotifier
+	notified *accessDebouncer
+
+	heavy *HeavyOpLimiter
+
+	// thumbnails serves the previews on landing responses; nil leaves them out
+	thumbnails *ThumbnailCache
 }
 
-func NewShareHandler(shareRepo domain.Repository, fileService fileService.Service, baseURL string) *ShareHandler {
+func NewShareHandler(shareRepo domain.Repository, userRepo user.Repository, fileService fileService.Service, fileIndex fileDomain.IDIndex, cfg *config.Config, notifier domain.AccessNotifier, heavy *HeavyOpLimiter, thumbnails *ThumbnailCache) *ShareHandler {
 	return &ShareHandler{
-		shareRepo:   shareRepo,
-		fileService: fileService,
-		baseURL:     baseURL,
+		shareRepo:       shareRepo,
+		userRepo:        userRepo,
+		fileService:     fileService,
+		fileIndex:       fileIndex,
+		baseURL:         cfg.PublicURL(),
+		maxLifetime:     time.Duration(cfg.MaxShareLifetime) * time.Hour,
+		defaultLifetime: time.Duration(cfg.DefaultShareLifetime) * time.Hour,
+		clampLifetime:   cfg.ClampShareLifetime,
+		maxShares:       cfg.MaxSharesPerUser,
+
+		defaultType:       domain.ShareType(cfg.DefaultShareType),
+		defaultPermission: domain.Permission(cfg.DefaultSharePermission),
+
+		viewerCanDownload: cfg.ViewerCanDownloadShares,
+		allowNoReferrer:   cfg.ShareAllowNoReferrer,
+		frontendHost:      domain.ReferrerHost(cfg.FrontendURL),
+		notifier:          notifier,
+		notified:          newAccessDebouncer(),
+		heavy:             heavy,
+		thumbnails:        thumbnails,
 	}
 }
 
+// maxShareMessageLength caps the recipient message on a share, in characters
+const maxShareMessageLength = 2000
+
+// maxShareReferrers caps the sites a share can be restricted to
+const maxShareReferrers = 50
+
+// maxExpiresInHours caps a relative share expiry, well below where the
+// duration it becomes would overflow
+const maxExpiresInHours = 100 * 365 * 24
+
 // CreateShare handles POST /api/shares
 func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -49,22 +116,25 @@ func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate the path exists
-	_, err := h.fileService.GetFileForDownload(req.Path)
-	if err != nil {
-		// Check if it's a directory by trying to list it
-		_, listErr := h.fileService.ListFiles(req.Path)
-		if listErr != nil {
-			SendError(w, "Path not found", http.StatusNotFound)
-			return
-		}
+	if _, err := h.fileService.Stat(req.Path); err != nil {
+		SendError(w, "Path not found", http.StatusNotFound)
+		return
 	}
 
 	// Set defaults
 	if req.ShareType == "" {
-		req.ShareType = domain.ShareTypePublic
+		req.ShareType = h.defaultType
 	}
 	if req.Permission == "" {
-		req.Permission = domain.PermissionDownload
+		req.Permission = h.defaultPermission
+	}
+	if !req.ShareType.Valid() {
+		SendError(w, "Invalid share type", http.StatusBadRequest)
+		return
+	}
+	if !req.Permission.Valid() {
+		SendError(w, "Invalid permission", http.StatusBadRequest)
+		return
 	}
 
 	// Validate password for password-protected shares
@@ -73,6 +143,88 @@ func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Resolve a relative expiry against the server clock
+	if req.ExpiresInHours != nil {
+		if req.ExpiresAt != nil {
+			SendError(w, "Provide either expiresAt or expiresInHours, not both", http.StatusBadRequest)
+			return
+		}
+		if *req.ExpiresInHours <= 0 {
+			SendError(w, "expiresInHours must be positive", http.StatusBadRequest)
+			return
+		}
+		if *req.ExpiresInHours > maxExpiresInHours {
+			SendError(w, fmt.Sprintf("expiresInHours can be at most %d", maxExpiresInHours), http.StatusBadRequest)
+			return
+		}
+		expiresAt := time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour)
+		req.ExpiresAt = &expiresAt
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		SendError(w, "Expiry must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	// Enforce the configured maximum lifetime
+	if h.maxLifetime > 0 && req.ExpiresAt != nil {
+		maxExpiry := time.Now().Add(h.maxLifetime)
+		if req.ExpiresAt.After(maxExpiry) {
+			if !h.clampLifetime {
+				SendError(w, "Expiry exceeds the maximum share lifetime", http.StatusBadRequest)
+				return
+			}
+			req.ExpiresAt = &maxExpiry
+		}
+	}
+
+	// Apply the default lifetime; with a maximum set, shares never go without expiry
+	if req.ExpiresAt == nil {
+		lifetime := h.defaultLifetime
+		if h.maxLifetime > 0 && (lifetime == 0 || lifetime > h.maxLifetime) {
+			lifetime = h.maxLifetime
+		}
+		if lifetime > 0 {
+			expiresAt := time.Now().Add(lifetime)
+			req.ExpiresAt = &expiresAt
+		}
+	}
+
+	if req.MaxDownloads != nil && *req.MaxDownloads <= 0 {
+		SendError(w, "maxDownloads must be positive", http.StatusBadRequest)
+		return
+	}
+
+	req.Message = strings.TrimSpace(req.Message)
+	if utf8.RuneCountInString(req.Message) > maxShareMessageLength {
+		SendError(w, fmt.Sprintf("message must be at most %d characters", maxShareMessageLength), http.StatusBadRequest)
+		return
+	}
+
+	referrers, ok := normalizeReferrers(req.AllowedReferrers)
+	if !ok {
+		SendError(w, fmt.Sprintf("allowedReferrers must be at most %d sites, given as hosts, origins or *.domain", maxShareReferrers), http.StatusBadRequest)
+		return
+	}
+	req.AllowedReferrers = referrers
+
+	if req.NotifyOnAccess && h.notifier == nil {
+		SendError(w, "Access notifications are not enabled on this server", http.StatusBadRequest)
+		return
+	}
+
+	// Return an identical active share instead of creating a duplicate
+	if req.ReuseExisting {
+		if existing := h.findReusableShare(u.ID, req); existing != nil {
+			SendSuccess(w, "Existing share returned", existing.ToResponse(h.baseURL))
+			return
+		}
+	}
+
+	if req.Title == "" {
+		req.Title = filepath.Base(req.Path)
+	}
+
 	// Create share entity
 	share := &domain.Share{
 		Path:         req.Path,
@@ -83,9 +235,31 @@ func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt:    req.ExpiresAt,
 		MaxDownloads: req.MaxDownloads,
 		IsActive:     true,
+		Title:        req.Title,
+		Description:  req.Description,
+		Message:      req.Message,
+
+		AllowedReferrers: req.AllowedReferrers,
+		NotifyOnAccess:   req.NotifyOnAccess,
 	}
 
-	if err := h.shareRepo.Create(share); err != nil {
+	fileID, err := h.fileIndex.GetOrCreate(req.Path)
+	if err != nil {
+		SendError(w, "Failed to create share", http.StatusInternalServerError)
+		return
+	}
+	share.FileID = fileID
+
+	// Only authenticated shares can be restricted to specific users
+	if req.ShareType == domain.ShareTypeAuthenticated {
+		share.AllowedUsers = req.AllowedUsers
+	}
+
+	if err := h.shareRepo.CreateWithinLimit(share, h.maxShares); err != nil {
+		if errors.Is(err, domain.ErrShareLimitReached) {
+			SendError(w, fmt.Sprintf("Share limit reached: at most %d active shares per user", h.maxShares), http.StatusConflict)
+			return
+		}
 		SendError(w, "Failed to create share", http.StatusInternalServerError)
 		return
 	}
@@ -93,6 +267,45 @@ func (h *ShareHandler) CreateShare(w http.ResponseWriter, r *http.Request) {
 	SendSuccess(w, "Share created successfully", share.ToResponse(h.baseURL))
 }
 
+// findReusableShare returns the user's active share matching the request's path, type and permission
+func (h *ShareHandler) findReusableShare(userID string, req domain.CreateShareRequest) *domain.Share {
+	shares, err := h.shareRepo.GetByPath(req.Path)
+	if err != nil {
+		return nil
+	}
+
+	for i := range shares {
+		s := &shares[i]
+		if s.CreatedBy != userID || !s.IsValid() {
+			continue
+		}
+		if s.ShareType != req.ShareType || s.Permission != req.Permission || s.Message != req.Message {
+			continue
+		}
+		if !slices.Equal(s.AllowedReferrers, req.AllowedReferrers) || s.NotifyOnAccess != req.NotifyOnAccess {
+			continue
+		}
+		// A password share is only identical if the password matches too
+		if s.ShareType == domain.ShareTypePassword && !s.CheckPassword(req.Password) {
+			continue
+		}
+		return s
+	}
+	return nil
+}
+
+// resolveSharePath follows the share's file ID to the file's current path.
+// Only s changes: stored paths are kept current by SharePathFollower as
+// files move, so reads never write.
+func (h *ShareHandler) resolveSharePath(s *domain.Share) {
+	if s.FileID == "" {
+		return
+	}
+	if current, err := h.fileIndex.Resolve(s.FileID); err == nil {
+		s.Path = current
+	}
+}
+
 // ListUserShares handles GET /api/shares
 func (h *ShareHandler) ListUserShares(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -114,14 +327,61 @@ func (h *ShareHandler) ListUserShares(w http.ResponseWriter, r *http.Request) {
 
 	// Convert to responses
 	responses := make([]domain.ShareResponse, len(shares))
-	for i, share := range shares {
-		responses[i] = share.ToResponse(h.baseURL)
+	for i := range shares {
+		h.resolveSharePath(&shares[i])
+		responses[i] = shares[i].ToResponse(h.baseURL)
 	}
 
 	SendSuccess(w, "", responses)
 }
 
-// DeleteShare handles DELETE /api/shares/{id}
+// topSharesLimit is how many shares the summary lists by download count
+const topSharesLimit = 5
+
+// ShareSummary handles GET /api/shares/summary
+func (h *ShareHandler) ShareSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	summary, err := h.shareRepo.GetSummaryByUser(u.ID)
+	if err != nil {
+		SendError(w, "Failed to retrieve share summary", http.StatusInternalServerError)
+		return
+	}
+
+	top, err := h.shareRepo.GetTopDownloadedByUser(u.ID, topSharesLimit)
+	if err != nil {
+		SendError(w, "Failed to retrieve share summary", http.StatusInternalServerError)
+		return
+	}
+
+	summary.TopShares = make([]domain.ShareResponse, len(top))
+	for i := range top {
+		h.resolveSharePath(&top[i])
+		summary.TopShares[i] = top[i].ToResponse(h.baseURL)
+	}
+
+	SendSuccess(w, "", summary)
+}
+
+// removeShare soft-deletes a share, keeping its row for stats and audit,
+// unless permanent is set
+func (h *ShareHandler) removeShare(id string, permanent bool) error {
+	if permanent {
+		return h.shareRepo.Delete(id)
+	}
+	return h.shareRepo.SoftDelete(id)
+}
+
+// DeleteShare handles DELETE /api/shares/{id}?permanent=
 func (h *ShareHandler) DeleteShare(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -158,11 +418,16 @@ func (h *ShareHandler) DeleteShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.shareRepo.Delete(shareID); err != nil {
+	permanent := r.URL.Query().Get("permanent") == "true"
+	if err := h.removeShare(shareID, permanent); err != nil {
 		SendError(w, "Failed to delete share", http.StatusInternalServerError)
 		return
 	}
 
+	if permanent {
+		SendSuccess(w, "Share permanently deleted", nil)
+		return
+	}
 	SendSuccess(w, "Share deleted successfully", nil)
 }
 
@@ -180,29 +445,8 @@ func (h *ShareHandler) AccessShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	share, err := h.shareRepo.GetByToken(token)
-	if err != nil {
-		if errors.Is(err, domain.ErrShareNotFound) {
-			SendError(w, "Share not found", http.StatusNotFound)
-			return
-		}
-		SendError(w, "Failed to retrieve share", http.StatusInternalServerError)
-		return
-	}
-
-	// Check if share is still valid
-	if !share.IsActive {
-		SendError(w, "Share is no longer active", http.StatusGone)
-		return
-	}
-
-	if share.IsExpired() {
-		SendError(w, "Share has expired", http.StatusGone)
-		return
-	}
-
-	if share.HasReachedMaxDownloads() {
-		SendError(w, "Maximum downloads reached", http.StatusGone)
+	share, ok := h.loadAccessibleShare(w, r, token)
+	if !ok {
 		return
 	}
 
@@ -210,13 +454,11 @@ func (h *ShareHandler) AccessShare(w http.ResponseWriter, r *http.Request) {
 	if share.ShareType == domain.ShareTypePassword {
 		if r.Method == http.MethodGet {
 			// Return info that password is required
-			SendJSON(w, http.StatusOK, Response{
-				Success: true,
-				Message: "Password required",
-				Data: map[string]interface{}{
-					"requiresPassword": true,
-					"path":             share.Path,
-				},
+			SendSuccess(w, "Password required", map[string]interface{}{
+				"requiresPassword": true,
+				"path":             share.Path,
+				"title":            share.Title,
+				"description":      share.Description,
 			})
 			return
 		}
@@ -228,41 +470,269 @@ func (h *ShareHandler) AccessShare(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Password validation should be done by comparing hashed passwords
-		// This assumes the repository stores hashed passwords
-		if req.Password != share.Password {
+		if !share.CheckPassword(req.Password) {
 			SendError(w, "Invalid password", http.StatusUnauthorized)
 			return
 		}
 	}
 
-	// Get file/folder info
+	// ?format=tar or tar.gz streams a folder share as an archive
+	if format := r.URL.Query().Get("format"); format != "" {
+		h.serveShareArchive(w, r, share, format)
+		return
+	}
+
+	// Get file/folder info; images among them get inline thumbnails
 	files, err := h.fileService.ListFiles(share.Path)
+	previewSource := files
 	if err != nil {
 		// It's a file, not a directory
-		fullPath, fileErr := h.fileService.GetFileForDownload(share.Path)
+		f, info, fileErr := h.fileService.OpenFile(share.Path)
 		if fileErr != nil {
 			SendError(w, "Shared content not found", http.StatusNotFound)
 			return
 		}
+		defer f.Close()
+
+		if share.Permission == domain.PermissionDownload && h.viewerBlocked(r) {
+			SendError(w, "Viewers cannot download shared files", http.StatusForbidden)
+			return
+		}
 
 		// Increment download counter
 		h.shareRepo.IncrementDownloads(share.ID)
+		h.notifyAccess(r, share)
 
 		// For download permission, serve the file
 		if share.Permission == domain.PermissionDownload {
-			w.Header().Set("Content-Disposition", "attachment; filename=\""+strings.TrimPrefix(share.Path, "/")+"\"")
+			w.Header().Set("Content-Disposition", ContentDisposition("attachment", info.Name))
 			w.Header().Set("Content-Type", "application/octet-stream")
-			http.ServeFile(w, r, fullPath)
+			http.ServeContent(w, r, info.Name, info.ModTime, f)
 			return
 		}
+		info.Path = strings.TrimPrefix(share.Path, "/")
+		previewSource = []fileDomain.FileInfo{*info}
+	}
+
+	previews := h.sharePreviews(previewSource)
+
+	// For directories or view permission, return the file list. Password
+	// shares only get here once the password checked out, so the message is
+	// safe to include.
+	SendSuccess(w, "", map[string]interface{}{
+		"path":        share.Path,
+		"title":       share.Title,
+		"description": share.Description,
+		"message":     share.Message,
+		"permission":  share.Permission,
+		"files":       files,
+		"previews":    previews,
+	})
+}
+
+// viewerBlocked reports whether a logged-in viewer is barred from downloading
+// shared files; anonymous public access is unaffected
+func (h *ShareHandler) viewerBlocked(r *http.Request) bool {
+	if h.viewerCanDownload {
+		return false
+	}
+	u := GetUserFromContext(r.Context())
+	return u != nil && u.Role == user.RoleViewer
+}
+
+// serveShareArchive streams a folder share as a tar, or tar.gz, archive.
+// Each archive counts as one download.
+func (h *ShareHandler) serveShareArchive(w http.ResponseWriter, r *http.Request, share *domain.Share, format string) {
+	var compress bool
+	switch format {
+	case "tar":
+	case "tar.gz":
+		compress = true
+	default:
+		SendError(w, "Unsupported format; use tar or tar.gz", http.StatusBadRequest)
+		return
+	}
+
+	if share.Permission != domain.PermissionDownload {
+		SendError(w, "This share does not allow downloads", http.StatusForbidden)
+		return
+	}
+	if h.viewerBlocked(r) {
+		SendError(w, "Viewers cannot download shared files", http.StatusForbidden)
+		return
+	}
+
+	info, err := h.fileService.Stat(share.Path)
+	if err != nil {
+		SendError(w, "Shared content not found", http.StatusNotFound)
+		return
+	}
+	if !info.IsDir {
+		SendError(w, "Archives are only available for folder shares", http.StatusBadRequest)
+		return
+	}
+
+	name := info.Name
+	if name == "" {
+		name = "share"
+	}
+	contentType := "application/x-tar"
+	if compress {
+		contentType = "application/gzip"
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	h.shareRepo.IncrementDownloads(share.ID)
+	h.notifyAccess(r, share)
+
+	// The archive is written as it's read, so errors past this point can
+	// only cut the stream short
+	w.Header().Set("Content-Disposition", ContentDisposition("attachment", name+"."+format))
+	w.Header().Set("Content-Type", contentType)
+	if err := h.fileService.WriteTar(share.Path, w, compress); err != nil {
+		log.Printf("share %s: archive failed: %v", share.ID, err)
+	}
+}
+
+// loadAccessibleShare looks up a share by token and checks it can be
+// accessed by this request, writing the error response when it can't
+func (h *ShareHandler) loadAccessibleShare(w http.ResponseWriter, r *http.Request, token string) (*domain.Share, bool) {
+	share, err := h.shareRepo.GetByToken(token)
+	if err != nil {
+		if errors.Is(err, domain.ErrShareNotFound) {
+			SendError(w, "Share not found", http.StatusNotFound)
+			return nil, false
+		}
+		SendError(w, "Failed to retrieve share", http.StatusInternalServerError)
+		return nil, false
+	}
+	h.resolveSharePath(share)
+
+	// Check if share is still valid
+	if !share.IsActive {
+		SendError(w, "Share is no longer active", http.StatusGone)
+		return nil, false
+	}
+
+	if share.IsExpired() {
+		SendError(w, "Share has expired", http.StatusGone)
+		return nil, false
+	}
+
+	if share.HasReachedMaxDownloads() {
+		SendError(w, "Maximum downloads reached", http.StatusGone)
+		return nil, false
+	}
+
+	// Handle shares restricted to logged-in users
+	if share.ShareType == domain.ShareTypeAuthenticated {
+		u := GetUserFromContext(r.Context())
+		if u == nil {
+			SendError(w, "Authentication required", http.StatusUnauthorized)
+			return nil, false
+		}
+		if !share.IsAllowedUser(u.ID) {
+			SendError(w, "Permission denied", http.StatusForbidden)
+			return nil, false
+		}
+	}
+
+	if !h.referrerAllowed(r, share) {
+		SendError(w, "This share can't be opened from this site", http.StatusForbidden)
+		return nil, false
+	}
+
+	return share, true
+}
+
+// referrerAllowed applies the share's hotlink protection. Origin is checked
+// when sent, Referer otherwise; the app's own frontend always passes.
+func (h *ShareHandler) referrerAllowed(r *http.Request, share *domain.Share) bool {
+	if len(share.AllowedReferrers) == 0 {
+		return true
+	}
+
+	source := r.Header.Get("Origin")
+	if source == "" || source == "null" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return h.allowNoReferrer
+	}
+	if h.frontendHost != "" && domain.ReferrerHost(source) == h.frontendHost {
+		return true
+	}
+	return share.ReferrerAllowed(source)
+}
+
+// normalizeReferrers reduces allowed referrers to lowercase hosts, keeping
+// a leading "*." wildcard. ok is false for malformed entries or too many.
+func normalizeReferrers(entries []string) (referrers []string, ok bool) {
+	if len(entries) > maxShareReferrers {
+		return nil, false
+	}
+	for _, entry := range entries {
+		wildcard := ""
+		entry = strings.TrimSpace(entry)
+		if rest, found := strings.CutPrefix(entry, "*."); found {
+			wildcard, entry = "*.", rest
+		}
+		host := domain.ReferrerHost(entry)
+		if host == "" || strings.ContainsAny(host, "*,") {
+			return nil, false
+		}
+		if !slices.Contains(referrers, wildcard+host) {
+			referrers = append(referrers, wildcard+host)
+		}
+	}
+	return referrers, true
+}
+
+// VerifySharePassword handles POST /api/s/{token}/verify. It only checks the
+// password so the UI can gate a download without starting it.
+func (h *ShareHandler) VerifySharePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/s/"), "/verify")
+	if token == "" || strings.Contains(token, "/") {
+		SendError(w, "Share token is required", http.StatusBadRequest)
+		return
+	}
+
+	share, ok := h.loadAccessibleShare(w, r, token)
+	if !ok {
+		return
+	}
+
+	var req domain.AccessShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Shares without a password accept any input
+	if share.ShareType == domain.ShareTypePassword && !share.CheckPassword(req.Password) {
+		SendJSON(w, http.StatusUnauthorized, Response{
+			Success: false,
+			Message: "Invalid password",
+			Data:    map[string]bool{"valid": false},
+		})
+		return
 	}
 
-	// For directories or view permission, return the file list
 	SendSuccess(w, "", map[string]interface{}{
+		"valid":      true,
 		"path":       share.Path,
 		"permission": share.Permission,
-		"files":      files,
+		"message":    share.Message,
 	})
 }
 
@@ -303,6 +773,7 @@ func (h *ShareHandler) GetShareInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.resolveSharePath(share)
 	SendSuccess(w, "", share.ToResponse(h.baseURL))
 }
 
@@ -318,6 +789,100 @@ func (h *ShareHandler) HandleShares(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// DeleteExpiredShares handles POST /api/shares/delete-expired?permanent=
+func (h *ShareHandler) DeleteExpiredShares(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shares, err := h.shareRepo.GetExpiredByUser(u.ID)
+	if err != nil {
+		SendError(w, "Failed to retrieve shares", http.StatusInternalServerError)
+		return
+	}
+
+	permanent := r.URL.Query().Get("permanent") == "true"
+	deleted := 0
+	for _, s := range shares {
+		if err := h.removeShare(s.ID, permanent); err == nil {
+			deleted++
+		}
+	}
+
+	SendSuccess(w, fmt.Sprintf("Deleted %d expired share(s)", deleted), map[string]int{
+		"deleted": deleted,
+	})
+}
+
+// DeleteSharesBatchRequest lists shares to delete in one call
+type DeleteSharesBatchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// maxShareBatchSize bounds how many shares one batch request may delete
+const maxShareBatchSize = 100
+
+// DeleteSharesBatch handles POST /api/shares/delete-batch?permanent=
+func (h *ShareHandler) DeleteSharesBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req DeleteSharesBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 {
+		SendError(w, "ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxShareBatchSize {
+		SendError(w, fmt.Sprintf("At most %d shares can be deleted at once", maxShareBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	permanent := r.URL.Query().Get("permanent") == "true"
+	deleted := make([]string, 0, len(req.IDs))
+	failed := make(map[string]string)
+	for _, id := range req.IDs {
+		share, err := h.shareRepo.GetByID(id)
+		if err != nil {
+			failed[id] = "Share not found"
+			continue
+		}
+		// Other users' shares are reported as missing so IDs can't be probed
+		if share.CreatedBy != u.ID {
+			failed[id] = "Share not found"
+			continue
+		}
+		if err := h.removeShare(id, permanent); err != nil {
+			failed[id] = "Failed to delete share"
+			continue
+		}
+		deleted = append(deleted, id)
+	}
+
+	SendSuccess(w, fmt.Sprintf("Deleted %d share(s)", len(deleted)), map[string]interface{}{
+		"deleted": deleted,
+		"failed":  failed,
+	})
+}
+
 // HandleShareByID routes /api/shares/{id} based on method
 func (h *ShareHandler) HandleShareByID(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/shares/")
@@ -337,3 +902,144 @@ func (h *ShareHandler) HandleShareByID(w http.ResponseWriter, r *http.Request) {
 		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+// Page sizes for the admin share audit
+const (
+	defaultAdminSharesPageSize = 50
+	maxAdminSharesPageSize     = 200
+)
+
+// ShareOwner identifies who created a share in admin listings
+type ShareOwner struct {
+	ID       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// AdminShareResponse is a share as seen in the admin audit
+type AdminShareResponse struct {
+	domain.ShareResponse
+	Owner   ShareOwner `json:"owner"`
+	IsValid bool       `json:"isValid"` // Active, unexpired and under its download limit
+}
+
+// ListAllShares handles GET /api/admin/shares?page=&pageSize=&type=&active=&deleted=
+func (h *ShareHandler) ListAllShares(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, pageSize := 1, defaultAdminSharesPageSize
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		// Bounded so the offset computed from it can't overflow
+		if err != nil || n < 1 || n > math.MaxInt32 {
+			SendError(w, "page must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	if v := query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			SendError(w, "pageSize must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		pageSize = min(n, maxAdminSharesPageSize)
+	}
+
+	var filter domain.ListFilter
+	if t := domain.ShareType(query.Get("type")); t != "" {
+		if !t.Valid() {
+			SendError(w, "Invalid share type", http.StatusBadRequest)
+			return
+		}
+		filter.ShareType = t
+	}
+	if v := query.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			SendError(w, "active must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.Active = &active
+	}
+	if v := query.Get("deleted"); v != "" {
+		deleted, err := strconv.ParseBool(v)
+		if err != nil {
+			SendError(w, "deleted must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.Deleted = &deleted
+	}
+
+	shares, total, err := h.shareRepo.ListAll((page-1)*pageSize, pageSize, filter)
+	if err != nil {
+		SendError(w, "Failed to retrieve shares", http.StatusInternalServerError)
+		return
+	}
+
+	owners := make(map[string]ShareOwner)
+	responses := make([]AdminShareResponse, len(shares))
+	for i := range shares {
+		h.resolveSharePath(&shares[i])
+
+		owner, ok := owners[shares[i].CreatedBy]
+		if !ok {
+			owner = ShareOwner{ID: shares[i].CreatedBy}
+			if u, err := h.userRepo.GetByID(shares[i].CreatedBy); err == nil {
+				owner.Username = u.Username
+				owner.Email = u.Email
+			}
+			owners[shares[i].CreatedBy] = owner
+		}
+
+		responses[i] = AdminShareResponse{
+			ShareResponse: shares[i].ToResponse(h.baseURL),
+			Owner:         owner,
+			IsValid:       shares[i].IsValid(),
+		}
+	}
+
+	SendSuccess(w, "", map[string]interface{}{
+		"shares":   responses,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// defaultSharePurgeDays is how long soft-deleted shares are kept when the
+// purge request doesn't say
+const defaultSharePurgeDays = 30
+
+// PurgeDeletedShares handles POST /api/admin/shares/purge?olderThanDays=
+// and permanently removes shares soft-deleted at least that many days ago
+func (h *ShareHandler) PurgeDeletedShares(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := defaultSharePurgeDays
+	if v := r.URL.Query().Get("olderThanDays"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			SendError(w, "olderThanDays must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	purged, err := h.shareRepo.PurgeDeleted(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		SendError(w, "Failed to purge shares", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, fmt.Sprintf("Purged %d deleted share(s)", purged), map[string]int{
+		"purged": purged,
+	})
+}
@@ -0,0 +1,1072 @@
+package handler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/database"
+	"gomanager/internal/infrastructure/repository"
+)
+
+func TestCreateShareDefaults(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultType    string
+		defaultPerm    string
+		body           string
+		status         int
+		wantType       share.ShareType
+		wantPermission share.Permission
+	}{
+		{"deployment defaults", "public", "view", `{"path":"a.txt"}`, http.StatusOK, share.ShareTypePublic, share.PermissionView},
+		{"authenticated default", "authenticated", "download", `{"path":"a.txt"}`, http.StatusOK, share.ShareTypeAuthenticated, share.PermissionDownload},
+		{"explicit values win", "authenticated", "view", `{"path":"a.txt","shareType":"public","permission":"download"}`, http.StatusOK, share.ShareTypePublic, share.PermissionDownload},
+		{"password default needs a password", "password", "view", `{"path":"a.txt"}`, http.StatusBadRequest, "", ""},
+		{"password default", "password", "view", `{"path":"a.txt","password":"secret"}`, http.StatusOK, share.ShareTypePassword, share.PermissionView},
+		{"unknown type", "public", "view", `{"path":"a.txt","shareType":"secret"}`, http.StatusBadRequest, "", ""},
+		{"unknown permission", "public", "view", `{"path":"a.txt","permission":"edit"}`, http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DefaultShareType: tt.defaultType, DefaultSharePermission: tt.defaultPerm}
+			h, db := newTestShareHandler(t, cfg, map[string]string{"a.txt": "a"})
+
+			r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(tt.body)), &user.User{ID: "owner", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			shares, err := repository.NewShareRepository(db).GetByUser("owner")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != http.StatusOK {
+				if len(shares) != 0 {
+					t.Fatalf("rejected request stored %d share(s)", len(shares))
+				}
+				return
+			}
+			if len(shares) != 1 || shares[0].ShareType != tt.wantType || shares[0].Permission != tt.wantPermission {
+				t.Fatalf("stored %+v, want one %s/%s share", shares, tt.wantType, tt.wantPermission)
+			}
+		})
+	}
+}
+
+func TestCreateShareLimit(t *testing.T) {
+	h, _ := newTestShareHandler(t, &config.Config{MaxSharesPerUser: 2, DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+	owner := &user.User{ID: "owner", Role: user.RoleUser}
+	create := func() *httptest.ResponseRecorder {
+		r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.txt"}`)), owner)
+		w := httptest.NewRecorder()
+		h.CreateShare(w, r)
+		return w
+	}
+
+	var firstID string
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusConflict} {
+		w := create()
+		if w.Code != want {
+			t.Fatalf("share %d: status %d, want %d: %s", i+1, w.Code, want, w.Body)
+		}
+		if i == 0 {
+			var resp struct{ Data struct{ ID string } }
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			firstID = resp.Data.ID
+		}
+	}
+
+	r := withUser(httptest.NewRequest(http.MethodDelete, "/api/shares/"+firstID, nil), owner)
+	w := httptest.NewRecorder()
+	h.DeleteShare(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+	}
+	if w := create(); w.Code != http.StatusOK {
+		t.Fatalf("after deleting one: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestTransferFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxShares int
+		from, to  string
+		path      string
+		status    int
+		moved     []string // Share paths owned by "other" afterwards
+	}{
+		{"folder", 0, "owner", "other", "docs", http.StatusOK, []string{"docs", "docs/a.txt"}},
+		{"single file", 0, "owner", "other", "docs/a.txt", http.StatusOK, []string{"docs/a.txt"}},
+		{"whole storage", 0, "owner", "other", "", http.StatusOK, []string{"docs", "docs/a.txt", "top.txt"}},
+		{"recipient at the share limit", 2, "owner", "other", "docs", http.StatusConflict, nil},
+		{"missing path", 0, "owner", "other", "nope", http.StatusNotFound, nil},
+		{"unknown sender", 0, "ghost", "other", "docs", http.StatusNotFound, nil},
+		{"unknown recipient", 0, "owner", "ghost", "docs", http.StatusNotFound, nil},
+		{"same user", 0, "owner", "owner", "docs", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestShareHandler(t, &config.Config{MaxSharesPerUser: tt.maxShares}, map[string]string{"docs/a.txt": "a", "top.txt": "t"})
+			shares := repository.NewShareRepository(db)
+			var own string // ID of the share "other" already had
+			for _, s := range []*share.Share{
+				{Path: "docs", CreatedBy: "owner"},
+				{Path: "docs/a.txt", CreatedBy: "owner"},
+				{Path: "top.txt", CreatedBy: "owner"},
+				{Path: "top.txt", CreatedBy: "other"},
+			} {
+				s.ShareType, s.Permission, s.IsActive = share.ShareTypePublic, share.PermissionView, true
+				if err := shares.Create(s); err != nil {
+					t.Fatal(err)
+				}
+				own = s.ID
+			}
+
+			body := fmt.Sprintf(`{"fromUserId":%q,"toUserId":%q,"path":%q}`, tt.from, tt.to, tt.path)
+			r := withUser(httptest.NewRequest(http.MethodPost, "/api/admin/files/transfer", strings.NewReader(body)), &user.User{ID: "admin", Role: user.RoleAdmin})
+			w := httptest.NewRecorder()
+			h.TransferFiles(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				var resp struct{ Data TransferResult }
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp.Data.SharesTransferred != len(tt.moved) {
+					t.Fatalf("reported %d shares transferred, want %d", resp.Data.SharesTransferred, len(tt.moved))
+				}
+			}
+
+			received, err := shares.GetByUser("other")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]bool{}
+			for _, s := range received {
+				if s.ID != own {
+					got[s.Path] = true
+				}
+			}
+			if len(got) != len(tt.moved) {
+				t.Fatalf("other owns %v, want %v", got, tt.moved)
+			}
+			for _, p := range tt.moved {
+				if !got[p] {
+					t.Fatalf("other owns %v, want %v", got, tt.moved)
+				}
+			}
+		})
+	}
+}
+
+func TestTransferFollowsMovedFiles(t *testing.T) {
+	h, db := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"docs/a.txt": "a"})
+	w := httptest.NewRecorder()
+	h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"docs/a.txt"}`)), &user.User{ID: "owner", Role: user.RoleUser}))
+	var created struct{ Data struct{ ID string } }
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := h.fileService.Move("docs/a.txt", "archive/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The share is matched by where its file is now, not where it was shared from
+	for _, tt := range []struct {
+		path  string
+		owner string
+	}{
+		{"docs", "owner"},
+		{"archive", "other"},
+	} {
+		w := httptest.NewRecorder()
+		h.TransferFiles(w, withUser(httptest.NewRequest(http.MethodPost, "/api/admin/files/transfer", strings.NewReader(`{"fromUserId":"owner","toUserId":"other","path":"`+tt.path+`"}`)), &user.User{ID: "admin", Role: user.RoleAdmin}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("transfer %s: status %d: %s", tt.path, w.Code, w.Body)
+		}
+		s, err := repository.NewShareRepository(db).GetByID(created.Data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.CreatedBy != tt.owner {
+			t.Fatalf("after transferring %s the share belongs to %s, want %s", tt.path, s.CreatedBy, tt.owner)
+		}
+	}
+}
+
+func TestCreateShareReuse(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		same   bool
+	}{
+		{"identical request", `{"path":"a.txt","reuseExisting":true}`, `{"path":"a.txt","reuseExisting":true}`, true},
+		{"reuse not asked", `{"path":"a.txt"}`, `{"path":"a.txt"}`, false},
+		{"other permission", `{"path":"a.txt"}`, `{"path":"a.txt","permission":"download","reuseExisting":true}`, false},
+		{"other type", `{"path":"a.txt"}`, `{"path":"a.txt","shareType":"authenticated","reuseExisting":true}`, false},
+		{"other path", `{"path":"a.txt"}`, `{"path":"b.txt","reuseExisting":true}`, false},
+		{"same password", `{"path":"a.txt","shareType":"password","password":"pw"}`, `{"path":"a.txt","shareType":"password","password":"pw","reuseExisting":true}`, true},
+		{"other password", `{"path":"a.txt","shareType":"password","password":"pw"}`, `{"path":"a.txt","shareType":"password","password":"other","reuseExisting":true}`, false},
+		{"other message", `{"path":"a.txt"}`, `{"path":"a.txt","message":"hi","reuseExisting":true}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a", "b.txt": "b"})
+			create := func(body string) string {
+				t.Helper()
+				r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser})
+				w := httptest.NewRecorder()
+				h.CreateShare(w, r)
+				if w.Code != http.StatusOK {
+					t.Fatalf("status %d: %s", w.Code, w.Body)
+				}
+				var resp struct{ Data struct{ Token string } }
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				return resp.Data.Token
+			}
+
+			first, second := create(tt.first), create(tt.second)
+			if same := first == second; same != tt.same {
+				t.Fatalf("tokens %q and %q: same = %v, want %v", first, second, same, tt.same)
+			}
+		})
+	}
+}
+
+func TestCreateShareReuseSkipsOtherUsers(t *testing.T) {
+	h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+	tokens := map[string]string{}
+	for _, id := range []string{"other", "owner"} {
+		r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.txt","reuseExisting":true}`)), &user.User{ID: id, Role: user.RoleUser})
+		w := httptest.NewRecorder()
+		h.CreateShare(w, r)
+		var resp struct{ Data struct{ Token string } }
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		tokens[id] = resp.Data.Token
+	}
+	if tokens["owner"] == "" || tokens["owner"] == tokens["other"] {
+		t.Fatalf("owner got %q, other got %q; want distinct tokens", tokens["owner"], tokens["other"])
+	}
+}
+
+func TestCreateShareExpiry(t *testing.T) {
+	at := func(d time.Duration) string { return time.Now().Add(d).UTC().Format(time.RFC3339) }
+	tests := []struct {
+		name          string
+		body          string
+		status        int
+		wantExpiresIn time.Duration // 0: no expiry
+		wantMax       int           // 0: no download limit
+	}{
+		{"no expiry", `{"path":"a.txt"}`, http.StatusOK, 0, 0},
+		{"future expiry", fmt.Sprintf(`{"path":"a.txt","expiresAt":%q}`, at(2*time.Hour)), http.StatusOK, 2 * time.Hour, 0},
+		{"past expiry", fmt.Sprintf(`{"path":"a.txt","expiresAt":%q}`, at(-time.Hour)), http.StatusBadRequest, 0, 0},
+		{"relative expiry", `{"path":"a.txt","expiresInHours":24}`, http.StatusOK, 24 * time.Hour, 0},
+		{"zero hours", `{"path":"a.txt","expiresInHours":0}`, http.StatusBadRequest, 0, 0},
+		{"negative hours", `{"path":"a.txt","expiresInHours":-1}`, http.StatusBadRequest, 0, 0},
+		{"hours past the cap", `{"path":"a.txt","expiresInHours":876001}`, http.StatusBadRequest, 0, 0},
+		{"hours that overflow a duration", `{"path":"a.txt","expiresInHours":9223372036854775807}`, http.StatusBadRequest, 0, 0},
+		{"both expiries", fmt.Sprintf(`{"path":"a.txt","expiresAt":%q,"expiresInHours":1}`, at(time.Hour)), http.StatusBadRequest, 0, 0},
+		{"download limit", `{"path":"a.txt","maxDownloads":3}`, http.StatusOK, 0, 3},
+		{"zero downloads", `{"path":"a.txt","maxDownloads":0}`, http.StatusBadRequest, 0, 0},
+		{"negative downloads", `{"path":"a.txt","maxDownloads":-2}`, http.StatusBadRequest, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+			r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(tt.body)), &user.User{ID: "owner", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			shares, err := repository.NewShareRepository(db).GetByUser("owner")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != http.StatusOK {
+				if len(shares) != 0 {
+					t.Fatalf("rejected request stored %d share(s)", len(shares))
+				}
+				return
+			}
+			if len(shares) != 1 {
+				t.Fatalf("stored %d shares, want 1", len(shares))
+			}
+			s := shares[0]
+			switch {
+			case tt.wantExpiresIn == 0 && s.ExpiresAt != nil:
+				t.Fatalf("expires at %v, want no expiry", s.ExpiresAt)
+			case tt.wantExpiresIn != 0 && (s.ExpiresAt == nil || time.Until(*s.ExpiresAt)-tt.wantExpiresIn > time.Minute || tt.wantExpiresIn-time.Until(*s.ExpiresAt) > time.Minute):
+				t.Fatalf("expires at %v, want in %v", s.ExpiresAt, tt.wantExpiresIn)
+			}
+			if (s.MaxDownloads == nil) != (tt.wantMax == 0) || (s.MaxDownloads != nil && *s.MaxDownloads != tt.wantMax) {
+				t.Fatalf("max downloads %v, want %d", s.MaxDownloads, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestAccessShareMessage(t *testing.T) {
+	h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"docs/a.txt": "a"})
+	create := func(body string) string {
+		t.Helper()
+		r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser})
+		w := httptest.NewRecorder()
+		h.CreateShare(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("create: status %d: %s", w.Code, w.Body)
+		}
+		var resp struct{ Data struct{ Token string } }
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Data.Token
+	}
+	public := create(`{"path":"docs","message":"  Please review by Friday  "}`)
+	protected := create(`{"path":"docs","shareType":"password","password":"pw","message":"Please review by Friday"}`)
+
+	tests := []struct {
+		name        string
+		token       string
+		method      string
+		body        string
+		status      int
+		wantMessage bool
+	}{
+		{"public", public, http.MethodGet, "", http.StatusOK, true},
+		{"password not given", protected, http.MethodGet, "", http.StatusOK, false},
+		{"wrong password", protected, http.MethodPost, `{"password":"nope"}`, http.StatusUnauthorized, false},
+		{"right password", protected, http.MethodPost, `{"password":"pw"}`, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.AccessShare(w, httptest.NewRequest(tt.method, "/api/s/"+tt.token, strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var resp struct{ Data map[string]any }
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			_, present := resp.Data["message"]
+			if present != tt.wantMessage || strings.Contains(w.Body.String(), "Friday") != tt.wantMessage {
+				t.Fatalf("message shown = %v, want %v: %s", present, tt.wantMessage, w.Body)
+			}
+			if tt.wantMessage && resp.Data["message"] != "Please review by Friday" {
+				t.Fatalf("message %q", resp.Data["message"])
+			}
+		})
+	}
+}
+
+func TestCreateShareMessageLength(t *testing.T) {
+	h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+	for _, tt := range []struct {
+		length int
+		status int
+	}{
+		{maxShareMessageLength, http.StatusOK},
+		{maxShareMessageLength + 1, http.StatusBadRequest},
+	} {
+		// Multi-byte characters count once each
+		body := fmt.Sprintf(`{"path":"a.txt","message":%q}`, strings.Repeat("é", tt.length))
+		r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser})
+		w := httptest.NewRecorder()
+		h.CreateShare(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%d characters: status %d, want %d", tt.length, w.Code, tt.status)
+		}
+	}
+}
+
+func TestShareReadsResolveMovesWithoutWriting(t *testing.T) {
+	h, db := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"docs/old.txt": "a"})
+	owner := &user.User{ID: "owner", Role: user.RoleUser}
+	r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"docs/old.txt"}`)), owner)
+	w := httptest.NewRecorder()
+	h.CreateShare(w, r)
+	var created struct{ Data struct{ ID, Token string } }
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+
+	// Move the file without anything following shares, as if it had been
+	// moved before share paths were kept in step
+	if _, err := h.fileService.Move("docs/old.txt", "docs/new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	shares := repository.NewShareRepository(db)
+	before, err := shares.GetByID(created.Data.ID)
+	if err != nil || before.Path != "docs/old.txt" {
+		t.Fatalf("stored share %+v, %v", before, err)
+	}
+
+	admin := &user.User{ID: "admin", Role: user.RoleAdmin}
+	reads := []struct {
+		name  string
+		serve func(w http.ResponseWriter, r *http.Request)
+		r     *http.Request
+	}{
+		{"list", h.ListUserShares, withUser(httptest.NewRequest(http.MethodGet, "/api/shares", nil), owner)},
+		{"info", h.GetShareInfo, withUser(httptest.NewRequest(http.MethodGet, "/api/shares/"+created.Data.ID+"/info", nil), owner)},
+		{"summary", h.ShareSummary, withUser(httptest.NewRequest(http.MethodGet, "/api/shares/summary", nil), owner)},
+		{"admin list", h.ListAllShares, withUser(httptest.NewRequest(http.MethodGet, "/api/admin/shares", nil), admin)},
+		{"public access", h.AccessShare, httptest.NewRequest(http.MethodGet, "/api/s/"+created.Data.Token, nil)},
+	}
+	for _, tt := range reads {
+		w := httptest.NewRecorder()
+		tt.serve(w, tt.r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.name, w.Code, w.Body)
+		}
+		if tt.name != "summary" && !strings.Contains(w.Body.String(), "docs/new.txt") {
+			t.Errorf("%s doesn't show the current path: %s", tt.name, w.Body)
+		}
+	}
+
+	after, err := shares.GetByID(created.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Path != before.Path {
+		t.Errorf("reads stored the share's path: %q -> %q", before.Path, after.Path)
+	}
+}
+
+func TestListAllSharesPages(t *testing.T) {
+	h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+	r := withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.txt"}`)), &user.User{ID: "owner", Role: user.RoleUser})
+	w := httptest.NewRecorder()
+	h.CreateShare(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+
+	admin := &user.User{ID: "admin", Role: user.RoleAdmin}
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantShares int
+	}{
+		{"", http.StatusOK, 1},
+		{"?page=2", http.StatusOK, 0},
+		{"?page=0", http.StatusBadRequest, 0},
+		{"?page=abc", http.StatusBadRequest, 0},
+		{"?page=4611686018427387904&pageSize=200", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ListAllShares(w, withUser(httptest.NewRequest(http.MethodGet, "/api/admin/shares"+tt.query, nil), admin))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Data struct{ Shares []AdminShareResponse }
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Data.Shares) != tt.wantShares {
+				t.Errorf("%d shares, want %d", len(resp.Data.Shares), tt.wantShares)
+			}
+		})
+	}
+}
+
+func TestAccessAuthenticatedShare(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed string // JSON list of allowed user IDs
+		viewer  string // "" for an anonymous request
+		status  int
+	}{
+		{"anonymous", `[]`, "", http.StatusUnauthorized},
+		{"any signed-in user", `[]`, "other", http.StatusOK},
+		{"anonymous with allowed users", `["other"]`, "", http.StatusUnauthorized},
+		{"allowed user", `["other"]`, "other", http.StatusOK},
+		{"user not allowed", `["other"]`, "stranger", http.StatusForbidden},
+		{"owner", `["other"]`, "owner", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+			body := `{"path":"a.txt","shareType":"authenticated","allowedUsers":` + tt.allowed + `}`
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser}))
+			var created struct{ Data struct{ Token string } }
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("create: status %d: %s", w.Code, w.Body)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/s/"+created.Data.Token, nil)
+			if tt.viewer != "" {
+				r = withUser(r, &user.User{ID: tt.viewer, Role: user.RoleViewer})
+			}
+			w = httptest.NewRecorder()
+			h.AccessShare(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateShareLifetime(t *testing.T) {
+	tests := []struct {
+		name                   string
+		defaultHours, maxHours int
+		clamp                  bool
+		body                   string
+		status                 int
+		wantExpiresIn          time.Duration // 0: no expiry
+	}{
+		{"no limits", 0, 0, false, `{"path":"a.txt"}`, http.StatusOK, 0},
+		{"default applied", 24, 0, false, `{"path":"a.txt"}`, http.StatusOK, 24 * time.Hour},
+		{"maximum as default", 0, 48, false, `{"path":"a.txt"}`, http.StatusOK, 48 * time.Hour},
+		{"default capped by maximum", 100, 48, false, `{"path":"a.txt"}`, http.StatusOK, 48 * time.Hour},
+		{"expiry within maximum", 0, 48, false, `{"path":"a.txt","expiresInHours":24}`, http.StatusOK, 24 * time.Hour},
+		{"expiry beyond maximum rejected", 0, 48, false, `{"path":"a.txt","expiresInHours":72}`, http.StatusBadRequest, 0},
+		{"expiry beyond maximum clamped", 0, 48, true, `{"path":"a.txt","expiresInHours":72}`, http.StatusOK, 48 * time.Hour},
+		{"explicit expiry beats default", 24, 0, false, `{"path":"a.txt","expiresInHours":2}`, http.StatusOK, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DefaultShareType: "public", DefaultSharePermission: "view", DefaultShareLifetime: tt.defaultHours, MaxShareLifetime: tt.maxHours, ClampShareLifetime: tt.clamp}
+			h, db := newTestShareHandler(t, cfg, map[string]string{"a.txt": "a"})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(tt.body)), &user.User{ID: "owner", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			shares, err := repository.NewShareRepository(db).GetByUser("owner")
+			if err != nil || len(shares) != 1 {
+				t.Fatalf("stored %d shares, %v", len(shares), err)
+			}
+			expiresAt := shares[0].ExpiresAt
+			switch {
+			case tt.wantExpiresIn == 0 && expiresAt != nil:
+				t.Fatalf("expires at %v, want no expiry", expiresAt)
+			case tt.wantExpiresIn != 0 && (expiresAt == nil || time.Until(*expiresAt) > tt.wantExpiresIn || time.Until(*expiresAt) < tt.wantExpiresIn-time.Minute):
+				t.Fatalf("expires at %v, want in %v", expiresAt, tt.wantExpiresIn)
+			}
+		})
+	}
+}
+
+func TestShareTitle(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		wantTitle       string
+		wantDescription string
+	}{
+		{"defaults to the base name", `{"path":"docs/report.pdf"}`, "report.pdf", ""},
+		{"given title and description", `{"path":"docs/report.pdf","title":"Q3 report","description":"For the board"}`, "Q3 report", "For the board"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"docs/report.pdf": "a"})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(tt.body)), &user.User{ID: "owner", Role: user.RoleUser}))
+			var created struct {
+				Data struct{ Token, Title, Description string }
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("create: status %d: %s", w.Code, w.Body)
+			}
+			if created.Data.Title != tt.wantTitle || created.Data.Description != tt.wantDescription {
+				t.Errorf("created %q, %q", created.Data.Title, created.Data.Description)
+			}
+
+			// Recipients see them on the landing page
+			w = httptest.NewRecorder()
+			h.AccessShare(w, httptest.NewRequest(http.MethodGet, "/api/s/"+created.Data.Token, nil))
+			var landing struct {
+				Data struct{ Title, Description string }
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &landing); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("access: status %d: %s", w.Code, w.Body)
+			}
+			if landing.Data.Title != tt.wantTitle || landing.Data.Description != tt.wantDescription {
+				t.Errorf("landing page shows %q, %q", landing.Data.Title, landing.Data.Description)
+			}
+		})
+	}
+}
+
+// storeShares saves shares for "owner" and "other" with the given states,
+// keyed by a name made of the owner and the state
+func storeShares(t *testing.T, db *database.DB) map[string]string {
+	t.Helper()
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	limit := 2
+	states := map[string]func(s *share.Share){
+		"active":  func(s *share.Share) {},
+		"expired": func(s *share.Share) { s.ExpiresAt = &past },
+		"maxed":   func(s *share.Share) { s.MaxDownloads, s.Downloads = &limit, 2 },
+		"limited": func(s *share.Share) { s.ExpiresAt, s.MaxDownloads, s.Downloads = &future, &limit, 1 },
+	}
+	repo := repository.NewShareRepository(db)
+	ids := make(map[string]string)
+	for _, owner := range []string{"owner", "other"} {
+		for state, apply := range states {
+			s := &share.Share{Path: "a.txt", CreatedBy: owner, ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+			apply(s)
+			if err := repo.Create(s); err != nil {
+				t.Fatal(err)
+			}
+			ids[owner+"/"+state] = s.ID
+		}
+	}
+	return ids
+}
+
+// remainingShares lists which stored shares, by storeShares name, weren't deleted
+func remainingShares(t *testing.T, db *database.DB, ids map[string]string) []string {
+	t.Helper()
+	var left []string
+	for name, id := range ids {
+		if _, err := repository.NewShareRepository(db).GetByID(id); err == nil {
+			left = append(left, name)
+		}
+	}
+	slices.Sort(left)
+	return left
+}
+
+func TestDeleteExpiredShares(t *testing.T) {
+	h, db := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+	ids := storeShares(t, db)
+
+	w := httptest.NewRecorder()
+	h.DeleteExpiredShares(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares/delete-expired", nil), &user.User{ID: "owner", Role: user.RoleUser}))
+	var resp struct{ Data struct{ Deleted int } }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if resp.Data.Deleted != 2 {
+		t.Errorf("deleted %d, want 2", resp.Data.Deleted)
+	}
+	// Other users' expired shares are theirs to clean up
+	want := []string{"other/active", "other/expired", "other/limited", "other/maxed", "owner/active", "owner/limited"}
+	if left := remainingShares(t, db, ids); !slices.Equal(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+}
+
+func TestDeleteSharesBatch(t *testing.T) {
+	h, db := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.txt": "a"})
+	ids := storeShares(t, db)
+
+	body, _ := json.Marshal(DeleteSharesBatchRequest{IDs: []string{ids["owner/active"], ids["owner/expired"], ids["other/active"], "missing"}})
+	w := httptest.NewRecorder()
+	h.DeleteSharesBatch(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares/delete-batch", bytes.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser}))
+	var resp struct {
+		Data struct {
+			Deleted []string
+			Failed  map[string]string
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if !slices.Equal(resp.Data.Deleted, []string{ids["owner/active"], ids["owner/expired"]}) {
+		t.Errorf("deleted %v", resp.Data.Deleted)
+	}
+	// Someone else's share looks the same as a missing one
+	if len(resp.Data.Failed) != 2 || resp.Data.Failed[ids["other/active"]] != "Share not found" || resp.Data.Failed["missing"] != "Share not found" {
+		t.Errorf("failed %v", resp.Data.Failed)
+	}
+	if left := remainingShares(t, db, ids); slices.Contains(left, "owner/active") || !slices.Contains(left, "other/active") {
+		t.Errorf("left %v", left)
+	}
+
+	for _, body := range []string{`{"ids":[]}`, `{"ids":` + strings.Repeat(`"x",`, maxShareBatchSize) + `"x"]}`, `{`} {
+		w := httptest.NewRecorder()
+		h.DeleteSharesBatch(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares/delete-batch", strings.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser}))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%.20s: status %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestViewerCanDownloadShares(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    bool
+		permission string
+		viewer     user.Role // "" for an anonymous request
+		status     int
+	}{
+		{"viewer blocked", false, "download", user.RoleViewer, http.StatusForbidden},
+		{"viewer allowed", true, "download", user.RoleViewer, http.StatusOK},
+		{"anonymous unaffected", false, "download", "", http.StatusOK},
+		{"user unaffected", false, "download", user.RoleUser, http.StatusOK},
+		{"viewer may still view", false, "view", user.RoleViewer, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DefaultShareType: "public", DefaultSharePermission: "view", ViewerCanDownloadShares: tt.allowed}
+			h, _ := newTestShareHandler(t, cfg, map[string]string{"a.txt": "hello"})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.txt","permission":"`+tt.permission+`"}`)), &user.User{ID: "owner", Role: user.RoleUser}))
+			var created struct{ Data struct{ Token string } }
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("create: status %d: %s", w.Code, w.Body)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/s/"+created.Data.Token, nil)
+			if tt.viewer != "" {
+				r = withUser(r, &user.User{ID: "other", Role: tt.viewer})
+			}
+			w = httptest.NewRecorder()
+			h.AccessShare(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestShareArchive(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		path       string
+		permission share.Permission
+		expiresAt  *time.Time
+		format     string
+		status     int
+		want       []string
+	}{
+		{"tar of a folder", "docs", share.PermissionDownload, nil, "tar", http.StatusOK, []string{"docs/", "docs/a.txt", "docs/sub/", "docs/sub/b.txt"}},
+		{"gzipped tar", "docs", share.PermissionDownload, nil, "tar.gz", http.StatusOK, []string{"docs/", "docs/a.txt", "docs/sub/", "docs/sub/b.txt"}},
+		{"root share skips hidden folders", "", share.PermissionDownload, nil, "tar", http.StatusOK, []string{"docs/", "docs/a.txt", "docs/sub/", "docs/sub/b.txt", "top.txt"}},
+		{"expired share", "docs", share.PermissionDownload, &past, "tar", http.StatusGone, nil},
+		{"view-only share", "docs", share.PermissionView, nil, "tar", http.StatusForbidden, nil},
+		{"file share", "top.txt", share.PermissionDownload, nil, "tar", http.StatusBadRequest, nil},
+		{"unknown format", "docs", share.PermissionDownload, nil, "zip", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestShareHandler(t, &config.Config{}, map[string]string{
+				"docs/a.txt":     "a",
+				"docs/sub/b.txt": "bb",
+				"top.txt":        "top",
+				".avatars/u.png": "png",
+			})
+			repo := repository.NewShareRepository(db)
+			s := &share.Share{Path: tt.path, CreatedBy: "owner", ShareType: share.ShareTypePublic, Permission: tt.permission, ExpiresAt: tt.expiresAt, IsActive: true}
+			if err := repo.Create(s); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			h.AccessShare(w, httptest.NewRequest(http.MethodGet, "/api/s/"+s.Token+"?format="+tt.format, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			stored, err := repo.GetByID(s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// One download per archive, none for a refused request
+			wantDownloads := 0
+			if tt.status == http.StatusOK {
+				wantDownloads = 1
+			}
+			if stored.Downloads != wantDownloads {
+				t.Errorf("%d downloads counted, want %d", stored.Downloads, wantDownloads)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var body io.Reader = w.Body
+			if tt.format == "tar.gz" {
+				gz, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+			}
+			var names []string
+			tr := tar.NewReader(body)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, hdr.Name)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("archive holds %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteShareSoftAndPermanent(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		wantRow       bool // Whether the row survives for stats and audit
+		wantDownloads int
+		wantInList    string
+	}{
+		{"soft delete", "", true, 3, "?deleted=true"},
+		{"permanent delete", "?permanent=true", false, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, db := newTestShareHandler(t, &config.Config{}, map[string]string{"a.txt": "a"})
+			s := newShareOf("owner", "a.txt")
+			s.Downloads = 3
+			if err := repository.NewShareRepository(db).Create(s); err != nil {
+				t.Fatal(err)
+			}
+			owner := &user.User{ID: "owner", Role: user.RoleUser}
+
+			w := httptest.NewRecorder()
+			h.DeleteShare(w, withUser(httptest.NewRequest(http.MethodDelete, "/api/shares/"+s.ID+tt.query, nil), owner))
+			if w.Code != http.StatusOK {
+				t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+			}
+
+			// Gone for every non-admin lookup either way
+			w = httptest.NewRecorder()
+			h.AccessShare(w, httptest.NewRequest(http.MethodGet, "/api/s/"+s.Token, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("access: status %d, want 404", w.Code)
+			}
+			w = httptest.NewRecorder()
+			h.ListUserShares(w, withUser(httptest.NewRequest(http.MethodGet, "/api/shares", nil), owner))
+			if strings.Contains(w.Body.String(), s.ID) {
+				t.Errorf("owner's list still holds the share: %s", w.Body)
+			}
+			w = httptest.NewRecorder()
+			h.DeleteShare(w, withUser(httptest.NewRequest(http.MethodDelete, "/api/shares/"+s.ID+"?permanent=true", nil), owner))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("second delete: status %d, want 404", w.Code)
+			}
+
+			var rows int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM shares WHERE id = ?`, s.ID).Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if (rows == 1) != tt.wantRow {
+				t.Errorf("%d rows left, want kept = %v", rows, tt.wantRow)
+			}
+
+			summary, err := repository.NewShareRepository(db).GetSummaryByUser("owner")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.TotalShares != 0 || summary.TotalDownloads != tt.wantDownloads {
+				t.Errorf("summary %d shares, %d downloads; want 0, %d", summary.TotalShares, summary.TotalDownloads, tt.wantDownloads)
+			}
+
+			admin := &user.User{ID: "admin", Role: user.RoleAdmin}
+			for _, query := range []string{"?deleted=true", "?deleted=false"} {
+				w = httptest.NewRecorder()
+				h.ListAllShares(w, withUser(httptest.NewRequest(http.MethodGet, "/api/admin/shares"+query, nil), admin))
+				if listed := strings.Contains(w.Body.String(), s.ID); listed != (query == tt.wantInList) {
+					t.Errorf("admin list %s holds the share: %v", query, listed)
+				}
+			}
+		})
+	}
+}
+
+func TestPurgeDeletedShares(t *testing.T) {
+	h, db := newTestShareHandler(t, &config.Config{}, map[string]string{"a.txt": "a"})
+	repo := repository.NewShareRepository(db)
+	ids := make(map[string]string)
+	for name, deletedDaysAgo := range map[string]int{"active": -1, "recent": 1, "old": 40} {
+		s := newShareOf("owner", "a.txt")
+		if err := repo.Create(s); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = s.ID
+		if deletedDaysAgo < 0 {
+			continue
+		}
+		if _, err := db.Exec(`UPDATE shares SET deleted_at = ?, is_active = ? WHERE id = ?`, time.Now().AddDate(0, 0, -deletedDaysAgo), false, s.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		query      string
+		status     int
+		wantPurged int
+		wantLeft   []string
+	}{
+		{"?olderThanDays=-1", http.StatusBadRequest, 0, []string{"active", "old", "recent"}},
+		{"", http.StatusOK, 1, []string{"active", "recent"}},
+		{"?olderThanDays=0", http.StatusOK, 1, []string{"active"}},
+		{"?olderThanDays=0", http.StatusOK, 0, []string{"active"}},
+	}
+	for _, st := range steps {
+		w := httptest.NewRecorder()
+		h.PurgeDeletedShares(w, withUser(httptest.NewRequest(http.MethodPost, "/api/admin/shares/purge"+st.query, nil), &user.User{ID: "admin", Role: user.RoleAdmin}))
+		if w.Code != st.status {
+			t.Fatalf("%q: status %d, want %d: %s", st.query, w.Code, st.status, w.Body)
+		}
+		if st.status == http.StatusOK {
+			var resp struct{ Data struct{ Purged int } }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Purged != st.wantPurged {
+				t.Errorf("%q: purged %d, want %d", st.query, resp.Data.Purged, st.wantPurged)
+			}
+		}
+		var left []string
+		for name, id := range ids {
+			var rows int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM shares WHERE id = ?`, id).Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if rows == 1 {
+				left = append(left, name)
+			}
+		}
+		slices.Sort(left)
+		if !slices.Equal(left, st.wantLeft) {
+			t.Errorf("%q: left %v, want %v", st.query, left, st.wantLeft)
+		}
+	}
+}
+
+// newShareOf returns an active public share of p owned by userID
+func newShareOf(userID, p string) *share.Share {
+	return &share.Share{Path: p, CreatedBy: userID, ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+}
+
+func TestShareURLUnderBasePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		basePath string
+		wantURL  string
+	}{
+		{"root mount", "", "https://files.example.com/s/"},
+		{"mounted under a base path", "/gomanager", "https://files.example.com/gomanager/s/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{BaseURL: "https://files.example.com", APIBasePath: tt.basePath, DefaultShareType: "public", DefaultSharePermission: "view"}
+			h, _ := newTestShareHandler(t, cfg, map[string]string{"a.txt": "a"})
+
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.txt"}`)), &user.User{ID: "owner", Role: user.RoleUser}))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp struct{ Data struct{ Token, URL string } }
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Data.URL != tt.wantURL+resp.Data.Token {
+				t.Fatalf("share URL %q, want %q", resp.Data.URL, tt.wantURL+resp.Data.Token)
+			}
+		})
+	}
+}
+
+func TestAccessShareReferrer(t *testing.T) {
+	tests := []struct {
+		name            string
+		allowNoReferrer bool
+		origin, referer string
+		status          int
+	}{
+		{"matching referer", true, "", "https://blog.example.com/post", http.StatusOK},
+		{"matching origin", true, "https://blog.example.com", "", http.StatusOK},
+		{"wildcard subdomain", true, "", "https://img.cdn.example.net/page", http.StatusOK},
+		{"origin wins over referer", true, "https://evil.test", "https://blog.example.com/post", http.StatusForbidden},
+		{"opaque origin falls back to referer", true, "null", "https://blog.example.com/post", http.StatusOK},
+		{"non-matching referer", true, "", "https://evil.test/hotlink", http.StatusForbidden},
+		{"own frontend", false, "https://app.example.com", "", http.StatusOK},
+		{"absent referrer allowed", true, "", "", http.StatusOK},
+		{"absent referrer denied", false, "", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DefaultShareType: "public", DefaultSharePermission: "download", FrontendURL: "https://app.example.com", ShareAllowNoReferrer: tt.allowNoReferrer}
+			h, _ := newTestShareHandler(t, cfg, map[string]string{"a.png": "png"})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.png","allowedReferrers":["https://Blog.example.com/","*.cdn.example.net"]}`)), &user.User{ID: "owner", Role: user.RoleUser}))
+			var created struct{ Data share.ShareResponse }
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("create: status %d: %s", w.Code, w.Body)
+			}
+			if !slices.Equal(created.Data.AllowedReferrers, []string{"blog.example.com", "*.cdn.example.net"}) {
+				t.Fatalf("stored referrers %q", created.Data.AllowedReferrers)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/s/"+created.Data.Token, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			w = httptest.NewRecorder()
+			h.AccessShare(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateShareAllowedReferrers(t *testing.T) {
+	tests := []struct {
+		name      string
+		referrers string
+		status    int
+	}{
+		{"hosts and origins", `["example.com","https://blog.example.com:8443"]`, http.StatusOK},
+		{"wildcard", `["*.example.com"]`, http.StatusOK},
+		{"inner wildcard", `["blog.*.example.com"]`, http.StatusBadRequest},
+		{"empty entry", `[""]`, http.StatusBadRequest},
+		{"too many", `[` + strings.TrimSuffix(strings.Repeat(`"example.com",`, maxShareReferrers+1), ",") + `]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "view"}, map[string]string{"a.png": "png"})
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.png","allowedReferrers":`+tt.referrers+`}`)), &user.User{ID: "owner", Role: user.RoleUser}))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
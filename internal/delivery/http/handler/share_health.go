@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	domain "gomanager/internal/domain/share"
+)
+
+// ShareHealth reports which of a user's shares point at missing files
+type ShareHealth struct {
+	Checked     int                    `json:"checked"`
+	Broken      []domain.ShareResponse `json:"broken"`
+	Deactivated int                    `json:"deactivated"` // Only with POST
+}
+
+// ShareHealthCheck handles GET /api/shares/health and checks each of the
+// caller's shares against storage, after following files the index saw
+// move. Files removed outside the API leave shares that can only 404; POST
+// runs the same check and deactivates the active ones among them.
+func (h *ShareHandler) ShareHealthCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	shares, err := h.shareRepo.GetByUser(u.ID)
+	if err != nil {
+		SendError(w, "Failed to retrieve shares", http.StatusInternalServerError)
+		return
+	}
+
+	fix := r.Method == http.MethodPost
+	health := ShareHealth{Checked: len(shares), Broken: []domain.ShareResponse{}}
+	for i := range shares {
+		s := &shares[i]
+		h.resolveSharePath(s)
+		exists, _, err := h.fileService.Exists(s.Path)
+		if err != nil {
+			SendError(w, "Failed to check shares", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			continue
+		}
+
+		if fix && s.IsActive {
+			s.IsActive = false
+			if err := h.shareRepo.Update(s); err != nil {
+				SendError(w, "Failed to deactivate share", http.StatusInternalServerError)
+				return
+			}
+			health.Deactivated++
+		}
+		health.Broken = append(health.Broken, s.ToResponse(h.baseURL))
+	}
+
+	message := ""
+	if fix {
+		message = fmt.Sprintf("Deactivated %d broken share(s)", health.Deactivated)
+	}
+	SendSuccess(w, message, health)
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/repository"
+)
+
+func TestShareHealthCheck(t *testing.T) {
+	tests := []struct {
+		method          string
+		status          int
+		wantDeactivated int
+		wantActive      bool // Whether the dangling share is still active afterwards
+	}{
+		{http.MethodGet, http.StatusOK, 0, true},
+		{http.MethodPost, http.StatusOK, 1, false},
+		{http.MethodDelete, http.StatusMethodNotAllowed, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			h, db := newTestShareHandler(t, &config.Config{}, map[string]string{"here.txt": "x"})
+			shares := repository.NewShareRepository(db)
+			valid := &share.Share{Path: "here.txt", CreatedBy: "owner", ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+			dangling := &share.Share{Path: "gone.txt", CreatedBy: "owner", ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+			for _, s := range []*share.Share{valid, dangling} {
+				if err := shares.Create(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := withUser(httptest.NewRequest(tt.method, "/api/shares/health", nil), &user.User{ID: "owner", Role: user.RoleUser})
+			w := httptest.NewRecorder()
+			h.ShareHealthCheck(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				var resp struct{ Data ShareHealth }
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Data.Checked != 2 || len(resp.Data.Broken) != 1 || resp.Data.Broken[0].ID != dangling.ID {
+					t.Fatalf("health %+v, want only the dangling share broken", resp.Data)
+				}
+				if resp.Data.Deactivated != tt.wantDeactivated {
+					t.Fatalf("deactivated %d, want %d", resp.Data.Deactivated, tt.wantDeactivated)
+				}
+			}
+
+			stored, err := shares.GetByID(dangling.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.IsActive != tt.wantActive {
+				t.Fatalf("dangling share active = %v, want %v", stored.IsActive, tt.wantActive)
+			}
+		})
+	}
+}
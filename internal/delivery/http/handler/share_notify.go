@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	domain "gomanager/internal/domain/share"
+)
+
+// shareNotifyDebounce is how long repeat downloads of a share from the same
+// IP go unreported after a notification
+const shareNotifyDebounce = 10 * time.Minute
+
+// accessDebouncer remembers recently reported share and IP pairs. It is
+// in-memory, so each process debounces on its own.
+type accessDebouncer struct {
+	mu        sync.Mutex
+	last      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newAccessDebouncer() *accessDebouncer {
+	return &accessDebouncer{last: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// allow reports whether key hasn't been reported within the debounce window,
+// and starts a new window if so
+func (d *accessDebouncer) allow(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop stale entries now and then so the map can't grow unbounded
+	if now.Sub(d.lastSweep) > shareNotifyDebounce {
+		for k, t := range d.last {
+			if now.Sub(t) > shareNotifyDebounce {
+				delete(d.last, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	if t, ok := d.last[key]; ok && now.Sub(t) <= shareNotifyDebounce {
+		return false
+	}
+	d.last[key] = now
+	return true
+}
+
+// notifyAccess tells the owner of a share with notifyOnAccess set that it was
+// downloaded. Sending happens in the background so downloads never wait on it.
+func (h *ShareHandler) notifyAccess(r *http.Request, share *domain.Share) {
+	if !share.NotifyOnAccess || h.notifier == nil {
+		return
+	}
+	now := time.Now()
+	ip := ClientIP(r)
+	if !h.notified.allow(share.ID+" "+ip, now) {
+		return
+	}
+
+	event := domain.AccessEvent{
+		ShareID: share.ID,
+		Title:   share.Title,
+		Path:    share.Path,
+		OwnerID: share.CreatedBy,
+		IP:      ip,
+		Time:    now,
+	}
+	go func() {
+		if owner, err := h.userRepo.GetByID(event.OwnerID); err == nil {
+			event.OwnerEmail = owner.Email
+		}
+		if err := h.notifier.NotifyAccess(event); err != nil {
+			log.Printf("share %s: access notification failed: %v", event.ShareID, err)
+		}
+	}()
+}
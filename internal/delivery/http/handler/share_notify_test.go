@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/config"
+)
+
+// chanNotifier hands access events to a channel
+type chanNotifier chan share.AccessEvent
+
+func (n chanNotifier) NotifyAccess(event share.AccessEvent) error {
+	n <- event
+	return nil
+}
+
+func TestShareAccessNotifications(t *testing.T) {
+	tests := []struct {
+		name      string
+		notify    bool
+		clientIPs []string // One download from each
+		wantIPs   []string // Notifications expected; they are sent concurrently
+	}{
+		{"flag set", true, []string{"203.0.113.7"}, []string{"203.0.113.7"}},
+		{"flag not set", false, []string{"203.0.113.7"}, nil},
+		{"repeat downloads from one IP", true, []string{"203.0.113.7", "203.0.113.7", "203.0.113.7"}, []string{"203.0.113.7"}},
+		{"downloads from two IPs", true, []string{"203.0.113.7", "198.51.100.2"}, []string{"203.0.113.7", "198.51.100.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "download"}, map[string]string{"docs/a.txt": "a"})
+			notifier := make(chanNotifier, 10)
+			h.notifier = notifier
+
+			body := `{"path":"docs/a.txt","title":"Report","notifyOnAccess":` + strconv.FormatBool(tt.notify) + `}`
+			w := httptest.NewRecorder()
+			h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(body)), &user.User{ID: "owner", Role: user.RoleUser}))
+			var created struct{ Data share.ShareResponse }
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("create: status %d: %s", w.Code, w.Body)
+			}
+
+			for _, ip := range tt.clientIPs {
+				r := httptest.NewRequest(http.MethodGet, "/api/s/"+created.Data.Token, nil)
+				r.RemoteAddr = ip + ":1234"
+				w := httptest.NewRecorder()
+				h.AccessShare(w, r)
+				if w.Code != http.StatusOK || w.Body.String() != "a" {
+					t.Fatalf("download: status %d: %s", w.Code, w.Body)
+				}
+			}
+
+			var gotIPs []string
+			for range tt.wantIPs {
+				select {
+				case event := <-notifier:
+					if event.ShareID != created.Data.ID || event.Path != "docs/a.txt" || event.Title != "Report" ||
+						event.OwnerID != "owner" || event.OwnerEmail != "owner@example.com" || event.Time.IsZero() {
+						t.Fatalf("notified %+v", event)
+					}
+					gotIPs = append(gotIPs, event.IP)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("notified downloads from %v, want %v", gotIPs, tt.wantIPs)
+				}
+			}
+			if !slices.Equal(slices.Sorted(slices.Values(gotIPs)), slices.Sorted(slices.Values(tt.wantIPs))) {
+				t.Fatalf("notified downloads from %v, want %v", gotIPs, tt.wantIPs)
+			}
+			select {
+			case event := <-notifier:
+				t.Fatalf("unexpected notification %+v", event)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestCreateShareNotifyNeedsNotifier(t *testing.T) {
+	h, _ := newTestShareHandler(t, &config.Config{DefaultShareType: "public", DefaultSharePermission: "download"}, map[string]string{"a.txt": "a"})
+	w := httptest.NewRecorder()
+	h.CreateShare(w, withUser(httptest.NewRequest(http.MethodPost, "/api/shares", strings.NewReader(`{"path":"a.txt","notifyOnAccess":true}`)), &user.User{ID: "owner", Role: user.RoleUser}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+	}
+}
+
+func TestAccessDebouncer(t *testing.T) {
+	d := newAccessDebouncer()
+	start := time.Now()
+	steps := []struct {
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{"s1 203.0.113.7", 0, true},
+		{"s1 203.0.113.7", time.Minute, false},
+		{"s1 198.51.100.2", time.Minute, true},
+		{"s2 203.0.113.7", time.Minute, true},
+		{"s1 203.0.113.7", shareNotifyDebounce, false},
+		{"s1 203.0.113.7", shareNotifyDebounce + time.Second, true},
+		{"s1 203.0.113.7", shareNotifyDebounce + 2*time.Second, false},
+	}
+	for i, step := range steps {
+		if got := d.allow(step.key, start.Add(step.after)); got != step.want {
+			t.Fatalf("step %d (%s after %v): got %v, want %v", i, step.key, step.after, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"log"
+
+	fileDomain "gomanager/internal/domain/file"
+	domain "gomanager/internal/domain/share"
+)
+
+// SharePathFollower rewrites share paths when the file service moves or
+// renames what they point at, so shares of a folder's contents keep working
+// after it moves. Shares with a file ID would find their file anyway; this
+// also covers older shares without one and keeps path lookups current.
+type SharePathFollower struct {
+	shares domain.Repository
+}
+
+func NewSharePathFollower(shares domain.Repository) *SharePathFollower {
+	return &SharePathFollower{shares: shares}
+}
+
+// Publish implements fileDomain.EventPublisher. It runs before the move
+// returns, so clients see updated shares as soon as the move succeeds.
+func (f *SharePathFollower) Publish(event fileDomain.Event) {
+	if event.Type != fileDomain.EventMoved {
+		return
+	}
+	if _, err := f.shares.UpdatePathPrefix(event.From, event.Path); err != nil {
+		log.Printf("failed to update shares moved from %s to %s: %v", event.From, event.Path, err)
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/base64"
+	"os"
+
+	fileDomain "gomanager/internal/domain/file"
+)
+
+// Limits for the thumbnails embedded in a share's landing response
+const (
+	sharePreviewMaxImages = 4
+	sharePreviewSize      = 160      // One of ThumbnailSizes, so previews come from the thumbnail cache
+	sharePreviewMaxBytes  = 20 << 20 // Larger images are skipped rather than decoded
+)
+
+// SharePreview is a small thumbnail of a shared image. The image is inlined
+// as a data URL so viewers need no token to display it.
+type SharePreview struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Thumbnail string `json:"thumbnail"`
+}
+
+// sharePreviews returns thumbnails for the first few images among entries.
+// Cached thumbnails are used as they are; building a missing one takes a
+// heavy-operation slot, and a busy server leaves that image out. Images
+// that can't be decoded are left out too.
+func (h *ShareHandler) sharePreviews(entries []fileDomain.FileInfo) []SharePreview {
+	previews := []SharePreview{}
+	if h.thumbnails == nil {
+		return previews
+	}
+
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
+		}
+	}()
+	for _, entry := range entries {
+		if len(previews) == sharePreviewMaxImages {
+			break
+		}
+		if entry.IsDir || entry.Size > sharePreviewMaxBytes || fileDomain.CategoryOf(entry.Name) != fileDomain.CategoryImage {
+			continue
+		}
+
+		thumbPath, ok := h.thumbnails.cached(entry.Path, sharePreviewSize)
+		if !ok {
+			if release == nil {
+				if release, ok = h.heavy.tryAcquire(); !ok {
+					continue
+				}
+			}
+			var err error
+			if thumbPath, err = h.thumbnails.get(entry.Path, sharePreviewSize); err != nil {
+				continue
+			}
+		}
+		data, err := os.ReadFile(thumbPath)
+		if err != nil {
+			continue
+		}
+		previews = append(previews, SharePreview{
+			Name:      entry.Name,
+			Path:      entry.Path,
+			Thumbnail: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data),
+		})
+	}
+	return previews
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gomanager/internal/domain/share"
+	"gomanager/internal/infrastructure/config"
+	"gomanager/internal/infrastructure/repository"
+)
+
+func testPNG(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestSharePreviewsUseThumbnailCache(t *testing.T) {
+	h, db := newTestShareHandler(t, &config.Config{}, map[string]string{"pics/a.png": testPNG(t), "pics/notes.txt": "x"})
+	s := &share.Share{Path: "pics", CreatedBy: "owner", ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+	if err := repository.NewShareRepository(db).Create(s); err != nil {
+		t.Fatal(err)
+	}
+	h.heavy = NewHeavyOpLimiter(1, 0)
+
+	previews := func() []SharePreview {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.AccessShare(w, httptest.NewRequest(http.MethodGet, "/api/s/"+s.Token, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var resp struct {
+			Data struct{ Previews []SharePreview }
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Data.Previews
+	}
+
+	// A busy server leaves out thumbnails that would have to be built
+	release, _ := h.heavy.tryAcquire()
+	if got := previews(); len(got) != 0 {
+		t.Fatalf("previews %v while busy and cold, want none", got)
+	}
+	release()
+
+	got := previews()
+	if len(got) != 1 || got[0].Path != "pics/a.png" || !strings.HasPrefix(got[0].Thumbnail, "data:image/png;base64,") {
+		t.Fatalf("previews %+v, want a.png", got)
+	}
+
+	// Once built, the thumbnail is served from the cache even when busy
+	release, _ = h.heavy.tryAcquire()
+	defer release()
+	if cached := previews(); len(cached) != 1 || cached[0].Thumbnail != got[0].Thumbnail {
+		t.Fatalf("previews %+v while busy, want the cached thumbnail", cached)
+	}
+}
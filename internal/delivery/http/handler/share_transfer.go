@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	fileDomain "gomanager/internal/domain/file"
+	domain "gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+)
+
+// TransferRequest hands the files under Path over from one user to another
+type TransferRequest struct {
+	FromUserID string `json:"fromUserId"`
+	ToUserID   string `json:"toUserId"`
+	Path       string `json:"path"`
+}
+
+// TransferResult reports what a transfer changed
+type TransferResult struct {
+	Path              string `json:"path"`
+	FromUserID        string `json:"fromUserId"`
+	ToUserID          string `json:"toUserId"`
+	SharesTransferred int    `json:"sharesTransferred"`
+}
+
+// TransferFiles handles POST /api/admin/files/transfer, e.g. when offboarding
+// a user. Storage is shared rather than split into per-user roots, so the
+// files stay where they are and keep their stable IDs; what moves is the
+// ownership of fromUserId's shares of path and anything under it.
+func (h *ShareHandler) TransferFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		SendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.FromUserID == "" || req.ToUserID == "" {
+		SendError(w, "fromUserId and toUserId are required", http.StatusBadRequest)
+		return
+	}
+	if req.FromUserID == req.ToUserID {
+		SendError(w, "fromUserId and toUserId must differ", http.StatusBadRequest)
+		return
+	}
+
+	for _, id := range []string{req.FromUserID, req.ToUserID} {
+		if _, err := h.userRepo.GetByID(id); err != nil {
+			if errors.Is(err, user.ErrUserNotFound) {
+				SendError(w, "User not found: "+id, http.StatusNotFound)
+				return
+			}
+			SendError(w, "Failed to look up user", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := h.fileService.Stat(req.Path); err != nil {
+		if errors.Is(err, fileDomain.ErrNotFound) {
+			SendError(w, "File or directory not found", http.StatusNotFound)
+			return
+		}
+		SendError(w, "Failed to read file info", http.StatusInternalServerError)
+		return
+	}
+
+	shares, err := h.shareRepo.GetAllByUser(req.FromUserID)
+	if err != nil {
+		SendError(w, "Failed to list shares", http.StatusInternalServerError)
+		return
+	}
+
+	root := cleanSharePath(req.Path)
+	var ids []string
+	for i := range shares {
+		// Match on the file's current location, not where it was shared from
+		h.resolveSharePath(&shares[i])
+		p := cleanSharePath(shares[i].Path)
+		if root == "" || p == root || strings.HasPrefix(p, root+"/") {
+			ids = append(ids, shares[i].ID)
+		}
+	}
+
+	n, err := h.shareRepo.SetOwner(ids, req.ToUserID, h.maxShares)
+	if err != nil {
+		if errors.Is(err, domain.ErrShareLimitReached) {
+			SendError(w, fmt.Sprintf("Share limit reached: %s would have more than %d active shares", req.ToUserID, h.maxShares), http.StatusConflict)
+			return
+		}
+		SendError(w, "Failed to transfer shares", http.StatusInternalServerError)
+		return
+	}
+
+	SendSuccess(w, "Files transferred", TransferResult{
+		Path:              root,
+		FromUserID:        req.FromUserID,
+		ToUserID:          req.ToUserID,
+		SharesTransferred: n,
+	})
+}
+
+// cleanSharePath normalizes a storage path for comparison; share paths may
+// carry a leading slash
+func cleanSharePath(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
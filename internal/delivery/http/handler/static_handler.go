@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// NewSPAHandler serves a built single-page app from dir. Paths that don't
+// match a file get index.html so client-side routes survive a reload, while
+// unknown /api/ paths still return a JSON 404.
+func NewSPAHandler(dir string) http.HandlerFunc {
+	fileServer := http.FileServer(http.Dir(dir))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+			SendError(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		cleaned := path.Clean("/" + r.URL.Path)
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(cleaned)))
+		if err != nil || info.IsDir() {
+			// History fallback: let the file server resolve / to index.html
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = "/"
+			w.Header().Set("Cache-Control", "no-cache")
+			fileServer.ServeHTTP(w, r2)
+			return
+		}
+
+		fileServer.ServeHTTP(w, r)
+	}
+}
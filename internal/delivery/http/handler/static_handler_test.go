@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSPAHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"index.html":          "<html>app</html>",
+		"assets/app.js":       "console.log(1)",
+		"assets/nested/x.css": "body{}",
+	})
+	h := NewSPAHandler(dir)
+
+	tests := []struct {
+		name      string
+		path      string
+		status    int
+		wantBody  string
+		wantType  string
+		wantCache bool
+	}{
+		{"asset", "/assets/app.js", http.StatusOK, "console.log(1)", "text/javascript", false},
+		{"root", "/", http.StatusOK, "<html>app</html>", "text/html", true},
+		{"deep link", "/files/docs/report", http.StatusOK, "<html>app</html>", "text/html", true},
+		{"asset directory", "/assets/nested", http.StatusOK, "<html>app</html>", "text/html", true},
+		{"escaping the dir", "/../../etc/passwd", http.StatusOK, "<html>app</html>", "text/html", true},
+		{"unknown api path", "/api/nope", http.StatusNotFound, `"success":false`, "application/json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = tt.path
+			w := httptest.NewRecorder()
+			h(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body %q, want %q", w.Body, tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("content type %q, want %q", got, tt.wantType)
+			}
+			if got := w.Header().Get("Cache-Control") == "no-cache"; got != tt.wantCache {
+				t.Errorf("no-cache %v, want %v", got, tt.wantCache)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	fileDomain "gomanager/internal/domain/file"
+)
+
+// ThumbnailSizes are the standard thumbnail dimensions, smallest first. Only
+// these are generated so the cache stays bounded.
+var ThumbnailSizes = []int{160, 480}
+
+const (
+	// thumbnailMaxSourceBytes skips images too large to decode cheaply
+	thumbnailMaxSourceBytes = 20 << 20
+	// thumbnailQueueSize is how many uploads may wait for pregeneration;
+	// beyond that they are left to be generated on first view
+	thumbnailQueueSize = 256
+	// thumbnailWorkers is how many uploads are thumbnailed at once
+	thumbnailWorkers = 2
+)
+
+var errNoThumbnail = errors.New("no thumbnail for this file")
+
+// ThumbnailCache stores PNG thumbnails of images under the storage folder's
+// .thumbnails directory, regenerated when the image is newer than its
+// thumbnail. The directory mirrors the storage folders, so a folder's
+// thumbnails move and go with it. As an event publisher it keeps the cache in
+// step with deletes and moves and, once Pregenerate is called, warms it for
+// new uploads in the background.
+type ThumbnailCache struct {
+	files fileDomain.Repository
+	dir   string
+
+	// building holds a lock per thumbnail file so concurrent requests for a
+	// cold thumbnail decode the image once, without holding up others
+	buildingMu sync.Mutex
+	building   map[string]*thumbnailLock
+
+	jobs chan string // nil unless pregeneration is on
+}
+
+// thumbnailLock is a building lock and the number of requests holding or
+// waiting for it; it is dropped when that reaches zero
+type thumbnailLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// NewThumbnailCache reads images from files and keeps thumbnails in storagePath
+func NewThumbnailCache(files fileDomain.Repository, storagePath string) *ThumbnailCache {
+	dir := filepath.Join(storagePath, ".thumbnails")
+	os.MkdirAll(dir, 0755)
+	return &ThumbnailCache{files: files, dir: dir, building: make(map[string]*thumbnailLock)}
+}
+
+// Pregenerate starts workers that build every standard size for images as
+// they are uploaded, so they are warm by the time a client asks for them
+func (c *ThumbnailCache) Pregenerate() {
+	c.jobs = make(chan string, thumbnailQueueSize)
+	for i := 0; i < thumbnailWorkers; i++ {
+		go func() {
+			for p := range c.jobs {
+				for _, size := range ThumbnailSizes {
+					if _, err := c.get(p, size); err != nil && !errors.Is(err, errNoThumbnail) {
+						log.Printf("thumbnail pregeneration failed for %s: %v", p, err)
+						break
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Publish implements file.EventPublisher. It runs before the file operation
+// returns, so new uploads are only queued here.
+func (c *ThumbnailCache) Publish(event fileDomain.Event) {
+	switch event.Type {
+	case fileDomain.EventCreated:
+		if c.jobs == nil || !thumbnailable(event.Path) {
+			return
+		}
+		select {
+		case c.jobs <- event.Path:
+		default:
+		}
+	case fileDomain.EventDeleted:
+		c.remove(event.Path)
+	case fileDomain.EventMoved:
+		c.move(event.From, event.Path)
+	}
+}
+
+// get returns the cached thumbnail of the image at p, generating it if it is
+// missing or older than the image
+func (c *ThumbnailCache) get(p string, size int) (string, error) {
+	p = cleanSharePath(p)
+	if !thumbnailable(p) {
+		return "", errNoThumbnail
+	}
+	info, err := c.files.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir || info.Size > thumbnailMaxSourceBytes {
+		return "", errNoThumbnail
+	}
+
+	thumbPath := c.file(p, size)
+	if cached, err := os.Stat(thumbPath); err == nil && !cached.ModTime().Before(info.ModTime) {
+		return thumbPath, nil
+	}
+
+	unlock := c.lock(thumbPath)
+	defer unlock()
+	// Another request may have built it while this one waited
+	if cached, err := os.Stat(thumbPath); err == nil && !cached.ModTime().Before(info.ModTime) {
+		return thumbPath, nil
+	}
+
+	f, _, err := c.files.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	img, err := decodeAvatar(f, strings.ToLower(path.Ext(p)))
+	if err != nil {
+		return "", errNoThumbnail
+	}
+
+	// Write beside the final name so readers never see a partial file
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	encodeErr := png.Encode(tmp, fitAvatar(img, size))
+	if closeErr := tmp.Close(); encodeErr == nil {
+		encodeErr = closeErr
+	}
+	if encodeErr == nil {
+		encodeErr = os.MkdirAll(filepath.Dir(thumbPath), 0755)
+	}
+	if encodeErr == nil {
+		encodeErr = os.Rename(tmp.Name(), thumbPath)
+	}
+	if encodeErr != nil {
+		os.Remove(tmp.Name())
+		return "", encodeErr
+	}
+	return thumbPath, nil
+}
+
+// cached returns the thumbnail of the image at p if one is already built and
+// no older than the image, without generating it
+func (c *ThumbnailCache) cached(p string, size int) (string, bool) {
+	p = cleanSharePath(p)
+	if !thumbnailable(p) {
+		return "", false
+	}
+	info, err := c.files.Stat(p)
+	if err != nil {
+		return "", false
+	}
+	thumbPath := c.file(p, size)
+	if cached, err := os.Stat(thumbPath); err != nil || cached.ModTime().Before(info.ModTime) {
+		return "", false
+	}
+	return thumbPath, true
+}
+
+// lock takes the building lock for the thumbnail file key
+func (c *ThumbnailCache) lock(key string) (unlock func()) {
+	c.buildingMu.Lock()
+	l, ok := c.building[key]
+	if !ok {
+		l = &thumbnailLock{}
+		c.building[key] = l
+	}
+	l.waiters++
+	c.buildingMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		c.buildingMu.Lock()
+		if l.waiters--; l.waiters == 0 {
+			delete(c.building, key)
+		}
+		c.buildingMu.Unlock()
+	}
+}
+
+// remove drops every cached size of p and, if p was a folder, the
+// thumbnails of everything in it
+func (c *ThumbnailCache) remove(p string) {
+	for _, size := range ThumbnailSizes {
+		os.Remove(c.file(p, size))
+	}
+	if folder := c.folder(p); folder != c.dir {
+		os.RemoveAll(folder)
+	}
+}
+
+// move carries the thumbnails of p, or of everything in it if p is a
+// folder, over to its new path
+func (c *ThumbnailCache) move(from, to string) {
+	for _, size := range ThumbnailSizes {
+		if dst := c.file(to, size); os.MkdirAll(filepath.Dir(dst), 0755) == nil {
+			os.Rename(c.file(from, size), dst)
+		}
+	}
+	src, dst := c.folder(from), c.folder(to)
+	if src == c.dir || dst == c.dir {
+		return
+	}
+	if _, err := os.Stat(src); err != nil {
+		return
+	}
+	// Whatever was cached for a folder replaced at to is stale
+	os.RemoveAll(dst)
+	if os.MkdirAll(filepath.Dir(dst), 0755) == nil {
+		os.Rename(src, dst)
+	}
+}
+
+// file is where the thumbnail of p at size is kept: under p's folder in the
+// mirror, named by a hash of p's name so long names still fit
+func (c *ThumbnailCache) file(p string, size int) string {
+	p = cleanSharePath(p)
+	sum := sha256.Sum256([]byte(path.Base(p)))
+	return filepath.Join(c.folder(path.Dir(p)), hex.EncodeToString(sum[:])+"-"+strconv.Itoa(size)+".png")
+}
+
+// folder is the mirror of the storage folder p inside the cache
+func (c *ThumbnailCache) folder(p string) string {
+	p = cleanSharePath(p)
+	if p == "" || p == "." {
+		return c.dir
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(p))
+}
+
+// thumbnailable reports whether p has an image extension thumbnails can be
+// decoded from
+func thumbnailable(p string) bool {
+	_, ok := avatarFormats[strings.ToLower(path.Ext(p))]
+	return ok
+}
+
+// Thumbnail handles GET /api/files/thumbnail?path=&size= and serves a PNG of
+// the image scaled to fit size (one of ThumbnailSizes, default the smallest).
+// Thumbnails are generated on first request unless pregeneration built them
+// at upload.
+func (h *FileHandler) Thumbnail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filePath := r.URL.Query().Get("path")
+	if filePath == "" {
+		SendError(w, "Path is required", http.StatusBadRequest)
+		return
+	}
+
+	size := ThumbnailSizes[0]
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || !slices.Contains(ThumbnailSizes, n) {
+			SendError(w, "Unsupported thumbnail size", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	if h.thumbnails == nil {
+		SendError(w, "Thumbnails are not available", http.StatusNotFound)
+		return
+	}
+
+	// Go through the service so hidden paths stay hidden
+	info, err := h.service.Stat(filePath)
+	if err != nil {
+		SendError(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if info.IsDir {
+		SendError(w, "Cannot thumbnail a directory", http.StatusBadRequest)
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	thumbPath, err := h.thumbnails.get(info.Path, size)
+	release()
+	if err != nil {
+		if errors.Is(err, errNoThumbnail) {
+			SendError(w, "No thumbnail available for this file", http.StatusUnsupportedMediaType)
+			return
+		}
+		SendError(w, "Failed to generate thumbnail", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	http.ServeFile(w, r, thumbPath)
+}
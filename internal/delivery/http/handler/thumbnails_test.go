@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	fileDomain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// newTestThumbnailCache returns a cache over a temporary storage folder
+// holding files, a map of slash-separated paths to contents
+func newTestThumbnailCache(t *testing.T, files map[string]string) (*ThumbnailCache, string) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFiles(t, dir, files)
+	return NewThumbnailCache(repository.NewFilesystemRepository(dir, nil, false), dir), dir
+}
+
+func TestThumbnailCacheFollowsChanges(t *testing.T) {
+	img := testPNG(t)
+	tests := []struct {
+		name     string
+		event    fileDomain.Event
+		rename   [2]string // Storage move to apply with the event, if any
+		wantPath string    // Image expected to have a cached thumbnail afterwards
+		gone     string    // Image expected to have none
+	}{
+		{"file move", fileDomain.Event{Type: fileDomain.EventMoved, From: "a/b/img.png", Path: "a/b/moved.png"}, [2]string{"a/b/img.png", "a/b/moved.png"}, "a/b/moved.png", ""},
+		{"folder move", fileDomain.Event{Type: fileDomain.EventMoved, From: "a", Path: "c/d"}, [2]string{"a", "c/d"}, "c/d/b/img.png", ""},
+		{"file delete", fileDomain.Event{Type: fileDomain.EventDeleted, Path: "a/b/img.png"}, [2]string{}, "", "a/b/img.png"},
+		{"folder delete", fileDomain.Event{Type: fileDomain.EventDeleted, Path: "a"}, [2]string{}, "", "a/b/img.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTestThumbnailCache(t, map[string]string{"a/b/img.png": img})
+			built, err := c.get("a/b/img.png", ThumbnailSizes[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.rename[0] != "" {
+				dst := filepath.Join(dir, filepath.FromSlash(tt.rename[1]))
+				os.MkdirAll(filepath.Dir(dst), 0755)
+				if err := os.Rename(filepath.Join(dir, filepath.FromSlash(tt.rename[0])), dst); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.Publish(tt.event)
+
+			if tt.wantPath != "" {
+				if _, ok := c.cached(tt.wantPath, ThumbnailSizes[0]); !ok {
+					t.Errorf("no cached thumbnail for %s", tt.wantPath)
+				}
+			}
+			if tt.gone != "" {
+				if _, err := os.Stat(built); err == nil {
+					t.Errorf("thumbnail of %s is still cached", tt.gone)
+				}
+			}
+		})
+	}
+}
+
+func TestThumbnailPregeneration(t *testing.T) {
+	c, _ := newTestThumbnailCache(t, map[string]string{"up/new.png": testPNG(t)})
+	c.Pregenerate()
+	c.Publish(fileDomain.Event{Type: fileDomain.EventCreated, Path: "up/new.png"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, size := range ThumbnailSizes {
+		for {
+			if _, ok := c.cached("up/new.png", size); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("size %d was not pregenerated", size)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestThumbnailConcurrentBuilds(t *testing.T) {
+	img := testPNG(t)
+	c, _ := newTestThumbnailCache(t, map[string]string{"a.png": img, "b.png": img})
+	var wg sync.WaitGroup
+	paths := make([]string, 8)
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := c.get([]string{"a.png", "b.png"}[i%2], ThumbnailSizes[0])
+			if err != nil {
+				t.Error(err)
+			}
+			paths[i] = p
+		}()
+	}
+	wg.Wait()
+	for i, p := range paths {
+		if p != paths[i%2] {
+			t.Fatalf("request %d got %s, want %s", i, p, paths[i%2])
+		}
+	}
+	if len(c.building) != 0 {
+		t.Fatalf("%d building locks left behind", len(c.building))
+	}
+}
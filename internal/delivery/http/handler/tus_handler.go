@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// Subset of the tus 1.0.0 resumable upload protocol (core, creation and
+// expiration).
+// See https://tus.io/protocols/resumable-upload
+const (
+	tusVersion     = "1.0.0"
+	tusBasePath    = "/api/uploads/tus"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// Tus handles /api/uploads/tus (POST) and /api/uploads/tus/{id} (HEAD, PATCH)
+func (h *FileHandler) Tus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		SendError(w, "Unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, tusBasePath), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		h.tusCreate(w, r)
+	case id != "" && r.Method == http.MethodHead:
+		h.tusHead(w, r, id)
+	case id != "" && r.Method == http.MethodPatch:
+		h.tusPatch(w, r, id)
+	default:
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// tusCreate starts an upload. The target folder and name come from the
+// Upload-Metadata "path" and "filename" keys; ?path= is accepted as well.
+func (h *FileHandler) tusCreate(w http.ResponseWriter, r *http.Request) {
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		SendError(w, "Upload-Length is required", http.StatusBadRequest)
+		return
+	}
+	if maxSize := h.uploadPolicy.MaxFileSize(u.Role); length > maxSize {
+		SendError(w, fmt.Sprintf("Upload exceeds the %d byte limit", maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		SendError(w, "Invalid Upload-Metadata", http.StatusBadRequest)
+		return
+	}
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = metadata["name"]
+	}
+	targetPath := metadata["path"]
+	if targetPath == "" {
+		targetPath = r.URL.Query().Get("path")
+	}
+
+	upload, err := h.service.CreateUpload(u.ID, targetPath, filename, length)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidPath):
+			SendError(w, "A valid filename is required in Upload-Metadata", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrExists):
+			SendError(w, "File already exists", http.StatusConflict)
+		case errors.Is(err, domain.ErrPathTooDeep):
+			SendError(w, "Path exceeds the maximum directory depth", http.StatusBadRequest)
+		case errors.Is(err, domain.ErrNotDirectory):
+			SendError(w, "Target is not a directory", http.StatusBadRequest)
+		default:
+			SendError(w, "Failed to create upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// An empty file is complete as soon as it is created
+	if length == 0 {
+		if _, _, err := h.service.AppendUpload(upload.ID, 0, http.NoBody); err != nil {
+			SendError(w, "Failed to create upload", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Location", publicPath(r, tusBasePath+"/"+upload.ID))
+	w.Header().Set("Upload-Offset", "0")
+	if length > 0 {
+		setUploadExpires(w, upload)
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// tusHead reports how many bytes the server has
+func (h *FileHandler) tusHead(w http.ResponseWriter, r *http.Request, id string) {
+	upload, ok := h.ownedUpload(w, r, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	setUploadExpires(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+// tusPatch appends the request body at Upload-Offset
+func (h *FileHandler) tusPatch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != tusContentType {
+		SendError(w, "Content-Type must be "+tusContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		SendError(w, "Upload-Offset is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.ownedUpload(w, r, id); !ok {
+		return
+	}
+
+	upload, finalPath, err := h.service.AppendUpload(id, offset, r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrOffsetMismatch):
+			SendError(w, "Upload-Offset does not match the current offset", http.StatusConflict)
+		case errors.Is(err, domain.ErrUploadTooLarge):
+			SendError(w, "Body exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, domain.ErrUploadNotFound):
+			SendError(w, "Upload not found", http.StatusNotFound)
+		case errors.Is(err, domain.ErrScanRejected):
+			SendError(w, "File was rejected by the upload scanner", http.StatusUnprocessableEntity)
+		default:
+			// Bytes received before the failure are kept; the client resumes via HEAD
+			SendError(w, "Failed to write upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if finalPath != "" {
+		w.Header().Set("X-Upload-Path", finalPath)
+	} else {
+		setUploadExpires(w, upload)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setUploadExpires tells the client when an unfinished upload is removed
+func setUploadExpires(w http.ResponseWriter, upload *domain.PendingUpload) {
+	if !upload.ExpiresAt.IsZero() {
+		w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// ownedUpload loads an upload and checks it belongs to the caller
+func (h *FileHandler) ownedUpload(w http.ResponseWriter, r *http.Request, id string) (*domain.PendingUpload, bool) {
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	upload, err := h.service.GetUpload(id)
+	if err != nil || upload.UserID != u.ID {
+		SendError(w, "Upload not found", http.StatusNotFound)
+		return nil, false
+	}
+	return upload, true
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"gomanager/internal/domain/user"
+)
+
+const largeUploadSize = 32 << 20
+
+// largeUploadRequest streams a multipart upload of one size-byte file, so
+// the request body itself is never held in memory
+func largeUploadRequest(size int64) *http.Request {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		fw, err := mw.CreateFormFile("files", "big.bin")
+		if err == nil {
+			_, err = io.CopyN(fw, zeroReader{}, size)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/upload", pr)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return withUser(r, &user.User{ID: "u1", Role: user.RoleUser})
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// allocatedDuring returns the bytes allocated while fn runs
+func allocatedDuring(fn func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestUploadMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		memoryLimit int64
+		maxAlloc    uint64 // 0: no bound, at least the file is buffered
+		minAlloc    uint64
+	}{
+		{"spooled to disk", 1 << 20, 8 << 20, 0},
+		{"buffered in memory", 64 << 20, 0, largeUploadSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, dir := newTestFileService(t, newTestDB(t), nil)
+			h := NewFileHandler(svc, UploadPolicy{DefaultMaxFileSize: 1 << 30, MemoryLimit: tt.memoryLimit}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+
+			w := httptest.NewRecorder()
+			r := largeUploadRequest(largeUploadSize)
+			allocated := allocatedDuring(func() { h.Upload(w, r) })
+			if r.MultipartForm != nil {
+				r.MultipartForm.RemoveAll()
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if info, err := os.Stat(filepath.Join(dir, "big.bin")); err != nil || info.Size() != largeUploadSize {
+				t.Fatalf("stored %v, %v", info, err)
+			}
+
+			if tt.maxAlloc > 0 && allocated > tt.maxAlloc {
+				t.Fatalf("allocated %d MB for a %d MB upload, want at most %d MB", allocated>>20, largeUploadSize>>20, tt.maxAlloc>>20)
+			}
+			if allocated < tt.minAlloc {
+				t.Fatalf("allocated %d MB, want at least %d MB", allocated>>20, tt.minAlloc>>20)
+			}
+		})
+	}
+}
+
+// BenchmarkUploadMemoryLimit shows memory use per upload (B/op) stays near
+// the limit rather than growing with the file
+func BenchmarkUploadMemoryLimit(b *testing.B) {
+	for _, bm := range []struct {
+		name  string
+		limit int64
+	}{
+		{"limit=1MB", 1 << 20},
+		{"limit=8MB", 8 << 20},
+		{"limit=64MB", 64 << 20},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			svc, _ := newTestFileService(b, newTestDB(b), nil)
+			h := NewFileHandler(svc, UploadPolicy{DefaultMaxFileSize: 1 << 30, MemoryLimit: bm.limit}, nil, "", 0, NewHeavyOpLimiter(0, 0), nil, nil, nil)
+			b.SetBytes(largeUploadSize)
+			b.ReportAllocs()
+			for range b.N {
+				r := largeUploadRequest(largeUploadSize)
+				r.URL.RawQuery = "overwrite=true"
+				h.Upload(httptest.NewRecorder(), r)
+				if r.MultipartForm != nil {
+					r.MultipartForm.RemoveAll()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+	"gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+)
+
+// UserExport bundles the data kept about one user. Password hashes, session
+// tokens and the Google refresh token are left out.
+type UserExport struct {
+	ExportedAt      time.Time             `json:"exportedAt"`
+	Profile         user.UserResponse     `json:"profile"`
+	GoogleConnected bool                  `json:"googleConnected"`
+	Shares          []share.ShareResponse `json:"shares"` // Includes soft-deleted shares
+	Files           []ExportedFile        `json:"files"`
+}
+
+// ExportedFile is a manifest entry for a path the user has shared. Storage
+// isn't owned per user, so shared paths are what ties files to a user.
+type ExportedFile struct {
+	Path     string     `json:"path"`
+	Exists   bool       `json:"exists"`
+	IsDir    bool       `json:"isDir,omitempty"`
+	Size     int64      `json:"size,omitempty"`
+	ModTime  *time.Time `json:"modTime,omitempty"`
+	ShareIDs []string   `json:"shareIds"`
+}
+
+// Export handles GET /api/user/export. The default is a JSON document;
+// format=zip streams export.json as an archive, adding the avatar and, with
+// includeFiles=true, the shared files under files/.
+func (h *UserHandler) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		SendError(w, "format must be json or zip", http.StatusBadRequest)
+		return
+	}
+
+	export, err := h.buildExport(u)
+	if err != nil {
+		log.Printf("export for user %s failed: %v", u.ID, err)
+		SendError(w, "Failed to export user data", http.StatusInternalServerError)
+		return
+	}
+
+	if format != "zip" {
+		SendSuccess(w, "", export)
+		return
+	}
+
+	release, ok := h.heavy.acquire(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// The archive is written as it's read, so errors past this point can
+	// only cut the stream short
+	name := "gomanager-export-" + u.Username + "-" + export.ExportedAt.Format("20060102") + ".zip"
+	w.Header().Set("Content-Disposition", ContentDisposition("attachment", name))
+	w.Header().Set("Content-Type", "application/zip")
+	if err := h.writeExportZip(w, u, export, r.URL.Query().Get("includeFiles") == "true"); err != nil {
+		log.Printf("export for user %s: archive failed: %v", u.ID, err)
+	}
+}
+
+func (h *UserHandler) buildExport(u *user.User) (*UserExport, error) {
+	shares, err := h.shareRepo.GetAllByUser(u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserExport{
+		ExportedAt:      time.Now().UTC(),
+		Profile:         u.ToResponse(),
+		GoogleConnected: u.GoogleToken != "",
+		Shares:          make([]share.ShareResponse, len(shares)),
+		Files:           []ExportedFile{},
+	}
+
+	byPath := make(map[string]int)
+	for i := range shares {
+		export.Shares[i] = shares[i].ToResponse(h.baseURL)
+
+		p := strings.TrimPrefix(shares[i].Path, "/")
+		if idx, ok := byPath[p]; ok {
+			export.Files[idx].ShareIDs = append(export.Files[idx].ShareIDs, shares[i].ID)
+			continue
+		}
+		entry := ExportedFile{Path: p, ShareIDs: []string{shares[i].ID}}
+		if info, err := h.fileService.Stat(p); err == nil {
+			modTime := info.ModTime
+			entry.Exists = true
+			entry.IsDir = info.IsDir
+			entry.Size = info.Size
+			entry.ModTime = &modTime
+		}
+		byPath[p] = len(export.Files)
+		export.Files = append(export.Files, entry)
+	}
+	return export, nil
+}
+
+// writeExportZip writes export.json, the local avatar if any, and optionally
+// every file under the user's shared paths
+func (h *UserHandler) writeExportZip(w io.Writer, u *user.User, export *UserExport, includeFiles bool) error {
+	zw := zip.NewWriter(w)
+
+	jw, err := zw.CreateHeader(&zip.FileHeader{Name: "export.json", Method: zip.Deflate, Modified: export.ExportedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(jw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(u.AvatarURL, "/api/user/avatar/") {
+		filename := filepath.Base(strings.TrimPrefix(u.AvatarURL, "/api/user/avatar/"))
+		if err := addExportFile(zw, filepath.Join(h.avatarPath, filename), "avatar"+filepath.Ext(filename)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if includeFiles {
+		// Overlapping shares (a folder and a file inside it) add each file once
+		written := make(map[string]bool)
+		for _, f := range export.Files {
+			if !f.Exists {
+				continue
+			}
+			err := h.fileService.Walk(f.Path, func(info domain.FileInfo) error {
+				if info.IsDir || written[info.Path] {
+					return nil
+				}
+				written[info.Path] = true
+				return h.addStoredFile(zw, info.Path)
+			})
+			if err != nil && !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrWalkIncomplete) {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+func (h *UserHandler) addStoredFile(zw *zip.Writer, p string) error {
+	src, info, err := h.fileService.OpenFile(p)
+	if err != nil {
+		// Removed since the walk listed it
+		return nil
+	}
+	defer src.Close()
+
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: path.Join("files", p), Method: zip.Deflate, Modified: info.ModTime})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func addExportFile(zw *zip.Writer, diskPath, name string) error {
+	src, err := os.Open(diskPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: stat.ModTime()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -2,13 +2,20 @@ package handler
 
 import (
 	"encoding/json"
-	"io"
+	"errors"
+	"image/png"
+	"log"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"gomanager/internal/application/auth"
+	fileService "gomanager/internal/application/file"
+	"gomanager/internal/domain/share"
 	"gomanager/internal/domain/user"
 
 	"github.com/google/uuid"
@@ -16,20 +23,31 @@ import (
 
 // UserHandler handles user profile operations
 type UserHandler struct {
-	authService auth.Service
-	userRepo    user.Repository
-	avatarPath  string
+	authService  auth.Service
+	userRepo     user.Repository
+	shareRepo    share.Repository
+	fileService  fileService.Service
+	avatarPath   string
+	avatarMaxDim int
+	baseURL      string
+
+	heavy *HeavyOpLimiter
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(authService auth.Service, userRepo user.Repository, storagePath string) *UserHandler {
+func NewUserHandler(authService auth.Service, userRepo user.Repository, shareRepo share.Repository, fileSvc fileService.Service, storagePath string, avatarMaxDim int, baseURL string, heavy *HeavyOpLimiter) *UserHandler {
 	avatarPath := filepath.Join(storagePath, ".avatars")
 	os.MkdirAll(avatarPath, 0755)
 
 	return &UserHandler{
-		authService: authService,
-		userRepo:    userRepo,
-		avatarPath:  avatarPath,
+		authService:  authService,
+		userRepo:     userRepo,
+		shareRepo:    shareRepo,
+		fileService:  fileSvc,
+		avatarPath:   avatarPath,
+		avatarMaxDim: avatarMaxDim,
+		baseURL:      baseURL,
+		heavy:        heavy,
 	}
 }
 
@@ -58,7 +76,7 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	SendSuccess(w, "", u.ToResponse())
+	SendSuccess(w, "", userResponse(r, u))
 }
 
 // UpdateProfile handles PUT /api/user/profile
@@ -110,7 +128,7 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	SendSuccess(w, "Profile updated successfully", u.ToResponse())
+	SendSuccess(w, "Profile updated successfully", userResponse(r, u))
 }
 
 // UpdatePassword handles PUT /api/user/password
@@ -198,43 +216,57 @@ func (h *UserHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 
 	// Validate file type
 	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".gif" && ext != ".webp" {
-		SendError(w, "Invalid file type. Allowed: jpg, jpeg, png, gif, webp", http.StatusBadRequest)
+	if _, ok := avatarFormats[ext]; !ok {
+		SendError(w, "Invalid file type. Allowed: jpg, jpeg, png, gif", http.StatusBadRequest)
 		return
 	}
 
-	// Delete old avatar if exists and is local
-	if u.AvatarURL != "" && strings.HasPrefix(u.AvatarURL, "/api/user/avatar/") {
-		oldPath := filepath.Join(h.avatarPath, filepath.Base(u.AvatarURL))
-		os.Remove(oldPath)
+	img, err := decodeAvatar(file, ext)
+	if err != nil {
+		switch {
+		case errors.Is(err, errAvatarFormatMismatch):
+			SendError(w, "File content does not match its extension", http.StatusBadRequest)
+		case errors.Is(err, errAvatarTooLarge):
+			SendError(w, "Image dimensions are too large", http.StatusBadRequest)
+		default:
+			SendError(w, "Invalid or corrupt image", http.StatusBadRequest)
+		}
+		return
 	}
+	img = fitAvatar(img, h.avatarMaxDim)
 
-	// Generate unique filename
-	filename := uuid.New().String() + ext
+	// Avatars are always stored as PNG
+	filename := uuid.New().String() + ".png"
 	filePath := filepath.Join(h.avatarPath, filename)
 
-	// Save file
 	dst, err := os.Create(filePath)
 	if err != nil {
 		SendError(w, "Failed to save avatar", http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
 
-	if _, err := io.Copy(dst, file); err != nil {
+	encodeErr := png.Encode(dst, img)
+	if closeErr := dst.Close(); encodeErr == nil {
+		encodeErr = closeErr
+	}
+	if encodeErr != nil {
+		os.Remove(filePath)
 		SendError(w, "Failed to save avatar", http.StatusInternalServerError)
 		return
 	}
 
-	// Update user avatar URL
+	// Update user avatar URL; the old file is only removed once the new one is committed
+	oldAvatarURL := u.AvatarURL
 	u.AvatarURL = "/api/user/avatar/" + filename
 	if err := h.userRepo.Update(u); err != nil {
+		os.Remove(filePath)
 		SendError(w, "Failed to update avatar", http.StatusInternalServerError)
 		return
 	}
+	h.removeLocalAvatar(oldAvatarURL)
 
 	SendSuccess(w, "Avatar uploaded successfully", map[string]string{
-		"avatarUrl": u.AvatarURL,
+		"avatarUrl": publicPath(r, u.AvatarURL),
 	})
 }
 
@@ -279,17 +311,139 @@ func (h *UserHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete file if it's a local avatar
-	if u.AvatarURL != "" && strings.HasPrefix(u.AvatarURL, "/api/user/avatar/") {
-		filePath := filepath.Join(h.avatarPath, filepath.Base(u.AvatarURL))
-		os.Remove(filePath)
-	}
-
+	oldAvatarURL := u.AvatarURL
 	u.AvatarURL = ""
 	if err := h.userRepo.Update(u); err != nil {
 		SendError(w, "Failed to delete avatar", http.StatusInternalServerError)
 		return
 	}
+	h.removeLocalAvatar(oldAvatarURL)
 
 	SendSuccess(w, "Avatar deleted successfully", nil)
 }
+
+const (
+	defaultUsersPageSize = 20
+	maxUsersPageSize     = 100
+)
+
+// ListUsers handles GET /api/admin/users?page=&pageSize=&search=&role=
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		SendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, pageSize := 1, defaultUsersPageSize
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		// Bounded so the offset computed from it can't overflow
+		if err != nil || n < 1 || n > math.MaxInt32 {
+			SendError(w, "page must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+	if v := query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			SendError(w, "pageSize must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		pageSize = min(n, maxUsersPageSize)
+	}
+
+	role := query.Get("role")
+	switch user.Role(role) {
+	case "", user.RoleAdmin, user.RoleUser, user.RoleViewer:
+	default:
+		SendError(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	users, total, err := h.userRepo.ListPaged((page-1)*pageSize, pageSize, strings.TrimSpace(query.Get("search")), role)
+	if err != nil {
+		SendError(w, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]user.UserResponse, len(users))
+	for i := range users {
+		responses[i] = userResponse(r, &users[i])
+	}
+
+	SendSuccess(w, "", map[string]interface{}{
+		"users":    responses,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// removeLocalAvatar deletes the file behind a locally served avatar URL
+func (h *UserHandler) removeLocalAvatar(avatarURL string) {
+	if avatarURL != "" && strings.HasPrefix(avatarURL, "/api/user/avatar/") {
+		os.Remove(filepath.Join(h.avatarPath, filepath.Base(avatarURL)))
+	}
+}
+
+const (
+	// avatarSweepInterval is how often orphaned avatar files are removed
+	avatarSweepInterval = time.Hour
+	// avatarSweepGrace skips recent files whose user update may still be in flight
+	avatarSweepGrace = 10 * time.Minute
+)
+
+// StartAvatarSweeper periodically removes avatar files no user references.
+// Racing uploads or a failed update can leave such files behind.
+func (h *UserHandler) StartAvatarSweeper() {
+	go func() {
+		ticker := time.NewTicker(avatarSweepInterval)
+		defer ticker.Stop()
+		for {
+			if removed, err := h.SweepOrphanAvatars(); err != nil {
+				log.Printf("avatar sweep failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("avatar sweep removed %d orphaned file(s)", removed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// SweepOrphanAvatars deletes avatar files older than the grace period that
+// aren't referenced by any user, returning how many were removed
+func (h *UserHandler) SweepOrphanAvatars() (int, error) {
+	entries, err := os.ReadDir(h.avatarPath)
+	if err != nil {
+		return 0, err
+	}
+
+	users, err := h.userRepo.List()
+	if err != nil {
+		return 0, err
+	}
+	referenced := make(map[string]bool, len(users))
+	for _, u := range users {
+		if strings.HasPrefix(u.AvatarURL, "/api/user/avatar/") {
+			referenced[filepath.Base(u.AvatarURL)] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-avatarSweepGrace)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || referenced[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.avatarPath, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
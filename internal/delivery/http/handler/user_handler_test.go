@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/repository"
+)
+
+// avatarFiles lists the stored avatar files
+func avatarFiles(t *testing.T, h *UserHandler) []string {
+	t.Helper()
+	entries, err := os.ReadDir(h.avatarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// uploadAvatar posts data as u's new avatar, named name
+func uploadAvatar(h *UserHandler, u *user.User, name string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("avatar", name)
+	fw.Write(data)
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/api/user/avatar", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.UploadAvatar(w, withUser(r, u))
+	return w
+}
+
+func TestUploadAvatarReplacesOldOnlyAfterUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		failUpdate bool
+		status     int
+	}{
+		{"update succeeds", false, http.StatusOK},
+		{"update fails", true, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			u := newTestUser(t, db, "u1", user.RoleUser)
+			u.AvatarURL = "/api/user/avatar/old.png"
+			users := repository.NewUserRepository(db)
+			if err := users.Update(u); err != nil {
+				t.Fatal(err)
+			}
+			var repo user.Repository = users
+			if tt.failUpdate {
+				repo = failingUpdates{users}
+			}
+			h := NewUserHandler(nil, repo, nil, nil, t.TempDir(), 64, "", NewHeavyOpLimiter(0, 0))
+			if err := os.WriteFile(filepath.Join(h.avatarPath, "old.png"), []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			var img bytes.Buffer
+			png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+			w := uploadAvatar(h, u, "me.png", img.Bytes())
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			files := avatarFiles(t, h)
+			stored, err := users.GetByID("u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.failUpdate {
+				// Nothing committed, so the old avatar stays and the new file goes
+				if !slices.Equal(files, []string{"old.png"}) || stored.AvatarURL != "/api/user/avatar/old.png" {
+					t.Fatalf("files %v, avatar %q", files, stored.AvatarURL)
+				}
+				return
+			}
+			var resp struct{ Data struct{ AvatarURL string } }
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if len(files) != 1 || stored.AvatarURL != "/api/user/avatar/"+files[0] || resp.Data.AvatarURL != stored.AvatarURL {
+				t.Fatalf("files %v, avatar %q, response %q", files, stored.AvatarURL, resp.Data.AvatarURL)
+			}
+		})
+	}
+}
+
+func TestSweepOrphanAvatars(t *testing.T) {
+	db := newTestDB(t)
+	u := newTestUser(t, db, "u1", user.RoleUser)
+	u.AvatarURL = "/api/user/avatar/current.png"
+	users := repository.NewUserRepository(db)
+	if err := users.Update(u); err != nil {
+		t.Fatal(err)
+	}
+	h := NewUserHandler(nil, users, nil, nil, t.TempDir(), 64, "", NewHeavyOpLimiter(0, 0))
+
+	old := time.Now().Add(-2 * avatarSweepGrace)
+	for name, modTime := range map[string]time.Time{
+		"current.png": old,
+		"orphan.png":  old,
+		"fresh.png":   time.Now(), // An upload whose user update may still be running
+	} {
+		p := filepath.Join(h.avatarPath, name)
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := h.SweepOrphanAvatars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := avatarFiles(t, h); removed != 1 || !slices.Equal(files, []string{"current.png", "fresh.png"}) {
+		t.Fatalf("removed %d, left %v", removed, files)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "admin", user.RoleAdmin)
+	newTestUser(t, db, "u1", user.RoleUser)
+	newTestUser(t, db, "v1", user.RoleViewer)
+	h := NewUserHandler(nil, repository.NewUserRepository(db), nil, nil, t.TempDir(), 64, "", NewHeavyOpLimiter(0, 0))
+
+	tests := []struct {
+		query     string
+		status    int
+		wantTotal int
+	}{
+		{"", http.StatusOK, 3},
+		{"?role=viewer", http.StatusOK, 1},
+		{"?search=u1", http.StatusOK, 1},
+		{"?page=2&pageSize=2", http.StatusOK, 3},
+		{"?role=owner", http.StatusBadRequest, 0},
+		{"?page=0", http.StatusBadRequest, 0},
+		{"?pageSize=-1", http.StatusBadRequest, 0},
+		{"?page=4611686018427387904&pageSize=100", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ListUsers(w, httptest.NewRequest(http.MethodGet, "/api/admin/users"+tt.query, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp struct{ Data struct{ Total int } }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Data.Total != tt.wantTotal {
+				t.Errorf("total %d, want %d", resp.Data.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestUserExport(t *testing.T) {
+	db := newTestDB(t)
+	owner := newTestUser(t, db, "owner", user.RoleUser)
+	owner.Password, owner.GoogleToken = "$2a$10$secret-password-hash", "secret-refresh-token"
+	if err := repository.NewUserRepository(db).Update(owner); err != nil {
+		t.Fatal(err)
+	}
+	newTestUser(t, db, "other", user.RoleUser)
+	svc, _ := newTestFileService(t, db, map[string]string{
+		"docs/a.txt":     "a",
+		"docs/sub/b.txt": "bb",
+		"secret.txt":     "s",
+	})
+	shares := repository.NewShareRepository(db)
+	var ownerShares []string
+	for _, s := range []*share.Share{newShareOf("owner", "docs"), newShareOf("owner", "docs/a.txt"), newShareOf("owner", "gone.txt"), newShareOf("other", "secret.txt")} {
+		if err := shares.Create(s); err != nil {
+			t.Fatal(err)
+		}
+		if s.CreatedBy == "owner" {
+			ownerShares = append(ownerShares, s.ID)
+		}
+	}
+	if err := shares.SoftDelete(ownerShares[2]); err != nil {
+		t.Fatal(err)
+	}
+	h := NewUserHandler(nil, repository.NewUserRepository(db), shares, svc, t.TempDir(), 64, "", NewHeavyOpLimiter(0, 0))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.Export(w, withUser(httptest.NewRequest(http.MethodGet, "/api/user/export"+query, nil), owner))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", query, w.Code, w.Body)
+		}
+		if body := w.Body.String(); strings.Contains(body, "secret-") {
+			t.Fatalf("%s: export leaks a secret", query)
+		}
+		return w
+	}
+
+	var resp struct{ Data UserExport }
+	if err := json.Unmarshal(get("").Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	export := resp.Data
+	if export.Profile.ID != "owner" || export.Profile.Email != "owner@example.com" || !export.GoogleConnected {
+		t.Errorf("profile %+v, google connected %v", export.Profile, export.GoogleConnected)
+	}
+	var gotShares []string
+	for _, s := range export.Shares {
+		gotShares = append(gotShares, s.ID)
+	}
+	slices.Sort(gotShares)
+	slices.Sort(ownerShares)
+	if !slices.Equal(gotShares, ownerShares) {
+		t.Errorf("shares %v, want the owner's %v", gotShares, ownerShares)
+	}
+	manifest := make(map[string]bool)
+	for _, f := range export.Files {
+		manifest[f.Path] = f.Exists
+	}
+	if want := map[string]bool{"docs": true, "docs/a.txt": true, "gone.txt": false}; !maps.Equal(manifest, want) {
+		t.Errorf("manifest %v, want %v", manifest, want)
+	}
+
+	w := get("?format=zip&includeFiles=true")
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"export.json", "files/docs/a.txt", "files/docs/sub/b.txt"}; !slices.Equal(names, want) {
+		t.Errorf("archive holds %v, want %v", names, want)
+	}
+}
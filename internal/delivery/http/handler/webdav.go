@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/webdav"
+)
+
+// davPrefix is where the WebDAV tree is mounted
+const davPrefix = "/dav"
+
+// davReadMethods are the WebDAV methods that never change storage
+var davReadMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+}
+
+// WebDAV handles /dav/ so the storage can be mounted in an OS file manager.
+// Roles apply as in the JSON API: viewers get a read-only mount, and PUTs are
+// held to the role's upload limit. Locks are kept in memory, which is enough
+// for clients that lock before writing but doesn't span processes.
+func (h *FileHandler) WebDAV(w http.ResponseWriter, r *http.Request) {
+	u := GetUserFromContext(r.Context())
+	if u == nil {
+		SendError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	readOnly := !u.Role.CanUpload()
+	if readOnly && !davReadMethods[r.Method] {
+		SendError(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+	maxSize := h.uploadPolicy.MaxFileSize(u.Role)
+	if r.Method == http.MethodPut && maxSize > 0 && r.ContentLength > maxSize {
+		SendError(w, fmt.Sprintf("Upload exceeds the %d byte limit", maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Files are stored under exactly the name sent or not at all; a
+	// sanitized name would answer 201 for a file that then can't be found
+	if name, ok := davNewName(r); ok && h.service.SanitizeName(name) != name {
+		SendError(w, fmt.Sprintf("File name %q is not allowed", name), http.StatusBadRequest)
+		return
+	}
+
+	// Hrefs in responses and Destination headers carry the mount prefix, so
+	// the handler sees the path as the client sent it
+	if base := BasePath(r); base != "" {
+		r = r.Clone(r.Context())
+		r.URL.Path = base + r.URL.Path
+		r.URL.RawPath = ""
+	}
+
+	dav := &webdav.Handler{
+		Prefix:     BasePath(r) + davPrefix,
+		FileSystem: &davFS{service: h.service, readOnly: readOnly, maxFileSize: maxSize},
+		LockSystem: h.davLocks,
+	}
+	dav.ServeHTTP(w, r)
+}
+
+// davNewName returns the name of the file a PUT or COPY creates
+func davNewName(r *http.Request) (string, bool) {
+	target := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+	case "COPY":
+		u, err := url.Parse(r.Header.Get("Destination"))
+		if err != nil || u.Path == "" {
+			return "", false
+		}
+		target = u.Path
+	default:
+		return "", false
+	}
+	name := path.Base(strings.TrimRight(target, "/"))
+	if name == "." || name == "/" {
+		return "", false
+	}
+	return name, true
+}
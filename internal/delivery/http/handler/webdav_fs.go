@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	fileService "gomanager/internal/application/file"
+	domain "gomanager/internal/domain/file"
+)
+
+// davFS exposes the file service as a WebDAV file system. Every operation
+// goes through the service, so hidden paths, name sanitizing, share path
+// updates and change events apply exactly as they do for the JSON API.
+type davFS struct {
+	service     fileService.Service
+	readOnly    bool
+	maxFileSize int64 // 0 = no limit
+}
+
+// davPath turns a WebDAV name ("/docs/a.txt") into a storage path
+func davPath(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// davError maps service errors to the os errors the webdav package turns
+// into status codes
+func davError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return os.ErrNotExist
+	case errors.Is(err, domain.ErrExists):
+		return os.ErrExist
+	case errors.Is(err, domain.ErrInvalidPath), errors.Is(err, domain.ErrMoveIntoSelf):
+		return os.ErrInvalid
+	}
+	return err
+}
+
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if d.readOnly {
+		return os.ErrPermission
+	}
+	p := davPath(name)
+	if p == "" {
+		return os.ErrExist
+	}
+	if exists, _, err := d.service.Exists(p); err != nil {
+		return err
+	} else if exists {
+		return os.ErrExist
+	}
+	// MKCOL doesn't create missing parents, unlike CreateFolder
+	if parent, err := d.service.Stat(davPath(path.Dir("/" + p))); err != nil || !parent.IsDir {
+		return os.ErrNotExist
+	}
+	return davError(d.service.CreateFolder(p))
+}
+
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p := davPath(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return d.create(p)
+	}
+
+	info, err := d.service.Stat(p)
+	if err != nil {
+		return nil, davError(err)
+	}
+	if info.IsDir {
+		return &davDir{service: d.service, info: *info}, nil
+	}
+	f, _, err := d.service.OpenFile(p)
+	if err != nil {
+		return nil, davError(err)
+	}
+	return &davFile{ReadSeekCloser: f, info: *info}, nil
+}
+
+// create starts streaming a new version of the file at p into storage; the
+// upload completes when the returned file is closed
+func (d *davFS) create(p string) (webdav.File, error) {
+	if d.readOnly {
+		return nil, os.ErrPermission
+	}
+	if p == "" || d.service.SanitizeName(path.Base(p)) != path.Base(p) {
+		return nil, os.ErrInvalid
+	}
+	dir := davPath(path.Dir("/" + p))
+	if parent, err := d.service.Stat(dir); err != nil || !parent.IsDir {
+		return nil, os.ErrNotExist
+	}
+
+	pr, pw := io.Pipe()
+	w := &davWriter{
+		pw:    pw,
+		done:  make(chan error, 1),
+		limit: d.maxFileSize,
+		info:  domain.FileInfo{Name: path.Base(p), Path: p, ModTime: time.Now()},
+	}
+	go func() {
+		_, err := d.service.UploadStream(dir, path.Base(p), pr, domain.ConflictOverwrite)
+		// Unblock writes if the upload stopped reading early
+		pr.CloseWithError(err)
+		w.done <- davError(err)
+	}()
+	return w, nil
+}
+
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	if d.readOnly {
+		return os.ErrPermission
+	}
+	p := davPath(name)
+	if p == "" {
+		return os.ErrPermission
+	}
+	return davError(d.service.Delete(p))
+}
+
+// Rename only ever sees a free destination: the webdav package removes an
+// existing one first (Overwrite: T) or refuses the request
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	if d.readOnly {
+		return os.ErrPermission
+	}
+	_, err := d.service.Move(davPath(oldName), davPath(newName))
+	return davError(err)
+}
+
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := d.service.Stat(davPath(name))
+	if err != nil {
+		return nil, davError(err)
+	}
+	return davInfo{*info}, nil
+}
+
+// davInfo adapts a FileInfo to os.FileInfo
+type davInfo struct {
+	info domain.FileInfo
+}
+
+func (i davInfo) Name() string {
+	if i.info.Path == "" {
+		return "/"
+	}
+	return i.info.Name
+}
+
+func (i davInfo) Size() int64        { return i.info.Size }
+func (i davInfo) ModTime() time.Time { return i.info.ModTime }
+func (i davInfo) IsDir() bool        { return i.info.IsDir }
+func (i davInfo) Sys() any           { return nil }
+
+func (i davInfo) Mode() os.FileMode {
+	if i.info.IsDir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType implements webdav.ContentTyper so PROPFIND doesn't open every
+// file to sniff its type
+func (i davInfo) ContentType(ctx context.Context) (string, error) {
+	if i.info.IsDir {
+		return "", webdav.ErrNotImplemented
+	}
+	return domain.ContentType(i.info.Name), nil
+}
+
+// davFile is a file opened for reading
+type davFile struct {
+	io.ReadSeekCloser
+	info domain.FileInfo
+}
+
+func (f *davFile) Readdir(count int) ([]fs.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *davFile) Stat() (fs.FileInfo, error)               { return davInfo{f.info}, nil }
+func (f *davFile) Write(p []byte) (int, error)              { return 0, os.ErrPermission }
+
+// davDir is an open folder; its listing is read on the first Readdir
+type davDir struct {
+	service fileService.Service
+	info    domain.FileInfo
+	entries []fs.FileInfo
+	listed  bool
+}
+
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.listed {
+		files, err := d.service.ListFiles(d.info.Path)
+		if err != nil {
+			return nil, davError(err)
+		}
+		for _, f := range files {
+			f.Path = path.Join(d.info.Path, f.Name)
+			d.entries = append(d.entries, davInfo{f})
+		}
+		d.listed = true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *davDir) Stat() (fs.FileInfo, error)                   { return davInfo{d.info}, nil }
+func (d *davDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, nil }
+func (d *davDir) Write(p []byte) (int, error)                  { return 0, os.ErrInvalid }
+func (d *davDir) Close() error                                 { return nil }
+
+// davWriter feeds a PUT body to the upload running in the background
+type davWriter struct {
+	pw    *io.PipeWriter
+	done  chan error
+	limit int64
+	info  domain.FileInfo
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func (w *davWriter) Write(p []byte) (int, error) {
+	if w.limit > 0 && w.info.Size+int64(len(p)) > w.limit {
+		err := fmt.Errorf("file exceeds the %d byte limit", w.limit)
+		w.pw.CloseWithError(err)
+		return 0, err
+	}
+	n, err := w.pw.Write(p)
+	w.info.Size += int64(n)
+	return n, err
+}
+
+// Close finishes the upload and reports whether it was stored
+func (w *davWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.pw.Close()
+		w.closeErr = <-w.done
+	})
+	return w.closeErr
+}
+
+func (w *davWriter) Stat() (fs.FileInfo, error)                   { return davInfo{w.info}, nil }
+func (w *davWriter) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (w *davWriter) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (w *davWriter) Readdir(count int) ([]fs.FileInfo, error)     { return nil, os.ErrInvalid }
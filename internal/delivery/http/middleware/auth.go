@@ -14,11 +14,15 @@ import (
 func Auth(authService auth.Service) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			token := extractToken(r)
+			token, fromCookie := handler.ExtractToken(r)
 			if token == "" {
 				handler.SendError(w, "Authorization required", http.StatusUnauthorized)
 				return
 			}
+			if fromCookie && !handler.ValidCSRF(r) {
+				handler.SendError(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
 
 			u, err := authService.ValidateToken(token)
 			if err != nil {
@@ -33,6 +37,59 @@ func Auth(authService auth.Service) func(http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// DAVAuth authenticates WebDAV clients. OS file managers can only send Basic
+// credentials, so the password carries a login token and the username is
+// ignored; Bearer tokens work too. Cookies aren't accepted since DAV methods
+// can't carry a CSRF token. Failures ask for credentials so clients prompt.
+func DAVAuth(authService auth.Service) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if _, password, ok := r.BasicAuth(); ok {
+				token = password
+			}
+			if token == "" || strings.HasPrefix(token, "Basic ") {
+				w.Header().Set("WWW-Authenticate", `Basic realm="GoManager", charset="UTF-8"`)
+				handler.SendError(w, "Authorization required", http.StatusUnauthorized)
+				return
+			}
+
+			u, err := authService.ValidateToken(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="GoManager", charset="UTF-8"`)
+				handler.SendError(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), handler.UserContextKey, u)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// LoadUser replaces the context user with its stored record. It must follow
+// Auth on routes that read or update fields a JWT doesn't carry.
+func LoadUser(authService auth.Service) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			u := GetUserFromContext(r.Context())
+			if u == nil {
+				handler.SendError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			full, err := authService.LoadUser(u)
+			if err != nil {
+				handler.SendError(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), handler.UserContextKey, full)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
 // RequireRole middleware checks if user has required role
 func RequireRole(roles ...user.Role) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
@@ -59,8 +116,8 @@ func RequireRole(roles ...user.Role) func(http.HandlerFunc) http.HandlerFunc {
 func OptionalAuth(authService auth.Service) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			token := extractToken(r)
-			if token != "" {
+			token, fromCookie := handler.ExtractToken(r)
+			if token != "" && (!fromCookie || handler.ValidCSRF(r)) {
 				if u, err := authService.ValidateToken(token); err == nil {
 					ctx := context.WithValue(r.Context(), handler.UserContextKey, u)
 					r = r.WithContext(ctx)
@@ -75,18 +132,3 @@ func OptionalAuth(authService auth.Service) func(http.HandlerFunc) http.HandlerF
 func GetUserFromContext(ctx context.Context) *user.User {
 	return handler.GetUserFromContext(ctx)
 }
-
-func extractToken(r *http.Request) string {
-	// Check Authorization header
-	authHeader := r.Header.Get("Authorization")
-	if strings.HasPrefix(authHeader, "Bearer ") {
-		return strings.TrimPrefix(authHeader, "Bearer ")
-	}
-
-	// Check query parameter (for downloads)
-	if token := r.URL.Query().Get("token"); token != "" {
-		return token
-	}
-
-	return ""
-}
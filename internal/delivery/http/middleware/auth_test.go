@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gomanager/internal/application/auth"
+	"gomanager/internal/delivery/http/handler"
+	"gomanager/internal/domain/user"
+)
+
+// tokenAuth is an auth service accepting only the token "good"
+type tokenAuth struct {
+	auth.Service
+}
+
+func (tokenAuth) ValidateToken(token string) (*user.User, error) {
+	if token != "good" {
+		return nil, errors.New("invalid token")
+	}
+	return &user.User{ID: "u1", Role: user.RoleUser}, nil
+}
+
+func TestAuthCSRF(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		bearer string
+		cookie string
+		csrf   string // CSRF cookie
+		header string
+		status int
+	}{
+		{"cookie read needs no CSRF token", http.MethodGet, "", "good", "", "", http.StatusOK},
+		{"cookie write with CSRF token", http.MethodPost, "", "good", "c1", "c1", http.StatusOK},
+		{"cookie write without CSRF header", http.MethodPost, "", "good", "c1", "", http.StatusForbidden},
+		{"cookie write with wrong CSRF header", http.MethodDelete, "", "good", "c1", "c2", http.StatusForbidden},
+		{"cookie write without CSRF cookie", http.MethodPut, "", "good", "", "c1", http.StatusForbidden},
+		{"bearer write needs no CSRF token", http.MethodPost, "good", "", "", "", http.StatusOK},
+		{"bearer wins over a cookie", http.MethodPost, "good", "bad", "", "", http.StatusOK},
+		{"invalid cookie", http.MethodGet, "", "bad", "", "", http.StatusUnauthorized},
+		{"no token", http.MethodPost, "", "", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/delete", nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: handler.SessionCookieName, Value: tt.cookie})
+			}
+			if tt.csrf != "" {
+				r.AddCookie(&http.Cookie{Name: handler.CSRFCookieName, Value: tt.csrf})
+			}
+			if tt.header != "" {
+				r.Header.Set(handler.CSRFHeaderName, tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			Auth(tokenAuth{})(func(w http.ResponseWriter, r *http.Request) {
+				if GetUserFromContext(r.Context()) == nil {
+					t.Error("no user in context")
+				}
+			})(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestOptionalAuthCSRF(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		csrf     bool
+		wantUser bool
+	}{
+		{"cookie read", http.MethodGet, false, true},
+		{"cookie write with CSRF token", http.MethodPost, true, true},
+		// Treated as anonymous rather than as the cookie's owner
+		{"cookie write without CSRF token", http.MethodPost, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/share/abc", nil)
+			r.AddCookie(&http.Cookie{Name: handler.SessionCookieName, Value: "good"})
+			if tt.csrf {
+				r.AddCookie(&http.Cookie{Name: handler.CSRFCookieName, Value: "c1"})
+				r.Header.Set(handler.CSRFHeaderName, "c1")
+			}
+			var gotUser bool
+			OptionalAuth(tokenAuth{})(func(w http.ResponseWriter, r *http.Request) {
+				gotUser = GetUserFromContext(r.Context()) != nil
+			})(httptest.NewRecorder(), r)
+			if gotUser != tt.wantUser {
+				t.Errorf("user in context = %v, want %v", gotUser, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+// StripBasePath serves the API under base (e.g. "/gomanager") by removing
+// the prefix before routing, so routes keep their root-relative patterns.
+// Requests outside the prefix get a 404. An empty base passes requests
+// through unchanged.
+func StripBasePath(base string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if base == "" {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			p := strings.TrimPrefix(r.URL.Path, base)
+			if len(p) == len(r.URL.Path) || (p != "" && p[0] != '/') {
+				handler.SendError(w, "Not found", http.StatusNotFound)
+				return
+			}
+			if p == "" {
+				p = "/"
+			}
+
+			r2 := r.Clone(context.WithValue(r.Context(), handler.BasePathContextKey, base))
+			r2.URL.Path = p
+			r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, base)
+			next(w, r2)
+		}
+	}
+}
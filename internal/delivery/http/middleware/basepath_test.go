@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+func TestStripBasePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		path     string
+		status   int
+		wantPath string // Path seen by the route
+		wantBase string // Base path the handler reports
+	}{
+		{"root mount", "", "/api/files", http.StatusOK, "/api/files", ""},
+		{"route under base", "/gomanager", "/gomanager/api/files", http.StatusOK, "/api/files", "/gomanager"},
+		{"base itself", "/gomanager", "/gomanager", http.StatusOK, "/", "/gomanager"},
+		{"base with slash", "/gomanager", "/gomanager/", http.StatusOK, "/", "/gomanager"},
+		{"outside base", "/gomanager", "/api/files", http.StatusNotFound, "", ""},
+		{"prefix of a longer segment", "/gomanager", "/gomanagerx/api/files", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotBase string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotBase = r.URL.Path, handler.BasePath(r)
+			})
+
+			w := httptest.NewRecorder()
+			StripBasePath(tt.base)(mux.ServeHTTP)(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if gotPath != tt.wantPath || gotBase != tt.wantBase {
+				t.Fatalf("routed %q with base %q, want %q with base %q", gotPath, gotBase, tt.wantPath, tt.wantBase)
+			}
+		})
+	}
+}
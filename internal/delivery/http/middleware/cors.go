@@ -8,6 +8,20 @@ import (
 // CORSConfig holds CORS configuration
 type CORSConfig struct {
 	AllowedOrigins []string
+
+	// AllowCredentials lets browsers send cookies and auth headers. Browsers
+	// reject credentials with a wildcard origin, so the request origin is
+	// echoed instead of "*" when this is set.
+	AllowCredentials bool
+
+	// AnyOrigin admits origins outside AllowedOrigins with "*", so without
+	// credentials. It suits public read routes like share links.
+	AnyOrigin bool
+
+	// Strict leaves origins outside AllowedOrigins without CORS headers so
+	// browsers block them. Otherwise they are echoed, which is convenient
+	// in development.
+	Strict bool
 }
 
 // CORS adds CORS headers to responses
@@ -22,21 +36,35 @@ func CORSWithConfig(config CORSConfig, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
 
-		// Set allowed origin
+		// Resolve the allowed origin
+		allowOrigin := ""
 		if isOriginAllowed(origin, config.AllowedOrigins) {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
+			allowOrigin = origin
 		} else if len(config.AllowedOrigins) == 1 && config.AllowedOrigins[0] == "*" {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-		} else if origin != "" {
+			allowOrigin = "*"
+		} else if origin != "" && config.AnyOrigin {
+			allowOrigin = "*"
+		} else if origin != "" && !config.Strict {
 			// If we have a specific origin but it's not in the allowed list,
 			// still allow it for development purposes
-			w.Header().Set("Access-Control-Allow-Origin", origin)
+			allowOrigin = origin
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		// The answer depends on the origin unless everyone gets "*"
+		if origin != "" && (allowOrigin != "*" || config.AnyOrigin) {
+			w.Header().Add("Vary", "Origin")
+		}
+		if allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			// Never pair credentials with the "*" wildcard
+			if config.AllowCredentials && allowOrigin != "*" {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
 
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata, X-Response-Format, Idempotency-Key, X-Checksum")
+		w.Header().Set("Access-Control-Expose-Headers", "Location, Tus-Resumable, Tus-Version, Upload-Offset, Upload-Length, Upload-Expires, X-Upload-Path, X-Request-ID, Idempotent-Replayed")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
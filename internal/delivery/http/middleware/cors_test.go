@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSStrictOrigins(t *testing.T) {
+	tests := []struct {
+		name   string
+		strict bool
+		origin string
+		want   string
+	}{
+		{"listed origin", true, "https://app.example.com", "https://app.example.com"},
+		{"unlisted origin refused", true, "https://evil.example", ""},
+		{"unlisted origin echoed when loose", false, "https://evil.example", "https://evil.example"},
+		{"no origin", true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := CORSWithConfig(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, Strict: tt.strict}, func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodGet, "/api/files", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Fatalf("allowed origin %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     CORSConfig
+		origin     string
+		wantOrigin string
+		wantCreds  string
+	}{
+		{"listed origin", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, "https://app.example.com", "https://app.example.com", "true"},
+		{"credentials disabled", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}, "https://app.example.com", "https://app.example.com", ""},
+		{"any origin never gets credentials", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, AnyOrigin: true}, "https://blog.example", "*", ""},
+		{"any origin keeps them for listed origins", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, AnyOrigin: true}, "https://app.example.com", "https://app.example.com", "true"},
+		{"wildcard echoes the origin", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "https://blog.example", "https://blog.example", "true"},
+		{"wildcard without origin", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, "", "*", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := CORSWithConfig(tt.config, func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodGet, "/api/s/abc123", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			origin := w.Header().Get("Access-Control-Allow-Origin")
+			credentials := w.Header().Get("Access-Control-Allow-Credentials")
+			if origin != tt.wantOrigin || credentials != tt.wantCreds {
+				t.Fatalf("origin %q, credentials %q; want %q, %q", origin, credentials, tt.wantOrigin, tt.wantCreds)
+			}
+			if origin == "*" && credentials != "" {
+				t.Fatal("credentials sent with a wildcard origin")
+			}
+		})
+	}
+}
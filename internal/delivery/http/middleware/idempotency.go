@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gomanager/internal/delivery/http/handler"
+	"gomanager/internal/domain/idempotency"
+)
+
+// Headers for idempotent retries
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentResponseSize = 1 << 20 // Larger responses aren't stored, so retries run again
+	maxUnreadBodyHashed       = 1 << 20 // Requests leaving more unread aren't stored
+	idempotencySweepInterval  = time.Hour
+)
+
+// Idempotent makes POSTs carrying an Idempotency-Key safe to retry: the
+// first response for a user's key is stored for ttl and replayed for
+// repeats instead of running the handler again. A repeat must send the same
+// body. Server errors aren't stored so a retry can still succeed. It must
+// follow Auth, since keys are scoped per user. A nil repo disables it.
+func Idempotent(repo idempotency.Repository, ttl time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	var (
+		mu       sync.Mutex
+		inFlight = make(map[string]bool)
+	)
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		if repo == nil || ttl <= 0 {
+			return next
+		}
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			u := GetUserFromContext(r.Context())
+			if r.Method != http.MethodPost || key == "" || u == nil {
+				next(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				handler.SendError(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+			contentType := r.Header.Get("Content-Type")
+
+			stored, err := repo.Get(u.ID, key, time.Now().Add(-ttl))
+			switch {
+			case err == nil:
+				hash, err := requestFingerprint(contentType, r.Body)
+				if err != nil {
+					handler.SendError(w, "Failed to read request body", http.StatusBadRequest)
+					return
+				}
+				if !stored.Matches(r.Method, r.URL.Path, hash) {
+					handler.SendError(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+					return
+				}
+				w.Header().Set("Content-Type", stored.ContentType)
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				w.WriteHeader(stored.StatusCode)
+				w.Write(stored.Body)
+				return
+			case !errors.Is(err, idempotency.ErrNotFound):
+				handler.SendError(w, "Failed to check Idempotency-Key", http.StatusInternalServerError)
+				return
+			}
+
+			// A retry arriving while the first attempt still runs can't be
+			// answered yet, and running it too would defeat the key
+			flightKey := u.ID + "\x00" + key
+			mu.Lock()
+			if inFlight[flightKey] {
+				mu.Unlock()
+				handler.SendError(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+				return
+			}
+			inFlight[flightKey] = true
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				delete(inFlight, flightKey)
+				mu.Unlock()
+			}()
+
+			// The body is fingerprinted as the handler reads it
+			body := r.Body
+			pr, pw := io.Pipe()
+			fingerprint := make(chan string, 1)
+			go func() {
+				hash, err := requestFingerprint(contentType, pr)
+				io.Copy(io.Discard, pr)
+				if err != nil {
+					hash = ""
+				}
+				fingerprint <- hash
+			}()
+			r.Body = readCloser{io.TeeReader(body, pw), body}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+
+			// Whatever the handler left unread is part of the request too
+			n, _ := io.Copy(pw, io.LimitReader(body, maxUnreadBodyHashed+1))
+			pw.Close()
+			hash := <-fingerprint
+			if rec.status >= 500 || rec.overflow || n > maxUnreadBodyHashed || hash == "" {
+				return
+			}
+
+			now := time.Now()
+			if err := repo.Save(&idempotency.Response{
+				UserID:      u.ID,
+				Key:         key,
+				Method:      r.Method,
+				Path:        r.URL.Path,
+				RequestHash: hash,
+				StatusCode:  rec.status,
+				ContentType: w.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+				CreatedAt:   now,
+			}, now.Add(-ttl)); err != nil {
+				log.Printf("failed to store idempotent response: %v", err)
+			}
+		}
+	}
+}
+
+// StartIdempotencySweeper removes stored responses once they are older than
+// ttl, checking every idempotencySweepInterval. A nil repo disables it.
+func StartIdempotencySweeper(repo idempotency.Repository, ttl time.Duration) {
+	if repo == nil || ttl <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(idempotencySweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := repo.DeleteBefore(time.Now().Add(-ttl)); err != nil {
+				log.Printf("idempotency key sweep failed: %v", err)
+			}
+		}
+	}()
+}
+
+// requestFingerprint hashes the body a retry must repeat to be replayed.
+// Multipart bodies are hashed part by part, since clients pick a new
+// boundary for each attempt.
+func requestFingerprint(contentType string, body io.Reader) (string, error) {
+	h := sha256.New()
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		fmt.Fprintf(h, "%q\n", mediaType)
+		if _, err := io.Copy(h, body); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		content := sha256.New()
+		if _, err := io.Copy(content, part); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%q %q %q %x\n", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), content.Sum(nil))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readCloser reads from one source and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseRecorder passes a response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxIdempotentResponseSize {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/delivery/http/handler"
+	"gomanager/internal/domain/idempotency"
+	"gomanager/internal/domain/user"
+)
+
+// memIdempotency keeps responses in a map
+type memIdempotency map[string]*idempotency.Response
+
+func (m memIdempotency) Get(userID, key string, since time.Time) (*idempotency.Response, error) {
+	resp, ok := m[userID+"/"+key]
+	if !ok || resp.CreatedAt.Before(since) {
+		return nil, idempotency.ErrNotFound
+	}
+	return resp, nil
+}
+
+func (m memIdempotency) Save(resp *idempotency.Response, expiredBefore time.Time) error {
+	if old, ok := m[resp.UserID+"/"+resp.Key]; !ok || old.CreatedAt.Before(expiredBefore) {
+		m[resp.UserID+"/"+resp.Key] = resp
+	}
+	return nil
+}
+
+func (m memIdempotency) DeleteBefore(cutoff time.Time) error { return nil }
+
+// multipartBody encodes a file upload with a boundary of its own
+func multipartBody(t *testing.T, content string) (string, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("files", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(content))
+	mw.Close()
+	return mw.FormDataContentType(), &buf
+}
+
+func TestIdempotent(t *testing.T) {
+	runs := 0
+	h := Idempotent(memIdempotency{}, time.Hour)(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			r.ParseMultipartForm(1 << 20)
+		}
+		runs++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"run":%d}`, runs)
+	})
+
+	send := func(userID, key, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, body)
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set(IdempotencyKeyHeader, key)
+		r = r.WithContext(context.WithValue(r.Context(), handler.UserContextKey, &user.User{ID: userID}))
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+	json := func(s string) *bytes.Buffer { return bytes.NewBufferString(s) }
+
+	steps := []struct {
+		name        string
+		userID, key string
+		path        string
+		contentType string
+		body        *bytes.Buffer
+		status      int
+		response    string // Expected response body, if checked
+		replayed    bool
+	}{
+		{"first share", "u1", "k1", "/api/shares", "application/json", json(`{"path":"a"}`), http.StatusCreated, `{"run":1}`, false},
+		{"retried share", "u1", "k1", "/api/shares", "application/json", json(`{"path":"a"}`), http.StatusCreated, `{"run":1}`, true},
+		{"other body", "u1", "k1", "/api/shares", "application/json", json(`{"path":"b"}`), http.StatusUnprocessableEntity, "", false},
+		{"other endpoint", "u1", "k1", "/api/upload", "application/json", json(`{"path":"a"}`), http.StatusUnprocessableEntity, "", false},
+		{"other user", "u2", "k1", "/api/shares", "application/json", json(`{"path":"a"}`), http.StatusCreated, `{"run":2}`, false},
+		{"first upload", "u1", "k2", "/api/upload", "", nil, http.StatusCreated, `{"run":3}`, false},
+		{"upload retried with a new boundary", "u1", "k2", "/api/upload", "", nil, http.StatusCreated, `{"run":3}`, true},
+	}
+	for _, s := range steps {
+		if s.body == nil {
+			s.contentType, s.body = multipartBody(t, "file content")
+		}
+		w := send(s.userID, s.key, s.path, s.contentType, s.body)
+		if w.Code != s.status {
+			t.Fatalf("%s: status %d, want %d: %s", s.name, w.Code, s.status, w.Body)
+		}
+		if s.response != "" && w.Body.String() != s.response {
+			t.Fatalf("%s: body %s, want %s", s.name, w.Body, s.response)
+		}
+		if replayed := w.Header().Get(IdempotentReplayedHeader) == "true"; replayed != s.replayed {
+			t.Fatalf("%s: replayed = %v, want %v", s.name, replayed, s.replayed)
+		}
+	}
+
+	// The upload's content matters, not just its part names
+	contentType, body := multipartBody(t, "other content")
+	if w := send("u1", "k2", "/api/upload", contentType, body); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("changed upload: status %d, want 422", w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestrictPrefix(t *testing.T) {
+	trusted, err := ParseNetworks([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed, err := ParseNetworks([]string{"192.168.0.0/16", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		allowed   bool // Whether the allowlist is configured
+		path      string
+		peer      string
+		forwarded string
+		status    int
+	}{
+		{"allowed IP", true, "/api/admin/users", "192.168.1.20", "", http.StatusOK},
+		{"allowed IPv6", true, "/api/admin/users", "[2001:db8::1]", "", http.StatusOK},
+		{"denied IP", true, "/api/admin/users", "203.0.113.7", "", http.StatusForbidden},
+		{"allowed client behind trusted proxy", true, "/api/admin/users", "10.0.0.1", "192.168.1.20", http.StatusOK},
+		{"denied client behind trusted proxy", true, "/api/admin/users", "10.0.0.1", "203.0.113.7", http.StatusForbidden},
+		{"spoofed header from untrusted peer", true, "/api/admin/users", "203.0.113.7", "192.168.1.20", http.StatusForbidden},
+		{"other routes unrestricted", true, "/api/files", "203.0.113.7", "", http.StatusOK},
+		{"no allowlist", false, "/api/admin/users", "203.0.113.7", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets := allowed
+			if !tt.allowed {
+				nets = nil
+			}
+			// Same order as the server: RealIP resolves the client first
+			h := RealIP(trusted)(RestrictPrefix("/api/admin/", nets)(func(w http.ResponseWriter, r *http.Request) {}))
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.RemoteAddr = tt.peer + ":1234"
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	tests := []struct {
+		entries []string
+		wantErr bool
+	}{
+		{[]string{"10.0.0.0/8", "192.168.1.1", "::1"}, false},
+		{[]string{"not-an-ip"}, true},
+		{[]string{"10.0.0.0/33"}, true},
+	}
+	for _, tt := range tests {
+		if _, err := ParseNetworks(tt.entries); (err != nil) != tt.wantErr {
+			t.Errorf("%v: got %v, want error %v", tt.entries, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+// rateWindow counts requests for one key in the current window
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit allows at most limit requests per client IP and path in each
+// window. It is in-memory, so limits are per process.
+func RateLimit(limit int, window time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return rateLimitBy(limit, window, func(r *http.Request) (string, bool) {
+		return handler.ClientIP(r) + " " + r.URL.Path, true
+	})
+}
+
+// SharePasswordLimit allows at most limit password attempts per client IP
+// and share token in each window. Attempts are POSTs to /api/s/{token} or
+// /api/s/{token}/verify, and both routes count against the same limit, so
+// switching routes doesn't reset it. Other requests pass through.
+func SharePasswordLimit(limit int, window time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return rateLimitBy(limit, window, func(r *http.Request) (string, bool) {
+		if r.Method != http.MethodPost {
+			return "", false
+		}
+		token, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/s/"), "/")
+		return handler.ClientIP(r) + " " + token, true
+	})
+}
+
+// rateLimitBy limits requests by the key keyOf returns for them; requests it
+// returns no key for aren't limited
+func rateLimitBy(limit int, window time.Duration, keyOf func(r *http.Request) (string, bool)) func(http.HandlerFunc) http.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+	lastSweep := time.Now()
+
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key, limited := keyOf(r)
+			if !limited {
+				next(w, r)
+				return
+			}
+			now := time.Now()
+
+			mu.Lock()
+			// Drop expired windows now and then so the map can't grow unbounded
+			if now.Sub(lastSweep) > window {
+				for k, rw := range windows {
+					if now.Sub(rw.start) > window {
+						delete(windows, k)
+					}
+				}
+				lastSweep = now
+			}
+
+			rw, ok := windows[key]
+			if !ok || now.Sub(rw.start) > window {
+				rw = &rateWindow{start: now}
+				windows[key] = rw
+			}
+			rw.count++
+			allowed := rw.count <= limit
+			retryAfter := rw.start.Add(window).Sub(now)
+			mu.Unlock()
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				handler.SendError(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSharePasswordLimit(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	limit := SharePasswordLimit(3, time.Minute)
+	access, verify := limit(ok), limit(ok)
+
+	send := func(h http.HandlerFunc, method, target, ip string) int {
+		r := httptest.NewRequest(method, target, nil)
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w.Code
+	}
+
+	steps := []struct {
+		name   string
+		h      http.HandlerFunc
+		method string
+		target string
+		ip     string
+		want   int
+	}{
+		{"first attempt", access, http.MethodPost, "/api/s/tok", "10.0.0.1", http.StatusOK},
+		{"second attempt", access, http.MethodPost, "/api/s/tok", "10.0.0.1", http.StatusOK},
+		{"verify shares the limit", verify, http.MethodPost, "/api/s/tok/verify", "10.0.0.1", http.StatusOK},
+		{"over the limit on verify", verify, http.MethodPost, "/api/s/tok/verify", "10.0.0.1", http.StatusTooManyRequests},
+		{"over the limit on access", access, http.MethodPost, "/api/s/tok", "10.0.0.1", http.StatusTooManyRequests},
+		{"GET isn't an attempt", access, http.MethodGet, "/api/s/tok", "10.0.0.1", http.StatusOK},
+		{"other share", access, http.MethodPost, "/api/s/other", "10.0.0.1", http.StatusOK},
+		{"other client", access, http.MethodPost, "/api/s/tok", "10.0.0.2", http.StatusOK},
+	}
+	for _, s := range steps {
+		if got := send(s.h, s.method, s.target, s.ip); got != s.want {
+			t.Fatalf("%s: status %d, want %d", s.name, got, s.want)
+		}
+	}
+}
+
+func TestRateLimitKeysByPath(t *testing.T) {
+	h := RateLimit(1, time.Minute)(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tt := range []struct {
+		target string
+		want   int
+	}{
+		{"/a", http.StatusOK},
+		{"/a", http.StatusTooManyRequests},
+		{"/b", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.want {
+			t.Fatalf("%s: status %d, want %d", tt.target, w.Code, tt.want)
+		}
+	}
+}
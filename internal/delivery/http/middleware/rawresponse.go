@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+// RawResponses lets read requests opt out of the response envelope with
+// X-Response-Format: raw or ?raw=true
+func RawResponses(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		// Both formats share ETags, so caches must tell them apart
+		w.Header().Add("Vary", handler.ResponseFormatHeader)
+		if handler.WantsRaw(r) {
+			w = handler.RawWriter(w)
+		}
+		next(w, r)
+	}
+}
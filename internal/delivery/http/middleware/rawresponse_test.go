@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+func TestRawResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		header   string
+		fail     bool // Whether the handler sends an error
+		wantBody string
+		wantVary bool
+	}{
+		{"enveloped read", http.MethodGet, "/api/files", "", false, `{"success":true,"data":[1,2]}`, true},
+		{"raw header", http.MethodGet, "/api/files", "raw", false, `[1,2]`, true},
+		{"raw query", http.MethodGet, "/api/files?raw=true", "", false, `[1,2]`, true},
+		{"writes stay enveloped", http.MethodPost, "/api/files?raw=true", "raw", false, `{"success":true,"data":[1,2]}`, false},
+		{"raw error", http.MethodGet, "/api/files", "raw", true, `{"success":false,"message":"Not found"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := RawResponses(func(w http.ResponseWriter, r *http.Request) {
+				if tt.fail {
+					handler.SendError(w, "Not found", http.StatusNotFound)
+					return
+				}
+				handler.SendSuccess(w, "", []int{1, 2})
+			})
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set(handler.ResponseFormatHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("body %s, want %s", got, tt.wantBody)
+			}
+			if got := w.Header().Get("Vary") == handler.ResponseFormatHeader; got != tt.wantVary {
+				t.Fatalf("Vary %q", w.Header().Get("Vary"))
+			}
+		})
+	}
+}
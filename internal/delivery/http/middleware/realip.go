@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+// RealIP resolves the client IP and stores it in the request context.
+// X-Forwarded-For and X-Real-IP are only honoured when the immediate peer is
+// a trusted proxy; with no trusted proxies configured they are ignored so
+// clients can't spoof their address.
+func RealIP(trusted []*net.IPNet) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			if inNetworks(ip, trusted) {
+				ip = forwardedIP(r, ip, trusted)
+			}
+
+			ctx := context.WithValue(r.Context(), handler.ClientIPContextKey, ip)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// remoteIP returns the host part of the connection's remote address
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedIP walks X-Forwarded-For from the right, skipping trusted proxies,
+// and returns the first untrusted hop. X-Real-IP is used when there is no
+// X-Forwarded-For header.
+func forwardedIP(r *http.Request, peer string, trusted []*net.IPNet) string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return peer
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			// Anything left of a malformed entry can't be trusted
+			break
+		}
+		if !inNetworks(hops[i], trusted) {
+			return hops[i]
+		}
+		peer = hops[i]
+	}
+	return peer
+}
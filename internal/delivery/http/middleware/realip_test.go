@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		trusted   bool // Whether the trusted proxies are configured
+		peer      string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{"no trusted proxies ignores headers", false, "10.0.0.1", "203.0.113.7", "203.0.113.8", "10.0.0.1"},
+		{"untrusted peer ignores headers", true, "198.51.100.1", "203.0.113.7", "", "198.51.100.1"},
+		{"trusted peer", true, "10.0.0.1", "203.0.113.7", "", "203.0.113.7"},
+		{"trusted single address", true, "192.168.1.1", "203.0.113.7", "", "203.0.113.7"},
+		{"trusted hops skipped", true, "10.0.0.1", "203.0.113.7, 10.0.0.2", "", "203.0.113.7"},
+		{"spoofed leftmost entry", true, "10.0.0.1", "1.2.3.4, 203.0.113.7", "", "203.0.113.7"},
+		{"malformed hop", true, "10.0.0.1", "1.2.3.4, bogus, 10.0.0.2", "", "10.0.0.2"},
+		{"X-Real-IP from trusted peer", true, "10.0.0.1", "", "203.0.113.8", "203.0.113.8"},
+		{"invalid X-Real-IP", true, "10.0.0.1", "", "bogus", "10.0.0.1"},
+		{"only proxies", true, "10.0.0.1", "10.0.0.3", "", "10.0.0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets := trusted
+			if !tt.trusted {
+				nets = nil
+			}
+			var got string
+			h := RealIP(nets)(func(w http.ResponseWriter, r *http.Request) { got = handler.ClientIP(r) })
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.peer + ":1234"
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h(httptest.NewRecorder(), r)
+			if got != tt.want {
+				t.Fatalf("client IP %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"gomanager/internal/delivery/http/handler"
+)
+
+// RequestIDHeader carries the ID used to match a client report to server logs
+const RequestIDHeader = "X-Request-ID"
+
+// Recover turns a panicking handler into a 500 response. The stack trace is
+// logged with the request ID, which is also returned to the client so the
+// two can be matched without exposing internals.
+func Recover(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		rw := &headerTracker{ResponseWriter: w}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// ErrAbortHandler deliberately aborts the response; let net/http handle it
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			log.Printf("panic [%s] %s %s: %v\n%s", id, r.Method, r.URL.Path, err, debug.Stack())
+
+			// A partly written response can't be replaced, only cut short
+			if rw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			handler.SendError(w, "Internal server error (request "+id+")", http.StatusInternalServerError)
+		}()
+
+		next(rw, r)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// headerTracker records whether the response has started
+type headerTracker struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (t *headerTracker) WriteHeader(status int) {
+	t.wroteHeader = true
+	t.ResponseWriter.WriteHeader(status)
+}
+
+func (t *headerTracker) Write(b []byte) (int, error) {
+	t.wroteHeader = true
+	return t.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (t *headerTracker) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", Recover(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret internal state")
+	}))
+	mux.HandleFunc("/partial", Recover(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "half")
+		w.(http.Flusher).Flush()
+		panic("mid-response")
+	}))
+	mux.HandleFunc("/ok", Recover(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "fine")
+	}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		requestID string
+		status    int // 0 when the response is cut short
+		wantBody  string
+	}{
+		{"panic", "/panic", "", http.StatusInternalServerError, `"success":false`},
+		{"server still up", "/ok", "", http.StatusOK, "fine"},
+		{"client request ID kept", "/panic", "abc123", http.StatusInternalServerError, "abc123"},
+		{"panic after writing", "/partial", "", 0, ""},
+		{"server still up after abort", "/ok", "", http.StatusOK, "fine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if tt.status == 0 {
+				if err == nil {
+					t.Fatalf("got a complete response %q, want it cut short", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status || !strings.Contains(string(body), tt.wantBody) {
+				t.Fatalf("status %d, body %q; want %d, %q", resp.StatusCode, body, tt.status, tt.wantBody)
+			}
+			if strings.Contains(string(body), "secret internal state") || strings.Contains(string(body), "goroutine") {
+				t.Fatalf("response leaks internals: %s", body)
+			}
+			id := resp.Header.Get(RequestIDHeader)
+			if id == "" || (tt.requestID != "" && id != tt.requestID) {
+				t.Fatalf("request ID %q, want %q", id, tt.requestID)
+			}
+			if tt.status == http.StatusInternalServerError && !strings.Contains(logs.String(), "panic ["+id+"]") {
+				t.Fatalf("no log line for request %s in:\n%s", id, logs.String())
+			}
+		})
+	}
+	if !strings.Contains(logs.String(), "goroutine") {
+		t.Error("stack trace not logged")
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// NoDeadline clears the server read/write deadlines for long-running transfers.
+// The server-wide timeouts protect against slow clients, but a large upload or
+// download can legitimately take longer than they allow.
+func NoDeadline(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+		next(w, r)
+	}
+}
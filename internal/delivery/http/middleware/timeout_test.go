@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNoDeadline(t *testing.T) {
+	// Takes longer than the server's write timeout
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		io.WriteString(w, "done")
+	}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantOK  bool
+	}{
+		{"server deadline applies", slow, false},
+		{"deadline cleared", NoDeadline(slow), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(tt.handler)
+			srv.Config.WriteTimeout = 100 * time.Millisecond
+			srv.Start()
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			if ok := err == nil && string(body) == "done"; ok != tt.wantOK {
+				t.Fatalf("got %q, %v; want completed = %v", body, err, tt.wantOK)
+			}
+		})
+	}
+}
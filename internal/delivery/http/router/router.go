@@ -7,6 +7,7 @@ import (
 	"gomanager/internal/application/auth"
 	"gomanager/internal/delivery/http/handler"
 	"gomanager/internal/delivery/http/middleware"
+	"gomanager/internal/domain/idempotency"
 	"gomanager/internal/domain/user"
 	"gomanager/internal/infrastructure/config"
 )
@@ -20,6 +21,11 @@ type Handlers struct {
 	User           *handler.UserHandler
 	GoogleServices *handler.GoogleServicesHandler
 	GoogleAds      *handler.GoogleAdsHandler
+	Database       *handler.DatabaseHandler
+
+	// Idempotency stores responses replayed for retried POSTs; nil disables
+	// Idempotency-Key support
+	Idempotency idempotency.Repository
 }
 
 // Setup configures all routes for the application
@@ -44,122 +50,208 @@ func SetupWithConfig(handlers Handlers, authService auth.Service, cfg *config.Co
 	}
 
 	corsConfig := middleware.CORSConfig{
-		AllowedOrigins: allowedOrigins,
+		AllowedOrigins:   allowedOrigins,
+		AllowCredentials: cfg == nil || cfg.CORSAllowCredentials,
+		Strict:           cfg != nil && cfg.CORSStrictOrigins,
 	}
 	corsMiddleware := func(next http.HandlerFunc) http.HandlerFunc {
 		return middleware.CORSWithConfig(corsConfig, next)
 	}
 
+	// Authenticated routes only answer the listed origins
+	authCORSConfig := corsConfig
+	authCORSConfig.Strict = true
+	authCORS := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.CORSWithConfig(authCORSConfig, next)
+	}
+
+	// Public read routes can be embedded anywhere; only the listed origins
+	// get credentials, so other sites can't read them as a logged-in user
+	publicCORSConfig := corsConfig
+	publicCORSConfig.AnyOrigin = true
+	publicCORS := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.CORSWithConfig(publicCORSConfig, next)
+	}
+
 	// Middleware helpers
 	authRequired := middleware.Auth(authService)
 	optionalAuth := middleware.OptionalAuth(authService)
+	fullUser := middleware.LoadUser(authService)
 	adminOnly := middleware.RequireRole(user.RoleAdmin)
 	canUpload := middleware.RequireRole(user.RoleAdmin, user.RoleUser)
+	noDeadline := middleware.NoDeadline
+	sharePasswordLimit := middleware.SharePasswordLimit(10, time.Minute)
+	idempotencyTTL := 24 * time.Hour
+	if cfg != nil {
+		idempotencyTTL = time.Duration(cfg.IdempotencyKeyTTL) * time.Hour
+	}
+	idempotent := middleware.Idempotent(handlers.Idempotency, idempotencyTTL)
 
-	// Chain helper
+	// Chain helper; panic recovery and the raw response opt-in always wrap
+	// the whole chain
 	chain := func(h http.HandlerFunc, middlewares ...func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
 		for i := len(middlewares) - 1; i >= 0; i-- {
 			h = middlewares[i](h)
 		}
-		return h
+		return middleware.Recover(middleware.RawResponses(h))
 	}
 
 	// ==================
 	// Health check route (public)
 	// ==================
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","message":"GoManager API is running"}`))
-	})
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	if cfg != nil && cfg.StaticDir != "" {
+		// Serve the frontend; unmatched paths fall back to its index.html
+		mux.HandleFunc("/", chain(handler.NewSPAHandler(cfg.StaticDir)))
+	} else {
+		mux.HandleFunc("/", chain(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"ok","message":"GoManager API is running"}`))
+		}))
+	}
+	mux.HandleFunc("/health", chain(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"healthy","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`))
-	})
+	}))
 
 	// ==================
 	// Auth routes (public)
 	// ==================
-	mux.HandleFunc("/api/auth/register", corsMiddleware(handlers.Auth.Register))
-	mux.HandleFunc("/api/auth/login", corsMiddleware(handlers.Auth.Login))
-	mux.HandleFunc("/api/auth/logout", chain(handlers.Auth.Logout, corsMiddleware, authRequired))
-	mux.HandleFunc("/api/auth/me", chain(handlers.Auth.Me, corsMiddleware, authRequired))
+	mux.HandleFunc("/api/auth/register", chain(handlers.Auth.Register, corsMiddleware))
+	mux.HandleFunc("/api/auth/login", chain(handlers.Auth.Login, corsMiddleware))
+	mux.HandleFunc("/api/auth/logout", chain(handlers.Auth.Logout, authCORS, authRequired))
+	mux.HandleFunc("/api/auth/me", chain(handlers.Auth.Me, authCORS, authRequired))
+	mux.HandleFunc("/api/auth/token/status", chain(handlers.Auth.TokenStatus, corsMiddleware)) // Reports invalid tokens instead of rejecting them
 
 	// ==================
 	// Google OAuth routes (public)
 	// ==================
 	if handlers.OAuth != nil {
-		mux.HandleFunc("/api/auth/google", corsMiddleware(handlers.OAuth.GoogleLogin))
-		mux.HandleFunc("/api/auth/google/callback", handlers.OAuth.GoogleCallback)
-		mux.HandleFunc("/api/auth/google/status", corsMiddleware(handlers.OAuth.GoogleStatus))
+		mux.HandleFunc("/api/auth/google", chain(handlers.OAuth.GoogleLogin, corsMiddleware))
+		mux.HandleFunc("/api/auth/google/callback", chain(handlers.OAuth.GoogleCallback))
+		mux.HandleFunc("/api/auth/google/link", chain(handlers.OAuth.GoogleLink, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/auth/google/status", chain(handlers.OAuth.GoogleStatus, corsMiddleware))
 	}
 
 	// ==================
 	// File routes (protected)
 	// ==================
-	mux.HandleFunc("/api/files", chain(handlers.File.List, corsMiddleware, authRequired))
-	mux.HandleFunc("/api/stats", chain(handlers.File.Stats, corsMiddleware, authRequired))
-	mux.HandleFunc("/api/upload", chain(handlers.File.Upload, corsMiddleware, authRequired, canUpload))
-	mux.HandleFunc("/api/download/", chain(handlers.File.Download, corsMiddleware, authRequired))
-	mux.HandleFunc("/api/mkdir", chain(handlers.File.CreateFolder, corsMiddleware, authRequired, canUpload))
-	mux.HandleFunc("/api/delete", chain(handlers.File.Delete, corsMiddleware, authRequired, canUpload))
+	mux.HandleFunc("/api/files", chain(handlers.File.List, authCORS, authRequired))
+	mux.HandleFunc("/api/stats", chain(handlers.File.Stats, authCORS, authRequired))
+	mux.HandleFunc("/api/stats/by-type", chain(handlers.File.StatsByType, authCORS, authRequired))
+	mux.HandleFunc("/api/upload", chain(handlers.File.Upload, noDeadline, authCORS, authRequired, idempotent, canUpload))
+	mux.HandleFunc("/api/download/", chain(handlers.File.Download, noDeadline, authCORS, authRequired))
+	mux.HandleFunc("/api/download/signed", chain(handlers.File.SignedDownload, noDeadline, publicCORS)) // Signature replaces auth
+	mux.HandleFunc("/api/files/signed-url", chain(handlers.File.SignedURL, authCORS, authRequired))
+	mux.HandleFunc("/api/uploads/tus", chain(handlers.File.Tus, noDeadline, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/uploads/tus/", chain(handlers.File.Tus, noDeadline, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/mkdir", chain(handlers.File.CreateFolder, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/delete", chain(handlers.File.Delete, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/move", chain(handlers.File.Move, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/files/raw", chain(handlers.File.UploadRaw, noDeadline, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/files/paste", chain(handlers.File.Paste, authCORS, authRequired, canUpload))
+	mux.HandleFunc("/api/files/exists", chain(handlers.File.Exists, authCORS, authRequired))
+	mux.HandleFunc("/api/files/info", chain(handlers.File.Info, authCORS, authRequired))
+	mux.HandleFunc("/api/files/stat-batch", chain(handlers.File.StatBatch, authCORS, authRequired))
+	mux.HandleFunc("/api/files/diff", chain(handlers.File.Diff, authCORS, authRequired))
+	mux.HandleFunc("/api/files/events", chain(handlers.File.Events, noDeadline, authCORS, authRequired))
+	mux.HandleFunc("/api/user/capabilities", chain(handlers.File.Capabilities, authCORS, authRequired))
+	mux.HandleFunc("/api/files/metadata", chain(handlers.File.Metadata, authCORS, authRequired)) // PUT checks the role itself
+	mux.HandleFunc("/api/files/breadcrumbs", chain(handlers.File.Breadcrumbs, authCORS, authRequired))
+	mux.HandleFunc("/api/files/preview", chain(handlers.File.Preview, authCORS, authRequired))
+	mux.HandleFunc("/api/files/thumbnail", chain(handlers.File.Thumbnail, authCORS, authRequired))
+	mux.HandleFunc("/api/files/recent-downloads", chain(handlers.File.RecentDownloads, authCORS, authRequired))
+	mux.HandleFunc("/api/files/archive/list", chain(handlers.File.ArchiveList, authCORS, authRequired))
+	mux.HandleFunc("/api/files/archive/extract-entry", chain(handlers.File.ArchiveExtractEntry, noDeadline, authCORS, authRequired))
+	mux.HandleFunc("/api/files/touch", chain(handlers.File.Touch, authCORS, authRequired, canUpload))
+
+	// ==================
+	// WebDAV (protected; roles are checked per method)
+	// ==================
+	if cfg != nil && cfg.WebDAVEnabled {
+		davAuth := middleware.DAVAuth(authService)
+		mux.HandleFunc("/dav", chain(handlers.File.WebDAV, noDeadline, davAuth))
+		mux.HandleFunc("/dav/", chain(handlers.File.WebDAV, noDeadline, davAuth))
+	}
 
 	// ==================
 	// Share routes
 	// ==================
-	mux.HandleFunc("/api/shares", chain(handlers.Share.HandleShares, corsMiddleware, authRequired))
-	mux.HandleFunc("/api/shares/", chain(handlers.Share.HandleShareByID, corsMiddleware, authRequired))
+	mux.HandleFunc("/api/shares", chain(handlers.Share.HandleShares, authCORS, authRequired, idempotent))
+	mux.HandleFunc("/api/shares/", chain(handlers.Share.HandleShareByID, authCORS, authRequired))
+	mux.HandleFunc("/api/shares/summary", chain(handlers.Share.ShareSummary, authCORS, authRequired))
+	mux.HandleFunc("/api/shares/health", chain(handlers.Share.ShareHealthCheck, authCORS, authRequired))
+	mux.HandleFunc("/api/shares/delete-expired", chain(handlers.Share.DeleteExpiredShares, authCORS, authRequired))
+	mux.HandleFunc("/api/shares/delete-batch", chain(handlers.Share.DeleteSharesBatch, authCORS, authRequired))
 
 	// Public share access (no auth required)
-	mux.HandleFunc("/api/s/", chain(handlers.Share.AccessShare, corsMiddleware, optionalAuth))
+	mux.HandleFunc("/api/s/", chain(handlers.Share.AccessShare, noDeadline, publicCORS, optionalAuth, sharePasswordLimit))
+	mux.HandleFunc("/api/s/{token}/verify", chain(handlers.Share.VerifySharePassword, publicCORS, optionalAuth, sharePasswordLimit))
 
 	// ==================
 	// Admin routes
 	// ==================
-	_ = adminOnly // Will be used for user management endpoints
+	mux.HandleFunc("/api/admin/shares", chain(handlers.Share.ListAllShares, authCORS, authRequired, adminOnly))
+	mux.HandleFunc("/api/admin/invites", chain(handlers.Auth.Invites, authCORS, authRequired, adminOnly))
+	mux.HandleFunc("/api/admin/shares/purge", chain(handlers.Share.PurgeDeletedShares, authCORS, authRequired, adminOnly))
+	mux.HandleFunc("/api/admin/files/transfer", chain(handlers.Share.TransferFiles, authCORS, authRequired, adminOnly))
+	mux.HandleFunc("/api/admin/reindex", chain(handlers.File.Reindex, authCORS, authRequired, adminOnly))
+	mux.HandleFunc("/api/admin/reindex/status", chain(handlers.File.ReindexStatus, authCORS, authRequired, adminOnly))
+	if handlers.Database != nil {
+		mux.HandleFunc("/api/admin/vacuum", chain(handlers.Database.Vacuum, noDeadline, authCORS, authRequired, adminOnly))
+	}
+	if handlers.User != nil {
+		mux.HandleFunc("/api/admin/users", chain(handlers.User.ListUsers, authCORS, authRequired, adminOnly))
+	}
 
 	// ==================
 	// User profile routes (protected)
 	// ==================
 	if handlers.User != nil {
-		mux.HandleFunc("/api/user/profile", chain(handlers.User.GetProfile, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/user/profile/update", chain(handlers.User.UpdateProfile, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/user/password", chain(handlers.User.UpdatePassword, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/user/avatar", chain(handlers.User.UploadAvatar, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/user/avatar/delete", chain(handlers.User.DeleteAvatar, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/user/avatar/", corsMiddleware(handlers.User.ServeAvatar)) // Public for serving images
+		mux.HandleFunc("/api/user/profile", chain(handlers.User.GetProfile, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/user/profile/update", chain(handlers.User.UpdateProfile, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/user/export", chain(handlers.User.Export, noDeadline, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/user/password", chain(handlers.User.UpdatePassword, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/user/avatar", chain(handlers.User.UploadAvatar, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/user/avatar/delete", chain(handlers.User.DeleteAvatar, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/user/avatar/", chain(handlers.User.ServeAvatar, publicCORS)) // Public for serving images
 	}
 
 	// ==================
 	// Google Services routes (protected)
 	// ==================
 	if handlers.GoogleServices != nil {
-		mux.HandleFunc("/api/google/status", chain(handlers.GoogleServices.GoogleConnectionStatus, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/calendars", chain(handlers.GoogleServices.ListCalendars, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/calendar/events", chain(handlers.GoogleServices.ListEvents, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/calendar/events/create", chain(handlers.GoogleServices.CreateEvent, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/tasks/lists", chain(handlers.GoogleServices.ListTaskLists, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/tasks", chain(handlers.GoogleServices.ListTasks, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/tasks/create", chain(handlers.GoogleServices.CreateTask, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/tasks/update", chain(handlers.GoogleServices.UpdateTask, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/tasks/complete", chain(handlers.GoogleServices.CompleteTask, corsMiddleware, authRequired))
+		mux.HandleFunc("/api/google/status", chain(handlers.GoogleServices.GoogleConnectionStatus, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/grants", chain(handlers.GoogleServices.GoogleGrants, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/revoke", chain(handlers.GoogleServices.RevokeGoogle, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/calendars", chain(handlers.GoogleServices.ListCalendars, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/calendar/events", chain(handlers.GoogleServices.ListEvents, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/calendar/events/create", chain(handlers.GoogleServices.CreateEvent, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/calendar/freebusy", chain(handlers.GoogleServices.FreeBusy, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/tasks/lists", chain(handlers.GoogleServices.ListTaskLists, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/tasks", chain(handlers.GoogleServices.ListTasks, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/tasks/create", chain(handlers.GoogleServices.CreateTask, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/tasks/update", chain(handlers.GoogleServices.UpdateTask, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/tasks/complete", chain(handlers.GoogleServices.CompleteTask, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/tasks/complete-batch", chain(handlers.GoogleServices.CompleteTasksBatch, authCORS, authRequired, fullUser))
 
 		// Google Drive routes
-		mux.HandleFunc("/api/google/drive/files", chain(handlers.GoogleServices.ListDriveFiles, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/drive/folders", chain(handlers.GoogleServices.CreateDriveFolder, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/drive/upload", chain(handlers.GoogleServices.UploadDriveFile, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/drive/delete", chain(handlers.GoogleServices.DeleteDriveFile, corsMiddleware, authRequired))
+		mux.HandleFunc("/api/google/drive/files", chain(handlers.GoogleServices.ListDriveFiles, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/drive/file", chain(handlers.GoogleServices.GetDriveFile, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/drive/folders", chain(handlers.GoogleServices.CreateDriveFolder, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/drive/upload", chain(handlers.GoogleServices.UploadDriveFile, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/drive/delete", chain(handlers.GoogleServices.DeleteDriveFile, authCORS, authRequired, fullUser))
 	}
 
 	// ==================
 	// Google Ads routes (protected)
 	// ==================
 	if handlers.GoogleAds != nil {
-		mux.HandleFunc("/api/google/ads/status", chain(handlers.GoogleAds.GoogleAdsStatus, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/ads/campaigns", chain(handlers.GoogleAds.ListCampaigns, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/ads/campaigns/create", chain(handlers.GoogleAds.CreateCampaign, corsMiddleware, authRequired))
-		mux.HandleFunc("/api/google/ads/campaigns/performance", chain(handlers.GoogleAds.GetCampaignPerformance, corsMiddleware, authRequired))
+		mux.HandleFunc("/api/google/ads/status", chain(handlers.GoogleAds.GoogleAdsStatus, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/ads/campaigns", chain(handlers.GoogleAds.ListCampaigns, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/ads/campaigns/create", chain(handlers.GoogleAds.CreateCampaign, authCORS, authRequired, fullUser))
+		mux.HandleFunc("/api/google/ads/campaigns/performance", chain(handlers.GoogleAds.GetCampaignPerformance, authCORS, authRequired, fullUser))
 	}
 
 	return mux
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gomanager/internal/delivery/http/handler"
+	"gomanager/internal/infrastructure/config"
+)
+
+func TestCORSPerRouteGroup(t *testing.T) {
+	mux := SetupWithConfig(Handlers{User: &handler.UserHandler{}}, nil, &config.Config{FrontendURL: "https://app.example.com", CORSAllowCredentials: true})
+
+	tests := []struct {
+		name   string
+		path   string
+		origin string
+		want   string
+	}{
+		{"share from any origin", "/api/s/abc123", "https://blog.example", "*"},
+		{"avatar from any origin", "/api/user/avatar/u1", "https://blog.example", "*"},
+		{"auth route refuses other origins", "/api/auth/me", "https://blog.example", ""},
+		{"file route refuses other origins", "/api/files", "https://blog.example", ""},
+		{"auth route allows the frontend", "/api/auth/me", "https://app.example.com", "https://app.example.com"},
+		{"login keeps echoing by default", "/api/auth/login", "https://blog.example", "https://blog.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Preflights are answered by the CORS middleware alone
+			r := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			r.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d", w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Fatalf("allowed origin %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		staticDir string
+		path      string
+		want      string
+	}{
+		{"banner without a static dir", "", "/", "GoManager API is running"},
+		{"frontend at the root", dir, "/", "<html>app</html>"},
+		{"deep link falls back to the frontend", dir, "/settings/profile", "<html>app</html>"},
+		{"health stays routed", dir, "/health", `"status":"healthy"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := SetupWithConfig(Handlers{}, nil, &config.Config{StaticDir: tt.staticDir})
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+				t.Fatalf("status %d, body %q; want %q", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package auth
+
+import "errors"
+
+// ErrCaptchaFailed means the CAPTCHA token was missing, expired or rejected
+var ErrCaptchaFailed = errors.New("captcha verification failed")
+
+// CaptchaVerifier checks the CAPTCHA token a client solved before registering.
+// ip is the client's address, which providers use as an extra signal.
+type CaptchaVerifier interface {
+	Verify(token, ip string) error
+}
+
+// NoCaptcha accepts every request; it stands in when no provider is configured
+type NoCaptcha struct{}
+
+func (NoCaptcha) Verify(token, ip string) error { return nil }
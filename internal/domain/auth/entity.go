@@ -15,17 +15,39 @@ type Session struct {
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	ClientIP string `json:"-"` // Exempts clients that signed in before from lockouts
 }
 
 // LoginResponse represents a successful login response
 type LoginResponse struct {
-	Token     string `json:"token"`
+	Token     string `json:"token,omitempty"` // Left out when issued as a cookie
 	ExpiresAt int64  `json:"expiresAt"`
 }
 
+// TokenStatus reports whether a token is still accepted and for how long
+type TokenStatus struct {
+	Valid            bool  `json:"valid"`
+	ExpiresAt        int64 `json:"expiresAt,omitempty"`
+	SecondsRemaining int64 `json:"secondsRemaining"`
+}
+
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email    string `json:"email"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// InviteCode is required when registration is invite-only
+	InviteCode string `json:"inviteCode,omitempty"`
+	// CaptchaToken is required when a CAPTCHA provider is configured
+	CaptchaToken string `json:"captchaToken,omitempty"`
+}
+
+// Invite is a single-use code that allows one registration
+type Invite struct {
+	Code      string     `json:"code"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UsedBy    string     `json:"usedBy,omitempty"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
 }
@@ -0,0 +1,59 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ParseChecksum reads a client-supplied SHA-256 as hex, optionally prefixed
+// with "sha256:" or "sha256=", and returns it in lowercase
+func ParseChecksum(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, prefix := range []string{"sha256:", "sha256="} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	if len(s) != sha256.Size*2 {
+		return "", ErrInvalidChecksum
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", ErrInvalidChecksum
+	}
+	return s, nil
+}
+
+// checksumReader hashes data as it is read and fails the final read if the
+// result doesn't match
+type checksumReader struct {
+	r        io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+// NewChecksumReader passes r through, returning ErrChecksumMismatch instead
+// of io.EOF when the SHA-256 of everything read differs from expected (as
+// returned by ParseChecksum). Uploads fed through it are discarded on a
+// mismatch before they reach storage.
+func NewChecksumReader(r io.Reader, expected string) io.Reader {
+	return &checksumReader{r: r, hash: sha256.New(), expected: expected}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(c.hash.Sum(nil)) != c.expected {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+// Checksum returns the SHA-256 of everything in r as lowercase hex
+func Checksum(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,62 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// helloSum is the SHA-256 of "hello"
+const helloSum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{helloSum, helloSum, false},
+		{strings.ToUpper(helloSum), helloSum, false},
+		{"sha256:" + helloSum, helloSum, false},
+		{" SHA256=" + helloSum + " ", helloSum, false},
+		{helloSum[:63], "", true},
+		{helloSum[:63] + "g", "", true},
+		{"md5:" + helloSum, "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseChecksum(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%q: got %q, %v", tt.in, got, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidChecksum) {
+			t.Errorf("%q: got %v, want ErrInvalidChecksum", tt.in, err)
+		}
+	}
+}
+
+func TestChecksumReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		want     error
+	}{
+		{"match", "hello", helloSum, nil},
+		{"mismatch", "hellO", helloSum, ErrChecksumMismatch},
+		{"truncated", "hell", helloSum, ErrChecksumMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := io.ReadAll(NewChecksumReader(strings.NewReader(tt.data), tt.expected))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			// Everything is passed through; the check only fails the end
+			if string(data) != tt.data {
+				t.Fatalf("read %q", data)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package file
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // FileInfo represents a file or directory in the system
 type FileInfo struct {
@@ -9,6 +12,67 @@ type FileInfo struct {
 	IsDir   bool      `json:"isDir"`
 	ModTime time.Time `json:"modTime"`
 	Path    string    `json:"path"`
+
+	// SizePartial is set when a directory size walk hit its depth or time limit
+	SizePartial bool `json:"sizePartial,omitempty"`
+
+	// ContentType is only filled in for single-entry lookups
+	ContentType string `json:"contentType,omitempty"`
+
+	// Meta is only filled in when a listing asks for it
+	Meta *Metadata `json:"meta,omitempty"`
+}
+
+// Metadata is user-assigned tags and key/value pairs attached to a file.
+// It follows the file's ID, so it survives moves and renames.
+type Metadata struct {
+	Tags   []string          `json:"tags"`
+	Values map[string]string `json:"values"`
+}
+
+// MetadataTagKey is the key tags are stored under, so it can't be used in Values
+const MetadataTagKey = "tag"
+
+// HasTag reports whether tag is among m's tags, ignoring case
+func (m *Metadata) HasTag(tag string) bool {
+	if m == nil {
+		return false
+	}
+	for _, t := range m.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Breadcrumb is one ancestor segment of a path, from the root down
+type Breadcrumb struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	IsDir  bool   `json:"isDir"`
+}
+
+// ConflictPolicy decides what an upload does when the target name already exists
+type ConflictPolicy string
+
+const (
+	ConflictReject    ConflictPolicy = "reject"    // Skip the file and report a conflict
+	ConflictOverwrite ConflictPolicy = "overwrite" // Replace the existing file
+	ConflictRename    ConflictPolicy = "rename"    // Save as "name (1).ext", "name (2).ext", ...
+)
+
+// UploadResult reports which files were saved and which were skipped as conflicts
+type UploadResult struct {
+	Uploaded  []string `json:"uploaded"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Rejected  []string `json:"rejected,omitempty"` // Failed the upload scan and were deleted
+	Pending   []string `json:"pending,omitempty"`  // Quarantined until a background scan finishes
+
+	// ChecksumMismatch lists files whose content didn't match the checksum
+	// sent with them; they were not stored
+	ChecksumMismatch []string `json:"checksumMismatch,omitempty"`
 }
 
 // CreateFolderRequest represents a request to create a folder
@@ -21,11 +85,149 @@ type DeleteRequest struct {
 	Path string `json:"path"`
 }
 
+// DeletePreview is the inventory a delete would remove. Totals always cover
+// the whole tree; Entries stops at the requested limit.
+type DeletePreview struct {
+	Path      string     `json:"path"`
+	Entries   []FileInfo `json:"entries"`
+	Truncated bool       `json:"truncated"`
+	Files     int64      `json:"files"`
+	Folders   int64      `json:"folders"`
+	TotalSize int64      `json:"totalSize"`
+}
+
+// DirDiff compares two folders entry by entry. Paths in it are relative to
+// both roots, and a folder found on one side only is listed without its
+// contents.
+type DirDiff struct {
+	Left      string      `json:"left"`
+	Right     string      `json:"right"`
+	OnlyLeft  []FileInfo  `json:"onlyLeft"`
+	OnlyRight []FileInfo  `json:"onlyRight"`
+	Changed   []DiffEntry `json:"changed"`
+	Same      int64       `json:"same"`      // Entries present on both sides with no difference found
+	Checksums bool        `json:"checksums"` // Whether same-size files had their contents compared
+	Unhashed  int64       `json:"unhashed"`  // Same-size files left uncompared once the checksum budget ran out
+}
+
+// Reasons a DiffEntry differs
+const (
+	DiffType     = "type" // File on one side, folder on the other
+	DiffSize     = "size"
+	DiffModTime  = "modTime"
+	DiffChecksum = "checksum"
+)
+
+// DiffEntry is a path present in both folders that differs between them
+type DiffEntry struct {
+	Path    string   `json:"path"`
+	Left    FileInfo `json:"left"`
+	Right   FileInfo `json:"right"`
+	Reasons []string `json:"reasons"`
+}
+
+// MoveRequest represents a request to move a file or folder. When Destination
+// is an existing directory the source is moved into it under its own name.
+type MoveRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// Paste operations
+const (
+	PasteMove = "move"
+	PasteCopy = "copy"
+)
+
+// PasteRequest moves or copies several items into one folder. Conflict is
+// skip (default), overwrite or rename.
+type PasteRequest struct {
+	Operation   string   `json:"operation"`
+	Sources     []string `json:"sources"`
+	Destination string   `json:"destination"`
+	Conflict    string   `json:"conflict"`
+}
+
+// Per-item paste outcomes
+const (
+	PasteStatusDone    = "done"
+	PasteStatusSkipped = "skipped"
+	PasteStatusFailed  = "failed"
+)
+
+// PasteResult reports what happened to one pasted item
+type PasteResult struct {
+	Source string `json:"source"`
+	Path   string `json:"path,omitempty"` // Where the item ended up
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// StatBatchRequest asks for the info of several paths at once
+type StatBatchRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// StatResult is the info for one path in a batch, or why it is missing
+type StatResult struct {
+	Path  string    `json:"path"` // As requested
+	Info  *FileInfo `json:"info,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// ReindexStatus reports the progress of the latest storage reindex
+type ReindexStatus struct {
+	Running     bool       `json:"running"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Files       int64      `json:"files"`
+	Folders     int64      `json:"folders"`
+	IndexPruned int        `json:"indexPruned"` // File-ID entries whose paths no longer exist
+	Error       string     `json:"error,omitempty"`
+}
+
+// MaxDownloadHistory is how many downloads are remembered per user
+const MaxDownloadHistory = 100
+
+// Download is an entry in a user's download history
+type Download struct {
+	Path         string    `json:"path"`
+	Name         string    `json:"name"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
 // StorageStats represents storage statistics
 type StorageStats struct {
-	TotalFiles   int64            `json:"totalFiles"`
-	TotalFolders int64            `json:"totalFolders"`
-	TotalSize    int64            `json:"totalSize"`
-	FilesByType  map[string]int64 `json:"filesByType"`
-	RecentFiles  []FileInfo       `json:"recentFiles"`
+	TotalFiles     int64            `json:"totalFiles"`
+	TotalFolders   int64            `json:"totalFolders"`
+	TotalSize      int64            `json:"totalSize"`
+	TotalBytes     uint64           `json:"totalBytes"`     // Capacity of the storage filesystem
+	AvailableBytes uint64           `json:"availableBytes"` // Free space available to the server
+	FilesByType    map[string]int64 `json:"filesByType"`
+	SizeByType     map[string]int64 `json:"sizeByType"` // Bytes per extension; sums to TotalSize
+	RecentFiles    []FileInfo       `json:"recentFiles"`
+}
+
+// TypeFiles is one page of the files of a type (see TypeKey), largest first
+type TypeFiles struct {
+	Ext       string     `json:"ext"`
+	Files     []FileInfo `json:"files"`
+	Total     int        `json:"total"`     // Files of this type across all pages
+	TotalSize int64      `json:"totalSize"` // Bytes across all pages
+}
+
+// ArchiveListing is the table of contents of a zip file
+type ArchiveListing struct {
+	Path      string         `json:"path"`
+	Entries   []ArchiveEntry `json:"entries"`
+	TotalSize int64          `json:"totalSize"` // Uncompressed bytes of all entries
+}
+
+// ArchiveEntry is a file or folder stored in a zip file
+type ArchiveEntry struct {
+	Name           string    `json:"name"` // Path inside the archive
+	Size           int64     `json:"size"`
+	CompressedSize int64     `json:"compressedSize"`
+	ModTime        time.Time `json:"modTime"`
+	IsDir          bool      `json:"isDir"`
 }
@@ -3,12 +3,38 @@ package file
 import "errors"
 
 var (
-	ErrNotFound     = errors.New("file or directory not found")
-	ErrInvalidPath  = errors.New("invalid path")
-	ErrIsDirectory  = errors.New("cannot download a directory")
-	ErrRootDeletion = errors.New("cannot delete root directory")
-	ErrUploadFailed = errors.New("failed to upload files")
-	ErrCreateFailed = errors.New("failed to create directory")
-	ErrDeleteFailed = errors.New("failed to delete")
-	ErrReadFailed   = errors.New("failed to read directory")
+	ErrNotFound         = errors.New("file or directory not found")
+	ErrInvalidPath      = errors.New("invalid path")
+	ErrIsDirectory      = errors.New("cannot download a directory")
+	ErrRootDeletion     = errors.New("cannot delete root directory")
+	ErrUploadFailed     = errors.New("failed to upload files")
+	ErrCreateFailed     = errors.New("failed to create directory")
+	ErrDeleteFailed     = errors.New("failed to delete")
+	ErrReadFailed       = errors.New("failed to read directory")
+	ErrTouchFailed      = errors.New("failed to update modification time")
+	ErrMoveFailed       = errors.New("failed to move")
+	ErrCopyFailed       = errors.New("failed to copy")
+	ErrExists           = errors.New("destination already exists")
+	ErrMoveIntoSelf     = errors.New("cannot move a folder into itself")
+	ErrUploadNotFound   = errors.New("upload not found")
+	ErrOffsetMismatch   = errors.New("upload offset does not match")
+	ErrUploadTooLarge   = errors.New("upload exceeds its declared length")
+	ErrUploadIncomplete = errors.New("upload is not complete")
+	ErrScanRejected     = errors.New("file rejected by scanner")
+	ErrPathTooDeep      = errors.New("path exceeds the maximum directory depth")
+	ErrNotDirectory     = errors.New("path is not a directory")
+	ErrNotSupported     = errors.New("not supported by the storage backend")
+	ErrLinkInvalid      = errors.New("invalid download link signature")
+	ErrLinkExpired      = errors.New("download link has expired")
+	ErrReindexRunning   = errors.New("a reindex is already running")
+	ErrInvalidMetadata  = errors.New("invalid tags or metadata")
+	ErrDiffTooLarge     = errors.New("folders are too large to compare")
+	ErrInvalidChecksum  = errors.New("checksum must be a hex SHA-256")
+	ErrChecksumMismatch = errors.New("content does not match the checksum")
+	ErrNotArchive       = errors.New("file is not a zip archive")
+	ErrEntryNotFound    = errors.New("entry not found in the archive")
+	ErrArchiveTooLarge  = errors.New("archive exceeds the entry count or size limits")
+	ErrWalkIncomplete   = errors.New("some entries could not be read")
+
+	ErrRecursiveRequired = errors.New("path is a directory; recursive is required")
 )
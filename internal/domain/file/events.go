@@ -0,0 +1,24 @@
+package file
+
+import "time"
+
+// Kinds of file change events
+const (
+	EventCreated = "created"
+	EventDeleted = "deleted"
+	EventMoved   = "moved"
+)
+
+// Event describes a change the file service made to storage
+type Event struct {
+	Type string    `json:"type"`
+	Path string    `json:"path"`
+	From string    `json:"from,omitempty"` // Previous path of a moved entry
+	Time time.Time `json:"time"`
+}
+
+// EventPublisher receives file change events as they happen. Publish runs
+// before the file operation returns, so slow work belongs elsewhere.
+type EventPublisher interface {
+	Publish(event Event)
+}
@@ -0,0 +1,127 @@
+package file
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How strictly upload file names are cleaned
+const (
+	NameSanitizeOff      = "off"      // Only directories are stripped
+	NameSanitizePOSIX    = "posix"    // Also control characters and surrounding whitespace
+	NameSanitizePortable = "portable" // Also characters and names Windows can't store
+)
+
+// maxNameBytes is the longest name most filesystems accept
+const maxNameBytes = 255
+
+// fallbackName replaces names that sanitize to nothing
+const fallbackName = "file"
+
+// windowsReserved are device names Windows refuses with or without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// NameSanitizer cleans client-supplied upload names before they are written.
+// Different names may sanitize to the same result; the upload's conflict
+// policy decides what happens then.
+type NameSanitizer struct {
+	Mode          string
+	AllowDotfiles bool // Keep leading dots instead of stripping them
+}
+
+// Sanitize returns the name to store name under; it is never empty and never
+// contains a path separator
+func (s NameSanitizer) Sanitize(name string) string {
+	// Clients may send a full path, with either separator
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if s.Mode == NameSanitizeOff {
+		if name == "." || name == ".." || name == "/" {
+			return fallbackName
+		}
+		return name
+	}
+
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case s.Mode == NameSanitizePortable && strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if s.Mode == NameSanitizePortable {
+		// Windows drops trailing dots and spaces, so names could collide silently
+		name = strings.TrimRight(name, ". ")
+	}
+	if !s.AllowDotfiles {
+		name = strings.TrimLeft(name, ".")
+	}
+	if name == "" || name == "." || name == ".." {
+		name = fallbackName
+	}
+
+	if s.Mode == NameSanitizePortable {
+		stem := name
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			stem = name[:i]
+		}
+		if windowsReserved[strings.ToUpper(strings.TrimSpace(stem))] {
+			name = "_" + name
+		}
+	}
+
+	return truncateName(name)
+}
+
+// truncateName shortens name to maxNameBytes, keeping the extension and
+// whole UTF-8 characters
+func truncateName(name string) string {
+	if len(name) <= maxNameBytes {
+		return name
+	}
+	ext := path.Ext(name)
+	if len(ext) > maxNameBytes/2 {
+		ext = ""
+	}
+	stem := name[:maxNameBytes-len(ext)]
+	for len(stem) > 0 && !utf8.ValidString(stem) {
+		stem = stem[:len(stem)-1]
+	}
+	return stem + ext
+}
+
+// NameFilter narrows a listing by entry name, ignoring case. The zero value
+// matches everything.
+type NameFilter struct {
+	Prefix string
+	Glob   string // path.Match pattern, such as *.pdf
+}
+
+// Valid reports whether the glob is well formed
+func (f NameFilter) Valid() bool {
+	_, err := path.Match(f.Glob, "")
+	return err == nil
+}
+
+// Match reports whether name starts with the prefix and matches the glob
+func (f NameFilter) Match(name string) bool {
+	name = strings.ToLower(name)
+	if !strings.HasPrefix(name, strings.ToLower(f.Prefix)) {
+		return false
+	}
+	if f.Glob == "" {
+		return true
+	}
+	ok, _ := path.Match(strings.ToLower(f.Glob), name)
+	return ok
+}
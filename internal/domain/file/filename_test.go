@@ -0,0 +1,93 @@
+package file
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNameFilter(t *testing.T) {
+	tests := []struct {
+		filter NameFilter
+		name   string
+		want   bool
+	}{
+		{NameFilter{}, "anything.txt", true},
+		{NameFilter{Prefix: "rep"}, "report.pdf", true},
+		{NameFilter{Prefix: "REP"}, "Report.pdf", true},
+		{NameFilter{Prefix: "port"}, "report.pdf", false},
+		{NameFilter{Glob: "*.pdf"}, "report.PDF", true},
+		{NameFilter{Glob: "*.pdf"}, "report.pdf.txt", false},
+		{NameFilter{Glob: "r?port.*"}, "Report.doc", true},
+		{NameFilter{Glob: "[a-c]*"}, "Budget", true},
+		{NameFilter{Prefix: "rep", Glob: "*.pdf"}, "report.pdf", true},
+		{NameFilter{Prefix: "rep", Glob: "*.pdf"}, "report.txt", false},
+		{NameFilter{Prefix: "rep", Glob: "*.pdf"}, "summary.pdf", false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(tt.name); got != tt.want {
+			t.Errorf("%+v on %q: got %v, want %v", tt.filter, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameFilterValid(t *testing.T) {
+	tests := []struct {
+		glob string
+		want bool
+	}{
+		{"", true},
+		{"*.pdf", true},
+		{"[a-z]?.txt", true},
+		{"[", false},
+		{"a[b", false},
+		{`\`, false},
+	}
+	for _, tt := range tests {
+		if got := (NameFilter{Glob: tt.glob}).Valid(); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestNameSanitizer(t *testing.T) {
+	long := strings.Repeat("é", 200) + ".txt"
+	tests := []struct {
+		name      string
+		sanitizer NameSanitizer
+		in        string
+		want      string
+	}{
+		{"control characters", NameSanitizer{Mode: NameSanitizePortable}, "re\x00po\nrt\t.pdf", "report.pdf"},
+		{"control characters posix", NameSanitizer{Mode: NameSanitizePOSIX}, "a\x1fb\x7f.txt", "ab.txt"},
+		{"reserved name", NameSanitizer{Mode: NameSanitizePortable}, "CON", "_CON"},
+		{"reserved name with extension", NameSanitizer{Mode: NameSanitizePortable}, "nul.txt", "_nul.txt"},
+		{"reserved name padded", NameSanitizer{Mode: NameSanitizePortable}, "com1 .tar.gz", "_com1 .tar.gz"},
+		{"reserved prefix only", NameSanitizer{Mode: NameSanitizePortable}, "CONSOLE.txt", "CONSOLE.txt"},
+		{"reserved allowed on posix", NameSanitizer{Mode: NameSanitizePOSIX}, "CON", "CON"},
+		{"windows characters", NameSanitizer{Mode: NameSanitizePortable}, `a<b>c:d"e|f?g*.txt`, "a_b_c_d_e_f_g_.txt"},
+		{"windows characters on posix", NameSanitizer{Mode: NameSanitizePOSIX}, "a:b?.txt", "a:b?.txt"},
+		{"surrounding whitespace", NameSanitizer{Mode: NameSanitizePOSIX}, "  notes.txt \t", "notes.txt"},
+		{"trailing dots", NameSanitizer{Mode: NameSanitizePortable}, "notes.txt...", "notes.txt"},
+		{"leading dots", NameSanitizer{Mode: NameSanitizePortable}, "..hidden", "hidden"},
+		{"dotfiles allowed", NameSanitizer{Mode: NameSanitizePortable, AllowDotfiles: true}, ".env", ".env"},
+		{"directories stripped", NameSanitizer{Mode: NameSanitizePortable}, `C:\Users\me\photo.jpg`, "photo.jpg"},
+		{"directories stripped when off", NameSanitizer{Mode: NameSanitizeOff}, "../../etc/passwd", "passwd"},
+		{"off keeps characters", NameSanitizer{Mode: NameSanitizeOff}, "a\x01:b", "a\x01:b"},
+		{"off with a dot-dot", NameSanitizer{Mode: NameSanitizeOff}, "..", "file"},
+		{"nothing left", NameSanitizer{Mode: NameSanitizePortable}, " \x00... ", "file"},
+		{"invalid UTF-8", NameSanitizer{Mode: NameSanitizePOSIX}, "a\xffb.txt", "a_b.txt"},
+		{"too long", NameSanitizer{Mode: NameSanitizePOSIX}, long, strings.Repeat("é", 125) + ".txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.sanitizer.Sanitize(tt.in)
+			if got != tt.want {
+				t.Fatalf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if got == "" || strings.ContainsAny(got, `/\`) || len(got) > maxNameBytes || !utf8.ValidString(got) {
+				t.Fatalf("Sanitize(%q) = %q is not a storable name", tt.in, got)
+			}
+		})
+	}
+}
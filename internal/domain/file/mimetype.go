@@ -0,0 +1,139 @@
+package file
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Category groups MIME types for filtering listings
+type Category string
+
+const (
+	CategoryImage    Category = "image"
+	CategoryVideo    Category = "video"
+	CategoryAudio    Category = "audio"
+	CategoryDocument Category = "document"
+	CategoryOther    Category = "other"
+)
+
+// Valid reports whether c can be used as a listing filter
+func (c Category) Valid() bool {
+	switch c {
+	case CategoryImage, CategoryVideo, CategoryAudio, CategoryDocument:
+		return true
+	}
+	return false
+}
+
+// CategoryOf classifies a file by the MIME type of its extension
+func CategoryOf(filename string) Category {
+	contentType := ContentType(filename)
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return CategoryImage
+	case strings.HasPrefix(contentType, "video/"):
+		return CategoryVideo
+	case strings.HasPrefix(contentType, "audio/"):
+		return CategoryAudio
+	case contentType == "application/pdf",
+		contentType == "text/plain",
+		contentType == "text/csv",
+		contentType == "text/markdown",
+		strings.HasPrefix(contentType, "application/msword"),
+		strings.HasPrefix(contentType, "application/vnd.ms-"),
+		strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument."),
+		strings.HasPrefix(contentType, "application/vnd.oasis.opendocument."):
+		return CategoryDocument
+	}
+	return CategoryOther
+}
+
+// NoExtension is the type key of files without an extension
+const NoExtension = "no extension"
+
+// TypeKey is the key a file is counted under in storage stats: its
+// lowercased extension with the dot, or NoExtension
+func TypeKey(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return NoExtension
+	}
+	return ext
+}
+
+// ContentType returns the MIME type based on file extension
+func ContentType(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	// Images
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".svg":
+		return "image/svg+xml"
+	case ".bmp":
+		return "image/bmp"
+	case ".ico":
+		return "image/x-icon"
+	// Videos
+	case ".mp4":
+		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".ogg":
+		return "video/ogg"
+	case ".mov":
+		return "video/quicktime"
+	// Audio
+	case ".mp3":
+		return "audio/mpeg"
+	case ".wav":
+		return "audio/wav"
+	case ".flac":
+		return "audio/flac"
+	case ".aac":
+		return "audio/aac"
+	case ".m4a":
+		return "audio/mp4"
+	// Documents
+	case ".pdf":
+		return "application/pdf"
+	case ".txt":
+		return "text/plain"
+	case ".html", ".htm":
+		return "text/html"
+	case ".css":
+		return "text/css"
+	case ".js":
+		return "application/javascript"
+	case ".json":
+		return "application/json"
+	case ".xml":
+		return "application/xml"
+	case ".csv":
+		return "text/csv"
+	case ".md":
+		return "text/markdown"
+	case ".doc":
+		return "application/msword"
+	case ".docx":
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case ".xls":
+		return "application/vnd.ms-excel"
+	case ".xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case ".ppt":
+		return "application/vnd.ms-powerpoint"
+	case ".pptx":
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	case ".odt":
+		return "application/vnd.oasis.opendocument.text"
+	default:
+		return "application/octet-stream"
+	}
+}
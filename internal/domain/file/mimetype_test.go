@@ -0,0 +1,40 @@
+package file
+
+import "testing"
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		name string
+		want Category
+	}{
+		{"holiday.jpg", CategoryImage},
+		{"Logo.PNG", CategoryImage},
+		{"clip.mp4", CategoryVideo},
+		{"song.mp3", CategoryAudio},
+		{"report.pdf", CategoryDocument},
+		{"notes.txt", CategoryDocument},
+		{"budget.xlsx", CategoryDocument},
+		{"letter.docx", CategoryDocument},
+		{"archive.zip", CategoryOther},
+		{"Makefile", CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := CategoryOf(tt.name); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCategoryValid(t *testing.T) {
+	for _, c := range []Category{CategoryImage, CategoryVideo, CategoryAudio, CategoryDocument} {
+		if !c.Valid() {
+			t.Errorf("%q is invalid", c)
+		}
+	}
+	// "other" is what's left over, not something to filter by
+	for _, c := range []Category{CategoryOther, "", "images"} {
+		if c.Valid() {
+			t.Errorf("%q is valid", c)
+		}
+	}
+}
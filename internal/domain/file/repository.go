@@ -1,15 +1,69 @@
 package file
 
-import "mime/multipart"
+import (
+	"io"
+	"mime/multipart"
+	"time"
+)
 
 // Repository defines the contract for file storage operations
 type Repository interface {
 	List(path string) ([]FileInfo, error)
-	GetFilePath(relativePath string) (string, error)
-	Save(path string, files []*multipart.FileHeader) ([]string, error)
+	// Open returns a seekable reader over a file so ranges can be served
+	Open(path string) (io.ReadSeekCloser, *FileInfo, error)
+	Stat(path string) (*FileInfo, error)
+	Save(path string, files []*multipart.FileHeader, policy ConflictPolicy) (*UploadResult, error)
+	// Import takes ownership of a local file, storing it in dir as filename
+	// (or a name chosen by policy) and returning the stored relative path
+	Import(localPath, dir, filename string, policy ConflictPolicy) (string, error)
 	CreateDirectory(path string) error
 	Delete(path string) error
+	Move(source, destination string) error
+	Copy(source, destination string) error
 	Exists(path string) (bool, error)
 	IsDirectory(path string) (bool, error)
 	GetStats(excludePaths []string) (*StorageStats, error)
+	// Walk calls fn for root (unless it is the storage root) and everything
+	// below it, skipping excludePaths. Entries that can't be read are skipped
+	// too, and the walk then ends with ErrWalkIncomplete.
+	Walk(root string, excludePaths []string, fn func(info FileInfo) error) error
+	SetModTime(path string, modTime time.Time, recursive bool) error
+	DirSize(path string, maxDepth int, deadline time.Time) (size int64, complete bool, err error)
+	// WriteTar streams the directory at path as a tar archive, with entries
+	// under prefix and skipping excludePaths (relative to the storage root)
+	WriteTar(path, prefix string, w io.Writer, excludePaths []string) error
+}
+
+// IDIndex maps stable file IDs to current paths so references such as shares
+// survive moves and renames
+type IDIndex interface {
+	GetOrCreate(path string) (string, error)
+	Resolve(id string) (string, error)
+	Rename(oldPath, newPath string) error
+	Remove(path string) error
+	// Prune drops entries created before before whose path fails keep and
+	// returns how many went
+	Prune(before time.Time, keep func(path string) bool) (int, error)
+}
+
+// MetadataStore keeps file metadata keyed by file ID (see IDIndex), so it
+// follows moves and goes away with the ID. Reads go by current path so
+// untagged files never need an ID.
+type MetadataStore interface {
+	// Get returns the metadata for path, empty when none is set
+	Get(path string) (*Metadata, error)
+	// Set replaces all metadata for the file ID id
+	Set(id string, meta Metadata) error
+	// Children returns metadata for the direct children of dir that have
+	// any, keyed by path
+	Children(dir string) (map[string]*Metadata, error)
+}
+
+// DownloadHistory records the files each user downloads
+type DownloadHistory interface {
+	// Record adds a download, dropping the user's entries beyond
+	// MaxDownloadHistory
+	Record(userID, path string, at time.Time) error
+	// Recent returns the user's latest downloads, newest first
+	Recent(userID string, limit int) ([]Download, error)
 }
@@ -0,0 +1,14 @@
+package file
+
+// FileScanner inspects an uploaded file before it becomes visible in storage.
+// Scan returns nil for clean files; any error rejects the file.
+type FileScanner interface {
+	Scan(path string) error
+}
+
+// PlacementNotifier is implemented by repositories that may place scanned
+// uploads after Save returns. fn is called with the storage path of each
+// file placed that way.
+type PlacementNotifier interface {
+	OnPlaced(fn func(path string))
+}
@@ -0,0 +1,40 @@
+package file
+
+import (
+	"io"
+	"time"
+)
+
+// PendingUpload is a resumable upload that hasn't received all its bytes yet
+type PendingUpload struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Path      string    `json:"path"`     // Target directory
+	Filename  string    `json:"filename"` // Name to store the file under
+	Length    int64     `json:"length"`
+	Offset    int64     `json:"offset"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"-"` // Zero when pending uploads never expire
+}
+
+// IsComplete reports whether every byte has been received
+func (u *PendingUpload) IsComplete() bool {
+	return u.Offset >= u.Length
+}
+
+// UploadStore persists resumable uploads until they are complete
+type UploadStore interface {
+	Create(upload *PendingUpload) error
+	// Get returns a pending upload; one left without new data past its
+	// expiry is reported as ErrUploadNotFound
+	Get(id string) (*PendingUpload, error)
+	// Append writes data at offset, which must equal the stored offset, and
+	// returns the new offset. Bytes received before an error are kept.
+	Append(id string, offset int64, data io.Reader) (int64, error)
+	// Complete moves a finished upload into storage and returns its path
+	Complete(id string, policy ConflictPolicy) (string, error)
+	// Receive stages data in one pass, vets it and moves it into dir as
+	// filename (or a name chosen by policy), returning the stored path.
+	// Read errors from data are returned as is.
+	Receive(dir, filename string, data io.Reader, policy ConflictPolicy) (string, error)
+}
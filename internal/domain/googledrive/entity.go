@@ -12,6 +12,14 @@ type DriveFile struct {
 	WebViewLink  string            `json:"web_view_link"`
 	Permissions  []FilePermission  `json:"permissions"`
 	Properties   map[string]string `json:"properties"`
+	Owners       []FileOwner       `json:"owners,omitempty"`
+	Capabilities map[string]bool   `json:"capabilities,omitempty"` // What the caller may do, e.g. canEdit
+}
+
+// FileOwner is a user who owns a file
+type FileOwner struct {
+	DisplayName  string `json:"display_name"`
+	EmailAddress string `json:"email_address,omitempty"`
 }
 
 // DriveFolder represents a folder in Google Drive
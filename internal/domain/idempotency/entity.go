@@ -0,0 +1,23 @@
+package idempotency
+
+import "time"
+
+// Response is the stored outcome of the first request made with an
+// Idempotency-Key, replayed for retries that reuse the key
+type Response struct {
+	UserID      string
+	Key         string
+	Method      string
+	Path        string
+	RequestHash string // Fingerprint of the request body
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// Matches reports whether a retry is the same request as the stored one;
+// keys must not be reused for a different endpoint or body
+func (r *Response) Matches(method, path, requestHash string) bool {
+	return r.Method == method && r.Path == path && r.RequestHash == requestHash
+}
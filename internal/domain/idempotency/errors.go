@@ -0,0 +1,7 @@
+package idempotency
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("idempotency key not found")
+)
@@ -0,0 +1,15 @@
+package idempotency
+
+import "time"
+
+// Repository stores responses by user and key for a limited time
+type Repository interface {
+	// Get returns the response stored for the user's key since the cutoff,
+	// or ErrNotFound
+	Get(userID, key string, since time.Time) (*Response, error)
+	// Save stores a response. A response already stored for the key is kept
+	// unless it was stored before expiredBefore.
+	Save(response *Response, expiredBefore time.Time) error
+	// DeleteBefore removes responses stored before cutoff
+	DeleteBefore(cutoff time.Time) error
+}
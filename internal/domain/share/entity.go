@@ -1,6 +1,11 @@
 package share
 
-import "time"
+import (
+	"crypto/subtle"
+	"net/url"
+	"strings"
+	"time"
+)
 
 // ShareType represents the type of share
 type ShareType string
@@ -8,8 +13,19 @@ type ShareType string
 const (
 	ShareTypePublic   ShareType = "public"   // Anyone with link
 	ShareTypePassword ShareType = "password" // Requires password
+
+	ShareTypeAuthenticated ShareType = "authenticated" // Requires a logged-in user
 )
 
+// Valid reports whether t is a known share type
+func (t ShareType) Valid() bool {
+	switch t {
+	case ShareTypePublic, ShareTypePassword, ShareTypeAuthenticated:
+		return true
+	}
+	return false
+}
+
 // Permission represents what the share allows
 type Permission string
 
@@ -18,6 +34,11 @@ const (
 	PermissionDownload Permission = "download"
 )
 
+// Valid reports whether p is a known permission
+func (p Permission) Valid() bool {
+	return p == PermissionView || p == PermissionDownload
+}
+
 // Share represents a shared file or folder link
 type Share struct {
 	ID           string     `json:"id"`
@@ -32,6 +53,19 @@ type Share struct {
 	Downloads    int        `json:"downloads"`
 	CreatedAt    time.Time  `json:"createdAt"`
 	IsActive     bool       `json:"isActive"`
+	AllowedUsers []string   `json:"allowedUsers,omitempty"` // Restricts authenticated shares to these user IDs
+	Title        string     `json:"title"`
+	Description  string     `json:"description,omitempty"`
+	Message      string     `json:"message,omitempty"`   // Instructions for recipients, shown once they have access
+	FileID       string     `json:"fileId,omitempty"`    // Stable reference that follows moves of Path
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"` // Set when soft-deleted; the row is kept for stats
+
+	// AllowedReferrers restricts access to requests from these sites (hosts,
+	// or *.domain for subdomains) as reported by Origin or Referer
+	AllowedReferrers []string `json:"allowedReferrers,omitempty"`
+
+	// NotifyOnAccess sends the owner a notification when the share is downloaded
+	NotifyOnAccess bool `json:"notifyOnAccess"`
 }
 
 // ShareResponse is the safe share representation for API responses
@@ -46,7 +80,15 @@ type ShareResponse struct {
 	Downloads    int        `json:"downloads"`
 	CreatedAt    time.Time  `json:"createdAt"`
 	IsActive     bool       `json:"isActive"`
+	AllowedUsers []string   `json:"allowedUsers,omitempty"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description,omitempty"`
+	Message      string     `json:"message,omitempty"`
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"`
 	URL          string     `json:"url"`
+
+	AllowedReferrers []string `json:"allowedReferrers,omitempty"`
+	NotifyOnAccess   bool     `json:"notifyOnAccess"`
 }
 
 // CreateShareRequest represents a request to create a share
@@ -57,6 +99,39 @@ type CreateShareRequest struct {
 	Permission   Permission `json:"permission"`
 	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
 	MaxDownloads *int       `json:"maxDownloads,omitempty"`
+	AllowedUsers []string   `json:"allowedUsers,omitempty"`
+	Title        string     `json:"title,omitempty"` // Defaults to the base name of the path
+	Description  string     `json:"description,omitempty"`
+	Message      string     `json:"message,omitempty"` // Shown to recipients; behind the password on password shares
+
+	// AllowedReferrers limits access to embeds and links on these sites
+	AllowedReferrers []string `json:"allowedReferrers,omitempty"`
+
+	// NotifyOnAccess asks for a notification whenever the share is downloaded
+	NotifyOnAccess bool `json:"notifyOnAccess,omitempty"`
+
+	// ExpiresInHours sets the expiry relative to the server clock instead of ExpiresAt
+	ExpiresInHours *int `json:"expiresInHours,omitempty"`
+
+	// ReuseExisting returns an existing active share for the same path, type
+	// and permission owned by the caller instead of creating a duplicate
+	ReuseExisting bool `json:"reuseExisting,omitempty"`
+}
+
+// ListFilter narrows ListAll; zero values match everything
+type ListFilter struct {
+	ShareType ShareType
+	Active    *bool
+	Deleted   *bool
+}
+
+// Summary holds aggregate metrics over a user's shares
+type Summary struct {
+	TotalShares    int             `json:"totalShares"`
+	ActiveShares   int             `json:"activeShares"`   // Not deactivated; may still be expired
+	TotalDownloads int             `json:"totalDownloads"` // Includes soft-deleted shares
+	DeletedShares  int             `json:"deletedShares"`  // Soft-deleted, not counted in TotalShares
+	TopShares      []ShareResponse `json:"topShares"`
 }
 
 // AccessShareRequest represents a request to access a password-protected share
@@ -77,7 +152,15 @@ func (s *Share) ToResponse(baseURL string) ShareResponse {
 		Downloads:    s.Downloads,
 		CreatedAt:    s.CreatedAt,
 		IsActive:     s.IsActive,
+		AllowedUsers: s.AllowedUsers,
+		Title:        s.Title,
+		Description:  s.Description,
+		Message:      s.Message,
+		DeletedAt:    s.DeletedAt,
 		URL:          baseURL + "/s/" + s.Token,
+
+		AllowedReferrers: s.AllowedReferrers,
+		NotifyOnAccess:   s.NotifyOnAccess,
 	}
 }
 
@@ -101,3 +184,62 @@ func (s *Share) HasReachedMaxDownloads() bool {
 func (s *Share) IsValid() bool {
 	return s.IsActive && !s.IsExpired() && !s.HasReachedMaxDownloads()
 }
+
+// IsAllowedUser returns true if the user may access an authenticated share
+func (s *Share) IsAllowedUser(userID string) bool {
+	if len(s.AllowedUsers) == 0 || s.CreatedBy == userID {
+		return true
+	}
+	for _, id := range s.AllowedUsers {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferrerAllowed reports whether a request whose Origin or Referer is
+// source may access the share. Shares without allowed referrers accept any.
+func (s *Share) ReferrerAllowed(source string) bool {
+	if len(s.AllowedReferrers) == 0 {
+		return true
+	}
+	host := ReferrerHost(source)
+	if host == "" {
+		return false
+	}
+	for _, pattern := range s.AllowedReferrers {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferrerHost returns the lowercase host (and port, if any) of an Origin or
+// Referer value, or of a bare host; "" when there is none
+func ReferrerHost(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return ""
+	}
+	if !strings.Contains(v, "://") {
+		v = "http://" + v
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// CheckPassword reports whether password unlocks the share
+func (s *Share) CheckPassword(password string) bool {
+	return subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) == 1
+}
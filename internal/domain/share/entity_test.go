@@ -0,0 +1,69 @@
+package share
+
+import "testing"
+
+func TestShareTypeValid(t *testing.T) {
+	tests := []struct {
+		in   ShareType
+		want bool
+	}{
+		{ShareTypePublic, true},
+		{ShareTypePassword, true},
+		{ShareTypeAuthenticated, true},
+		{"", false},
+		{"Public", false},
+		{"private", false},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Valid(); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPermissionValid(t *testing.T) {
+	tests := []struct {
+		in   Permission
+		want bool
+	}{
+		{PermissionView, true},
+		{PermissionDownload, true},
+		{"", false},
+		{"edit", false},
+		{"VIEW", false},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Valid(); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReferrerAllowed(t *testing.T) {
+	s := &Share{AllowedReferrers: []string{"blog.example.com", "*.cdn.example.net", "localhost:8080"}}
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"https://blog.example.com/posts/1", true},
+		{"https://BLOG.example.com", true},
+		{"blog.example.com", true},
+		{"https://img.cdn.example.net/a.png", true},
+		{"https://a.b.cdn.example.net", true},
+		{"https://cdn.example.net", false}, // The wildcard only covers subdomains
+		{"https://evilcdn.example.net", false},
+		{"https://blog.example.com.evil.test", false},
+		{"http://localhost:8080/page", true},
+		{"http://localhost:9090/page", false},
+		{"https://example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := s.ReferrerAllowed(tt.source); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.source, got, tt.want)
+		}
+	}
+	if !(&Share{}).ReferrerAllowed("https://anywhere.test") {
+		t.Error("a share without allowed referrers rejected a request")
+	}
+}
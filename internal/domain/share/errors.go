@@ -11,4 +11,6 @@ var (
 	ErrPasswordRequired = errors.New("password required")
 	ErrInvalidPath      = errors.New("invalid path")
 	ErrPermissionDenied = errors.New("permission denied")
+
+	ErrShareLimitReached = errors.New("share limit reached")
 )
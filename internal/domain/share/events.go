@@ -0,0 +1,21 @@
+package share
+
+import "time"
+
+// AccessEvent reports a download through a share whose owner asked to be
+// notified
+type AccessEvent struct {
+	ShareID    string    `json:"shareId"`
+	Title      string    `json:"title"`
+	Path       string    `json:"path"`
+	OwnerID    string    `json:"ownerId"`
+	OwnerEmail string    `json:"ownerEmail,omitempty"`
+	IP         string    `json:"ip"`
+	Time       time.Time `json:"time"`
+}
+
+// AccessNotifier delivers share access notifications. It is called off the
+// request path, so it may block while sending.
+type AccessNotifier interface {
+	NotifyAccess(event AccessEvent) error
+}
@@ -1,13 +1,43 @@
 package share
 
-// Repository defines the contract for share storage operations
+import "time"
+
+// Repository defines the contract for share storage operations. Soft-deleted
+// shares are only returned by ListAll and GetAllByUser; the other lookups
+// treat them as missing.
 type Repository interface {
 	Create(share *Share) error
+	// CreateWithinLimit creates the share unless its owner already has
+	// maxActive active shares (0 = unlimited), returning ErrShareLimitReached
+	CreateWithinLimit(share *Share, maxActive int) error
 	GetByID(id string) (*Share, error)
 	GetByToken(token string) (*Share, error)
 	GetByUser(userID string) ([]Share, error)
+	// GetAllByUser returns the user's shares including soft-deleted ones
+	GetAllByUser(userID string) ([]Share, error)
 	GetByPath(path string) ([]Share, error)
+	// GetExpiredByUser returns the user's shares that are expired or have hit max downloads
+	GetExpiredByUser(userID string) ([]Share, error)
+	// ListAll pages through every user's shares, newest first, returning the total match count
+	ListAll(offset, limit int, filter ListFilter) ([]Share, int, error)
+	// GetSummaryByUser aggregates share and download counts for the user
+	GetSummaryByUser(userID string) (*Summary, error)
+	// GetTopDownloadedByUser returns the user's limit most-downloaded shares
+	GetTopDownloadedByUser(userID string, limit int) ([]Share, error)
 	Update(share *Share) error
+	// UpdatePathPrefix points shares of oldPath, or of anything under it, at
+	// the same place under newPath and returns how many changed
+	UpdatePathPrefix(oldPath, newPath string) (int, error)
+	// SetOwner makes userID the owner of the shares with the given IDs,
+	// soft-deleted ones included, and returns how many changed. Nothing
+	// changes, and ErrShareLimitReached is returned, if userID would end up
+	// with more than maxActive active shares (0 = unlimited).
+	SetOwner(ids []string, userID string, maxActive int) (int, error)
+	// SoftDelete deactivates the share and hides it while keeping the row
+	SoftDelete(id string) error
+	// Delete removes the share permanently, whether or not it was soft-deleted
 	Delete(id string) error
+	// PurgeDeleted permanently removes shares soft-deleted before cutoff
+	PurgeDeleted(cutoff time.Time) (int, error)
 	IncrementDownloads(id string) error
 }
@@ -72,6 +72,27 @@ type UpdateUserRequest struct {
 	Role     Role   `json:"role,omitempty"`
 }
 
+// Capabilities lists what a role may do, so clients can gate their UI on
+// the same rules the server enforces
+type Capabilities struct {
+	CanRead        bool `json:"canRead"`
+	CanUpload      bool `json:"canUpload"`
+	CanDelete      bool `json:"canDelete"`
+	CanShare       bool `json:"canShare"`
+	CanManageUsers bool `json:"canManageUsers"`
+}
+
+// Capabilities returns the role's permissions; every role may read
+func (r Role) Capabilities() Capabilities {
+	return Capabilities{
+		CanRead:        true,
+		CanUpload:      r.CanUpload(),
+		CanDelete:      r.CanDelete(),
+		CanShare:       r.CanShare(),
+		CanManageUsers: r.CanManageUsers(),
+	}
+}
+
 // CanManageUsers returns true if the role can manage other users
 func (r Role) CanManageUsers() bool {
 	return r == RoleAdmin
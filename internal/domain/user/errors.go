@@ -6,9 +6,13 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserAlreadyExists  = errors.New("user already exists")
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account temporarily locked")
 	ErrInvalidEmail       = errors.New("invalid email")
 	ErrInvalidUsername    = errors.New("invalid username")
 	ErrInvalidPassword    = errors.New("password must be at least 6 characters")
 	ErrUnauthorized       = errors.New("unauthorized")
 	ErrForbidden          = errors.New("forbidden")
+	ErrRegistrationClosed = errors.New("registration is disabled")
+	ErrInviteRequired     = errors.New("an invite code is required")
+	ErrInvalidInvite      = errors.New("invite code is invalid or already used")
 )
@@ -10,5 +10,8 @@ type Repository interface {
 	Update(user *User) error
 	Delete(id string) error
 	List() ([]User, error)
+	// ListPaged returns one page of users matching search (username or email)
+	// and role (empty matches all), plus the total number of matches
+	ListPaged(offset, limit int, search, roleFilter string) ([]User, int, error)
 	Count() (int, error)
 }
@@ -0,0 +1,88 @@
+package captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gomanager/internal/domain/auth"
+)
+
+// Supported providers
+const (
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderReCaptcha = "recaptcha"
+)
+
+// verifyURLs are the providers' siteverify endpoints; both take the same form
+// and answer with the same shape
+var verifyURLs = map[string]string{
+	ProviderHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	ProviderReCaptcha: "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// ErrUnknownProvider is returned for providers other than hcaptcha and recaptcha
+var ErrUnknownProvider = errors.New("unknown captcha provider")
+
+// siteVerifier checks tokens against a provider's siteverify endpoint
+type siteVerifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// New returns a verifier for provider using its secret key. An empty provider
+// disables CAPTCHA checks; an empty verifyURL uses the provider's own endpoint.
+func New(provider, secret, verifyURL string, timeout time.Duration) (auth.CaptchaVerifier, error) {
+	if provider == "" {
+		return auth.NoCaptcha{}, nil
+	}
+	defaultURL, ok := verifyURLs[strings.ToLower(provider)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	if verifyURL == "" {
+		verifyURL = defaultURL
+	}
+	if secret == "" {
+		return nil, errors.New("captcha secret is required")
+	}
+	return &siteVerifier{url: verifyURL, secret: secret, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// Verify returns auth.ErrCaptchaFailed for a missing or rejected token, and
+// other errors when the provider can't be reached
+func (v *siteVerifier) Verify(token, ip string) error {
+	if token == "" {
+		return auth.ErrCaptchaFailed
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if ip != "" {
+		form.Set("remoteip", ip)
+	}
+	resp, err := v.client.PostForm(v.url, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha verify returned %s", resp.Status)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding captcha verify response: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", auth.ErrCaptchaFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package captcha
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gomanager/internal/domain/auth"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		secret   string
+		wantErr  error // nil: any error, unless wantNone or wantSite
+		wantNone bool
+		wantSite bool
+	}{
+		{"disabled", "", "", nil, true, false},
+		{"hcaptcha", "hcaptcha", "s", nil, false, true},
+		{"recaptcha, any case", "ReCaptcha", "s", nil, false, true},
+		{"unknown provider", "turnstile", "s", ErrUnknownProvider, false, false},
+		{"missing secret", "hcaptcha", "", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := New(tt.provider, tt.secret, "", 0)
+			switch {
+			case tt.wantNone:
+				if _, ok := v.(auth.NoCaptcha); !ok || err != nil {
+					t.Fatalf("got %T, %v; want NoCaptcha", v, err)
+				}
+			case tt.wantSite:
+				if _, ok := v.(*siteVerifier); !ok || err != nil {
+					t.Fatalf("got %T, %v; want a siteverify client", v, err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err == nil {
+					t.Fatal("got no error")
+				}
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		status     int
+		body       string
+		wantErr    bool
+		wantFailed bool // The error is auth.ErrCaptchaFailed
+		wantCalled bool
+	}{
+		{"accepted", "tok", http.StatusOK, `{"success":true}`, false, false, true},
+		{"rejected", "tok", http.StatusOK, `{"success":false,"error-codes":["invalid-input-response"]}`, true, true, true},
+		{"missing token", "", http.StatusOK, `{"success":true}`, true, true, false},
+		{"provider down", "tok", http.StatusBadGateway, ``, true, false, true},
+		{"malformed answer", "tok", http.StatusOK, `<html>`, true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if r.PostFormValue("secret") != "s3cret" || r.PostFormValue("response") != tt.token || r.PostFormValue("remoteip") != "10.0.0.1" {
+					t.Errorf("unexpected form %v", r.PostForm)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			v, err := New(ProviderHCaptcha, "s3cret", srv.URL, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = v.Verify(tt.token, "10.0.0.1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, auth.ErrCaptchaFailed) != tt.wantFailed {
+				t.Fatalf("got %v, want ErrCaptchaFailed %v", err, tt.wantFailed)
+			}
+			if called != tt.wantCalled {
+				t.Fatalf("provider called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
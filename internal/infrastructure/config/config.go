@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -18,12 +19,168 @@ type Config struct {
 	MaxFileSize  int64
 	DatabasePath string
 	BaseURL      string
-	TokenExpiry  int // hours
-	FrontendURL  string
+
+	// Path prefix the API is mounted under behind a reverse proxy (e.g.
+	// "/gomanager"); empty serves from the root
+	APIBasePath string
+
+	// Database connection pool limits (0 keeps the backend default)
+	DBMaxOpenConns int
+	DBMaxIdleConns int
+
+	// How often to VACUUM a SQLite database, as a duration like "24h"
+	// (empty or 0 disables)
+	DBVacuumInterval string
+
+	// Where files live: "filesystem" (under StoragePath) or "s3"
+	StorageBackend string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3Prefix       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3PathStyle    bool
+
+	TokenExpiry int // hours
+	FrontendURL string
+
+	// Directory of a built frontend to serve at / (empty disables)
+	StaticDir string
+
+	// Serve the storage over WebDAV at /dav/
+	WebDAVEnabled bool
+
+	// Build image thumbnails in the background right after upload
+	PregenerateThumbnails bool
+
+	// Upload scanning: blocked extensions and an optional clamd address
+	BlockedUploadExtensions []string
+	ClamAVAddr              string
+	ClamAVTimeout           int  // seconds
+	UploadScanAsync         bool // Respond before scanning; files stay quarantined until clean
+
+	// Hours a resumable upload may go without new data before it is
+	// removed; 0 keeps them
+	ResumableUploadExpiry int
+
+	// Deepest directory (in path segments) mkdir and uploads may use; 0 disables
+	MaxPathDepth int
+
+	// Extra storage paths hidden like the built-in internal folders
+	HiddenPaths []string
+
+	// Upload name cleaning: off, posix or portable; leading dots are
+	// stripped unless dotfiles are allowed
+	UploadNameSanitize      string
+	UploadNameAllowDotfiles bool
+
+	// Concurrent stats walks, directory sizes, archives and reindexes (0 = no
+	// limit); extra requests queue for up to the timeout (seconds) before a 503
+	MaxConcurrentHeavyOps int
+	HeavyOpsQueueTimeout  int
+
+	// How long (hours) responses to POSTs with an Idempotency-Key are
+	// replayed for retries; 0 disables
+	IdempotencyKeyTTL int
+
+	// Directory listing cache (entries, 0 disables; TTL in seconds)
+	ListingCacheSize int
+	ListingCacheTTL  int
+
+	// Avatars larger than this (pixels per side) are downscaled
+	AvatarMaxDimension int
+
+	// Copy Google profile pictures into local avatar storage on OAuth login,
+	// downloading only from AvatarRemoteHosts (subdomains included)
+	CacheRemoteAvatars bool
+	AvatarRemoteHosts  []string
+
+	// Per-role upload size overrides (bytes, 0 falls back to MaxFileSize)
+	MaxFileSizeAdmin int64
+	MaxFileSizeUser  int64
+	// Bytes of a multipart upload kept in memory before the rest goes to temp files
+	MultipartMemoryLimit int64
+
+	// Share lifetimes (hours, 0 disables)
+	MaxShareLifetime     int
+	DefaultShareLifetime int
+	ClampShareLifetime   bool // Clamp expiries beyond the max instead of rejecting
+
+	// Type and permission of shares created without one
+	DefaultShareType       string
+	DefaultSharePermission string
+
+	// Active shares a user may hold at once (0 = unlimited)
+	MaxSharesPerUser int
+
+	// Whether logged-in viewers may download files through shares
+	ViewerCanDownloadShares bool
+
+	// Whether shares restricted to referrers accept requests that send
+	// neither Origin nor Referer
+	ShareAllowNoReferrer bool
+
+	// Webhook that receives download notifications for shares with
+	// notifyOnAccess set (empty disables them), and its signing secret
+	ShareAccessWebhook string
+	ShareWebhookSecret string
+
+	// HTTP server timeouts (seconds, 0 disables)
+	ReadHeaderTimeout int
+	ReadTimeout       int
+	WriteTimeout      int
+	IdleTimeout       int
+
+	// Token mode: opaque DB sessions or stateless JWTs
+	TokenMode   string
+	JWTSecret   string
+	JWTDenylist bool // Check a revocation list so logout works in JWT mode
+
+	// Per-account lockout after consecutive failed logins (0 failures disables)
+	LoginMaxFailures       int
+	LoginLockoutSeconds    int
+	LoginLockoutMaxSeconds int
+
+	// Signed download links; an empty secret is random per process
+	DownloadSigningSecret string
+	SignedURLMaxTTL       int // seconds, 0 = no limit
+
+	// Algorithm for new password hashes (bcrypt or argon2id)
+	PasswordHashAlgo string
+
+	// Self-service sign-up: disabled entirely, or "open" vs "invite" only
+	RegistrationEnabled bool
+	RegistrationMode    string
+	// Require a solved CAPTCHA (hcaptcha or recaptcha) to register
+	CaptchaProvider string
+	CaptchaSecret   string
+	// Overrides the provider's siteverify endpoint, e.g. for a proxy or tests
+	CaptchaVerifyURL string
+
+	// Send Access-Control-Allow-Credentials for allowed origins
+	CORSAllowCredentials bool
+	// Block unlisted origins on unauthenticated API routes instead of
+	// echoing them; authenticated routes always block them
+	CORSStrictOrigins bool
+
+	// Session cookies (in addition to bearer tokens)
+	SessionCookie         bool
+	SessionCookieSecure   bool
+	SessionCookieSameSite string // lax, strict or none
+
+	// Proxies (CIDRs or IPs) allowed to set X-Forwarded-For / X-Real-IP
+	TrustedProxies []string
+
+	// Networks (CIDRs or IPs) allowed to reach /api/admin/*; empty allows any
+	AdminIPAllowlist []string
 
 	// Google OAuth
 	GoogleClientID     string
 	GoogleClientSecret string
+	OAuthStateSecret   string   // Signs OAuth state for account linking
+	GoogleScopes       []string // Scopes requested during consent
+	GoogleHTTPTimeout  int      // seconds per call to a Google API (0 = no limit)
 
 	// Google Drive
 	GoogleDriveFolder string
@@ -39,17 +196,112 @@ func Load() *Config {
 		StoragePath:             getEnv("STORAGE_PATH", "./storage"),
 		MaxFileSize:             getEnvAsInt64("MAX_FILE_SIZE", 100<<20),                                // 100MB default
 		DatabasePath:            getEnv("DATABASE_URL", getEnv("DATABASE_PATH", "./data/gomanager.db")), // Support both DATABASE_URL (PostgreSQL) and DATABASE_PATH (SQLite)
+		StorageBackend:          getEnv("STORAGE_BACKEND", "filesystem"),
+		S3Endpoint:              getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:                getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:                getEnv("S3_BUCKET", ""),
+		S3Prefix:                getEnv("S3_PREFIX", ""),
+		S3AccessKey:             getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:             getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:             getEnv("S3_PATH_STYLE", "false") == "true",
+		DBMaxOpenConns:          int(getEnvAsInt64("DB_MAX_OPEN_CONNS", 0)),
+		DBMaxIdleConns:          int(getEnvAsInt64("DB_MAX_IDLE_CONNS", 0)),
+		DBVacuumInterval:        getEnv("DB_VACUUM_INTERVAL", ""),
 		BaseURL:                 getEnv("BASE_URL", "http://localhost:8005"),
+		APIBasePath:             normalizeBasePath(getEnv("API_BASE_PATH", "")),
 		TokenExpiry:             int(getEnvAsInt64("TOKEN_EXPIRY_HOURS", 24)),
 		FrontendURL:             getEnv("FRONTEND_URL", "http://localhost:5173"),
+		StaticDir:               getEnv("STATIC_DIR", ""),
+		WebDAVEnabled:           getEnv("WEBDAV_ENABLED", "false") == "true",
+		BlockedUploadExtensions: getEnvAsList("UPLOAD_BLOCKED_EXTENSIONS", nil),
+		ClamAVAddr:              getEnv("CLAMAV_ADDR", ""),
+		ClamAVTimeout:           int(getEnvAsInt64("CLAMAV_TIMEOUT", 30)),
+		UploadScanAsync:         getEnv("UPLOAD_SCAN_ASYNC", "false") == "true",
+		ResumableUploadExpiry:   int(getEnvAsInt64("RESUMABLE_UPLOAD_EXPIRY_HOURS", 24)),
+		PregenerateThumbnails:   getEnv("PREGENERATE_THUMBNAILS", "false") == "true",
+		MaxPathDepth:            int(getEnvAsInt64("MAX_PATH_DEPTH", 32)),
+		HiddenPaths:             getEnvAsList("HIDDEN_PATHS", nil),
+		UploadNameSanitize:      getEnv("UPLOAD_NAME_SANITIZE", "portable"),
+		UploadNameAllowDotfiles: getEnv("UPLOAD_NAME_ALLOW_DOTFILES", "false") == "true",
+		MaxConcurrentHeavyOps:   int(getEnvAsInt64("MAX_CONCURRENT_HEAVY_OPS", 4)),
+		HeavyOpsQueueTimeout:    int(getEnvAsInt64("HEAVY_OPS_QUEUE_TIMEOUT_SECONDS", 10)),
+		IdempotencyKeyTTL:       int(getEnvAsInt64("IDEMPOTENCY_KEY_TTL_HOURS", 24)),
+		ListingCacheSize:        int(getEnvAsInt64("LISTING_CACHE_SIZE", 0)),
+		ListingCacheTTL:         int(getEnvAsInt64("LISTING_CACHE_TTL_SECONDS", 30)),
+		AvatarMaxDimension:      int(getEnvAsInt64("AVATAR_MAX_DIMENSION", 512)),
+		CacheRemoteAvatars:      getEnv("CACHE_REMOTE_AVATARS", "false") == "true",
+		AvatarRemoteHosts:       getEnvAsList("AVATAR_REMOTE_HOSTS", []string{"googleusercontent.com"}),
+		MaxFileSizeAdmin:        getEnvAsInt64("MAX_FILE_SIZE_ADMIN", 0),
+		MaxFileSizeUser:         getEnvAsInt64("MAX_FILE_SIZE_USER", 0),
+		MultipartMemoryLimit:    getEnvAsInt64("MULTIPART_MEMORY_LIMIT", 8<<20),
+		MaxShareLifetime:        int(getEnvAsInt64("MAX_SHARE_LIFETIME_HOURS", 0)),
+		DefaultShareLifetime:    int(getEnvAsInt64("DEFAULT_SHARE_LIFETIME_HOURS", 0)),
+		ClampShareLifetime:      getEnv("SHARE_LIFETIME_EXCEEDED", "clamp") == "clamp",
+		DefaultShareType:        getEnv("DEFAULT_SHARE_TYPE", "public"),
+		DefaultSharePermission:  getEnv("DEFAULT_SHARE_PERMISSION", "download"),
+		MaxSharesPerUser:        int(getEnvAsInt64("MAX_SHARES_PER_USER", 0)),
+		ViewerCanDownloadShares: getEnv("VIEWER_CAN_DOWNLOAD_SHARES", "true") == "true",
+		ShareAllowNoReferrer:    getEnv("SHARE_MISSING_REFERRER", "allow") == "allow",
+		ShareAccessWebhook:      getEnv("SHARE_ACCESS_WEBHOOK_URL", ""),
+		ShareWebhookSecret:      getEnv("SHARE_ACCESS_WEBHOOK_SECRET", ""),
+		ReadHeaderTimeout:       int(getEnvAsInt64("HTTP_READ_HEADER_TIMEOUT", 10)),
+		ReadTimeout:             int(getEnvAsInt64("HTTP_READ_TIMEOUT", 60)),
+		WriteTimeout:            int(getEnvAsInt64("HTTP_WRITE_TIMEOUT", 120)),
+		IdleTimeout:             int(getEnvAsInt64("HTTP_IDLE_TIMEOUT", 120)),
 		GoogleClientID:          getEnv("GOOGLE_CLIENT_ID", ""),
 		GoogleClientSecret:      getEnv("GOOGLE_CLIENT_SECRET", ""),
+		TokenMode:               getEnv("TOKEN_MODE", "session"),
+		JWTSecret:               getEnv("JWT_SECRET", ""),
+		JWTDenylist:             getEnv("JWT_DENYLIST", "true") == "true",
+		LoginMaxFailures:        int(getEnvAsInt64("LOGIN_MAX_FAILURES", 5)),
+		LoginLockoutSeconds:     int(getEnvAsInt64("LOGIN_LOCKOUT_SECONDS", 30)),
+		LoginLockoutMaxSeconds:  int(getEnvAsInt64("LOGIN_LOCKOUT_MAX_SECONDS", 3600)),
+		DownloadSigningSecret:   getEnv("DOWNLOAD_SIGNING_SECRET", ""),
+		SignedURLMaxTTL:         int(getEnvAsInt64("SIGNED_URL_MAX_TTL_SECONDS", 7*24*3600)),
+		PasswordHashAlgo:        getEnv("PASSWORD_HASH_ALGO", "bcrypt"),
+		RegistrationEnabled:     getEnv("REGISTRATION_ENABLED", "true") == "true",
+		RegistrationMode:        getEnv("REGISTRATION_MODE", "open"),
+		CaptchaProvider:         getEnv("CAPTCHA_PROVIDER", ""),
+		CaptchaSecret:           getEnv("CAPTCHA_SECRET", ""),
+		CaptchaVerifyURL:        getEnv("CAPTCHA_VERIFY_URL", ""),
+		CORSAllowCredentials:    getEnv("CORS_ALLOW_CREDENTIALS", "true") == "true",
+		CORSStrictOrigins:       getEnv("CORS_STRICT_ORIGINS", "false") == "true",
+		SessionCookie:           getEnv("SESSION_COOKIE", "false") == "true",
+		SessionCookieSecure:     getEnv("SESSION_COOKIE_SECURE", "true") == "true",
+		SessionCookieSameSite:   getEnv("SESSION_COOKIE_SAMESITE", "lax"),
+		TrustedProxies:          getEnvAsList("TRUSTED_PROXIES", nil),
+		AdminIPAllowlist:        getEnvAsList("ADMIN_IP_ALLOWLIST", nil),
+		GoogleScopes:            getEnvAsList("GOOGLE_SCOPES", defaultGoogleScopes),
+		GoogleHTTPTimeout:       int(getEnvAsInt64("GOOGLE_HTTP_TIMEOUT", 15)),
+		OAuthStateSecret:        getEnv("OAUTH_STATE_SECRET", getEnv("GOOGLE_CLIENT_SECRET", "")),
 		GoogleDriveFolder:       getEnv("GOOGLE_DRIVE_FOLDER", "GoManager"),
 		GoogleAdsCustomerID:     getEnv("GOOGLE_ADS_CUSTOMER_ID", ""),
 		GoogleAdsDeveloperToken: getEnv("GOOGLE_ADS_DEVELOPER_TOKEN", ""),
 	}
 }
 
+// PublicURL is the external URL of the API root: BaseURL plus the base path.
+// Links handed to clients are built from it.
+func (c *Config) PublicURL() string {
+	return strings.TrimRight(c.BaseURL, "/") + c.APIBasePath
+}
+
+// normalizeBasePath gives a base path one leading slash and no trailing one;
+// "" and "/" both mean the root
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// defaultGoogleScopes only identifies the user; services need extra scopes via GOOGLE_SCOPES
+var defaultGoogleScopes = []string{
+	"https://www.googleapis.com/auth/userinfo.email",
+	"https://www.googleapis.com/auth/userinfo.profile",
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -65,3 +317,18 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	}
 	return defaultValue
 }
+
+func getEnvAsList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
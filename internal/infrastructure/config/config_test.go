@@ -0,0 +1,56 @@
+package config
+
+import "testing"
+
+func TestCORSStrictOriginsDefault(t *testing.T) {
+	for _, tt := range []struct {
+		env  string
+		want bool
+	}{
+		{"", false},
+		{"true", true},
+		{"false", false},
+	} {
+		t.Setenv("CORS_STRICT_ORIGINS", tt.env)
+		if got := Load().CORSStrictOrigins; got != tt.want {
+			t.Errorf("CORS_STRICT_ORIGINS=%q: strict %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultShareSettings(t *testing.T) {
+	for _, tt := range []struct {
+		shareType, permission    string
+		wantType, wantPermission string
+	}{
+		{"", "", "public", "download"},
+		{"password", "view", "password", "view"},
+		{"authenticated", "", "authenticated", "download"},
+	} {
+		t.Setenv("DEFAULT_SHARE_TYPE", tt.shareType)
+		t.Setenv("DEFAULT_SHARE_PERMISSION", tt.permission)
+		cfg := Load()
+		if cfg.DefaultShareType != tt.wantType || cfg.DefaultSharePermission != tt.wantPermission {
+			t.Errorf("DEFAULT_SHARE_TYPE=%q DEFAULT_SHARE_PERMISSION=%q: got %q, %q", tt.shareType, tt.permission, cfg.DefaultShareType, cfg.DefaultSharePermission)
+		}
+	}
+}
+
+func TestAPIBasePath(t *testing.T) {
+	for _, tt := range []struct {
+		env, want, wantURL string
+	}{
+		{"", "", "https://example.com"},
+		{"/", "", "https://example.com"},
+		{"gomanager", "/gomanager", "https://example.com/gomanager"},
+		{" /gomanager/ ", "/gomanager", "https://example.com/gomanager"},
+		{"/apps/files/", "/apps/files", "https://example.com/apps/files"},
+	} {
+		t.Setenv("API_BASE_PATH", tt.env)
+		t.Setenv("BASE_URL", "https://example.com/")
+		cfg := Load()
+		if cfg.APIBasePath != tt.want || cfg.PublicURL() != tt.wantURL {
+			t.Errorf("API_BASE_PATH=%q: base %q, public URL %q; want %q, %q", tt.env, cfg.APIBasePath, cfg.PublicURL(), tt.want, tt.wantURL)
+		}
+	}
+}
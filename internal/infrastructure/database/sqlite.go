@@ -26,17 +26,33 @@ const (
 	PostgreSQL DatabaseType = "postgres"
 )
 
+// PoolConfig holds connection pool limits; zero keeps the backend default
+type PoolConfig struct {
+	MaxOpenConns int
+	MaxIdleConns int
+}
+
+// apply sets any non-zero limits on db
+func (p PoolConfig) apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+}
+
 // NewDatabase creates a new database connection based on the connection string
-func NewDatabase(connectionString string) (*DB, error) {
+func NewDatabase(connectionString string, pool PoolConfig) (*DB, error) {
 	if strings.HasPrefix(connectionString, "postgresql://") || strings.HasPrefix(connectionString, "postgres://") {
-		return NewPostgreSQL(connectionString)
+		return NewPostgreSQL(connectionString, pool)
 	}
 	// Default to SQLite
-	return New(connectionString)
+	return New(connectionString, pool)
 }
 
 // NewPostgreSQL creates a new PostgreSQL database connection
-func NewPostgreSQL(connectionString string) (*DB, error) {
+func NewPostgreSQL(connectionString string, pool PoolConfig) (*DB, error) {
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
@@ -49,19 +65,25 @@ func NewPostgreSQL(connectionString string) (*DB, error) {
 	// Set connection pool settings for production
 	db.SetMaxOpenConns(25)
 	db.SetMaxIdleConns(5)
+	pool.apply(db)
 
 	return &DB{db, "postgres"}, nil
 }
 
+// sqliteDSNParams enables WAL so readers don't block behind a writer, and a
+// busy timeout so concurrent writers wait for the lock instead of failing
+// with "database is locked"
+const sqliteDSNParams = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000"
+
 // New creates a new database connection
-func New(dbPath string) (*DB, error) {
+func New(dbPath string, pool PoolConfig) (*DB, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
+	db, err := sql.Open("sqlite3", dbPath+"?"+sqliteDSNParams)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -70,6 +92,8 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	pool.apply(db)
+
 	return &DB{db, "sqlite"}, nil
 }
 
@@ -119,8 +143,64 @@ func (db *DB) MigrateSQLite() error {
 			downloads INTEGER DEFAULT 0,
 			is_active BOOLEAN DEFAULT 1,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			allowed_users TEXT,
+			title TEXT,
+			description TEXT,
+			message TEXT,
+			file_id TEXT,
+			deleted_at DATETIME,
+			allowed_referrers TEXT,
+			notify_on_access BOOLEAN DEFAULT 0,
 			FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		// Stable file IDs so shares survive moves and renames
+		`CREATE TABLE IF NOT EXISTS file_ids (
+			id TEXT PRIMARY KEY,
+			path TEXT UNIQUE NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// User tags ("tag" rows) and key/value pairs, dropped with the file ID
+		`CREATE TABLE IF NOT EXISTS file_metadata (
+			file_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (file_id, key, value),
+			FOREIGN KEY (file_id) REFERENCES file_ids(id) ON DELETE CASCADE
+		)`,
+		// JWT logout denylist, pruned once tokens expire
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti TEXT PRIMARY KEY,
+			expires_at DATETIME NOT NULL
+		)`,
+		// First responses to requests sent with an Idempotency-Key, replayed
+		// for retries until they expire
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status_code INTEGER NOT NULL,
+			content_type TEXT NOT NULL,
+			body BLOB,
+			created_at INTEGER NOT NULL, -- Unix seconds
+			PRIMARY KEY (user_id, key)
+		)`,
+		// Each user's most recent downloads
+		`CREATE TABLE IF NOT EXISTS download_history (
+			user_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			downloaded_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		// Single-use registration invites
+		`CREATE TABLE IF NOT EXISTS invites (
+			code TEXT PRIMARY KEY,
+			created_by TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			used_by TEXT,
+			used_at DATETIME
+		)`,
 		// New table for Google Drive integration
 		`CREATE TABLE IF NOT EXISTS google_drive_folders (
 			id TEXT PRIMARY KEY,
@@ -156,6 +236,14 @@ func (db *DB) MigrateSQLite() error {
 		`ALTER TABLE users ADD COLUMN google_id TEXT`,
 		`ALTER TABLE users ADD COLUMN google_token TEXT`,
 		`ALTER TABLE users ADD COLUMN avatar_url TEXT`,
+		`ALTER TABLE shares ADD COLUMN allowed_users TEXT`,
+		`ALTER TABLE shares ADD COLUMN title TEXT`,
+		`ALTER TABLE shares ADD COLUMN description TEXT`,
+		`ALTER TABLE shares ADD COLUMN message TEXT`,
+		`ALTER TABLE shares ADD COLUMN file_id TEXT`,
+		`ALTER TABLE shares ADD COLUMN deleted_at DATETIME`,
+		`ALTER TABLE shares ADD COLUMN allowed_referrers TEXT`,
+		`ALTER TABLE shares ADD COLUMN notify_on_access BOOLEAN DEFAULT 0`,
 	}
 
 	// Index creation (must run after ALTER TABLE for google_id)
@@ -164,12 +252,14 @@ func (db *DB) MigrateSQLite() error {
 		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_shares_token ON shares(token)`,
 		`CREATE INDEX IF NOT EXISTS idx_shares_created_by ON shares(created_by)`,
+		`CREATE INDEX IF NOT EXISTS idx_shares_file_id ON shares(file_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_drive_folders_user_id ON google_drive_folders(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_drive_folders_folder_id ON google_drive_folders(folder_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_ads_campaigns_user_id ON google_ads_campaigns(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_ads_campaigns_customer_id ON google_ads_campaigns(customer_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_download_history_user ON download_history(user_id, downloaded_at)`,
 	}
 
 	// 1. Create tables
@@ -191,6 +281,21 @@ func (db *DB) MigrateSQLite() error {
 		}
 	}
 
+	// 4. Assign file IDs to shares created before the index existed
+	backfillMigrations := []string{
+		`INSERT INTO file_ids (id, path)
+		 SELECT lower(hex(randomblob(16))), p FROM (
+			SELECT DISTINCT trim(path, '/') AS p FROM shares WHERE file_id IS NULL
+		 ) WHERE p != '' AND p NOT IN (SELECT path FROM file_ids)`,
+		`UPDATE shares SET file_id = (SELECT id FROM file_ids WHERE file_ids.path = trim(shares.path, '/'))
+		 WHERE file_id IS NULL`,
+	}
+	for _, migration := range backfillMigrations {
+		if _, err := db.Exec(migration); err != nil {
+			return fmt.Errorf("file ID backfill failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -232,8 +337,64 @@ func (db *DB) MigratePostgreSQL() error {
 			downloads INTEGER DEFAULT 0,
 			is_active BOOLEAN DEFAULT true,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			allowed_users TEXT,
+			title TEXT,
+			description TEXT,
+			message TEXT,
+			file_id TEXT,
+			deleted_at TIMESTAMP,
+			allowed_referrers TEXT,
+			notify_on_access BOOLEAN DEFAULT false,
 			FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
 		)`,
+		// Stable file IDs so shares survive moves and renames
+		`CREATE TABLE IF NOT EXISTS file_ids (
+			id TEXT PRIMARY KEY,
+			path TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// User tags ("tag" rows) and key/value pairs, dropped with the file ID
+		`CREATE TABLE IF NOT EXISTS file_metadata (
+			file_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			PRIMARY KEY (file_id, key, value),
+			FOREIGN KEY (file_id) REFERENCES file_ids(id) ON DELETE CASCADE
+		)`,
+		// JWT logout denylist, pruned once tokens expire
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti TEXT PRIMARY KEY,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		// First responses to requests sent with an Idempotency-Key, replayed
+		// for retries until they expire
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			user_id TEXT NOT NULL,
+			key TEXT NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status_code INTEGER NOT NULL,
+			content_type TEXT NOT NULL,
+			body BYTEA,
+			created_at BIGINT NOT NULL, -- Unix seconds
+			PRIMARY KEY (user_id, key)
+		)`,
+		// Each user's most recent downloads
+		`CREATE TABLE IF NOT EXISTS download_history (
+			user_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			downloaded_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)`,
+		// Single-use registration invites
+		`CREATE TABLE IF NOT EXISTS invites (
+			code TEXT PRIMARY KEY,
+			created_by TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			used_by TEXT,
+			used_at TIMESTAMP
+		)`,
 		// New table for Google Drive integration
 		`CREATE TABLE IF NOT EXISTS google_drive_folders (
 			id TEXT PRIMARY KEY,
@@ -262,18 +423,32 @@ func (db *DB) MigratePostgreSQL() error {
 		)`,
 	}
 
+	// Add columns if they don't exist (for existing databases)
+	alterMigrations := []string{
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS allowed_users TEXT`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS title TEXT`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS description TEXT`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS message TEXT`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS file_id TEXT`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS allowed_referrers TEXT`,
+		`ALTER TABLE shares ADD COLUMN IF NOT EXISTS notify_on_access BOOLEAN DEFAULT false`,
+	}
+
 	// Index creation
 	indexMigrations := []string{
 		`CREATE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_shares_token ON shares(token)`,
 		`CREATE INDEX IF NOT EXISTS idx_shares_created_by ON shares(created_by)`,
+		`CREATE INDEX IF NOT EXISTS idx_shares_file_id ON shares(file_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_google_id ON users(google_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_drive_folders_user_id ON google_drive_folders(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_drive_folders_folder_id ON google_drive_folders(folder_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_ads_campaigns_user_id ON google_ads_campaigns(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_google_ads_campaigns_customer_id ON google_ads_campaigns(customer_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_download_history_user ON download_history(user_id, downloaded_at)`,
 	}
 
 	// 1. Create tables
@@ -283,13 +458,35 @@ func (db *DB) MigratePostgreSQL() error {
 		}
 	}
 
-	// 2. Create indexes
+	// 2. Add columns
+	for _, migration := range alterMigrations {
+		if _, err := db.Exec(migration); err != nil {
+			return fmt.Errorf("PostgreSQL column migration failed: %w", err)
+		}
+	}
+
+	// 3. Create indexes
 	for _, migration := range indexMigrations {
 		if _, err := db.Exec(migration); err != nil {
 			return fmt.Errorf("PostgreSQL index creation failed: %w", err)
 		}
 	}
 
+	// 4. Assign file IDs to shares created before the index existed
+	backfillMigrations := []string{
+		`INSERT INTO file_ids (id, path)
+		 SELECT md5(random()::text || clock_timestamp()::text || p), p FROM (
+			SELECT DISTINCT trim(both '/' from path) AS p FROM shares WHERE file_id IS NULL
+		 ) paths WHERE p != '' AND p NOT IN (SELECT path FROM file_ids)`,
+		`UPDATE shares SET file_id = (SELECT id FROM file_ids WHERE file_ids.path = trim(both '/' from shares.path))
+		 WHERE file_id IS NULL`,
+	}
+	for _, migration := range backfillMigrations {
+		if _, err := db.Exec(migration); err != nil {
+			return fmt.Errorf("PostgreSQL file ID backfill failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
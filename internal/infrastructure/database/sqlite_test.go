@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentWrites(t *testing.T) {
+	db, err := New(filepath.Join(t.TempDir(), "test.db"), PoolConfig{MaxOpenConns: 10, MaxIdleConns: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "wal" {
+		t.Errorf("journal mode %q, want wal", mode)
+	}
+	if _, err := db.Exec(`CREATE TABLE writes (id INTEGER PRIMARY KEY, data TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each writer holds its write lock for a moment, so without the busy
+	// timeout the others fail with "database is locked"
+	const writers, rows = 20, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tx, err := db.Begin()
+			if err != nil {
+				errs <- err
+				return
+			}
+			for j := 0; j < rows; j++ {
+				if _, err := tx.Exec(`INSERT INTO writes (data) VALUES (?)`, fmt.Sprintf("%d-%d", i, j)); err != nil {
+					tx.Rollback()
+					errs <- err
+					return
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			errs <- tx.Commit()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM writes`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != writers*rows {
+		t.Errorf("%d rows written, want %d", n, writers*rows)
+	}
+}
+
+func TestPoolConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		pool     PoolConfig
+		wantOpen int
+	}{
+		{"backend default", PoolConfig{}, 0},
+		{"limited", PoolConfig{MaxOpenConns: 3, MaxIdleConns: 2}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := New(filepath.Join(t.TempDir(), "test.db"), tt.pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if got := db.Stats().MaxOpenConnections; got != tt.wantOpen {
+				t.Errorf("max open connections %d, want %d", got, tt.wantOpen)
+			}
+		})
+	}
+}
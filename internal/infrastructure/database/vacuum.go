@@ -0,0 +1,102 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrVacuumUnsupported is returned on backends that manage their own storage
+	ErrVacuumUnsupported = errors.New("vacuum is only supported for SQLite")
+	// ErrDatabaseBusy is returned when other queries are running; VACUUM
+	// rewrites the whole file and would hold up writers until it finishes
+	ErrDatabaseBusy = errors.New("database is busy")
+)
+
+// vacuumMu keeps scheduled and admin-triggered runs from overlapping
+var vacuumMu sync.Mutex
+
+// VacuumResult reports a compaction's effect on the database file
+type VacuumResult struct {
+	SizeBefore int64   `json:"sizeBefore"` // Bytes
+	SizeAfter  int64   `json:"sizeAfter"`
+	Duration   float64 `json:"duration"` // Seconds
+}
+
+// Vacuum rebuilds the SQLite file to release space left by deleted rows,
+// then refreshes the query planner's statistics. It refuses to start while
+// other queries are in flight so it doesn't stall request traffic.
+func (db *DB) Vacuum() (*VacuumResult, error) {
+	if db.dbType != "sqlite" {
+		return nil, ErrVacuumUnsupported
+	}
+	if !vacuumMu.TryLock() {
+		return nil, ErrDatabaseBusy
+	}
+	defer vacuumMu.Unlock()
+
+	if db.Stats().InUse > 0 {
+		return nil, ErrDatabaseBusy
+	}
+
+	start := time.Now()
+	before, err := db.fileSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return nil, fmt.Errorf("vacuum failed: %w", err)
+	}
+	// In WAL mode the rewritten pages sit in the log until a checkpoint
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return nil, fmt.Errorf("checkpoint failed: %w", err)
+	}
+	if _, err := db.Exec(`PRAGMA optimize`); err != nil {
+		return nil, fmt.Errorf("optimize failed: %w", err)
+	}
+
+	after, err := db.fileSize()
+	if err != nil {
+		return nil, err
+	}
+	return &VacuumResult{SizeBefore: before, SizeAfter: after, Duration: time.Since(start).Seconds()}, nil
+}
+
+// fileSize is the size of the main database file as SQLite sees it
+func (db *DB) fileSize() (int64, error) {
+	var pageCount, pageSize int64
+	if err := db.QueryRow(`PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return 0, err
+	}
+	if err := db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pageCount * pageSize, nil
+}
+
+// StartVacuum compacts a SQLite database every interval. Runs that find the
+// database busy are skipped until the next tick. Other backends are left alone.
+func (db *DB) StartVacuum(interval time.Duration) {
+	if db.dbType != "sqlite" || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			result, err := db.Vacuum()
+			switch {
+			case errors.Is(err, ErrDatabaseBusy):
+				log.Printf("database vacuum skipped: database busy")
+			case err != nil:
+				log.Printf("database vacuum failed: %v", err)
+			default:
+				log.Printf("database vacuum: %d -> %d bytes in %.3fs", result.SizeBefore, result.SizeAfter, result.Duration)
+			}
+		}
+	}()
+}
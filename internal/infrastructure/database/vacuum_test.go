@@ -0,0 +1,65 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVacuumShrinksAfterDeletes(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"), PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE churn (data TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	row := strings.Repeat("x", 4096)
+	for i := 0; i < 500; i++ {
+		if _, err := db.Exec(`INSERT INTO churn (data) VALUES (?)`, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`DELETE FROM churn`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.Vacuum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SizeAfter >= result.SizeBefore || result.SizeBefore < 500*4096 {
+		t.Fatalf("size %d -> %d, want the deleted rows released", result.SizeBefore, result.SizeAfter)
+	}
+
+	// Durations are reported in seconds, not nanoseconds
+	data, _ := json.Marshal(result)
+	var fields map[string]float64
+	if err := json.Unmarshal(data, &fields); err != nil || fields["duration"] < 0 || fields["duration"] > 60 {
+		t.Fatalf("reported %s", data)
+	}
+}
+
+func TestVacuumSkipsWhileBusy(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"), PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Vacuum()
+	rows.Close()
+	if !errors.Is(err, ErrDatabaseBusy) {
+		t.Fatalf("got %v, want ErrDatabaseBusy", err)
+	}
+	if _, err := db.Vacuum(); err != nil {
+		t.Fatalf("after the query finished: %v", err)
+	}
+}
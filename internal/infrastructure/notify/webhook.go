@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gomanager/internal/domain/share"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body when a secret is set
+const SignatureHeader = "X-GoManager-Signature"
+
+// webhookNotifier posts notifications as JSON to a fixed URL
+type webhookNotifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookNotifier sends share access events to url. With a secret, each
+// request is signed so the receiver can check it came from this server.
+func NewWebhookNotifier(url, secret string, timeout time.Duration) share.AccessNotifier {
+	return &webhookNotifier{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (n *webhookNotifier) NotifyAccess(event share.AccessEvent) error {
+	body, err := json.Marshal(struct {
+		Type string `json:"type"`
+		share.AccessEvent
+	}{Type: "share.accessed", AccessEvent: event})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(n.secret) > 0 {
+		mac := hmac.New(sha256.New, n.secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/share"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		status  int
+		wantErr bool
+	}{
+		{"signed", "s3cret", http.StatusNoContent, false},
+		{"unsigned", "", http.StatusOK, false},
+		{"receiver error", "", http.StatusInternalServerError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var signature, contentType string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				signature, contentType = r.Header.Get(SignatureHeader), r.Header.Get("Content-Type")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			at := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+			err := NewWebhookNotifier(srv.URL, tt.secret, time.Second).NotifyAccess(share.AccessEvent{ShareID: "s1", Path: "docs/a.txt", OwnerID: "owner", IP: "203.0.113.7", Time: at})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+
+			var got struct {
+				Type string
+				share.AccessEvent
+			}
+			if err := json.Unmarshal(body, &got); err != nil || contentType != "application/json" {
+				t.Fatalf("body %s (%s): %v", body, contentType, err)
+			}
+			if got.Type != "share.accessed" || got.ShareID != "s1" || got.Path != "docs/a.txt" || got.IP != "203.0.113.7" || !got.Time.Equal(at) {
+				t.Fatalf("posted %+v", got)
+			}
+
+			want := ""
+			if tt.secret != "" {
+				mac := hmac.New(sha256.New, []byte(tt.secret))
+				mac.Write(body)
+				want = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			}
+			if signature != want {
+				t.Fatalf("signature %q, want %q", signature, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !windows
+
+package repository
+
+import "errors"
+
+// diskSpace isn't available on this platform; stats leave capacity at zero
+func diskSpace(path string) (total, available uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
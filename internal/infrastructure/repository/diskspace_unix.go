@@ -0,0 +1,21 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package repository
+
+import "syscall"
+
+// diskSpace returns the total and available bytes of the filesystem holding path
+func diskSpace(path string) (total, available uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	// Field types vary by platform, and BSDs report negative free space
+	// once the root reserve is in use
+	bsize := uint64(stat.Bsize)
+	avail := int64(stat.Bavail)
+	if avail < 0 {
+		avail = 0
+	}
+	return uint64(stat.Blocks) * bsize, uint64(avail) * bsize, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/database"
+)
+
+type downloadHistoryRepository struct {
+	db *database.DB
+}
+
+// NewDownloadHistoryRepository creates the per-user download history store
+func NewDownloadHistoryRepository(db *database.DB) domain.DownloadHistory {
+	return &downloadHistoryRepository{db: db}
+}
+
+// getPlaceholderQuery converts a query template with %s placeholders to the correct database syntax
+func (r *downloadHistoryRepository) getPlaceholderQuery(queryTemplate string, paramCount int) string {
+	placeholders := make([]interface{}, paramCount)
+	for i := 0; i < paramCount; i++ {
+		if r.db.GetType() == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(queryTemplate, placeholders...)
+}
+
+func (r *downloadHistoryRepository) Record(userID, filePath string, at time.Time) error {
+	query := r.getPlaceholderQuery(`INSERT INTO download_history (user_id, path, downloaded_at) VALUES (%s, %s, %s)`, 3)
+	if _, err := r.db.Exec(query, userID, filePath, at); err != nil {
+		return err
+	}
+
+	// Trim to the newest MaxDownloadHistory entries
+	query = r.getPlaceholderQuery(`DELETE FROM download_history WHERE user_id = %s AND downloaded_at < (
+		SELECT downloaded_at FROM download_history WHERE user_id = %s
+		 ORDER BY downloaded_at DESC LIMIT 1 OFFSET %s)`, 3)
+	_, err := r.db.Exec(query, userID, userID, domain.MaxDownloadHistory-1)
+	return err
+}
+
+func (r *downloadHistoryRepository) Recent(userID string, limit int) ([]domain.Download, error) {
+	query := r.getPlaceholderQuery(`SELECT path, downloaded_at FROM download_history WHERE user_id = %s
+		 ORDER BY downloaded_at DESC LIMIT %s`, 2)
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	downloads := []domain.Download{}
+	for rows.Next() {
+		var d domain.Download
+		if err := rows.Scan(&d.Path, &d.DownloadedAt); err != nil {
+			return nil, err
+		}
+		d.Name = path.Base(d.Path)
+		downloads = append(downloads, d)
+	}
+	return downloads, rows.Err()
+}
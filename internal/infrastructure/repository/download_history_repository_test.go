@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestDownloadHistory(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "u1")
+	newTestUser(t, db, "u2")
+	history := NewDownloadHistoryRepository(db)
+
+	start := time.Now().Add(-time.Hour)
+	for i := range domain.MaxDownloadHistory + 5 {
+		if err := history.Record("u1", fmt.Sprintf("docs/f%d.txt", i), start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := history.Record("u2", "other.txt", start); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		limit  int
+		want   []string // Names, newest first
+		count  int
+	}{
+		{"newest first", "u1", 2, []string{"f104.txt", "f103.txt"}, 2},
+		{"trimmed to the maximum", "u1", 1000, nil, domain.MaxDownloadHistory},
+		{"other user", "u2", 10, []string{"other.txt"}, 1},
+		{"no history", "nobody", 10, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := history.Recent(tt.userID, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.count {
+				t.Fatalf("got %d downloads, want %d", len(got), tt.count)
+			}
+			for i, name := range tt.want {
+				if got[i].Name != name || (tt.userID == "u1" && got[i].Path != "docs/"+name) {
+					t.Fatalf("download %d = %+v, want %s", i, got[i], name)
+				}
+			}
+			if tt.count == domain.MaxDownloadHistory && got[len(got)-1].Name != "f5.txt" {
+				t.Fatalf("oldest kept is %s, want f5.txt", got[len(got)-1].Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	domain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/database"
+)
+
+type fileIndexRepository struct {
+	db *database.DB
+}
+
+// NewFileIndexRepository creates a new file ID index backed by the database
+func NewFileIndexRepository(db *database.DB) domain.IDIndex {
+	return &fileIndexRepository{db: db}
+}
+
+// getPlaceholderQuery converts a query template with %s placeholders to the correct database syntax
+func (r *fileIndexRepository) getPlaceholderQuery(queryTemplate string, paramCount int) string {
+	placeholders := make([]interface{}, paramCount)
+	for i := 0; i < paramCount; i++ {
+		if r.db.GetType() == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(queryTemplate, placeholders...)
+}
+
+// normalizeIndexPath stores paths slash-separated without a leading slash
+func normalizeIndexPath(p string) string {
+	cleaned := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if cleaned == "." {
+		return ""
+	}
+	return cleaned
+}
+
+// textLen is the length of s as SQL's substr counts it: in characters, not
+// bytes, so prefixes with non-ASCII names line up
+func textLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// GetOrCreate returns the ID for path, assigning one if needed. The storage
+// root has no ID.
+func (r *fileIndexRepository) GetOrCreate(p string) (string, error) {
+	p = normalizeIndexPath(p)
+	if p == "" {
+		return "", nil
+	}
+
+	var id string
+	err := r.db.QueryRow(r.getPlaceholderQuery(`SELECT id FROM file_ids WHERE path = %s`, 1), p).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	id = uuid.New().String()
+	_, err = r.db.Exec(
+		r.getPlaceholderQuery(`INSERT INTO file_ids (id, path, created_at) VALUES (%s, %s, %s)`, 3),
+		id, p, time.Now(),
+	)
+	if err != nil {
+		// A concurrent request may have indexed the path first
+		var existing string
+		if lookupErr := r.db.QueryRow(r.getPlaceholderQuery(`SELECT id FROM file_ids WHERE path = %s`, 1), p).Scan(&existing); lookupErr == nil {
+			return existing, nil
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+// Resolve returns the current path for id
+func (r *fileIndexRepository) Resolve(id string) (string, error) {
+	var p string
+	err := r.db.QueryRow(r.getPlaceholderQuery(`SELECT path FROM file_ids WHERE id = %s`, 1), id).Scan(&p)
+	if err == sql.ErrNoRows {
+		return "", domain.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// Rename points oldPath and everything below it at newPath
+func (r *fileIndexRepository) Rename(oldPath, newPath string) error {
+	oldPath = normalizeIndexPath(oldPath)
+	newPath = normalizeIndexPath(newPath)
+	if oldPath == "" || newPath == "" {
+		return domain.ErrInvalidPath
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		r.getPlaceholderQuery(`UPDATE file_ids SET path = %s WHERE path = %s`, 2),
+		newPath, oldPath,
+	); err != nil {
+		return err
+	}
+
+	// substr avoids LIKE so names containing % or _ match literally
+	prefix := oldPath + "/"
+	if _, err := tx.Exec(
+		r.getPlaceholderQuery(`UPDATE file_ids SET path = CAST(%s AS TEXT) || substr(path, %s) WHERE substr(path, 1, %s) = %s`, 4),
+		newPath+"/", textLen(prefix)+1, textLen(prefix), prefix,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Remove drops the entries for path and everything below it
+func (r *fileIndexRepository) Remove(p string) error {
+	p = normalizeIndexPath(p)
+	if p == "" {
+		return domain.ErrInvalidPath
+	}
+
+	prefix := p + "/"
+	_, err := r.db.Exec(
+		r.getPlaceholderQuery(`DELETE FROM file_ids WHERE path = %s OR substr(path, 1, %s) = %s`, 3),
+		p, textLen(prefix), prefix,
+	)
+	return err
+}
+
+func (r *fileIndexRepository) Prune(before time.Time, keep func(path string) bool) (int, error) {
+	rows, err := r.db.Query(`SELECT path, created_at FROM file_ids`)
+	if err != nil {
+		return 0, err
+	}
+	var stale []string
+	for rows.Next() {
+		var p string
+		var createdAt time.Time
+		if err := rows.Scan(&p, &createdAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		// Compared here rather than in SQL, where SQLite would compare text
+		if createdAt.Before(before) && !keep(p) {
+			stale = append(stale, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Delete after reading so SQLite isn't asked to write mid-query
+	pruned := 0
+	for _, p := range stale {
+		if _, err := r.db.Exec(r.getPlaceholderQuery(`DELETE FROM file_ids WHERE path = %s`, 1), p); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestFileIndexPruneKeepsNewerEntries(t *testing.T) {
+	db := newTestDB(t)
+	index := NewFileIndexRepository(db)
+	for _, p := range []string{"old-gone", "old-kept"} {
+		if _, err := index.GetOrCreate(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := index.GetOrCreate("new-gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := index.Prune(before, func(p string) bool { return p == "old-kept" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Fatalf("pruned %d, want 1", pruned)
+	}
+	for _, tt := range []struct {
+		path string
+		kept bool
+	}{
+		{"old-gone", false},
+		{"old-kept", true},
+		{"new-gone", true},
+	} {
+		var n int
+		db.QueryRow(`SELECT COUNT(*) FROM file_ids WHERE path = ?`, tt.path).Scan(&n)
+		if (n == 1) != tt.kept {
+			t.Errorf("%s kept = %v, want %v", tt.path, n == 1, tt.kept)
+		}
+	}
+}
+
+func TestFileIndexNonASCIIPrefixes(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     func(index domain.IDIndex) error
+		before string
+		after  string // Empty when the entry should be gone
+	}{
+		{"rename moves children", func(i domain.IDIndex) error { return i.Rename("Fotos/Año", "Fotos/Year") }, "Fotos/Año/a.jpg", "Fotos/Year/a.jpg"},
+		{"rename to a non-ASCII name", func(i domain.IDIndex) error { return i.Rename("docs", "Überblick") }, "docs/b.txt", "Überblick/b.txt"},
+		{"rename leaves siblings", func(i domain.IDIndex) error { return i.Rename("Fotos/Año", "Fotos/Year") }, "Fotos/Año2/a.jpg", "Fotos/Año2/a.jpg"},
+		{"remove drops children", func(i domain.IDIndex) error { return i.Remove("Fotos/Año") }, "Fotos/Año/a.jpg", ""},
+		{"remove leaves siblings", func(i domain.IDIndex) error { return i.Remove("Fotos/Año") }, "Fotos/Año2/a.jpg", "Fotos/Año2/a.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewFileIndexRepository(newTestDB(t))
+			id, err := index.GetOrCreate(tt.before)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.op(index); err != nil {
+				t.Fatal(err)
+			}
+			got, err := index.Resolve(id)
+			if tt.after == "" {
+				if !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("resolved to %q, %v; want it gone", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.after {
+				t.Fatalf("resolved to %q, %v; want %q", got, err, tt.after)
+			}
+		})
+	}
+}
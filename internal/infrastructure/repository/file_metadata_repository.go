@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	domain "gomanager/internal/domain/file"
+	"gomanager/internal/infrastructure/database"
+)
+
+type fileMetadataRepository struct {
+	db *database.DB
+}
+
+// NewFileMetadataRepository creates a new file metadata store backed by the database
+func NewFileMetadataRepository(db *database.DB) domain.MetadataStore {
+	return &fileMetadataRepository{db: db}
+}
+
+// getPlaceholderQuery converts a query template with %s placeholders to the correct database syntax
+func (r *fileMetadataRepository) getPlaceholderQuery(queryTemplate string, paramCount int) string {
+	placeholders := make([]interface{}, paramCount)
+	for i := 0; i < paramCount; i++ {
+		if r.db.GetType() == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(queryTemplate, placeholders...)
+}
+
+func (r *fileMetadataRepository) Get(p string) (*domain.Metadata, error) {
+	meta := &domain.Metadata{Tags: []string{}, Values: map[string]string{}}
+	rows, err := r.db.Query(
+		r.getPlaceholderQuery(`SELECT m.key, m.value FROM file_metadata m JOIN file_ids f ON f.id = m.file_id WHERE f.path = %s ORDER BY m.key, m.value`, 1),
+		normalizeIndexPath(p),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		addMetadataRow(meta, key, value)
+	}
+	return meta, rows.Err()
+}
+
+func (r *fileMetadataRepository) Set(id string, meta domain.Metadata) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.getPlaceholderQuery(`DELETE FROM file_metadata WHERE file_id = %s`, 1), id); err != nil {
+		return err
+	}
+
+	insert := r.getPlaceholderQuery(`INSERT INTO file_metadata (file_id, key, value) VALUES (%s, %s, %s)`, 3)
+	for _, tag := range meta.Tags {
+		if _, err := tx.Exec(insert, id, domain.MetadataTagKey, tag); err != nil {
+			return err
+		}
+	}
+	for key, value := range meta.Values {
+		if _, err := tx.Exec(insert, id, key, value); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *fileMetadataRepository) Children(dir string) (map[string]*domain.Metadata, error) {
+	dir = normalizeIndexPath(dir)
+
+	var rows *sql.Rows
+	var err error
+	prefix := ""
+	if dir == "" {
+		// Only top-level entries, rather than every tagged file in storage
+		rows, err = r.db.Query(`SELECT f.path, m.key, m.value FROM file_metadata m JOIN file_ids f ON f.id = m.file_id WHERE f.path NOT LIKE '%/%' ORDER BY m.key, m.value`)
+	} else {
+		// substr avoids LIKE so names containing % or _ match literally
+		prefix = dir + "/"
+		rows, err = r.db.Query(
+			r.getPlaceholderQuery(`SELECT f.path, m.key, m.value FROM file_metadata m JOIN file_ids f ON f.id = m.file_id WHERE substr(f.path, 1, %s) = %s ORDER BY m.key, m.value`, 2),
+			textLen(prefix), prefix,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	children := make(map[string]*domain.Metadata)
+	for rows.Next() {
+		var p, key, value string
+		if err := rows.Scan(&p, &key, &value); err != nil {
+			return nil, err
+		}
+		// Skip anything deeper than a direct child
+		if strings.Contains(p[len(prefix):], "/") {
+			continue
+		}
+		meta, ok := children[p]
+		if !ok {
+			meta = &domain.Metadata{Tags: []string{}, Values: map[string]string{}}
+			children[p] = meta
+		}
+		addMetadataRow(meta, key, value)
+	}
+	return children, rows.Err()
+}
+
+// addMetadataRow adds one stored row to meta
+func addMetadataRow(meta *domain.Metadata, key, value string) {
+	if key == domain.MetadataTagKey {
+		meta.Tags = append(meta.Tags, value)
+	} else {
+		meta.Values[key] = value
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"slices"
+	"sort"
+	"testing"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestFileMetadataChildren(t *testing.T) {
+	db := newTestDB(t)
+	index := NewFileIndexRepository(db)
+	meta := NewFileMetadataRepository(db)
+	for _, p := range []string{"top.txt", "Año", "Año/a.jpg", "Año/sub/b.jpg", "Año2/c.jpg", "a_b/d.txt", "axb/e.txt"} {
+		id, err := index.GetOrCreate(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := meta.Set(id, domain.Metadata{Tags: []string{"t"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"", []string{"top.txt", "Año"}},
+		{"Año", []string{"Año/a.jpg"}},
+		{"Año/sub", []string{"Año/sub/b.jpg"}},
+		{"a_b", []string{"a_b/d.txt"}},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			children, err := meta.Children(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for p := range children {
+				got = append(got, p)
+			}
+			sort.Strings(got)
+			want := slices.Clone(tt.want)
+			sort.Strings(want)
+			if !slices.Equal(got, want) {
+				t.Fatalf("children %v, want %v", got, want)
+			}
+		})
+	}
+}
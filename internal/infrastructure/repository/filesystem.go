@@ -1,25 +1,47 @@
 package repository
 
 import (
+	"archive/tar"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	domain "gomanager/internal/domain/file"
 )
 
 type filesystemRepository struct {
 	basePath string
+
+	// scanner vets uploads before they are placed; nil disables scanning
+	scanner   domain.FileScanner
+	asyncScan bool // Scan after responding, leaving files quarantined meanwhile
+
+	// placed is told about files placed after a background scan
+	placed func(path string)
 }
 
+// quarantineDir holds uploads still being received or awaiting a scan,
+// relative to the base path
+const quarantineDir = ".quarantine"
+
 // NewFilesystemRepository creates a new filesystem-based repository
-func NewFilesystemRepository(basePath string) domain.Repository {
+func NewFilesystemRepository(basePath string, scanner domain.FileScanner, asyncScan bool) domain.Repository {
 	// Ensure base path exists
 	os.MkdirAll(basePath, 0755)
-	return &filesystemRepository{basePath: basePath}
+	os.MkdirAll(filepath.Join(basePath, quarantineDir), 0755)
+	return &filesystemRepository{basePath: basePath, scanner: scanner, asyncScan: asyncScan}
+}
+
+func (r *filesystemRepository) OnPlaced(fn func(path string)) {
+	r.placed = fn
 }
 
 // sanitizePath prevents directory traversal attacks
@@ -71,62 +93,283 @@ func (r *filesystemRepository) List(path string) ([]domain.FileInfo, error) {
 		})
 	}
 
-	// Sort: directories first, then by name
+	sortListing(files)
+	return files, nil
+}
+
+// sortListing orders directories first, then by name
+func sortListing(files []domain.FileInfo) {
 	sort.Slice(files, func(i, j int) bool {
 		if files[i].IsDir != files[j].IsDir {
 			return files[i].IsDir
 		}
 		return strings.ToLower(files[i].Name) < strings.ToLower(files[j].Name)
 	})
+}
 
-	return files, nil
+func (r *filesystemRepository) Open(path string) (io.ReadSeekCloser, *domain.FileInfo, error) {
+	info, err := r.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir {
+		return nil, nil, domain.ErrIsDirectory
+	}
+
+	f, err := os.Open(r.getFullPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, domain.ErrNotFound
+		}
+		return nil, nil, domain.ErrReadFailed
+	}
+	return f, info, nil
 }
 
-func (r *filesystemRepository) GetFilePath(relativePath string) (string, error) {
-	fullPath := r.getFullPath(relativePath)
+func (r *filesystemRepository) Stat(path string) (*domain.FileInfo, error) {
+	info, err := os.Stat(r.getFullPath(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, domain.ErrReadFailed
+	}
 
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		return "", domain.ErrNotFound
+	relPath := r.sanitizePath(path)
+	if relPath == "." {
+		relPath = ""
 	}
 
-	return fullPath, nil
+	return &domain.FileInfo{
+		Name:    filepath.Base(relPath),
+		Size:    info.Size(),
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+		Path:    relPath,
+	}, nil
 }
 
-func (r *filesystemRepository) Save(path string, files []*multipart.FileHeader) ([]string, error) {
+func (r *filesystemRepository) Save(path string, files []*multipart.FileHeader, policy domain.ConflictPolicy) (*domain.UploadResult, error) {
 	fullPath := r.getFullPath(path)
-	uploadedFiles := make([]string, 0, len(files))
+	result := &domain.UploadResult{Uploaded: make([]string, 0, len(files))}
 
+	if r.scanner != nil {
+		r.saveScanned(fullPath, files, policy, result)
+		if len(result.Uploaded) == 0 && len(result.Conflicts) == 0 && len(result.Rejected) == 0 && len(result.Pending) == 0 {
+			return nil, domain.ErrUploadFailed
+		}
+		return result, nil
+	}
+
+	// Each file is received in quarantine and only renamed into place once
+	// complete, so a broken transfer never leaves a truncated file behind
 	for _, fileHeader := range files {
-		file, err := fileHeader.Open()
+		filename := filepath.Base(fileHeader.Filename)
+
+		if policy == domain.ConflictReject {
+			if _, err := os.Lstat(filepath.Join(fullPath, filename)); err == nil {
+				result.Conflicts = append(result.Conflicts, filename)
+				continue
+			}
+		}
+
+		tmpPath, err := r.quarantine(fileHeader, filepath.Ext(filename))
+		if err != nil {
+			continue
+		}
+
+		savedName, err := placeUpload(tmpPath, fullPath, filename, policy)
 		if err != nil {
+			if os.IsExist(err) {
+				result.Conflicts = append(result.Conflicts, filename)
+			}
 			continue
 		}
+		result.Uploaded = append(result.Uploaded, savedName)
+	}
+
+	if len(result.Uploaded) == 0 && len(result.Conflicts) == 0 {
+		return nil, domain.ErrUploadFailed
+	}
+
+	return result, nil
+}
 
+// saveScanned writes each upload to quarantine and only moves it into dir
+// once the scanner accepts it
+func (r *filesystemRepository) saveScanned(dir string, files []*multipart.FileHeader, policy domain.ConflictPolicy, result *domain.UploadResult) {
+	for _, fileHeader := range files {
 		filename := filepath.Base(fileHeader.Filename)
-		destPath := filepath.Join(fullPath, filename)
 
-		dst, err := os.Create(destPath)
+		// Report obvious conflicts now rather than after the scan
+		if policy == domain.ConflictReject {
+			if _, err := os.Lstat(filepath.Join(dir, filename)); err == nil {
+				result.Conflicts = append(result.Conflicts, filename)
+				continue
+			}
+		}
+
+		tmpPath, err := r.quarantine(fileHeader, filepath.Ext(filename))
 		if err != nil {
-			file.Close()
 			continue
 		}
 
-		if _, err := io.Copy(dst, file); err != nil {
-			file.Close()
-			dst.Close()
+		if r.asyncScan {
+			// The conflict check above is all the client hears about, so a
+			// name taken during the scan gets the file renamed, not dropped
+			placePolicy := policy
+			if placePolicy == domain.ConflictReject {
+				placePolicy = domain.ConflictRename
+			}
+			go func() {
+				savedName, err := r.scanAndPlace(tmpPath, dir, filename, placePolicy)
+				switch {
+				case err == nil:
+					if savedName != filename {
+						log.Printf("upload scan: %s was taken meanwhile; placed as %s", filename, savedName)
+					}
+					if r.placed != nil {
+						rel, _ := filepath.Rel(r.basePath, filepath.Join(dir, savedName))
+						r.placed(filepath.ToSlash(rel))
+					}
+				case !errors.Is(err, domain.ErrScanRejected):
+					// Rejections are logged by scanAndPlace itself
+					log.Printf("upload scan: %s not placed: %v", filename, err)
+				}
+			}()
+			result.Pending = append(result.Pending, filename)
 			continue
 		}
 
-		file.Close()
+		savedName, err := r.scanAndPlace(tmpPath, dir, filename, policy)
+		switch {
+		case err == nil:
+			result.Uploaded = append(result.Uploaded, savedName)
+		case errors.Is(err, domain.ErrScanRejected):
+			result.Rejected = append(result.Rejected, filename)
+		case os.IsExist(err):
+			result.Conflicts = append(result.Conflicts, filename)
+		}
+	}
+}
+
+// quarantine copies an upload into the quarantine directory, keeping ext so
+// scanners can check it
+func (r *filesystemRepository) quarantine(fileHeader *multipart.FileHeader, ext string) (string, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return r.receive(src, ext)
+}
+
+// receive copies src into a new quarantine file, removing it again if the
+// copy fails partway
+func (r *filesystemRepository) receive(src io.Reader, ext string) (string, error) {
+	dst, err := os.CreateTemp(filepath.Join(r.basePath, quarantineDir), "upload-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
 		dst.Close()
-		uploadedFiles = append(uploadedFiles, filename)
+		os.Remove(dst.Name())
+		return "", err
 	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
 
-	if len(uploadedFiles) == 0 {
-		return nil, domain.ErrUploadFailed
+// scanAndPlace scans a quarantined file and, if clean, renames it into dir
+// under a name chosen by policy. Rejected files are deleted.
+func (r *filesystemRepository) scanAndPlace(tmpPath, dir, filename string, policy domain.ConflictPolicy) (string, error) {
+	if err := r.scanner.Scan(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		log.Printf("upload scan: rejected %s: %v", filename, err)
+		return "", domain.ErrScanRejected
+	}
+	return placeUpload(tmpPath, dir, filename, policy)
+}
+
+// placeUpload renames a fully received file into dir under a name chosen by
+// policy. tmpPath is removed if it can't be placed.
+func placeUpload(tmpPath, dir, filename string, policy domain.ConflictPolicy) (string, error) {
+	// Reserve the destination name, then rename the data over it
+	dst, savedName, err := createUploadFile(dir, filename, policy)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	dst.Close()
+
+	if err := os.Rename(tmpPath, filepath.Join(dir, savedName)); err != nil {
+		os.Remove(tmpPath)
+		os.Remove(filepath.Join(dir, savedName))
+		return "", err
+	}
+	return savedName, nil
+}
+
+func (r *filesystemRepository) Import(localPath, dir, filename string, policy domain.ConflictPolicy) (string, error) {
+	relDir := r.sanitizePath(dir)
+	if relDir == "." {
+		relDir = ""
+	}
+	targetDir := r.getFullPath(relDir)
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return "", domain.ErrCreateFailed
+	}
+
+	// Reserve the destination name, then rename the data over it
+	dst, savedName, err := createUploadFile(targetDir, filepath.Base(filename), policy)
+	if err != nil {
+		if os.IsExist(err) {
+			return "", domain.ErrExists
+		}
+		return "", domain.ErrUploadFailed
+	}
+	dst.Close()
+
+	destPath := filepath.Join(targetDir, savedName)
+	if err := os.Rename(localPath, destPath); err != nil {
+		os.Remove(destPath)
+		return "", domain.ErrUploadFailed
 	}
 
-	return uploadedFiles, nil
+	return filepath.ToSlash(filepath.Join(relDir, savedName)), nil
+}
+
+// maxRenameAttempts bounds the " (n)" suffixes tried by ConflictRename
+const maxRenameAttempts = 1000
+
+// createUploadFile opens the destination for an upload according to policy.
+// Files are created with O_EXCL unless overwriting so a concurrent upload of
+// the same name can't be silently replaced.
+func createUploadFile(dir, filename string, policy domain.ConflictPolicy) (*os.File, string, error) {
+	if policy == domain.ConflictOverwrite {
+		dst, err := os.Create(filepath.Join(dir, filename))
+		return dst, filename, err
+	}
+
+	const flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	dst, err := os.OpenFile(filepath.Join(dir, filename), flags, 0644)
+	if err == nil || policy != domain.ConflictRename || !os.IsExist(err) {
+		return dst, filename, err
+	}
+
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		dst, err = os.OpenFile(filepath.Join(dir, candidate), flags, 0644)
+		if err == nil || !os.IsExist(err) {
+			return dst, candidate, err
+		}
+	}
+	return nil, "", err
 }
 
 func (r *filesystemRepository) CreateDirectory(path string) error {
@@ -158,10 +401,115 @@ func (r *filesystemRepository) Delete(path string) error {
 	return nil
 }
 
+func (r *filesystemRepository) Move(source, destination string) error {
+	srcPath := r.getFullPath(source)
+	dstPath := r.getFullPath(destination)
+
+	absBase, _ := filepath.Abs(r.basePath)
+	absSrc, _ := filepath.Abs(srcPath)
+	absDst, _ := filepath.Abs(dstPath)
+	if absSrc == absBase || absDst == absBase {
+		return domain.ErrInvalidPath
+	}
+
+	if _, err := os.Stat(srcPath); err != nil {
+		if os.IsNotExist(err) {
+			return domain.ErrNotFound
+		}
+		return domain.ErrMoveFailed
+	}
+
+	// os.Rename replaces existing files, so check first
+	if _, err := os.Lstat(dstPath); err == nil {
+		return domain.ErrExists
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return domain.ErrMoveFailed
+	}
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		return domain.ErrMoveFailed
+	}
+
+	return nil
+}
+
+// Copy duplicates a file or directory tree. Symlinks and special files are
+// left out so a copy can't reach outside the storage root.
+func (r *filesystemRepository) Copy(source, destination string) error {
+	srcPath := r.getFullPath(source)
+	dstPath := r.getFullPath(destination)
+
+	absBase, _ := filepath.Abs(r.basePath)
+	absSrc, _ := filepath.Abs(srcPath)
+	absDst, _ := filepath.Abs(dstPath)
+	if absSrc == absBase || absDst == absBase {
+		return domain.ErrInvalidPath
+	}
+
+	if _, err := os.Stat(srcPath); err != nil {
+		if os.IsNotExist(err) {
+			return domain.ErrNotFound
+		}
+		return domain.ErrCopyFailed
+	}
+	if _, err := os.Lstat(dstPath); err == nil {
+		return domain.ErrExists
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return domain.ErrCopyFailed
+	}
+
+	err := filepath.Walk(srcPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(srcPath, p)
+		target := filepath.Join(dstPath, rel)
+
+		switch {
+		case info.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info)
+		default:
+			return nil
+		}
+	})
+	if err != nil {
+		// Don't leave a partial copy behind
+		os.RemoveAll(dstPath)
+		return domain.ErrCopyFailed
+	}
+	return nil
+}
+
+func copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
 func (r *filesystemRepository) Exists(path string) (bool, error) {
 	fullPath := r.getFullPath(path)
 	_, err := os.Stat(fullPath)
-	if os.IsNotExist(err) {
+	// ENOTDIR means a parent is a file, so nothing can exist below it
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
 		return false, nil
 	}
 	if err != nil {
@@ -179,75 +527,250 @@ func (r *filesystemRepository) IsDirectory(path string) (bool, error) {
 	return info.IsDir(), nil
 }
 
-func (r *filesystemRepository) GetStats(excludePaths []string) (*domain.StorageStats, error) {
-	stats := &domain.StorageStats{
-		FilesByType: make(map[string]int64),
-		RecentFiles: make([]domain.FileInfo, 0),
+func (r *filesystemRepository) SetModTime(path string, modTime time.Time, recursive bool) error {
+	fullPath := r.getFullPath(path)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return domain.ErrNotFound
+		}
+		return domain.ErrTouchFailed
 	}
 
-	var allFiles []domain.FileInfo
+	if !info.IsDir() {
+		if err := os.Chtimes(fullPath, modTime, modTime); err != nil {
+			return domain.ErrTouchFailed
+		}
+		return nil
+	}
+
+	if !recursive {
+		return domain.ErrRecursiveRequired
+	}
 
-	err := filepath.Walk(r.basePath, func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(fullPath, func(p string, _ os.FileInfo, err error) error {
 		if err != nil {
-			return nil // Skip files we can't access
+			return err
 		}
+		return os.Chtimes(p, modTime, modTime)
+	})
+	if err != nil {
+		return domain.ErrTouchFailed
+	}
+	return nil
+}
 
-		// Get relative path
-		relPath, _ := filepath.Rel(r.basePath, path)
-		if relPath == "." {
-			return nil
+func (r *filesystemRepository) WriteTar(path, prefix string, w io.Writer, excludePaths []string) error {
+	fullPath := r.getFullPath(path)
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return domain.ErrNotFound
+	}
+	if !info.IsDir() {
+		return domain.ErrNotDirectory
+	}
+
+	tw := tar.NewWriter(w)
+	err = filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't access
 		}
 
-		// Check if path should be excluded
-		for _, exclude := range excludePaths {
-			if strings.HasPrefix(relPath, exclude) || relPath == exclude {
-				if info.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
+		relPath, _ := filepath.Rel(r.basePath, p)
+		if excluded(filepath.ToSlash(relPath), excludePaths) {
+			if info.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
 		}
 
-		if info.IsDir() {
-			stats.TotalFolders++
-		} else {
-			stats.TotalFiles++
-			stats.TotalSize += info.Size()
+		// Symlinks and other special files could point outside the storage root
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
 
-			// Count by file extension
-			ext := strings.ToLower(filepath.Ext(info.Name()))
-			if ext == "" {
-				ext = "no extension"
-			}
-			stats.FilesByType[ext]++
+		name, _ := filepath.Rel(fullPath, p)
+		name = filepath.ToSlash(filepath.Join(prefix, name))
+		if name == "." {
+			return nil
+		}
 
-			// Collect for recent files
-			allFiles = append(allFiles, domain.FileInfo{
-				Name:    info.Name(),
-				Size:    info.Size(),
-				IsDir:   false,
-				ModTime: info.ModTime(),
-				Path:    relPath,
-			})
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
 		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, io.LimitReader(f, info.Size()))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// errWalkLimit stops a directory size walk once its deadline passes
+var errWalkLimit = errors.New("walk limit reached")
 
+func (r *filesystemRepository) DirSize(path string, maxDepth int, deadline time.Time) (int64, bool, error) {
+	fullPath := r.getFullPath(path)
+	baseDepth := strings.Count(filepath.Clean(fullPath), string(os.PathSeparator))
+
+	var size int64
+	complete := true
+	err := filepath.Walk(fullPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't access
+		}
+		if time.Now().After(deadline) {
+			complete = false
+			return errWalkLimit
+		}
+		if info.IsDir() {
+			if strings.Count(p, string(os.PathSeparator))-baseDepth >= maxDepth {
+				complete = false
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		size += info.Size()
 		return nil
 	})
+	if err != nil && !errors.Is(err, errWalkLimit) {
+		return 0, false, domain.ErrReadFailed
+	}
+
+	return size, complete, nil
+}
 
+func (r *filesystemRepository) GetStats(excludePaths []string) (*domain.StorageStats, error) {
+	stats, err := collectStats(func(fn func(domain.FileInfo) error) error {
+		return r.Walk("", excludePaths, fn)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Sort by modification time (newest first) and take top 10
-	sort.Slice(allFiles, func(i, j int) bool {
-		return allFiles[i].ModTime.After(allFiles[j].ModTime)
+	// Disk capacity is best-effort; leave zero if the platform call fails
+	if total, available, err := diskSpace(r.basePath); err == nil {
+		stats.TotalBytes = total
+		stats.AvailableBytes = available
+	}
+	return stats, nil
+}
+
+func (r *filesystemRepository) Walk(root string, excludePaths []string, fn func(info domain.FileInfo) error) error {
+	rootPath := r.getFullPath(root)
+	if _, err := os.Lstat(rootPath); err != nil {
+		if os.IsNotExist(err) {
+			return domain.ErrNotFound
+		}
+		return domain.ErrReadFailed
+	}
+
+	skipped := false
+	err := filepath.Walk(rootPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			skipped = true
+			return nil // Skip entries we can't access
+		}
+		relPath, _ := filepath.Rel(r.basePath, p)
+		if relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if excluded(relPath, excludePaths) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return fn(domain.FileInfo{
+			Name:    info.Name(),
+			Size:    info.Size(),
+			IsDir:   info.IsDir(),
+			ModTime: info.ModTime(),
+			Path:    relPath,
+		})
 	})
+	if err == nil && skipped {
+		return domain.ErrWalkIncomplete
+	}
+	return err
+}
+
+// excluded reports whether p is, or is inside, one of excludePaths, ignoring
+// case as the file service does when hiding paths
+func excluded(p string, excludePaths []string) bool {
+	for _, exclude := range excludePaths {
+		if strings.EqualFold(p, exclude) {
+			return true
+		}
+		if len(p) > len(exclude) && p[len(exclude)] == '/' && strings.EqualFold(p[:len(exclude)], exclude) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectStats tallies counts, sizes and recent files over a Walk
+func collectStats(walk func(fn func(domain.FileInfo) error) error) (*domain.StorageStats, error) {
+	stats := &domain.StorageStats{
+		FilesByType: make(map[string]int64),
+		SizeByType:  make(map[string]int64),
+		RecentFiles: make([]domain.FileInfo, 0),
+	}
+
+	var allFiles []domain.FileInfo
+	err := walk(func(info domain.FileInfo) error {
+		if info.IsDir {
+			stats.TotalFolders++
+			return nil
+		}
+		stats.TotalFiles++
+		stats.TotalSize += info.Size
 
-	if len(allFiles) > 10 {
-		stats.RecentFiles = allFiles[:10]
-	} else {
-		stats.RecentFiles = allFiles
+		// Count and size by file extension
+		ext := domain.TypeKey(info.Name)
+		stats.FilesByType[ext]++
+		stats.SizeByType[ext] += info.Size
+
+		allFiles = append(allFiles, info)
+		return nil
+	})
+	// Stats over the readable entries are still worth showing
+	if err != nil && !errors.Is(err, domain.ErrWalkIncomplete) {
+		return nil, err
 	}
 
+	stats.RecentFiles = newestFiles(allFiles, 10)
 	return stats, nil
 }
+
+// newestFiles sorts files by modification time and returns the newest n
+func newestFiles(files []domain.FileInfo, n int) []domain.FileInfo {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	if len(files) > n {
+		return files[:n]
+	}
+	return files
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	domain "gomanager/internal/domain/file"
+)
+
+func TestFilesystemWalkReportsUnreadableEntries(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't restrict root")
+	}
+	dir := t.TempDir()
+	for _, p := range []string{"ok/a.txt", "locked/b.txt"} {
+		full := filepath.Join(dir, filepath.FromSlash(p))
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, nil, 0644)
+	}
+	locked := filepath.Join(dir, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+
+	var seen []string
+	err := NewFilesystemRepository(dir, nil, false).Walk("", nil, func(info domain.FileInfo) error {
+		seen = append(seen, info.Path)
+		return nil
+	})
+	if !errors.Is(err, domain.ErrWalkIncomplete) {
+		t.Fatalf("got %v, want ErrWalkIncomplete", err)
+	}
+	if len(seen) != 3 { // ok, ok/a.txt and locked itself
+		t.Fatalf("walked %v", seen)
+	}
+}
+
+func TestFilesystemSaveConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    domain.ConflictPolicy
+		existing  []string
+		uploaded  []string
+		conflicts []string
+		want      map[string]string // Contents of docs afterwards
+	}{
+		{"reject", domain.ConflictReject, []string{"a.txt"}, []string{}, []string{"a.txt"}, map[string]string{"a.txt": "old"}},
+		{"reject, no clash", domain.ConflictReject, nil, []string{"a.txt"}, nil, map[string]string{"a.txt": "new"}},
+		{"overwrite", domain.ConflictOverwrite, []string{"a.txt"}, []string{"a.txt"}, nil, map[string]string{"a.txt": "new"}},
+		{"rename", domain.ConflictRename, []string{"a.txt"}, []string{"a (1).txt"}, nil, map[string]string{"a.txt": "old", "a (1).txt": "new"}},
+		{"rename past taken suffixes", domain.ConflictRename, []string{"a.txt", "a (1).txt"}, []string{"a (2).txt"}, nil, map[string]string{"a.txt": "old", "a (1).txt": "old", "a (2).txt": "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			docs := filepath.Join(dir, "docs")
+			os.MkdirAll(docs, 0755)
+			for _, name := range tt.existing {
+				os.WriteFile(filepath.Join(docs, name), []byte("old"), 0644)
+			}
+
+			res, err := NewFilesystemRepository(dir, nil, false).Save("docs", newFileHeaders(t, map[string]string{"a.txt": "new"}), tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.Uploaded, tt.uploaded) || !slices.Equal(res.Conflicts, tt.conflicts) {
+				t.Fatalf("uploaded %q, conflicts %q; want %q, %q", res.Uploaded, res.Conflicts, tt.uploaded, tt.conflicts)
+			}
+
+			entries, _ := os.ReadDir(docs)
+			if len(entries) != len(tt.want) {
+				t.Fatalf("docs holds %d entries, want %d", len(entries), len(tt.want))
+			}
+			for name, content := range tt.want {
+				if data, err := os.ReadFile(filepath.Join(docs, name)); err != nil || string(data) != content {
+					t.Errorf("%s holds %q, %v; want %q", name, data, err, content)
+				}
+			}
+		})
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	exclude := []string{".avatars", "projects/.cache"}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{".avatars", true},
+		{".avatars/a.png", true},
+		{".AVATARS/a.png", true},
+		{".avatars-old", false},
+		{"projects/.cache/obj", true},
+		{"projects/.Cache", true},
+		{"projects/.cacheless", false},
+		{"projects", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := excluded(tt.path, exclude); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFilesystemStatsDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	if _, _, err := diskSpace(dir); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("disk space isn't reported on this platform")
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("abc"), 0644)
+
+	stats, err := NewFilesystemRepository(dir, nil, false).GetStats(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalBytes == 0 || stats.AvailableBytes == 0 || stats.AvailableBytes > stats.TotalBytes {
+		t.Fatalf("total %d, available %d", stats.TotalBytes, stats.AvailableBytes)
+	}
+	if stats.TotalSize != 3 {
+		t.Fatalf("used %d bytes, want 3", stats.TotalSize)
+	}
+}
+
+func TestFilesystemReceiveDropsPartialUploads(t *testing.T) {
+	data := strings.Repeat("x", 256<<10)
+	tests := []struct {
+		name    string
+		src     io.Reader
+		wantErr bool
+	}{
+		{"complete", strings.NewReader(data), false},
+		{"connection lost midway", io.MultiReader(strings.NewReader(data), iotest.ErrReader(errors.New("connection reset"))), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			repo := NewFilesystemRepository(dir, nil, false).(*filesystemRepository)
+
+			tmpPath, err := repo.receive(tt.src, ".bin")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			staged, _ := os.ReadDir(filepath.Join(dir, quarantineDir))
+			if tt.wantErr {
+				if len(staged) != 0 {
+					t.Fatalf("partial upload left %d staged files", len(staged))
+				}
+				return
+			}
+			if got, _ := os.ReadFile(tmpPath); string(got) != data || len(staged) != 1 {
+				t.Fatalf("staged %d bytes in %d files", len(got), len(staged))
+			}
+		})
+	}
+
+	// A completed save leaves nothing staged behind
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	if _, err := NewFilesystemRepository(dir, nil, false).Save("docs", newFileHeaders(t, map[string]string{"a.txt": data}), domain.ConflictReject); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "docs", "a.txt")); string(got) != data {
+		t.Fatalf("stored %d bytes, want %d", len(got), len(data))
+	}
+	if staged, _ := os.ReadDir(filepath.Join(dir, quarantineDir)); len(staged) != 0 {
+		t.Fatalf("%d files left staged", len(staged))
+	}
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/database"
+)
+
+// newTestDB returns a migrated SQLite database in a temporary folder
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"), database.PoolConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestUser stores a user with the given ID in db
+func newTestUser(t *testing.T, db *database.DB, id string) {
+	t.Helper()
+	u := &user.User{ID: id, Email: id + "@example.com", Username: id, Role: user.RoleUser, AuthProvider: user.AuthProviderLocal, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := NewUserRepository(db).Create(u); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gomanager/internal/domain/idempotency"
+	"gomanager/internal/infrastructure/database"
+)
+
+// idempotencyRepository stores times as Unix seconds so cutoffs compare as
+// numbers; SQLite would compare formatted timestamps as text
+type idempotencyRepository struct {
+	db *database.DB
+}
+
+// NewIdempotencyRepository creates the store of replayable responses
+func NewIdempotencyRepository(db *database.DB) idempotency.Repository {
+	return &idempotencyRepository{db: db}
+}
+
+// getPlaceholderQuery converts a query template with %s placeholders to the correct database syntax
+func (r *idempotencyRepository) getPlaceholderQuery(queryTemplate string, paramCount int) string {
+	placeholders := make([]interface{}, paramCount)
+	for i := 0; i < paramCount; i++ {
+		if r.db.GetType() == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(queryTemplate, placeholders...)
+}
+
+func (r *idempotencyRepository) Get(userID, key string, since time.Time) (*idempotency.Response, error) {
+	resp := &idempotency.Response{UserID: userID, Key: key}
+	var createdAt int64
+	query := r.getPlaceholderQuery(`SELECT method, path, request_hash, status_code, content_type, body, created_at FROM idempotency_keys
+		 WHERE user_id = %s AND key = %s AND created_at >= %s`, 3)
+	err := r.db.QueryRow(query, userID, key, since.Unix()).Scan(&resp.Method, &resp.Path, &resp.RequestHash, &resp.StatusCode, &resp.ContentType, &resp.Body, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, idempotency.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp.CreatedAt = time.Unix(createdAt, 0)
+	return resp, nil
+}
+
+func (r *idempotencyRepository) Save(resp *idempotency.Response, expiredBefore time.Time) error {
+	if resp.CreatedAt.IsZero() {
+		resp.CreatedAt = time.Now()
+	}
+
+	query := r.getPlaceholderQuery(`INSERT INTO idempotency_keys (user_id, key, method, path, request_hash, status_code, content_type, body, created_at)
+		 VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+		 ON CONFLICT (user_id, key) DO UPDATE SET method = excluded.method, path = excluded.path,
+		 request_hash = excluded.request_hash, status_code = excluded.status_code, content_type = excluded.content_type,
+		 body = excluded.body, created_at = excluded.created_at
+		 WHERE idempotency_keys.created_at < %s`, 10)
+	_, err := r.db.Exec(query, resp.UserID, resp.Key, resp.Method, resp.Path, resp.RequestHash, resp.StatusCode, resp.ContentType, resp.Body, resp.CreatedAt.Unix(), expiredBefore.Unix())
+	return err
+}
+
+func (r *idempotencyRepository) DeleteBefore(cutoff time.Time) error {
+	_, err := r.db.Exec(r.getPlaceholderQuery(`DELETE FROM idempotency_keys WHERE created_at < %s`, 1), cutoff.Unix())
+	return err
+}
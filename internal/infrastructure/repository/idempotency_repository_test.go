@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/idempotency"
+)
+
+func TestIdempotencyRepository(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewIdempotencyRepository(db)
+	now := time.Now()
+	// Stored in another zone so cutoffs would misorder as formatted text
+	east := time.FixedZone("UTC+5", 5*3600)
+
+	save := func(key string, at time.Time, body string) {
+		t.Helper()
+		err := repo.Save(&idempotency.Response{UserID: "u1", Key: key, Method: "POST", Path: "/api/shares", RequestHash: "h", StatusCode: 201, ContentType: "application/json", Body: []byte(body), CreatedAt: at.In(east)}, now.Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("fresh", now.Add(-time.Minute), "first")
+	save("fresh", now, "second")
+	save("expired", now.Add(-2*time.Hour), "old")
+	save("expired", now, "new")
+	save("stale", now.Add(-2*time.Hour), "stale")
+
+	tests := []struct {
+		key  string
+		body string // Empty: not found
+	}{
+		{"fresh", "first"},
+		{"expired", "new"},
+		{"stale", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			resp, err := repo.Get("u1", tt.key, now.Add(-time.Hour).UTC())
+			if tt.body == "" {
+				if !errors.Is(err, idempotency.ErrNotFound) {
+					t.Fatalf("got %v, want ErrNotFound", err)
+				}
+				return
+			}
+			if err != nil || string(resp.Body) != tt.body || resp.RequestHash != "h" {
+				t.Fatalf("got %+v, %v; want body %q", resp, err, tt.body)
+			}
+		})
+	}
+
+	if err := repo.DeleteBefore(now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM idempotency_keys`).Scan(&count); err != nil || count != 2 {
+		t.Fatalf("%d rows left after sweep (%v), want 2", count, err)
+	}
+}
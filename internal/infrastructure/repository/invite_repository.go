@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gomanager/internal/application/auth"
+	domain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/database"
+)
+
+type inviteRepository struct {
+	db *database.DB
+}
+
+// NewInviteRepository creates the registration invite store
+func NewInviteRepository(db *database.DB) auth.InviteRepository {
+	return &inviteRepository{db: db}
+}
+
+// getPlaceholderQuery converts a query template with %s placeholders to the correct database syntax
+func (r *inviteRepository) getPlaceholderQuery(queryTemplate string, paramCount int) string {
+	placeholders := make([]interface{}, paramCount)
+	for i := 0; i < paramCount; i++ {
+		if r.db.GetType() == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(queryTemplate, placeholders...)
+}
+
+func (r *inviteRepository) Create(invite *domain.Invite) error {
+	query := r.getPlaceholderQuery(`INSERT INTO invites (code, created_by, created_at) VALUES (%s, %s, %s)`, 3)
+	_, err := r.db.Exec(query, invite.Code, invite.CreatedBy, invite.CreatedAt)
+	return err
+}
+
+func (r *inviteRepository) List() ([]domain.Invite, error) {
+	rows, err := r.db.Query(`SELECT code, created_by, created_at, used_by, used_at FROM invites ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []domain.Invite{}
+	for rows.Next() {
+		var invite domain.Invite
+		var usedBy sql.NullString
+		var usedAt sql.NullTime
+		if err := rows.Scan(&invite.Code, &invite.CreatedBy, &invite.CreatedAt, &usedBy, &usedAt); err != nil {
+			return nil, err
+		}
+		invite.UsedBy = usedBy.String
+		if usedAt.Valid {
+			invite.UsedAt = &usedAt.Time
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+func (r *inviteRepository) Consume(code, userID string) error {
+	// The used_at check makes this a compare-and-set, so only one caller wins
+	query := r.getPlaceholderQuery(`UPDATE invites SET used_by = %s, used_at = %s WHERE code = %s AND used_at IS NULL`, 3)
+	result, err := r.db.Exec(query, userID, time.Now(), code)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return user.ErrInvalidInvite
+	}
+	return nil
+}
+
+func (r *inviteRepository) Release(code string) error {
+	query := r.getPlaceholderQuery(`UPDATE invites SET used_by = NULL, used_at = NULL WHERE code = %s`, 1)
+	_, err := r.db.Exec(query, code)
+	return err
+}
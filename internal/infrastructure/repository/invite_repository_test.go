@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/auth"
+	"gomanager/internal/domain/user"
+)
+
+func TestInviteConsumedOnce(t *testing.T) {
+	repo := NewInviteRepository(newTestDB(t))
+	if err := repo.Create(&domain.Invite{Code: "c1", CreatedBy: "admin", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Consume("c1", fmt.Sprintf("u%d", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	won := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, user.ErrInvalidInvite):
+			t.Fatal(err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d callers consumed the invite, want 1", won)
+	}
+
+	steps := []struct {
+		name   string
+		action string // consume or release
+		code   string
+		want   error
+	}{
+		{"used code", "consume", "c1", user.ErrInvalidInvite},
+		{"unknown code", "consume", "nope", user.ErrInvalidInvite},
+		{"release", "release", "c1", nil},
+		{"released code is usable again", "consume", "c1", nil},
+	}
+	for _, st := range steps {
+		var err error
+		if st.action == "release" {
+			err = repo.Release(st.code)
+		} else {
+			err = repo.Consume(st.code, "u99")
+		}
+		if !errors.Is(err, st.want) {
+			t.Fatalf("%s: got %v, want %v", st.name, err, st.want)
+		}
+	}
+
+	invites, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 1 || invites[0].UsedBy != "u99" || invites[0].UsedAt == nil {
+		t.Fatalf("listed %+v", invites)
+	}
+}
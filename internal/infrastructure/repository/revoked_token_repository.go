@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gomanager/internal/application/auth"
+	"gomanager/internal/infrastructure/database"
+)
+
+type revokedTokenRepository struct {
+	db *database.DB
+}
+
+// NewRevokedTokenRepository creates the JWT logout denylist
+func NewRevokedTokenRepository(db *database.DB) auth.RevocationRepository {
+	return &revokedTokenRepository{db: db}
+}
+
+// getPlaceholderQuery converts a query template with %s placeholders to the correct database syntax
+func (r *revokedTokenRepository) getPlaceholderQuery(queryTemplate string, paramCount int) string {
+	placeholders := make([]interface{}, paramCount)
+	for i := 0; i < paramCount; i++ {
+		if r.db.GetType() == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(queryTemplate, placeholders...)
+}
+
+func (r *revokedTokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	// Entries are only needed until the token would have expired anyway
+	r.db.Exec(r.getPlaceholderQuery(`DELETE FROM revoked_tokens WHERE expires_at < %s`, 1), time.Now())
+
+	var query string
+	if r.db.GetType() == "postgres" {
+		query = `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+	} else {
+		query = `INSERT OR IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`
+	}
+	_, err := r.db.Exec(query, jti, expiresAt)
+	return err
+}
+
+func (r *revokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	var count int
+	query := r.getPlaceholderQuery(`SELECT COUNT(*) FROM revoked_tokens WHERE jti = %s`, 1)
+	if err := r.db.QueryRow(query, jti).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevokedTokenRepository(t *testing.T) {
+	repo := NewRevokedTokenRepository(newTestDB(t))
+	steps := []struct {
+		name    string
+		revoke  string
+		expires time.Duration
+		check   string
+		want    bool
+	}{
+		{"unknown token", "", 0, "a", false},
+		{"revoked", "a", time.Hour, "a", true},
+		{"revoking twice", "a", time.Hour, "a", true},
+		{"other token", "", 0, "b", false},
+		{"already expired", "old", -time.Hour, "old", true},
+		{"expired entries are pruned", "c", time.Hour, "old", false},
+		{"live entries are kept", "", 0, "a", true},
+	}
+	for _, s := range steps {
+		if s.revoke != "" {
+			if err := repo.Revoke(s.revoke, time.Now().Add(s.expires)); err != nil {
+				t.Fatalf("%s: %v", s.name, err)
+			}
+		}
+		got, err := repo.IsRevoked(s.check)
+		if err != nil || got != s.want {
+			t.Fatalf("%s: revoked = %v, %v; want %v", s.name, got, err, s.want)
+		}
+	}
+}
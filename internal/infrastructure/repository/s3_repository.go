@@ -0,0 +1,603 @@
+package repository
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// s3Repository stores files as objects under a key prefix. Directories are
+// key prefixes; empty ones are kept alive by a zero-byte "dir/" marker.
+type s3Repository struct {
+	client *s3Client
+	prefix string // "" or ending in "/"
+
+	// scanner vets uploads before they are stored; nil disables scanning.
+	// Scans always run before responding since there is no local quarantine.
+	scanner domain.FileScanner
+}
+
+// NewS3Repository creates a repository backed by an S3-compatible bucket and
+// checks that the bucket can be listed
+func NewS3Repository(cfg S3Config, scanner domain.FileScanner) (domain.Repository, error) {
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	r := &s3Repository{client: client, prefix: prefix, scanner: scanner}
+
+	if err := client.list(prefix, "/", 1, func([]s3Object, []string) bool { return false }); err != nil {
+		return nil, fmt.Errorf("bucket %q not reachable: %w", cfg.Bucket, err)
+	}
+	return r, nil
+}
+
+// clean maps a client path to a slash-separated path relative to the root,
+// with "" for the root itself
+func (r *s3Repository) clean(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+func (r *s3Repository) key(rel string) string {
+	return r.prefix + rel
+}
+
+// dirKey is the prefix shared by everything inside rel
+func (r *s3Repository) dirKey(rel string) string {
+	if rel == "" {
+		return r.prefix
+	}
+	return r.prefix + rel + "/"
+}
+
+func (r *s3Repository) List(p string) ([]domain.FileInfo, error) {
+	rel := r.clean(p)
+	dir := r.dirKey(rel)
+
+	var files []domain.FileInfo
+	found := rel == ""
+	err := r.client.list(dir, "/", 0, func(objects []s3Object, prefixes []string) bool {
+		for _, sub := range prefixes {
+			found = true
+			name := strings.TrimSuffix(strings.TrimPrefix(sub, dir), "/")
+			files = append(files, domain.FileInfo{Name: name, IsDir: true, Path: path.Join(rel, name)})
+		}
+		for _, obj := range objects {
+			found = true
+			if obj.Key == dir {
+				continue // The directory's own marker
+			}
+			name := strings.TrimPrefix(obj.Key, dir)
+			files = append(files, domain.FileInfo{
+				Name:    name,
+				Size:    obj.Size,
+				ModTime: obj.LastModified,
+				Path:    path.Join(rel, name),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, domain.ErrReadFailed
+	}
+	if !found {
+		return nil, domain.ErrNotFound
+	}
+
+	if files == nil {
+		files = []domain.FileInfo{}
+	}
+	sortListing(files)
+	return files, nil
+}
+
+func (r *s3Repository) Stat(p string) (*domain.FileInfo, error) {
+	rel := r.clean(p)
+	if rel == "" {
+		return &domain.FileInfo{IsDir: true}, nil
+	}
+
+	obj, err := r.client.head(r.key(rel))
+	if err == nil {
+		return &domain.FileInfo{
+			Name:    path.Base(rel),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+			Path:    rel,
+		}, nil
+	}
+	if !errors.Is(err, errS3NotFound) {
+		return nil, domain.ErrReadFailed
+	}
+
+	// Not an object; it's a directory if anything lives under it
+	var first *s3Object
+	err = r.client.list(r.dirKey(rel), "", 1, func(objects []s3Object, _ []string) bool {
+		if len(objects) > 0 {
+			first = &objects[0]
+		}
+		return false
+	})
+	if err != nil {
+		return nil, domain.ErrReadFailed
+	}
+	if first == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	info := &domain.FileInfo{Name: path.Base(rel), IsDir: true, Path: rel}
+	if first.Key == r.dirKey(rel) {
+		info.ModTime = first.LastModified
+	}
+	return info, nil
+}
+
+func (r *s3Repository) Open(p string) (io.ReadSeekCloser, *domain.FileInfo, error) {
+	info, err := r.Stat(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir {
+		return nil, nil, domain.ErrIsDirectory
+	}
+	return &s3ObjectReader{client: r.client, key: r.key(info.Path), size: info.Size}, info, nil
+}
+
+func (r *s3Repository) Save(p string, files []*multipart.FileHeader, policy domain.ConflictPolicy) (*domain.UploadResult, error) {
+	dir := r.clean(p)
+	result := &domain.UploadResult{Uploaded: make([]string, 0, len(files))}
+
+	for _, fileHeader := range files {
+		filename := path.Base(fileHeader.Filename)
+		savedName, err := r.saveUpload(dir, filename, fileHeader, policy)
+		switch {
+		case err == nil:
+			result.Uploaded = append(result.Uploaded, savedName)
+		case errors.Is(err, domain.ErrScanRejected):
+			result.Rejected = append(result.Rejected, filename)
+		case errors.Is(err, domain.ErrExists):
+			result.Conflicts = append(result.Conflicts, filename)
+		}
+	}
+
+	if len(result.Uploaded) == 0 && len(result.Conflicts) == 0 && len(result.Rejected) == 0 {
+		return nil, domain.ErrUploadFailed
+	}
+	return result, nil
+}
+
+// saveUpload stores one multipart file, scanning a local copy first when a
+// scanner is configured
+func (r *s3Repository) saveUpload(dir, filename string, fileHeader *multipart.FileHeader, policy domain.ConflictPolicy) (string, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if r.scanner == nil {
+		return r.putUnique(dir, filename, src, fileHeader.Size, policy)
+	}
+
+	tmp, err := os.CreateTemp("", "upload-*"+path.Ext(filename))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		return "", err
+	}
+	if err := r.scanner.Scan(tmp.Name()); err != nil {
+		log.Printf("upload scan: rejected %s: %v", filename, err)
+		return "", domain.ErrScanRejected
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return r.putUnique(dir, filename, tmp, fileHeader.Size, policy)
+}
+
+func (r *s3Repository) Import(localPath, dir, filename string, policy domain.ConflictPolicy) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", domain.ErrUploadFailed
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", domain.ErrUploadFailed
+	}
+
+	rel := r.clean(dir)
+	savedName, err := r.putUnique(rel, path.Base(filename), f, info.Size(), policy)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(localPath)
+	return path.Join(rel, savedName), nil
+}
+
+// putUnique uploads body into dir under a name chosen by policy, following
+// the same " (n)" scheme as the filesystem backend
+func (r *s3Repository) putUnique(dir, filename string, body io.Reader, size int64, policy domain.ConflictPolicy) (string, error) {
+	if policy == domain.ConflictOverwrite {
+		if err := r.client.put(r.key(path.Join(dir, filename)), body, size, false); err != nil {
+			return "", domain.ErrUploadFailed
+		}
+		return filename, nil
+	}
+
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	candidate := filename
+	for i := 1; ; i++ {
+		if exists, err := r.Exists(path.Join(dir, candidate)); err != nil {
+			return "", domain.ErrUploadFailed
+		} else if !exists {
+			break
+		}
+		if policy != domain.ConflictRename || i > maxRenameAttempts {
+			return "", domain.ErrExists
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+
+	// If-None-Match closes the gap between the check and the write on
+	// services that support conditional writes
+	err := r.client.put(r.key(path.Join(dir, candidate)), body, size, true)
+	var s3err *s3Error
+	if errors.As(err, &s3err) && s3err.Status == 412 {
+		return "", domain.ErrExists
+	}
+	if err != nil {
+		return "", domain.ErrUploadFailed
+	}
+	return candidate, nil
+}
+
+func (r *s3Repository) CreateDirectory(p string) error {
+	rel := r.clean(p)
+	if rel == "" {
+		return nil
+	}
+	if err := r.client.put(r.dirKey(rel), nil, 0, false); err != nil {
+		return domain.ErrCreateFailed
+	}
+	return nil
+}
+
+func (r *s3Repository) Delete(p string) error {
+	rel := r.clean(p)
+	if rel == "" {
+		return domain.ErrRootDeletion
+	}
+
+	if err := r.client.delete(r.key(rel)); err != nil {
+		return domain.ErrDeleteFailed
+	}
+	err := r.eachObject(r.dirKey(rel), func(obj s3Object) error {
+		return r.client.delete(obj.Key)
+	})
+	if err != nil {
+		return domain.ErrDeleteFailed
+	}
+	return nil
+}
+
+// eachObject calls fn for every object under prefix, stopping at the first error
+func (r *s3Repository) eachObject(prefix string, fn func(obj s3Object) error) error {
+	var fnErr error
+	err := r.client.list(prefix, "", 0, func(objects []s3Object, _ []string) bool {
+		for _, obj := range objects {
+			if fnErr = fn(obj); fnErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// Move copies then deletes, since S3 has no rename. Objects over 5 GiB can't
+// be copied server-side in one request and will fail to move.
+func (r *s3Repository) Move(source, destination string) error {
+	if err := r.copyTree(source, destination, domain.ErrMoveFailed); err != nil {
+		return err
+	}
+	if err := r.Delete(source); err != nil {
+		return domain.ErrMoveFailed
+	}
+	return nil
+}
+
+func (r *s3Repository) Copy(source, destination string) error {
+	return r.copyTree(source, destination, domain.ErrCopyFailed)
+}
+
+// copyTree copies a file or directory server-side, reporting failures as failErr
+func (r *s3Repository) copyTree(source, destination string, failErr error) error {
+	src, dst := r.clean(source), r.clean(destination)
+	if src == "" || dst == "" {
+		return domain.ErrInvalidPath
+	}
+
+	info, err := r.Stat(src)
+	if err != nil {
+		return err
+	}
+	if exists, err := r.Exists(dst); err != nil {
+		return failErr
+	} else if exists {
+		return domain.ErrExists
+	}
+
+	if !info.IsDir {
+		if err := r.client.copy(r.key(src), r.key(dst)); err != nil {
+			return failErr
+		}
+		return nil
+	}
+
+	srcDir, dstDir := r.dirKey(src), r.dirKey(dst)
+	err = r.eachObject(srcDir, func(obj s3Object) error {
+		return r.client.copy(obj.Key, dstDir+strings.TrimPrefix(obj.Key, srcDir))
+	})
+	if err != nil {
+		// Don't leave a partial copy behind
+		r.Delete(dst)
+		return failErr
+	}
+	return nil
+}
+
+func (r *s3Repository) Exists(p string) (bool, error) {
+	_, err := r.Stat(p)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *s3Repository) IsDirectory(p string) (bool, error) {
+	info, err := r.Stat(p)
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir, nil
+}
+
+// SetModTime isn't possible: object timestamps are set by the service
+func (r *s3Repository) SetModTime(p string, modTime time.Time, recursive bool) error {
+	return domain.ErrNotSupported
+}
+
+func (r *s3Repository) DirSize(p string, maxDepth int, deadline time.Time) (int64, bool, error) {
+	dir := r.dirKey(r.clean(p))
+
+	var size int64
+	complete := true
+	err := r.client.list(dir, "", 0, func(objects []s3Object, _ []string) bool {
+		for _, obj := range objects {
+			if strings.Count(strings.TrimPrefix(obj.Key, dir), "/") >= maxDepth {
+				complete = false
+				continue
+			}
+			size += obj.Size
+		}
+		if time.Now().After(deadline) {
+			complete = false
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return 0, false, domain.ErrReadFailed
+	}
+	return size, complete, nil
+}
+
+func (r *s3Repository) GetStats(excludePaths []string) (*domain.StorageStats, error) {
+	// Buckets have no fixed capacity, so TotalBytes and AvailableBytes stay zero
+	return collectStats(func(fn func(domain.FileInfo) error) error {
+		return r.Walk("", excludePaths, fn)
+	})
+}
+
+// Walk reports directories once, from markers or the first key below them
+func (r *s3Repository) Walk(root string, excludePaths []string, fn func(info domain.FileInfo) error) error {
+	rel := r.clean(root)
+	if rel != "" {
+		info, err := r.Stat(rel)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir {
+			return fn(*info)
+		}
+	}
+
+	// Ancestors of root count as seen so directories stop at root
+	seen := make(map[string]bool)
+	for dir := path.Dir(rel); dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		seen[dir] = true
+	}
+	addDir := func(dir string, modTime time.Time) error {
+		var missing []string
+		for ; dir != "." && dir != "/" && dir != "" && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			missing = append(missing, dir)
+		}
+		// Parents first, as a filesystem walk would report them
+		for i := len(missing) - 1; i >= 0; i-- {
+			if err := fn(domain.FileInfo{Name: path.Base(missing[i]), IsDir: true, ModTime: modTime, Path: missing[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return r.eachObject(r.dirKey(rel), func(obj s3Object) error {
+		key := strings.TrimPrefix(obj.Key, r.prefix)
+		if excluded(strings.TrimSuffix(key, "/"), excludePaths) {
+			return nil
+		}
+
+		if strings.HasSuffix(key, "/") {
+			return addDir(strings.TrimSuffix(key, "/"), obj.LastModified)
+		}
+		if err := addDir(path.Dir(key), time.Time{}); err != nil {
+			return err
+		}
+		return fn(domain.FileInfo{Name: path.Base(key), Size: obj.Size, ModTime: obj.LastModified, Path: key})
+	})
+}
+
+func (r *s3Repository) WriteTar(p, prefix string, w io.Writer, excludePaths []string) error {
+	info, err := r.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir {
+		return domain.ErrNotDirectory
+	}
+
+	dir := r.dirKey(info.Path)
+	tw := tar.NewWriter(w)
+
+	// Parent directories are implied by keys, so emit their entries on demand
+	written := make(map[string]bool)
+	var addDir func(name string, modTime time.Time) error
+	addDir = func(name string, modTime time.Time) error {
+		if name == "." || name == "" || written[name] {
+			return nil
+		}
+		if err := addDir(path.Dir(name), modTime); err != nil {
+			return err
+		}
+		written[name] = true
+		return tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     name + "/",
+			Mode:     0755,
+			ModTime:  modTime,
+		})
+	}
+
+	if err := addDir(prefix, info.ModTime); err != nil {
+		return err
+	}
+
+	err = r.eachObject(dir, func(obj s3Object) error {
+		if excluded(strings.TrimSuffix(strings.TrimPrefix(obj.Key, r.prefix), "/"), excludePaths) {
+			return nil
+		}
+
+		name := path.Join(prefix, strings.TrimPrefix(obj.Key, dir))
+		if strings.HasSuffix(obj.Key, "/") {
+			return addDir(name, obj.LastModified)
+		}
+		if err := addDir(path.Dir(name), obj.LastModified); err != nil {
+			return err
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     obj.Size,
+			Mode:     0644,
+			ModTime:  obj.LastModified,
+		}); err != nil {
+			return err
+		}
+		body, err := r.client.get(obj.Key, 0)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		_, err = io.Copy(tw, io.LimitReader(body, obj.Size))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// s3ObjectReader reads an object lazily, issuing a ranged GET after each seek
+// so http.ServeContent can serve byte ranges without downloading everything
+type s3ObjectReader struct {
+	client *s3Client
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3ObjectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		body, err := o.client.get(o.key, o.offset)
+		if err != nil {
+			return 0, err
+		}
+		o.body = body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = o.offset + offset
+	case io.SeekEnd:
+		next = o.size + offset
+	default:
+		return 0, errors.New("s3: invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+
+	if next != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = next
+	return next, nil
+}
+
+func (o *s3ObjectReader) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	return o.body.Close()
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// fakeS3 is an in-memory bucket speaking enough of the S3 REST API for the
+// repository: path-style object GET/HEAD/PUT/DELETE, server-side copies,
+// conditional writes and paged ListObjectsV2
+type fakeS3 struct {
+	bucket   string
+	pageSize int // Largest listing page, to exercise continuation tokens
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") || r.Header.Get("x-amz-date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key == "" && r.Method == http.MethodGet {
+		f.list(w, r.URL.Query())
+		return
+	}
+
+	data, exists := f.objects[key]
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			from, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			data, status = data[from:], http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if src := r.Header.Get("x-amz-copy-source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			data, ok := f.objects[strings.TrimPrefix(src, "/"+f.bucket+"/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			f.objects[key] = data
+			io.WriteString(w, "<CopyObjectResult></CopyObjectResult>")
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	prefix, delimiter, after := query.Get("prefix"), query.Get("delimiter"), query.Get("continuation-token")
+	limit := f.pageSize
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n < limit {
+		limit = n
+	}
+
+	keys := make([]string, 0, len(f.objects))
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var result s3ListResult
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || k <= after {
+			continue
+		}
+		if len(result.Contents)+len(result.CommonPrefixes) == limit {
+			result.IsTruncated = true
+			break
+		}
+		result.NextContinuationToken = k
+		if i := strings.Index(k[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			p := k[:len(prefix)+i+1]
+			if !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, struct {
+					Prefix string `xml:"Prefix"`
+				}{p})
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, s3Object{Key: k, Size: int64(len(f.objects[k])), LastModified: time.Now().UTC()})
+	}
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"ListBucketResult"`
+		s3ListResult
+	}{s3ListResult: result})
+}
+
+// newFakeS3Repository returns a repository over an empty in-memory bucket
+func newFakeS3Repository(t *testing.T) domain.Repository {
+	t.Helper()
+	srv := httptest.NewServer(&fakeS3{bucket: "bucket", pageSize: 2, objects: map[string][]byte{}})
+	t.Cleanup(srv.Close)
+	repo, err := NewS3Repository(S3Config{Endpoint: srv.URL, Bucket: "bucket", Prefix: "/root/", AccessKey: "key", SecretKey: "secret", PathStyle: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestS3Escape(t *testing.T) {
+	tests := []struct {
+		in, path, query string
+	}{
+		{"docs/a.txt", "docs/a.txt", "docs%2Fa.txt"},
+		{"a b (1).txt", "a%20b%20%281%29.txt", "a%20b%20%281%29.txt"},
+		{"x+y=z&~_-.", "x%2By%3Dz%26~_-.", "x%2By%3Dz%26~_-."},
+		{"é", "%C3%A9", "%C3%A9"},
+	}
+	for _, tt := range tests {
+		if got := s3EscapePath(tt.in); got != tt.path {
+			t.Errorf("path %q: got %q, want %q", tt.in, got, tt.path)
+		}
+		if got := s3Escape(tt.in, false); got != tt.query {
+			t.Errorf("query %q: got %q, want %q", tt.in, got, tt.query)
+		}
+	}
+
+	query := url.Values{"prefix": {"a b/"}, "list-type": {"2"}, "delimiter": {"/"}}
+	if got, want := s3CanonicalQuery(query), "delimiter=%2F&list-type=2&prefix=a%20b%2F"; got != want {
+		t.Errorf("canonical query %q, want %q", got, want)
+	}
+}
+
+func TestS3Repository(t *testing.T) {
+	testS3Repository(t, newFakeS3Repository(t))
+}
+
+// TestS3RepositoryMinIO runs the same checks against a real service, e.g.
+// S3_TEST_ENDPOINT=http://localhost:9000 with a bucket made beforehand
+func TestS3RepositoryMinIO(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+	repo, err := NewS3Repository(S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("S3_TEST_REGION"),
+		Bucket:    os.Getenv("S3_TEST_BUCKET"),
+		Prefix:    fmt.Sprintf("gomanager-test-%d", time.Now().UnixNano()),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		PathStyle: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, name := range []string{"docs", "copy", "moved.txt", "many"} {
+			repo.Delete(name)
+		}
+	})
+	testS3Repository(t, repo)
+}
+
+func testS3Repository(t *testing.T, repo domain.Repository) {
+	names := func(p string) []string {
+		t.Helper()
+		files, err := repo.List(p)
+		if err != nil {
+			t.Fatalf("list %q: %v", p, err)
+		}
+		out := []string{}
+		for _, f := range files {
+			out = append(out, f.Name)
+		}
+		return out
+	}
+
+	steps := []struct {
+		name  string
+		run   func() error
+		check func()
+	}{
+		{"create folder", func() error { return repo.CreateDirectory("docs") }, func() {
+			if got := names(""); !slices.Equal(got, []string{"docs"}) {
+				t.Fatalf("root lists %q", got)
+			}
+			if got := names("docs"); len(got) != 0 {
+				t.Fatalf("new folder lists %q", got)
+			}
+		}},
+		{"upload", func() error {
+			res, err := repo.Save("docs", newFileHeaders(t, map[string]string{"a.txt": "hello"}), domain.ConflictReject)
+			if err == nil && !slices.Equal(res.Uploaded, []string{"a.txt"}) {
+				err = fmt.Errorf("uploaded %q", res.Uploaded)
+			}
+			return err
+		}, nil},
+		{"upload conflict", func() error {
+			res, err := repo.Save("docs", newFileHeaders(t, map[string]string{"a.txt": "again"}), domain.ConflictReject)
+			if err == nil && !slices.Equal(res.Conflicts, []string{"a.txt"}) {
+				err = fmt.Errorf("conflicts %q", res.Conflicts)
+			}
+			return err
+		}, nil},
+		{"upload renamed", func() error {
+			res, err := repo.Save("docs", newFileHeaders(t, map[string]string{"a.txt": "again"}), domain.ConflictRename)
+			if err == nil && !slices.Equal(res.Uploaded, []string{"a (1).txt"}) {
+				err = fmt.Errorf("uploaded %q", res.Uploaded)
+			}
+			return err
+		}, func() {
+			if got := names("docs"); !slices.Equal(got, []string{"a (1).txt", "a.txt"}) {
+				t.Fatalf("docs lists %q", got)
+			}
+		}},
+		{"stat", func() error {
+			info, err := repo.Stat("docs/a.txt")
+			if err == nil && (info.IsDir || info.Size != 5) {
+				err = fmt.Errorf("stat %+v", info)
+			}
+			if dir, _ := repo.IsDirectory("docs"); !dir {
+				err = errors.New("docs isn't a directory")
+			}
+			if _, missing := repo.Stat("nope"); !errors.Is(missing, domain.ErrNotFound) {
+				err = fmt.Errorf("stat of a missing path: %v", missing)
+			}
+			return err
+		}, nil},
+		{"ranged read", func() error {
+			f, _, err := repo.Open("docs/a.txt")
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			f.Seek(1, io.SeekStart)
+			data, err := io.ReadAll(f)
+			if err == nil && string(data) != "ello" {
+				err = fmt.Errorf("read %q", data)
+			}
+			return err
+		}, nil},
+		{"copy folder", func() error { return repo.Copy("docs", "copy") }, func() {
+			if got := names("copy"); !slices.Equal(got, []string{"a (1).txt", "a.txt"}) {
+				t.Fatalf("copy lists %q", got)
+			}
+		}},
+		{"copy onto existing", func() error {
+			if err := repo.Copy("docs", "copy"); !errors.Is(err, domain.ErrExists) {
+				return fmt.Errorf("got %v, want ErrExists", err)
+			}
+			return nil
+		}, nil},
+		{"move file", func() error { return repo.Move("docs/a.txt", "moved.txt") }, func() {
+			if got := names("docs"); !slices.Equal(got, []string{"a (1).txt"}) {
+				t.Fatalf("docs lists %q", got)
+			}
+			if ok, _ := repo.Exists("moved.txt"); !ok {
+				t.Fatal("moved file is missing")
+			}
+		}},
+		{"delete folder", func() error { return repo.Delete("copy") }, func() {
+			if _, err := repo.List("copy"); !errors.Is(err, domain.ErrNotFound) {
+				t.Fatalf("deleted folder lists: %v", err)
+			}
+		}},
+		{"listing spans pages", func() error {
+			files := map[string]string{}
+			for i := range 5 {
+				files[fmt.Sprintf("f%d.txt", i)] = "x"
+			}
+			_, err := repo.Save("many", newFileHeaders(t, files), domain.ConflictReject)
+			return err
+		}, func() {
+			if got := names("many"); len(got) != 5 {
+				t.Fatalf("many lists %q", got)
+			}
+			if size, complete, err := repo.DirSize("many", 10, time.Now().Add(time.Minute)); err != nil || !complete || size != 5 {
+				t.Fatalf("size %d, %v, %v", size, complete, err)
+			}
+		}},
+		{"root can't be deleted", func() error {
+			if err := repo.Delete(""); !errors.Is(err, domain.ErrRootDeletion) {
+				return fmt.Errorf("got %v", err)
+			}
+			return nil
+		}, nil},
+	}
+	for _, s := range steps {
+		if err := s.run(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if s.check != nil {
+			s.check()
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +13,9 @@ import (
 	"gomanager/internal/infrastructure/database"
 )
 
+// shareColumns lists the columns read by every share query, in scan order
+const shareColumns = `id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at, allowed_users, title, description, message, file_id, deleted_at, allowed_referrers, notify_on_access`
+
 type shareRepository struct {
 	db *database.DB
 }
@@ -19,150 +25,331 @@ func NewShareRepository(db *database.DB) share.Repository {
 	return &shareRepository{db: db}
 }
 
-func (r *shareRepository) Create(s *share.Share) error {
-	if s.ID == "" {
-		s.ID = uuid.New().String()
+// generateShareToken creates a random URL-safe token for share links
+func generateShareToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
 	}
-	s.CreatedAt = time.Now()
+	return hex.EncodeToString(bytes), nil
+}
 
-	_, err := r.db.Exec(
-		`INSERT INTO shares (id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at) 
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		s.ID, s.Token, s.Path, s.CreatedBy, s.ShareType, s.Password, s.Permission, s.ExpiresAt, s.MaxDownloads, s.Downloads, s.IsActive, s.CreatedAt,
-	)
-	return err
+// nullIfEmpty stores empty optional strings as NULL
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
 }
 
-func (r *shareRepository) GetByID(id string) (*share.Share, error) {
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanShare reads a share selected with shareColumns
+func scanShare(row rowScanner) (*share.Share, error) {
 	s := &share.Share{}
-	var expiresAt sql.NullTime
+	var expiresAt, deletedAt sql.NullTime
 	var maxDownloads sql.NullInt64
+	var allowedUsers, title, description, message, fileID, allowedReferrers sql.NullString
 
-	err := r.db.QueryRow(
-		`SELECT id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at 
-		 FROM shares WHERE id = ?`, id,
-	).Scan(&s.ID, &s.Token, &s.Path, &s.CreatedBy, &s.ShareType, &s.Password, &s.Permission, &expiresAt, &maxDownloads, &s.Downloads, &s.IsActive, &s.CreatedAt)
-
-	if err == sql.ErrNoRows {
-		return nil, share.ErrShareNotFound
-	}
-	if err != nil {
+	if err := row.Scan(&s.ID, &s.Token, &s.Path, &s.CreatedBy, &s.ShareType, &s.Password, &s.Permission, &expiresAt, &maxDownloads, &s.Downloads, &s.IsActive, &s.CreatedAt, &allowedUsers, &title, &description, &message, &fileID, &deletedAt, &allowedReferrers, &s.NotifyOnAccess); err != nil {
 		return nil, err
 	}
 
 	if expiresAt.Valid {
 		s.ExpiresAt = &expiresAt.Time
 	}
+	if deletedAt.Valid {
+		s.DeletedAt = &deletedAt.Time
+	}
 	if maxDownloads.Valid {
 		md := int(maxDownloads.Int64)
 		s.MaxDownloads = &md
 	}
+	if allowedUsers.String != "" {
+		s.AllowedUsers = strings.Split(allowedUsers.String, ",")
+	}
+	if allowedReferrers.String != "" {
+		s.AllowedReferrers = strings.Split(allowedReferrers.String, ",")
+	}
+	s.Title = title.String
+	s.Description = description.String
+	s.Message = message.String
+	s.FileID = fileID.String
 
 	return s, nil
 }
 
-func (r *shareRepository) GetByToken(token string) (*share.Share, error) {
-	s := &share.Share{}
-	var expiresAt sql.NullTime
-	var maxDownloads sql.NullInt64
+// queryShares runs a query selecting shareColumns and collects the results
+func (r *shareRepository) queryShares(query string, args ...any) ([]share.Share, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	err := r.db.QueryRow(
-		`SELECT id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at 
-		 FROM shares WHERE token = ?`, token,
-	).Scan(&s.ID, &s.Token, &s.Path, &s.CreatedBy, &s.ShareType, &s.Password, &s.Permission, &expiresAt, &maxDownloads, &s.Downloads, &s.IsActive, &s.CreatedAt)
+	var shares []share.Share
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *s)
+	}
 
+	return shares, nil
+}
+
+func (r *shareRepository) Create(s *share.Share) error {
+	return r.CreateWithinLimit(s, 0)
+}
+
+// activeSharesOf counts a user's active, non-deleted shares inside a statement
+const activeSharesOf = `(SELECT COUNT(*) FROM shares WHERE created_by = ? AND is_active AND deleted_at IS NULL)`
+
+func (r *shareRepository) CreateWithinLimit(s *share.Share, maxActive int) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.Token == "" {
+		token, err := generateShareToken()
+		if err != nil {
+			return err
+		}
+		s.Token = token
+	}
+	s.CreatedAt = time.Now()
+
+	// Counting and inserting in one statement keeps concurrent creates from
+	// both passing the check
+	result, err := r.db.Exec(
+		`INSERT INTO shares (id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at, allowed_users, title, description, message, file_id, allowed_referrers, notify_on_access)
+		 SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		 WHERE ? <= 0 OR `+activeSharesOf+` < ?`,
+		s.ID, s.Token, s.Path, s.CreatedBy, s.ShareType, s.Password, s.Permission, s.ExpiresAt, s.MaxDownloads, s.Downloads, s.IsActive, s.CreatedAt, strings.Join(s.AllowedUsers, ","), s.Title, s.Description, s.Message, nullIfEmpty(s.FileID), strings.Join(s.AllowedReferrers, ","), s.NotifyOnAccess,
+		maxActive, s.CreatedBy, maxActive,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return share.ErrShareLimitReached
+	}
+	return nil
+}
+
+func (r *shareRepository) GetByID(id string) (*share.Share, error) {
+	s, err := scanShare(r.db.QueryRow(`SELECT `+shareColumns+` FROM shares WHERE id = ? AND deleted_at IS NULL`, id))
 	if err == sql.ErrNoRows {
 		return nil, share.ErrShareNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	return s, nil
+}
 
-	if expiresAt.Valid {
-		s.ExpiresAt = &expiresAt.Time
+func (r *shareRepository) GetByToken(token string) (*share.Share, error) {
+	s, err := scanShare(r.db.QueryRow(`SELECT `+shareColumns+` FROM shares WHERE token = ? AND deleted_at IS NULL`, token))
+	if err == sql.ErrNoRows {
+		return nil, share.ErrShareNotFound
 	}
-	if maxDownloads.Valid {
-		md := int(maxDownloads.Int64)
-		s.MaxDownloads = &md
+	if err != nil {
+		return nil, err
 	}
-
 	return s, nil
 }
 
 func (r *shareRepository) GetByUser(userID string) ([]share.Share, error) {
-	rows, err := r.db.Query(
-		`SELECT id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at 
-		 FROM shares WHERE created_by = ? ORDER BY created_at DESC`, userID,
+	return r.queryShares(`SELECT `+shareColumns+` FROM shares WHERE created_by = ? AND deleted_at IS NULL ORDER BY created_at DESC`, userID)
+}
+
+func (r *shareRepository) GetAllByUser(userID string) ([]share.Share, error) {
+	return r.queryShares(`SELECT `+shareColumns+` FROM shares WHERE created_by = ? ORDER BY created_at DESC`, userID)
+}
+
+func (r *shareRepository) GetByPath(path string) ([]share.Share, error) {
+	return r.queryShares(`SELECT `+shareColumns+` FROM shares WHERE path = ? AND deleted_at IS NULL ORDER BY created_at DESC`, path)
+}
+
+func (r *shareRepository) GetExpiredByUser(userID string) ([]share.Share, error) {
+	candidates, err := r.queryShares(
+		`SELECT `+shareColumns+` FROM shares
+		 WHERE created_by = ? AND deleted_at IS NULL AND (expires_at IS NOT NULL OR max_downloads IS NOT NULL)
+		 ORDER BY created_at DESC`,
+		userID,
 	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var shares []share.Share
-	for rows.Next() {
-		var s share.Share
-		var expiresAt sql.NullTime
-		var maxDownloads sql.NullInt64
-
-		if err := rows.Scan(&s.ID, &s.Token, &s.Path, &s.CreatedBy, &s.ShareType, &s.Password, &s.Permission, &expiresAt, &maxDownloads, &s.Downloads, &s.IsActive, &s.CreatedAt); err != nil {
-			return nil, err
+	// Timestamps are compared in Go since SQLite stores them as text
+	var expired []share.Share
+	for _, s := range candidates {
+		if s.IsExpired() || s.HasReachedMaxDownloads() {
+			expired = append(expired, s)
 		}
+	}
+	return expired, nil
+}
 
-		if expiresAt.Valid {
-			s.ExpiresAt = &expiresAt.Time
-		}
-		if maxDownloads.Valid {
-			md := int(maxDownloads.Int64)
-			s.MaxDownloads = &md
+func (r *shareRepository) ListAll(offset, limit int, filter share.ListFilter) ([]share.Share, int, error) {
+	var conditions []string
+	var args []any
+	if filter.ShareType != "" {
+		conditions = append(conditions, `share_type = ?`)
+		args = append(args, filter.ShareType)
+	}
+	if filter.Active != nil {
+		conditions = append(conditions, `is_active = ?`)
+		args = append(args, *filter.Active)
+	}
+	if filter.Deleted != nil {
+		if *filter.Deleted {
+			conditions = append(conditions, `deleted_at IS NOT NULL`)
+		} else {
+			conditions = append(conditions, `deleted_at IS NULL`)
 		}
+	}
 
-		shares = append(shares, s)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	return shares, nil
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM shares`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	shares, err := r.queryShares(`SELECT `+shareColumns+` FROM shares`+where+` ORDER BY created_at DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return shares, total, nil
 }
 
-func (r *shareRepository) GetByPath(path string) ([]share.Share, error) {
-	rows, err := r.db.Query(
-		`SELECT id, token, path, created_by, share_type, password, permission, expires_at, max_downloads, downloads, is_active, created_at 
-		 FROM shares WHERE path = ? ORDER BY created_at DESC`, path,
-	)
+func (r *shareRepository) GetSummaryByUser(userID string) (*share.Summary, error) {
+	summary := &share.Summary{}
+	err := r.db.QueryRow(
+		`SELECT COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN 1 ELSE 0 END), 0),
+		        COALESCE(SUM(CASE WHEN is_active AND deleted_at IS NULL THEN 1 ELSE 0 END), 0),
+		        COALESCE(SUM(downloads), 0),
+		        COALESCE(SUM(CASE WHEN deleted_at IS NOT NULL THEN 1 ELSE 0 END), 0)
+		 FROM shares WHERE created_by = ?`,
+		userID,
+	).Scan(&summary.TotalShares, &summary.ActiveShares, &summary.TotalDownloads, &summary.DeletedShares)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return summary, nil
+}
 
-	var shares []share.Share
-	for rows.Next() {
-		var s share.Share
-		var expiresAt sql.NullTime
-		var maxDownloads sql.NullInt64
+func (r *shareRepository) GetTopDownloadedByUser(userID string, limit int) ([]share.Share, error) {
+	return r.queryShares(`SELECT `+shareColumns+` FROM shares WHERE created_by = ? AND deleted_at IS NULL ORDER BY downloads DESC, created_at DESC LIMIT ?`, userID, limit)
+}
 
-		if err := rows.Scan(&s.ID, &s.Token, &s.Path, &s.CreatedBy, &s.ShareType, &s.Password, &s.Permission, &expiresAt, &maxDownloads, &s.Downloads, &s.IsActive, &s.CreatedAt); err != nil {
-			return nil, err
-		}
+func (r *shareRepository) Update(s *share.Share) error {
+	result, err := r.db.Exec(
+		`UPDATE shares SET token = ?, path = ?, share_type = ?, password = ?, permission = ?, expires_at = ?, max_downloads = ?, downloads = ?, is_active = ?, allowed_users = ?, title = ?, description = ?, message = ?, file_id = ?, allowed_referrers = ?, notify_on_access = ?
+		 WHERE id = ?`,
+		s.Token, s.Path, s.ShareType, s.Password, s.Permission, s.ExpiresAt, s.MaxDownloads, s.Downloads, s.IsActive, strings.Join(s.AllowedUsers, ","), s.Title, s.Description, s.Message, nullIfEmpty(s.FileID), strings.Join(s.AllowedReferrers, ","), s.NotifyOnAccess, s.ID,
+	)
+	if err != nil {
+		return err
+	}
 
-		if expiresAt.Valid {
-			s.ExpiresAt = &expiresAt.Time
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return share.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *shareRepository) UpdatePathPrefix(oldPath, newPath string) (int, error) {
+	oldPath = normalizeIndexPath(oldPath)
+	newPath = normalizeIndexPath(newPath)
+	if oldPath == "" || newPath == "" {
+		return 0, share.ErrInvalidPath
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Share paths may have been stored with or without a leading slash
+	changed := 0
+	for _, old := range []string{oldPath, "/" + oldPath} {
+		result, err := tx.Exec(`UPDATE shares SET path = ? WHERE path = ?`, newPath, old)
+		if err != nil {
+			return 0, err
 		}
-		if maxDownloads.Valid {
-			md := int(maxDownloads.Int64)
-			s.MaxDownloads = &md
+		rows, _ := result.RowsAffected()
+		changed += int(rows)
+
+		// substr avoids LIKE so names containing % or _ match literally
+		prefix := old + "/"
+		result, err = tx.Exec(
+			`UPDATE shares SET path = CAST(? AS TEXT) || substr(path, ?) WHERE substr(path, 1, ?) = ?`,
+			newPath+"/", textLen(prefix)+1, textLen(prefix), prefix,
+		)
+		if err != nil {
+			return 0, err
 		}
-
-		shares = append(shares, s)
+		rows, _ = result.RowsAffected()
+		changed += int(rows)
 	}
 
-	return shares, nil
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return changed, nil
 }
 
-func (r *shareRepository) Update(s *share.Share) error {
+func (r *shareRepository) SetOwner(ids []string, userID string, maxActive int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	idArgs := make([]any, len(ids))
+	for i, id := range ids {
+		idArgs[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	// The new owner's active shares plus the active ones they receive stay
+	// the same as rows change hands, so the check holds for the whole update
+	args := append([]any{userID}, idArgs...)
+	args = append(args, maxActive, userID)
+	args = append(args, idArgs...)
+	args = append(args, userID, maxActive)
 	result, err := r.db.Exec(
-		`UPDATE shares SET token = ?, path = ?, share_type = ?, password = ?, permission = ?, expires_at = ?, max_downloads = ?, downloads = ?, is_active = ? 
-		 WHERE id = ?`,
-		s.Token, s.Path, s.ShareType, s.Password, s.Permission, s.ExpiresAt, s.MaxDownloads, s.Downloads, s.IsActive, s.ID,
+		`UPDATE shares SET created_by = ? WHERE id IN (`+placeholders+`)
+		 AND (? <= 0 OR `+activeSharesOf+` +
+		      (SELECT COUNT(*) FROM shares WHERE id IN (`+placeholders+`) AND is_active AND deleted_at IS NULL AND created_by <> ?) <= ?)`,
+		args...,
 	)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 && maxActive > 0 {
+		// Nothing matched, or the limit refused the whole transfer
+		var found int
+		if err := r.db.QueryRow(`SELECT COUNT(*) FROM shares WHERE id IN (`+placeholders+`)`, idArgs...).Scan(&found); err != nil {
+			return 0, err
+		}
+		if found > 0 {
+			return 0, share.ErrShareLimitReached
+		}
+	}
+	return int(rows), nil
+}
+
+func (r *shareRepository) SoftDelete(id string) error {
+	result, err := r.db.Exec(`UPDATE shares SET deleted_at = ?, is_active = ? WHERE id = ? AND deleted_at IS NULL`, time.Now(), false, id)
 	if err != nil {
 		return err
 	}
@@ -187,6 +374,26 @@ func (r *shareRepository) Delete(id string) error {
 	return nil
 }
 
+func (r *shareRepository) PurgeDeleted(cutoff time.Time) (int, error) {
+	candidates, err := r.queryShares(`SELECT ` + shareColumns + ` FROM shares WHERE deleted_at IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+
+	// Timestamps are compared in Go since SQLite stores them as text
+	purged := 0
+	for _, s := range candidates {
+		if !s.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if _, err := r.db.Exec(`DELETE FROM shares WHERE id = ?`, s.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
 func (r *shareRepository) IncrementDownloads(id string) error {
 	result, err := r.db.Exec(`UPDATE shares SET downloads = downloads + 1 WHERE id = ?`, id)
 	if err != nil {
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"gomanager/internal/domain/share"
+)
+
+func TestShareUpdatePathPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldPath string
+		newPath string
+		stored  string
+		want    string
+	}{
+		{"the folder itself", "Fotos/Año", "Fotos/Year", "Fotos/Año", "Fotos/Year"},
+		{"file below a non-ASCII folder", "Fotos/Año", "Fotos/Year", "Fotos/Año/a.jpg", "Fotos/Year/a.jpg"},
+		{"leading slash", "Fotos/Año", "Fotos/Year", "/Fotos/Año/a.jpg", "Fotos/Year/a.jpg"},
+		{"non-ASCII new name", "docs", "Überblick", "docs/b.txt", "Überblick/b.txt"},
+		{"sibling sharing the prefix", "Fotos/Año", "Fotos/Year", "Fotos/Año2/a.jpg", "Fotos/Año2/a.jpg"},
+		{"literal wildcard", "a_b", "c", "axb/f", "axb/f"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			newTestUser(t, db, "u1")
+			repo := NewShareRepository(db)
+			s := &share.Share{Path: tt.stored, CreatedBy: "u1", ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+			if err := repo.Create(s); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.UpdatePathPrefix(tt.oldPath, tt.newPath); err != nil {
+				t.Fatal(err)
+			}
+			got, err := repo.GetByID(s.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Path != tt.want {
+				t.Fatalf("path %q, want %q", got.Path, tt.want)
+			}
+		})
+	}
+}
+
+// newTestShare returns an active share of p owned by userID
+func newTestShare(userID, p string) *share.Share {
+	return &share.Share{Path: p, CreatedBy: userID, ShareType: share.ShareTypePublic, Permission: share.PermissionView, IsActive: true}
+}
+
+func TestShareCreateWithinLimit(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "u1")
+	repo := NewShareRepository(db)
+
+	first := newTestShare("u1", "a")
+	steps := []struct {
+		name  string
+		share *share.Share
+		limit int
+		want  error
+	}{
+		{"first", first, 2, nil},
+		{"second", newTestShare("u1", "b"), 2, nil},
+		{"over the limit", newTestShare("u1", "c"), 2, share.ErrShareLimitReached},
+		{"unlimited", newTestShare("u1", "d"), 0, nil},
+		{"higher limit", newTestShare("u1", "e"), 5, nil},
+	}
+	for _, s := range steps {
+		if err := repo.CreateWithinLimit(s.share, s.limit); !errors.Is(err, s.want) {
+			t.Fatalf("%s: got %v, want %v", s.name, err, s.want)
+		}
+	}
+
+	// Deleted shares don't count
+	if err := repo.SoftDelete(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateWithinLimit(newTestShare("u1", "f"), 4); err != nil {
+		t.Fatalf("after deleting one: %v", err)
+	}
+}
+
+func TestShareCreateWithinLimitConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "u1")
+	repo := NewShareRepository(db)
+
+	const limit = 3
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.CreateWithinLimit(newTestShare("u1", "a"), limit)
+			if err != nil && !errors.Is(err, share.ErrShareLimitReached) {
+				t.Error(err)
+			}
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if created != limit {
+		t.Fatalf("created %d shares, want %d", created, limit)
+	}
+}
+
+func TestShareSetOwnerLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		want  error
+	}{
+		{"unlimited", 0, nil},
+		{"fits exactly", 3, nil},
+		{"over the limit", 2, share.ErrShareLimitReached},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			newTestUser(t, db, "from")
+			newTestUser(t, db, "to")
+			repo := NewShareRepository(db)
+
+			// The recipient has one share and receives two active ones and a
+			// deleted one, which doesn't count
+			owned, a, b, deleted := newTestShare("to", "x"), newTestShare("from", "a"), newTestShare("from", "b"), newTestShare("from", "c")
+			for _, s := range []*share.Share{owned, a, b, deleted} {
+				if err := repo.Create(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := repo.SoftDelete(deleted.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := repo.SetOwner([]string{a.ID, b.ID, deleted.ID}, "to", tt.limit)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			wantOwner, wantN := "to", 3
+			if tt.want != nil {
+				wantOwner, wantN = "from", 0
+			}
+			if n != wantN {
+				t.Fatalf("changed %d shares, want %d", n, wantN)
+			}
+			for _, s := range []*share.Share{a, b} {
+				if got, err := repo.GetByID(s.ID); err != nil || got.CreatedBy != wantOwner {
+					t.Fatalf("share %s owned by %v (%v), want %s", s.Path, got, err, wantOwner)
+				}
+			}
+		})
+	}
+}
+
+func TestShareTitleRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "u1")
+	repo := NewShareRepository(db)
+
+	s := newTestShare("u1", "photos")
+	s.Title, s.Description = "Project photos", "For the client"
+	if err := repo.Create(s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.GetByToken(s.Token)
+	if err != nil || got.Title != "Project photos" || got.Description != "For the client" {
+		t.Fatalf("created share %+v, %v", got, err)
+	}
+
+	got.Title, got.Description = "Renamed", ""
+	if err := repo.Update(got); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.GetByID(s.ID)
+	if err != nil || got.Title != "Renamed" || got.Description != "" {
+		t.Fatalf("updated share %+v, %v", got, err)
+	}
+
+	// Shares stored before the columns existed have them NULL
+	if _, err := db.Exec(`INSERT INTO shares (id, token, path, created_by, share_type, password, permission) VALUES ('legacy', 'legacy-token', 'old.txt', 'u1', 'public', '', 'view')`); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := repo.GetByID("legacy")
+	if err != nil || legacy.Title != "" || legacy.Description != "" {
+		t.Fatalf("legacy share %+v, %v", legacy, err)
+	}
+}
+
+func TestShareSummary(t *testing.T) {
+	db := newTestDB(t)
+	newTestUser(t, db, "u1")
+	newTestUser(t, db, "u2")
+	repo := NewShareRepository(db)
+
+	seed := []struct {
+		owner     string
+		path      string
+		downloads int
+		active    bool
+	}{
+		{"u1", "a", 3, true},
+		{"u1", "b", 10, true},
+		{"u1", "c", 0, true},
+		{"u1", "d", 7, false},
+		{"u1", "e", 1, true},
+		{"u1", "f", 5, true},
+		{"u2", "g", 100, true},
+	}
+	for _, s := range seed {
+		sh := newTestShare(s.owner, s.path)
+		sh.Downloads = s.downloads
+		sh.IsActive = s.active
+		if err := repo.Create(sh); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary, err := repo.GetSummaryByUser("u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalShares != 6 || summary.ActiveShares != 5 || summary.TotalDownloads != 26 {
+		t.Errorf("got %d shares, %d active, %d downloads; want 6, 5, 26", summary.TotalShares, summary.ActiveShares, summary.TotalDownloads)
+	}
+
+	top, err := repo.GetTopDownloadedByUser("u1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, s := range top {
+		paths = append(paths, s.Path)
+	}
+	if want := []string{"b", "d", "f", "a", "e"}; !slices.Equal(paths, want) {
+		t.Errorf("top shares %v, want %v", paths, want)
+	}
+
+	empty, err := repo.GetSummaryByUser("nobody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.TotalShares != 0 || empty.ActiveShares != 0 || empty.TotalDownloads != 0 {
+		t.Errorf("summary for a user without shares %+v, want zeros", empty)
+	}
+}
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	domain "gomanager/internal/domain/file"
+)
+
+const (
+	// uploadsDir holds in-progress resumable uploads under the storage root
+	uploadsDir = ".uploads"
+	// uploadSweepInterval is how often expired uploads are removed
+	uploadSweepInterval = time.Hour
+)
+
+// uploadStore keeps each pending upload as <id>.bin (data) and <id>.json (metadata).
+// The offset is the size of the data file, so progress survives restarts.
+// An upload expires once its data file goes unchanged for expiry.
+type uploadStore struct {
+	dir     string
+	target  domain.Repository  // Receives completed uploads
+	scanner domain.FileScanner // Vets completed uploads; nil disables
+	expiry  time.Duration      // Zero keeps pending uploads forever
+
+	// locks serializes appends per upload
+	locks sync.Map
+}
+
+// NewUploadStore creates a store for resumable uploads that stages data on
+// local disk under basePath and imports completed uploads into target.
+// Uploads left without new data for expiry are removed; zero keeps them.
+func NewUploadStore(basePath string, target domain.Repository, scanner domain.FileScanner, expiry time.Duration) domain.UploadStore {
+	dir := filepath.Join(basePath, uploadsDir)
+	os.MkdirAll(dir, 0755)
+	s := &uploadStore{dir: dir, target: target, scanner: scanner, expiry: expiry}
+	if expiry > 0 {
+		go func() {
+			ticker := time.NewTicker(uploadSweepInterval)
+			defer ticker.Stop()
+			for {
+				if removed := s.removeExpired(time.Now().Add(-expiry)); removed > 0 {
+					log.Printf("upload sweep removed %d expired file(s)", removed)
+				}
+				<-ticker.C
+			}
+		}()
+	}
+	return s
+}
+
+// removeExpired deletes uploads whose data hasn't changed since cutoff, and
+// staging files left behind by interrupted requests, returning how many
+// files were removed
+func (s *uploadStore) removeExpired(cutoff time.Time) int {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("upload sweep failed: %v", err)
+		return 0
+	}
+
+	removed := 0
+	remove := func(name string) {
+		if err := os.Remove(filepath.Join(s.dir, name)); err == nil {
+			removed++
+		} else if !os.IsNotExist(err) {
+			log.Printf("upload sweep: failed to remove %s: %v", name, err)
+		}
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if id, ok := strings.CutSuffix(name, ".json"); ok {
+			if _, err := uuid.Parse(id); err != nil {
+				continue
+			}
+			// Held so an append or completion in progress finishes first
+			unlock := s.lock(id)
+			dataPath, _, _ := s.paths(id)
+			if info, err := os.Stat(dataPath); err != nil || info.ModTime().Before(cutoff) {
+				remove(id + ".bin")
+				remove(name)
+				s.locks.Delete(id)
+			}
+			unlock()
+			continue
+		}
+		if strings.HasSuffix(name, ".bin") {
+			continue // Removed along with its metadata
+		}
+		// Raw uploads and scans still in progress keep being written to
+		if info, err := entry.Info(); err == nil && !entry.IsDir() && info.ModTime().Before(cutoff) {
+			remove(name)
+		}
+	}
+	return removed
+}
+
+func (s *uploadStore) lock(id string) func() {
+	mu, _ := s.locks.LoadOrStore(id, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// paths returns the data and metadata files for id, rejecting anything that
+// isn't a plain ID so it can't escape the uploads directory
+func (s *uploadStore) paths(id string) (string, string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", "", domain.ErrUploadNotFound
+	}
+	return filepath.Join(s.dir, id+".bin"), filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *uploadStore) Create(upload *domain.PendingUpload) error {
+	if upload.ID == "" {
+		upload.ID = uuid.New().String()
+	}
+	dataPath, metaPath, err := s.paths(upload.ID)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dataPath, nil, 0644); err != nil {
+		return domain.ErrUploadFailed
+	}
+	if err := os.WriteFile(metaPath, meta, 0644); err != nil {
+		os.Remove(dataPath)
+		return domain.ErrUploadFailed
+	}
+	upload.Offset = 0
+	if s.expiry > 0 {
+		upload.ExpiresAt = time.Now().Add(s.expiry)
+	}
+	return nil
+}
+
+func (s *uploadStore) Get(id string) (*domain.PendingUpload, error) {
+	dataPath, metaPath, err := s.paths(id)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, domain.ErrUploadNotFound
+	}
+	var upload domain.PendingUpload
+	if err := json.Unmarshal(meta, &upload); err != nil {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return nil, domain.ErrUploadNotFound
+	}
+	if s.expiry > 0 {
+		upload.ExpiresAt = info.ModTime().Add(s.expiry)
+		if time.Now().After(upload.ExpiresAt) {
+			return nil, domain.ErrUploadNotFound
+		}
+	}
+	upload.Offset = info.Size()
+	return &upload, nil
+}
+
+func (s *uploadStore) Append(id string, offset int64, data io.Reader) (int64, error) {
+	defer s.lock(id)()
+
+	upload, err := s.Get(id)
+	if err != nil {
+		return 0, err
+	}
+	if offset != upload.Offset {
+		return upload.Offset, domain.ErrOffsetMismatch
+	}
+
+	dataPath, _, _ := s.paths(id)
+	f, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return offset, domain.ErrUploadFailed
+	}
+	defer f.Close()
+
+	// Read one byte past the remaining length to detect oversized bodies
+	remaining := upload.Length - offset
+	written, copyErr := io.Copy(f, io.LimitReader(data, remaining+1))
+	if written > remaining {
+		f.Truncate(upload.Length)
+		return upload.Length, domain.ErrUploadTooLarge
+	}
+	if copyErr != nil {
+		return offset + written, copyErr
+	}
+	return offset + written, nil
+}
+
+func (s *uploadStore) Complete(id string, policy domain.ConflictPolicy) (string, error) {
+	defer s.lock(id)()
+
+	upload, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if !upload.IsComplete() {
+		return "", domain.ErrUploadIncomplete
+	}
+
+	dataPath, metaPath, _ := s.paths(id)
+
+	// The data is still hidden under .uploads, so scan it there under a name
+	// carrying the original extension
+	if s.scanner != nil {
+		scanPath := filepath.Join(s.dir, id+".scan"+filepath.Ext(upload.Filename))
+		if err := os.Rename(dataPath, scanPath); err != nil {
+			return "", domain.ErrUploadFailed
+		}
+		if err := s.scanner.Scan(scanPath); err != nil {
+			os.Remove(scanPath)
+			os.Remove(metaPath)
+			s.locks.Delete(id)
+			log.Printf("upload scan: rejected %s: %v", upload.Filename, err)
+			return "", domain.ErrScanRejected
+		}
+		if err := os.Rename(scanPath, dataPath); err != nil {
+			return "", domain.ErrUploadFailed
+		}
+	}
+
+	finalPath, err := s.target.Import(dataPath, sanitizeRelative(upload.Path), upload.Filename, policy)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(metaPath)
+	s.locks.Delete(id)
+
+	return finalPath, nil
+}
+
+func (s *uploadStore) Receive(dir, filename string, data io.Reader, policy domain.ConflictPolicy) (string, error) {
+	// Staged under .uploads with the original extension, so it stays hidden
+	// until it has been scanned
+	f, err := os.CreateTemp(s.dir, "raw-*"+filepath.Ext(filename))
+	if err != nil {
+		return "", domain.ErrUploadFailed
+	}
+	stagedPath := f.Name()
+	_, copyErr := io.Copy(f, data)
+	closeErr := f.Close()
+	if copyErr != nil {
+		os.Remove(stagedPath)
+		return "", copyErr
+	}
+	if closeErr != nil {
+		os.Remove(stagedPath)
+		return "", domain.ErrUploadFailed
+	}
+
+	if s.scanner != nil {
+		if err := s.scanner.Scan(stagedPath); err != nil {
+			os.Remove(stagedPath)
+			log.Printf("upload scan: rejected %s: %v", filename, err)
+			return "", domain.ErrScanRejected
+		}
+	}
+
+	finalPath, err := s.target.Import(stagedPath, sanitizeRelative(dir), filename, policy)
+	if err != nil {
+		os.Remove(stagedPath)
+		return "", err
+	}
+	return finalPath, nil
+}
+
+// sanitizeRelative cleans a client path so it stays under the storage root
+func sanitizeRelative(p string) string {
+	cleaned := filepath.Clean("/" + p)
+	return filepath.Clean(cleaned[1:])
+}
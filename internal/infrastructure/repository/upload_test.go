@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// newFileHeaders returns multipart headers for files, a map of names to contents
+func newFileHeaders(t *testing.T, files map[string]string) []*multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		w, err := mw.CreateFormFile("files", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return r.MultipartForm.File["files"]
+}
+
+// gateScanner holds each scan until release is closed and rejects names in reject
+type gateScanner struct {
+	release chan struct{}
+	reject  string
+}
+
+func (s gateScanner) Scan(p string) error {
+	<-s.release
+	if filepath.Ext(p) == s.reject {
+		return errors.New("blocked")
+	}
+	return nil
+}
+
+func TestAsyncScanPlacement(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		takeName  bool // Another file takes the name while the scan runs
+		wantPath  string
+		wantTaken string // Content expected at the original name afterwards
+	}{
+		{"clean file", "a.txt", false, "a.txt", "new"},
+		{"name taken during the scan", "a.txt", true, "a (1).txt", "other"},
+		{"rejected file", "a.exe", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			scanner := gateScanner{release: make(chan struct{}), reject: ".exe"}
+			repo := NewFilesystemRepository(dir, scanner, true)
+			placed := make(chan string, 1)
+			repo.(domain.PlacementNotifier).OnPlaced(func(p string) { placed <- p })
+
+			result, err := repo.Save("", newFileHeaders(t, map[string]string{tt.file: "new"}), domain.ConflictReject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Pending) != 1 {
+				t.Fatalf("pending %v, want the file", result.Pending)
+			}
+			if tt.takeName {
+				os.WriteFile(filepath.Join(dir, tt.file), []byte("other"), 0644)
+			}
+			close(scanner.release)
+
+			var got string
+			select {
+			case got = <-placed:
+			case <-time.After(200 * time.Millisecond):
+			}
+			if got != tt.wantPath {
+				t.Fatalf("placed %q, want %q", got, tt.wantPath)
+			}
+			if tt.wantPath != "" {
+				if data, _ := os.ReadFile(filepath.Join(dir, tt.wantPath)); string(data) != "new" {
+					t.Errorf("%s holds %q", tt.wantPath, data)
+				}
+				if data, _ := os.ReadFile(filepath.Join(dir, tt.file)); string(data) != tt.wantTaken {
+					t.Errorf("%s holds %q, want %q", tt.file, data, tt.wantTaken)
+				}
+			}
+			// Nothing is left behind in quarantine
+			if entries, _ := os.ReadDir(filepath.Join(dir, quarantineDir)); len(entries) != 0 {
+				t.Errorf("quarantine holds %v", entries)
+			}
+		})
+	}
+}
+
+func TestRemoveExpiredUploads(t *testing.T) {
+	dir := t.TempDir()
+	s := NewUploadStore(dir, NewFilesystemRepository(dir, nil, false), nil, 0).(*uploadStore)
+	s.expiry = time.Hour
+	old := time.Now().Add(-2 * time.Hour)
+
+	create := func(lastWrite time.Time) string {
+		t.Helper()
+		upload := &domain.PendingUpload{Filename: "a.txt", Length: 10}
+		if err := s.Create(upload); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Append(upload.ID, 0, bytes.NewBufferString("12345")); err != nil {
+			t.Fatal(err)
+		}
+		dataPath, _, _ := s.paths(upload.ID)
+		os.Chtimes(dataPath, lastWrite, lastWrite)
+		return upload.ID
+	}
+	stale, fresh := create(old), create(time.Now())
+	orphan := create(time.Now())
+	dataPath, _, _ := s.paths(orphan)
+	os.Remove(dataPath)
+	staging := func(name string, modTime time.Time) {
+		os.WriteFile(filepath.Join(s.dir, name), []byte("x"), 0644)
+		os.Chtimes(filepath.Join(s.dir, name), modTime, modTime)
+	}
+	staging("raw-stale.txt", old)
+	staging("raw-fresh.txt", time.Now())
+
+	if _, err := s.Get(stale); !errors.Is(err, domain.ErrUploadNotFound) {
+		t.Fatalf("expired upload: got %v, want ErrUploadNotFound", err)
+	}
+	if upload, err := s.Get(fresh); err != nil || upload.ExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Fatalf("fresh upload: got %+v, %v", upload, err)
+	}
+
+	if removed := s.removeExpired(time.Now().Add(-s.expiry)); removed != 4 {
+		t.Fatalf("removed %d files, want 4", removed)
+	}
+	for _, tt := range []struct {
+		name string
+		kept bool
+	}{
+		{stale + ".bin", false},
+		{stale + ".json", false},
+		{orphan + ".json", false},
+		{"raw-stale.txt", false},
+		{fresh + ".bin", true},
+		{fresh + ".json", true},
+		{"raw-fresh.txt", true},
+	} {
+		if _, err := os.Stat(filepath.Join(s.dir, tt.name)); (err == nil) != tt.kept {
+			t.Errorf("%s: kept = %v, want %v", tt.name, err == nil, tt.kept)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -219,6 +220,61 @@ func (r *userRepository) List() ([]user.User, error) {
 	return users, nil
 }
 
+func (r *userRepository) ListPaged(offset, limit int, search, roleFilter string) ([]user.User, int, error) {
+	var conditions []string
+	var args []interface{}
+	if search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+		conditions = append(conditions, `(LOWER(username) LIKE %s ESCAPE '\' OR LOWER(email) LIKE %s ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if roleFilter != "" {
+		conditions = append(conditions, `role = %s`)
+		args = append(args, roleFilter)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	countQuery := r.getPlaceholderQuery(`SELECT COUNT(*) FROM users`+where, len(args))
+	if err := r.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := r.getPlaceholderQuery(
+		`SELECT id, email, username, password, role, auth_provider, google_id, google_token, avatar_url, created_at, updated_at 
+		 FROM users`+where+` ORDER BY created_at DESC LIMIT %s OFFSET %s`,
+		len(args)+2)
+	rows, err := r.db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]user.User, 0, limit)
+	for rows.Next() {
+		var u user.User
+		var googleID, googleToken, avatarURL sql.NullString
+		if err := rows.Scan(&u.ID, &u.Email, &u.Username, &u.Password, &u.Role, &u.AuthProvider, &googleID, &googleToken, &avatarURL, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		u.GoogleID = googleID.String
+		u.GoogleToken = googleToken.String
+		u.AvatarURL = avatarURL.String
+		users = append(users, u)
+	}
+	return users, total, nil
+}
+
+// escapeLike escapes LIKE wildcards so search terms match literally
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
+
 func (r *userRepository) Count() (int, error) {
 	var count int
 	err := r.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
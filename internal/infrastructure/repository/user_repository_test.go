@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"gomanager/internal/domain/user"
+)
+
+func TestUserListPaged(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+	start := time.Now()
+	for i, u := range []struct {
+		id, email, username string
+		role                user.Role
+	}{
+		{"u1", "alice@example.com", "alice", user.RoleAdmin},
+		{"u2", "bob@corp.example", "Bob", user.RoleUser},
+		{"u3", "carol@example.com", "carol_smith", user.RoleUser},
+		{"u4", "dave@corp.example", "dave", user.RoleViewer},
+	} {
+		// Newest first, so u4 lists before u1
+		created := start.Add(time.Duration(i) * time.Second)
+		if err := repo.Create(&user.User{ID: u.id, Email: u.email, Username: u.username, Role: u.role, AuthProvider: user.AuthProviderLocal, CreatedAt: created, UpdatedAt: created}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		search, role  string
+		want          []string
+		wantTotal     int
+	}{
+		{"everyone", 0, 10, "", "", []string{"u4", "u3", "u2", "u1"}, 4},
+		{"first page", 0, 2, "", "", []string{"u4", "u3"}, 4},
+		{"second page", 2, 2, "", "", []string{"u2", "u1"}, 4},
+		{"past the end", 4, 2, "", "", []string{}, 4},
+		{"search by email", 0, 10, "corp", "", []string{"u4", "u2"}, 2},
+		{"search ignores case", 0, 10, "BOB", "", []string{"u2"}, 1},
+		{"search by username", 0, 10, "carol", "", []string{"u3"}, 1},
+		{"underscore is literal", 0, 10, "l_s", "", []string{"u3"}, 1},
+		{"role filter", 0, 10, "", "user", []string{"u3", "u2"}, 2},
+		{"search and role", 0, 10, "corp", "viewer", []string{"u4"}, 1},
+		{"no match", 0, 10, "zed", "", []string{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := repo.ListPaged(tt.offset, tt.limit, tt.search, tt.role)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, u := range users {
+				ids = append(ids, u.ID)
+			}
+			if !slices.Equal(ids, tt.want) || total != tt.wantTotal {
+				t.Fatalf("got %v of %d, want %v of %d", ids, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// clamavChunkSize is the INSTREAM chunk size; clamd's StreamMaxLength
+// still bounds the total
+const clamavChunkSize = 64 << 10
+
+// clamavScanner streams files to clamd over TCP using the INSTREAM command
+type clamavScanner struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamAVScanner scans files with the clamd daemon at addr (host:port).
+// Connection failures reject the file so an outage can't let uploads through.
+func NewClamAVScanner(addr string, timeout time.Duration) domain.FileScanner {
+	return &clamavScanner{addr: addr, timeout: timeout}
+}
+
+func (s *clamavScanner) Scan(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	conn, err := net.DialTimeout("tcp", s.addr, s.timeout)
+	if err != nil {
+		return fmt.Errorf("clamav unavailable: %w", err)
+	}
+	defer conn.Close()
+	if s.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("clamav: %w", err)
+	}
+
+	buf := make([]byte, clamavChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := f.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return fmt.Errorf("clamav: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return fmt.Errorf("clamav: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	// A zero-length chunk ends the stream
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return fmt.Errorf("clamav: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return fmt.Errorf("clamav: %w", err)
+	}
+	reply = strings.TrimRight(reply, "\x00\n")
+
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND"
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return fmt.Errorf("infected: %s", strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND"))
+	default:
+		return fmt.Errorf("clamav: %s", reply)
+	}
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	domain "gomanager/internal/domain/file"
+)
+
+// extensionScanner rejects files by extension. Quarantined uploads keep
+// their original extension so it can be checked here.
+type extensionScanner struct {
+	blocked map[string]bool
+}
+
+// NewExtensionScanner rejects files whose extension is in blocked
+// (case-insensitive, with or without the leading dot). It returns nil when
+// nothing is blocked.
+func NewExtensionScanner(blocked []string) domain.FileScanner {
+	s := &extensionScanner{blocked: make(map[string]bool, len(blocked))}
+	for _, ext := range blocked {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		s.blocked[ext] = true
+	}
+	if len(s.blocked) == 0 {
+		return nil
+	}
+	return s
+}
+
+func (s *extensionScanner) Scan(path string) error {
+	if ext := strings.ToLower(filepath.Ext(path)); s.blocked[ext] {
+		return fmt.Errorf("extension %s is not allowed", ext)
+	}
+	return nil
+}
+
+// chain runs scanners in order and stops at the first rejection
+type chain []domain.FileScanner
+
+// Chain combines scanners; it returns nil when none are given so callers can
+// treat "no scanning" uniformly
+func Chain(scanners ...domain.FileScanner) domain.FileScanner {
+	var c chain
+	for _, s := range scanners {
+		if s != nil {
+			c = append(c, s)
+		}
+	}
+	if len(c) == 0 {
+		return nil
+	}
+	return c
+}
+
+func (c chain) Scan(path string) error {
+	for _, s := range c {
+		if err := s.Scan(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -9,10 +9,17 @@ import (
 	authService "gomanager/internal/application/auth"
 	fileService "gomanager/internal/application/file"
 	"gomanager/internal/delivery/http/handler"
+	"gomanager/internal/delivery/http/middleware"
 	"gomanager/internal/delivery/http/router"
+	fileDomain "gomanager/internal/domain/file"
+	"gomanager/internal/domain/share"
+	"gomanager/internal/domain/user"
+	"gomanager/internal/infrastructure/captcha"
 	"gomanager/internal/infrastructure/config"
 	"gomanager/internal/infrastructure/database"
+	"gomanager/internal/infrastructure/notify"
 	"gomanager/internal/infrastructure/repository"
+	"gomanager/internal/infrastructure/scanner"
 )
 
 func main() {
@@ -20,7 +27,10 @@ func main() {
 	cfg := config.Load()
 
 	// Initialize database (supports both PostgreSQL and SQLite)
-	db, err := database.NewDatabase(cfg.DatabasePath)
+	db, err := database.NewDatabase(cfg.DatabasePath, database.PoolConfig{
+		MaxOpenConns: cfg.DBMaxOpenConns,
+		MaxIdleConns: cfg.DBMaxIdleConns,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -31,24 +41,136 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// SQLite files don't shrink after deletes without a periodic VACUUM
+	if cfg.DBVacuumInterval != "" {
+		interval, err := time.ParseDuration(cfg.DBVacuumInterval)
+		if err != nil {
+			log.Fatalf("Invalid DB_VACUUM_INTERVAL %q (expected a duration like 24h)", cfg.DBVacuumInterval)
+		}
+		db.StartVacuum(interval)
+	}
+
+	// Upload scanning is off unless a blocklist or ClamAV is configured
+	var clamav fileDomain.FileScanner
+	if cfg.ClamAVAddr != "" {
+		clamav = scanner.NewClamAVScanner(cfg.ClamAVAddr, time.Duration(cfg.ClamAVTimeout)*time.Second)
+	}
+	blockedExtensions := scanner.NewExtensionScanner(cfg.BlockedUploadExtensions)
+	uploadScanner := scanner.Chain(blockedExtensions, clamav)
+
+	switch cfg.UploadNameSanitize {
+	case fileDomain.NameSanitizeOff, fileDomain.NameSanitizePOSIX, fileDomain.NameSanitizePortable:
+	default:
+		log.Fatalf("Invalid UPLOAD_NAME_SANITIZE %q (expected off, posix or portable)", cfg.UploadNameSanitize)
+	}
+
 	// Initialize repositories
-	fileRepo := repository.NewFilesystemRepository(cfg.StoragePath)
+	var fileRepo fileDomain.Repository
+	switch cfg.StorageBackend {
+	case "filesystem":
+		fileRepo = repository.NewFilesystemRepository(cfg.StoragePath, uploadScanner, cfg.UploadScanAsync)
+	case "s3":
+		fileRepo, err = repository.NewS3Repository(repository.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			Prefix:    cfg.S3Prefix,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		}, uploadScanner)
+		if err != nil {
+			log.Fatal("Failed to initialize S3 storage:", err)
+		}
+	default:
+		log.Fatalf("Invalid STORAGE_BACKEND %q (expected filesystem or s3)", cfg.StorageBackend)
+	}
 	userRepo := repository.NewUserRepository(db)
 	sessionRepo := repository.NewSessionRepository(db)
 	shareRepo := repository.NewShareRepository(db)
+	fileIndex := repository.NewFileIndexRepository(db)
+	fileMeta := repository.NewFileMetadataRepository(db)
 
 	// Initialize services
-	fileSvc := fileService.NewService(fileRepo)
-	authSvc := authService.NewService(userRepo, sessionRepo, time.Duration(cfg.TokenExpiry)*time.Hour)
+	fileEvents := fileService.NewEventBroker()
+	thumbnails := handler.NewThumbnailCache(fileRepo, cfg.StoragePath)
+	if cfg.PregenerateThumbnails {
+		thumbnails.Pregenerate()
+	}
+	fileSvc := fileService.NewService(fileRepo, fileIndex, fileMeta, repository.NewUploadStore(cfg.StoragePath, fileRepo, uploadScanner, time.Duration(cfg.ResumableUploadExpiry)*time.Hour), fileService.ListingCacheConfig{
+		Size: cfg.ListingCacheSize,
+		TTL:  time.Duration(cfg.ListingCacheTTL) * time.Second,
+	}, cfg.MaxPathDepth, cfg.HiddenPaths, fileDomain.NameSanitizer{
+		Mode:          cfg.UploadNameSanitize,
+		AllowDotfiles: cfg.UploadNameAllowDotfiles,
+	}, blockedExtensions, fileService.Publishers{handler.NewSharePathFollower(shareRepo), thumbnails, fileEvents})
+	passwordHasher, err := authService.NewPasswordHasher(cfg.PasswordHashAlgo)
+	if err != nil {
+		log.Fatal("Invalid PASSWORD_HASH_ALGO:", err)
+	}
+	tokens := authService.TokenConfig{Mode: cfg.TokenMode}
+	switch cfg.TokenMode {
+	case authService.TokenModeSession:
+	case authService.TokenModeJWT:
+		if len(cfg.JWTSecret) < 32 {
+			log.Fatal("TOKEN_MODE=jwt requires a JWT_SECRET of at least 32 characters")
+		}
+		tokens.JWTSecret = cfg.JWTSecret
+		if cfg.JWTDenylist {
+			tokens.Revocations = repository.NewRevokedTokenRepository(db)
+		}
+	default:
+		log.Fatalf("Invalid TOKEN_MODE %q (expected session or jwt)", cfg.TokenMode)
+	}
+	registration := authService.RegistrationConfig{
+		Enabled: cfg.RegistrationEnabled,
+		Mode:    cfg.RegistrationMode,
+		Invites: repository.NewInviteRepository(db),
+	}
+	switch cfg.RegistrationMode {
+	case authService.RegistrationModeOpen, authService.RegistrationModeInvite:
+	default:
+		log.Fatalf("Invalid REGISTRATION_MODE %q (expected open or invite)", cfg.RegistrationMode)
+	}
+	authSvc := authService.NewService(userRepo, sessionRepo, time.Duration(cfg.TokenExpiry)*time.Hour, passwordHasher, tokens, authService.LockoutConfig{
+		MaxFailures: cfg.LoginMaxFailures,
+		BaseDelay:   time.Duration(cfg.LoginLockoutSeconds) * time.Second,
+		MaxDelay:    time.Duration(cfg.LoginLockoutMaxSeconds) * time.Second,
+	}, registration)
 
 	// Initialize handlers
-	fileHandler := handler.NewFileHandler(fileSvc, cfg.MaxFileSize)
-	authHandler := handler.NewAuthHandler(authSvc)
-	shareHandler := handler.NewShareHandler(shareRepo, fileSvc, cfg.BaseURL)
+	heavyOps := handler.NewHeavyOpLimiter(cfg.MaxConcurrentHeavyOps, time.Duration(cfg.HeavyOpsQueueTimeout)*time.Second)
+	fileHandler := handler.NewFileHandler(fileSvc, handler.UploadPolicy{
+		DefaultMaxFileSize: cfg.MaxFileSize,
+		MaxFileSizeByRole: map[user.Role]int64{
+			user.RoleAdmin: cfg.MaxFileSizeAdmin,
+			user.RoleUser:  cfg.MaxFileSizeUser,
+		},
+		MemoryLimit: cfg.MultipartMemoryLimit,
+	}, fileService.NewDownloadSigner(cfg.DownloadSigningSecret), cfg.PublicURL(), time.Duration(cfg.SignedURLMaxTTL)*time.Second, heavyOps, fileEvents, thumbnails, repository.NewDownloadHistoryRepository(db))
+	captchaVerifier, err := captcha.New(cfg.CaptchaProvider, cfg.CaptchaSecret, cfg.CaptchaVerifyURL, 10*time.Second)
+	if err != nil {
+		log.Fatalf("Invalid CAPTCHA settings: %v (CAPTCHA_PROVIDER must be hcaptcha or recaptcha, with CAPTCHA_SECRET set)", err)
+	}
+	authHandler := handler.NewAuthHandler(authSvc, handler.NewSessionCookieConfig(cfg), captchaVerifier)
+	if !share.ShareType(cfg.DefaultShareType).Valid() {
+		log.Fatalf("Invalid DEFAULT_SHARE_TYPE %q (expected public, password or authenticated)", cfg.DefaultShareType)
+	}
+	if !share.Permission(cfg.DefaultSharePermission).Valid() {
+		log.Fatalf("Invalid DEFAULT_SHARE_PERMISSION %q (expected view or download)", cfg.DefaultSharePermission)
+	}
+	var shareNotifier share.AccessNotifier
+	if cfg.ShareAccessWebhook != "" {
+		shareNotifier = notify.NewWebhookNotifier(cfg.ShareAccessWebhook, cfg.ShareWebhookSecret, 10*time.Second)
+	}
+	shareHandler := handler.NewShareHandler(shareRepo, userRepo, fileSvc, fileIndex, cfg, shareNotifier, heavyOps, thumbnails)
 	oauthHandler := handler.NewOAuthHandler(cfg, authSvc, userRepo)
-	userHandler := handler.NewUserHandler(authSvc, userRepo, cfg.StoragePath)
+	userHandler := handler.NewUserHandler(authSvc, userRepo, shareRepo, fileSvc, cfg.StoragePath, cfg.AvatarMaxDimension, cfg.PublicURL(), heavyOps)
+	userHandler.StartAvatarSweeper()
 	googleServicesHandler := handler.NewGoogleServicesHandler(cfg, userRepo)
 	googleAdsHandler := handler.NewGoogleAdsHandler(cfg, userRepo)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	middleware.StartIdempotencySweeper(idempotencyRepo, time.Duration(cfg.IdempotencyKeyTTL)*time.Hour)
 
 	// Setup routes
 	handlers := router.Handlers{
@@ -59,16 +181,31 @@ func main() {
 		User:           userHandler,
 		GoogleServices: googleServicesHandler,
 		GoogleAds:      googleAdsHandler,
+		Database:       handler.NewDatabaseHandler(db, heavyOps),
+		Idempotency:    idempotencyRepo,
 	}
 	mux := router.SetupWithConfig(handlers, authSvc, cfg)
 
+	trustedProxies, err := middleware.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	adminNetworks, err := middleware.ParseNetworks(cfg.AdminIPAllowlist)
+	if err != nil {
+		log.Fatal("Invalid ADMIN_IP_ALLOWLIST:", err)
+	}
+
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.Port)
 	fmt.Println("=================================")
 	fmt.Println("       GoManager Server")
 	fmt.Println("=================================")
-	fmt.Printf("Server:    http://localhost%s\n", addr)
-	fmt.Printf("Storage:   %s\n", cfg.StoragePath)
+	fmt.Printf("Server:    http://localhost%s%s\n", addr, cfg.APIBasePath)
+	if cfg.StorageBackend == "s3" {
+		fmt.Printf("Storage:   s3://%s/%s\n", cfg.S3Bucket, cfg.S3Prefix)
+	} else {
+		fmt.Printf("Storage:   %s\n", cfg.StoragePath)
+	}
 	fmt.Printf("Database:  %s\n", cfg.DatabasePath)
 	if cfg.GoogleClientID != "" {
 		fmt.Println("Google:    Enabled")
@@ -80,5 +217,26 @@ func main() {
 		fmt.Printf("Drive Folder: %s\n", cfg.GoogleDriveFolder)
 	}
 	fmt.Println("=================================")
-	log.Fatal(http.ListenAndServe(addr, mux))
+
+	// The base path is stripped before the client IP is resolved, and the
+	// admin network check needs both
+	serverHandler := middleware.RestrictPrefix("/api/admin/", adminNetworks)(mux.ServeHTTP)
+	serverHandler = middleware.RealIP(trustedProxies)(serverHandler)
+	serverHandler = middleware.StripBasePath(cfg.APIBasePath)(serverHandler)
+
+	log.Fatal(newServer(cfg, addr, serverHandler).ListenAndServe())
+}
+
+// newServer returns the HTTP server with the configured timeouts. They guard
+// against slow clients; upload and download routes clear their own deadlines
+// so large transfers aren't cut off.
+func newServer(cfg *config.Config, addr string, handler http.HandlerFunc) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeout) * time.Second,
+	}
 }
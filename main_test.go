@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gomanager/internal/infrastructure/config"
+)
+
+func TestServerDropsStalledHeaders(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(&config.Config{ReadHeaderTimeout: 1}, "", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		request string
+		wantOK  bool
+	}{
+		{"complete request", "GET / HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n", true},
+		{"stalled headers", "GET / HTTP/1.1\r\nHost: test\r\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if _, err := io.WriteString(conn, tt.request); err != nil {
+				t.Fatal(err)
+			}
+
+			// The server must answer or hang up well before this
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			start := time.Now()
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			if tt.wantOK {
+				if err != nil || resp.StatusCode != http.StatusOK {
+					t.Fatalf("got %v, %v; want 200", resp, err)
+				}
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatalf("connection still open after %v", time.Since(start))
+			}
+			if err == nil && !strings.HasPrefix(resp.Status, "408") {
+				t.Fatalf("got %s, want the connection dropped", resp.Status)
+			}
+		})
+	}
+}